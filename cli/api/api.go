@@ -37,6 +37,7 @@ type Options struct {
 	Master               bool
 	DockerDNS            []string
 	Agent                bool
+	StorageStandby       bool // replicate the DFS from the storage leader and take over if it fails
 	MuxPort              int
 	TLS                  bool
 	KeyPEMFile           string
@@ -67,6 +68,16 @@ type Options struct {
 	LogstashMaxDays      int    // Days to keep logstash indices
 	DebugPort            int    // Port to listen for profile clients
 	AdminGroup           string // user group that can log in to control center
+	BackupPassphraseFile string // file containing the passphrase to encrypt backups
+	BackupPublicKey      string // RSA public key to encrypt backups
+	BackupPrivateKey     string // RSA private key to decrypt backups
+	VolumeSoftLimit      string // size of a tenant volume after which warnings are logged
+	VolumeHardLimit      string // size of a tenant volume after which snapshots are refused
+	StorageSSHKey        string // ssh key used to sync the DFS to agents of rsync pools
+	StorageKnownHosts    string // known hosts file of the agents of rsync pools, maintained by the operator
+	ImageGCInterval      int    // hours between removals of unused images, 0 to disable
+	SquashLayerLimit     int    // number of layers at which a committed image is squashed, 0 to disable
+	StrictImages         bool   // refuse to start containers whose image differs from the pinned one
 }
 
 // LoadOptions overwrites the existing server options
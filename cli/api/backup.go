@@ -16,8 +16,20 @@ package api
 import (
 	"fmt"
 	"path/filepath"
+
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/domain/service"
 )
 
+// RestoreTenantConfig is the configuration object to restore a single tenant
+type RestoreTenantConfig struct {
+	Path         string
+	TenantID     string
+	NewTenantID  string
+	PoolID       string
+	DeploymentID string
+}
+
 // Dump all templates and services to a tgz file.
 // This includes a snapshot of all shared file systems
 // and exports all docker images the services depend on.
@@ -50,3 +62,63 @@ func (a *api) Restore(path string) error {
 
 	return client.Restore(filepath.Clean(fp), &unusedInt)
 }
+
+// Restores a single tenant from a tgz file into a running system, optionally
+// as a new tenant or into a different pool.
+func (a *api) RestoreTenant(config RestoreTenantConfig) (*service.Service, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := filepath.Abs(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert '%s' to an absolute file path: %v", config.Path, err)
+	}
+
+	req := dao.RestoreTenantRequest{
+		Filename:     filepath.Clean(fp),
+		TenantID:     config.TenantID,
+		NewTenantID:  config.NewTenantID,
+		PoolID:       config.PoolID,
+		DeploymentID: config.DeploymentID,
+	}
+
+	if err := client.RestoreTenant(req, &unusedInt); err != nil {
+		return nil, err
+	}
+
+	tenantID := config.TenantID
+	if config.NewTenantID != "" {
+		tenantID = config.NewTenantID
+	}
+
+	s, err := a.GetService(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// address assignments are dropped when moving a tenant
+	if config.NewTenantID != "" || config.PoolID != "" {
+		if err := a.AssignIP(IPConfig{tenantID, ""}); err != nil {
+			return s, err
+		}
+	}
+
+	return s, nil
+}
+
+// Verifies the integrity of a backup file without restoring it.
+func (a *api) VerifyBackup(path string) error {
+	client, err := a.connectDAO()
+	if err != nil {
+		return err
+	}
+
+	fp, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("could not convert '%s' to an absolute file path: %v", path, err)
+	}
+
+	return client.VerifyBackup(filepath.Clean(fp), &unusedInt)
+}
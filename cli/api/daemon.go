@@ -21,7 +21,9 @@ import (
 	"github.com/control-center/serviced/dao/elasticsearch"
 	"github.com/control-center/serviced/datastore"
 	"github.com/control-center/serviced/datastore/elastic"
+	"github.com/control-center/serviced/dfs"
 	"github.com/control-center/serviced/dfs/nfs"
+	"github.com/control-center/serviced/dfs/rsync"
 	"github.com/control-center/serviced/domain/addressassignment"
 	"github.com/control-center/serviced/domain/host"
 	"github.com/control-center/serviced/domain/pool"
@@ -44,10 +46,13 @@ import (
 	"github.com/zenoss/glog"
 	// Need to do btrfs driver initializations
 	_ "github.com/control-center/serviced/volume/btrfs"
+	// Need to do lvm driver initializations
+	_ "github.com/control-center/serviced/volume/lvm"
 	// Need to do rsync driver initializations
 	_ "github.com/control-center/serviced/volume/rsync"
 	"github.com/control-center/serviced/web"
 	"github.com/control-center/serviced/zzk"
+	"github.com/dotcloud/docker/pkg/units"
 
 	"crypto/tls"
 	"encoding/json"
@@ -74,6 +79,10 @@ import (
 
 var minDockerVersion = version{0, 11, 1}
 
+// volumeCheckInterval is how often the master checks the size of the tenant
+// volumes against their limits
+const volumeCheckInterval = 5 * time.Minute
+
 type daemon struct {
 	servicedEndpoint string
 	staticIPs        []string
@@ -183,6 +192,11 @@ func (d *daemon) run() error {
 			glog.Fatalf("%v", err)
 		}
 	}
+	if options.StorageStandby {
+		if err = d.startStorageStandby(); err != nil {
+			glog.Fatalf("%v", err)
+		}
+	}
 
 	d.rpcServer.HandleHTTP(rpc.DefaultRPCPath, rpc.DefaultDebugPath)
 
@@ -287,6 +301,8 @@ func (d *daemon) startMaster() error {
 	d.initWeb()
 	d.startScheduler()
 	d.addTemplates()
+	d.startVolumeMonitor()
+	d.startImageGC()
 
 	agentIP := options.OutboundIP
 	if agentIP == "" {
@@ -309,10 +325,16 @@ func (d *daemon) startMaster() error {
 		return err
 	}
 
-	if nfsDriver, err := nfs.NewServer(options.VarPath, "serviced_var", "0.0.0.0/0"); err != nil {
+	transports, err := storageTransports()
+	if err != nil {
+		return err
+	}
+	// the master copies the volumes of a standby that took the lead before
+	// leading again
+	if nfsDriver, err := nfs.NewReplicaServer(options.VarPath, "serviced_var", "0.0.0.0/0"); err != nil {
 		return err
 	} else {
-		d.storageHandler, err = storage.NewServer(nfsDriver, thisHost, d.zClient)
+		d.storageHandler, err = storage.NewServer(nfsDriver, thisHost, d.zClient, transports)
 		if err != nil {
 			return err
 		}
@@ -328,6 +350,72 @@ func (d *daemon) startMaster() error {
 	return nil
 }
 
+// startStorageStandby replicates the DFS from the storage leader and takes over
+// exporting it to the agents if the leader fails
+func (d *daemon) startStorageStandby() error {
+	if options.Master || options.Agent {
+		return fmt.Errorf("storage standby cannot run on a master or an agent")
+	}
+
+	zClient, err := d.initZK(options.Zookeepers)
+	if err != nil {
+		glog.Errorf("failed to create a zookeeper client: %v", err)
+		return err
+	}
+	zzk.InitializeLocalClient(zClient)
+
+	standbyIP := options.OutboundIP
+	if standbyIP == "" {
+		standbyIP, err = utils.GetIPAddress()
+		if err != nil {
+			glog.Fatalf("Failed to acquire ip address: %s", err)
+		}
+	}
+
+	thisHost, err := host.Build(standbyIP, "unknown")
+	if err != nil {
+		glog.Errorf("could not build host for standby IP %s: %v", standbyIP, err)
+		return err
+	}
+
+	if err := os.MkdirAll(options.VarPath, 0755); err != nil {
+		glog.Errorf("could not create varpath %s: %s", options.VarPath, err)
+		return err
+	}
+
+	nfsDriver, err := nfs.NewReplicaServer(options.VarPath, "serviced_var", "0.0.0.0/0")
+	if err != nil {
+		return err
+	}
+	transports, err := storageTransports()
+	if err != nil {
+		return err
+	}
+	d.storageHandler, err = storage.NewServer(nfsDriver, thisHost, zClient, transports)
+	if err != nil {
+		return err
+	}
+	d.waitGroup.Add(1)
+	go func() {
+		defer d.waitGroup.Done()
+		<-d.shutdown
+		glog.Infof("Shutting down storage standby")
+		d.storageHandler.Close()
+	}()
+
+	return nil
+}
+
+// storageTransports returns the drivers that sync the tenant volumes to the
+// agents of pools that don't mount the nfs export
+func storageTransports() (map[string]storage.StorageDriver, error) {
+	rsyncDriver, err := rsync.NewServer(filepath.Join(options.VarPath, "volumes"), options.StorageSSHKey, options.StorageKnownHosts)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]storage.StorageDriver{pool.StorageRsync: rsyncDriver}, nil
+}
+
 func getKeyPairs(certPEMFile, keyPEMFile string) (certPEM, keyPEM []byte, err error) {
 	if len(certPEMFile) > 0 {
 		certPEM, err = ioutil.ReadFile(certPEMFile)
@@ -400,7 +488,7 @@ func (d *daemon) startAgent() error {
 	}
 
 	go func() {
-		var poolID string
+		var poolID, storageTransport string
 		for {
 			glog.Infof("Trying to discover my pool...")
 			var myHost *host.Host
@@ -424,6 +512,11 @@ func (d *daemon) startAgent() error {
 			}
 			poolID = myHost.PoolID
 			glog.Infof(" My PoolID: %v", poolID)
+			if myPool, err := masterClient.GetResourcePool(poolID); err != nil {
+				glog.Warningf("Could not get pool %s, using the default storage transport: %v", poolID, err)
+			} else if myPool != nil {
+				storageTransport = myPool.StorageTransport
+			}
 			//send updated host info
 			updatedHost, err := host.UpdateHostInfo(*myHost)
 			if err != nil {
@@ -455,7 +548,7 @@ func (d *daemon) startAgent() error {
 			glog.Errorf("Error in getting a connection based on pool %v: %v", poolID, err)
 		}
 
-		nfsClient, err := storage.NewClient(thisHost, options.VarPath)
+		nfsClient, err := storage.NewClient(thisHost, options.VarPath, storageTransport)
 		if err != nil {
 			glog.Fatalf("could not create an NFS client: %s", err)
 		}
@@ -498,6 +591,9 @@ func (d *daemon) startAgent() error {
 			Mux:                  mux,
 			DockerRegistry:       options.DockerRegistry,
 			MaxContainerAge:      time.Duration(int(time.Second) * options.MaxContainerAge),
+			ImageGCInterval:      time.Duration(int(time.Hour) * options.ImageGCInterval),
+			StrictImages:         options.StrictImages,
+			ReadOnlyVolumes:      storageTransport == pool.StorageRsync,
 			VirtualAddressSubnet: options.VirtualAddressSubnet,
 		}
 		// creates a zClient that is not pool based!
@@ -577,6 +673,7 @@ func (d *daemon) initDriver() (datastore.Driver, error) {
 	eDriver.AddMapping(service.MAPPING)
 	eDriver.AddMapping(addressassignment.MAPPING)
 	eDriver.AddMapping(serviceconfigfile.MAPPING)
+	eDriver.AddMapping(serviceconfigfile.VERSION_MAPPING)
 	eDriver.AddMapping(user.MAPPING)
 	err := eDriver.Initialize(10 * time.Second)
 	if err != nil {
@@ -630,7 +727,110 @@ func (d *daemon) initISVCS() error {
 
 func (d *daemon) initDAO() (dao.ControlPlane, error) {
 	dfsTimeout := time.Duration(options.MaxDFSTimeout) * time.Second
-	return elasticsearch.NewControlSvc("localhost", 9200, d.facade, options.VarPath, options.VFS, dfsTimeout, options.DockerRegistry)
+	backupKeys := dfs.BackupKeys{
+		PassphraseFile: options.BackupPassphraseFile,
+		PublicKeyFile:  options.BackupPublicKey,
+		PrivateKeyFile: options.BackupPrivateKey,
+	}
+	volumeQuota, err := parseVolumeQuota(options.VolumeSoftLimit, options.VolumeHardLimit)
+	if err != nil {
+		return nil, err
+	}
+	return elasticsearch.NewControlSvc("localhost", 9200, d.facade, options.VarPath, options.VFS, dfsTimeout, options.DockerRegistry, backupKeys, volumeQuota, options.SquashLayerLimit)
+}
+
+// parseVolumeQuota converts the volume limits (e.g. 100G) to bytes
+func parseVolumeQuota(softLimit, hardLimit string) (quota dfs.VolumeQuota, err error) {
+	parse := func(name, size string) (uint64, error) {
+		if size == "" {
+			return 0, nil
+		}
+		bytes, err := units.RAMInBytes(size)
+		if err != nil || bytes < 0 {
+			return 0, fmt.Errorf("invalid volume %s limit %q", name, size)
+		}
+		return uint64(bytes), nil
+	}
+
+	if quota.SoftLimit, err = parse("soft", softLimit); err != nil {
+		return
+	} else if quota.HardLimit, err = parse("hard", hardLimit); err != nil {
+		return
+	} else if quota.HardLimit > 0 && quota.SoftLimit > quota.HardLimit {
+		err = fmt.Errorf("volume soft limit %s is greater than the hard limit %s", softLimit, hardLimit)
+	}
+	return
+}
+
+// startVolumeMonitor logs a warning whenever a tenant volume crosses its soft
+// or hard limit.  It runs without daemon limits too, since tenants may set
+// their own.
+func (d *daemon) startVolumeMonitor() {
+	d.waitGroup.Add(1)
+	go func() {
+		defer d.waitGroup.Done()
+		// 0: under the limits, 1: over the soft limit, 2: over the hard limit
+		levels := make(map[string]int)
+		for {
+			var status []dao.VolumeStatus
+			if err := d.cpDao.GetVolumeStatus(0, &status); err != nil {
+				glog.Warningf("Could not check the size of the tenant volumes: %s", err)
+			}
+			for _, s := range status {
+				level := 0
+				if s.OverHardLimit() {
+					level = 2
+				} else if s.OverSoftLimit() {
+					level = 1
+				}
+
+				switch {
+				case level == levels[s.TenantID]:
+				case level == 2:
+					glog.Warningf("Volume for %s (%s) is using %d bytes, which is over its hard limit of %d bytes; snapshots are disabled", s.Name, s.TenantID, s.UsedBytes, s.HardLimit)
+				case level == 1:
+					glog.Warningf("Volume for %s (%s) is using %d bytes, which is over its soft limit of %d bytes", s.Name, s.TenantID, s.UsedBytes, s.SoftLimit)
+				default:
+					glog.Infof("Volume for %s (%s) is back under its limits at %d bytes", s.Name, s.TenantID, s.UsedBytes)
+				}
+				levels[s.TenantID] = level
+			}
+
+			select {
+			case <-d.shutdown:
+				return
+			case <-time.After(volumeCheckInterval):
+			}
+		}
+	}()
+}
+
+// startImageGC periodically removes the tenant images that are not used by a
+// service or a snapshot
+func (d *daemon) startImageGC() {
+	if options.ImageGCInterval <= 0 {
+		return
+	}
+	interval := time.Duration(options.ImageGCInterval) * time.Hour
+
+	d.waitGroup.Add(1)
+	go func() {
+		defer d.waitGroup.Done()
+		for {
+			select {
+			case <-d.shutdown:
+				return
+			case <-time.After(interval):
+			}
+
+			var removed []string
+			if err := d.cpDao.CollectImages(false, &removed); err != nil {
+				glog.Warningf("Could not remove unused images: %s", err)
+			} else if len(removed) > 0 {
+				glog.Infof("Removed %d unused images", len(removed))
+			}
+		}
+	}()
 }
 
 func (d *daemon) initWeb() {
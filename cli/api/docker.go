@@ -34,6 +34,22 @@ func (a *api) Squash(imageName, downToLayer, newName, tempDir string) (resultIma
 	return layer.Squash(client, imageName, downToLayer, newName, tempDir)
 }
 
+// CollectImages removes the tenant images that are not used by a service or a
+// snapshot, and returns the images that were removed
+func (a *api) CollectImages(dryRun bool) ([]string, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	if err := client.CollectImages(dryRun, &images); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
 // RegistrySync walks the service tree and syncs all images from docker to local registry
 func (a *api) RegistrySync() (err error) {
 	client, err := a.connectDocker()
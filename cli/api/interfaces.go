@@ -20,8 +20,10 @@ import (
 	"github.com/control-center/serviced/domain/host"
 	"github.com/control-center/serviced/domain/pool"
 	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/serviceconfigfile"
 	"github.com/control-center/serviced/domain/servicestate"
 	template "github.com/control-center/serviced/domain/servicetemplate"
+	"github.com/control-center/serviced/domain/servicetemplate/repository"
 	"github.com/control-center/serviced/facade"
 )
 
@@ -43,6 +45,7 @@ type API interface {
 	GetResourcePool(string) (*pool.ResourcePool, error)
 	AddResourcePool(PoolConfig) (*pool.ResourcePool, error)
 	RemoveResourcePool(string) error
+	SetStorageTransport(string, string) (*pool.ResourcePool, error)
 	GetPoolIPs(string) (*facade.PoolIPs, error)
 	AddVirtualIP(pool.VirtualIP) error
 	RemoveVirtualIP(pool.VirtualIP) error
@@ -59,6 +62,10 @@ type API interface {
 	StartService(string) error
 	RestartService(string) error
 	StopService(string) error
+	ResolveConfigConflict(ResolveConfigConfig) (*service.Service, error)
+	GetServiceConfigVersions(string, string) ([]serviceconfigfile.ConfigVersion, error)
+	RevertServiceConfig(string, string, int) error
+	ApplyServices(ApplyConfig) (*dao.ServiceApplyPlan, error)
 	AssignIP(IPConfig) error
 
 	// RunningServices (ServiceStates)
@@ -77,6 +84,14 @@ type API interface {
 	RemoveSnapshot(string) error
 	Commit(string) (string, error)
 	Rollback(string) error
+	DiffSnapshots(string, string) (*dao.SnapshotDiff, error)
+	CloneSnapshot(CloneSnapshotConfig) (*service.Service, error)
+	ExportSnapshot(string, string) (string, error)
+	ImportSnapshot(string) (*service.Service, error)
+
+	// Volumes
+	GetVolumeStatus() ([]dao.VolumeStatus, error)
+	SetVolumeLimits(tenantID, softLimit, hardLimit string) (*service.Service, error)
 
 	// Templates
 	GetServiceTemplates() ([]template.ServiceTemplate, error)
@@ -84,14 +99,25 @@ type API interface {
 	AddServiceTemplate(io.Reader) (*template.ServiceTemplate, error)
 	RemoveServiceTemplate(string) error
 	CompileServiceTemplate(CompileTemplateConfig) (*template.ServiceTemplate, error)
+	ExportServiceTemplate(ExportTemplateConfig) (*template.ServiceTemplate, error)
+	LintServiceTemplate(LintTemplateConfig) ([]template.LintProblem, error)
 	DeployServiceTemplate(DeployTemplateConfig) (*service.Service, error)
+	UpgradeServiceTemplate(UpgradeTemplateConfig) (*dao.ServiceTemplateUpgradePlan, error)
+	GetTemplateSources() ([]repository.Source, error)
+	AddTemplateSource(repository.Source) error
+	RemoveTemplateSource(string) error
+	SearchServiceTemplates(string) ([]TemplateEntry, error)
+	InstallServiceTemplate(string, string) (*template.ServiceTemplate, error)
 
 	// Backup & Restore
 	Backup(string) (string, error)
 	Restore(string) error
+	RestoreTenant(RestoreTenantConfig) (*service.Service, error)
+	VerifyBackup(string) error
 
 	// Docker
 	Squash(imageName, downToLayer, newName, tempDir string) (string, error)
+	CollectImages(dryRun bool) ([]string, error)
 	RegistrySync() error
 
 	// Logs
@@ -14,6 +14,8 @@
 package api
 
 import (
+	"fmt"
+
 	"github.com/control-center/serviced/domain/pool"
 	"github.com/control-center/serviced/facade"
 )
@@ -24,11 +26,12 @@ var ()
 
 // PoolConfig is the deserialized data from the command-line
 type PoolConfig struct {
-	PoolID      string
-	Realm       string
-	CoreLimit   int
-	MemoryLimit uint64
-	Priority    int
+	PoolID           string
+	Realm            string
+	CoreLimit        int
+	MemoryLimit      uint64
+	Priority         int
+	StorageTransport string
 }
 
 // Returns a list of all pools
@@ -59,11 +62,12 @@ func (a *api) AddResourcePool(config PoolConfig) (*pool.ResourcePool, error) {
 	}
 
 	p := pool.ResourcePool{
-		ID:          config.PoolID,
-		Realm:       config.Realm,
-		CoreLimit:   config.CoreLimit,
-		MemoryLimit: config.MemoryLimit,
-		Priority:    config.Priority,
+		ID:               config.PoolID,
+		Realm:            config.Realm,
+		CoreLimit:        config.CoreLimit,
+		MemoryLimit:      config.MemoryLimit,
+		Priority:         config.Priority,
+		StorageTransport: config.StorageTransport,
 	}
 
 	if err := client.AddResourcePool(p); err != nil {
@@ -73,6 +77,29 @@ func (a *api) AddResourcePool(config PoolConfig) (*pool.ResourcePool, error) {
 	return a.GetResourcePool(p.ID)
 }
 
+// Changes how hosts in a pool reach the DFS.  Agents pick up the new
+// transport when they restart.
+func (a *api) SetStorageTransport(id, transport string) (*pool.ResourcePool, error) {
+	client, err := a.connectMaster()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := client.GetResourcePool(id)
+	if err != nil {
+		return nil, err
+	} else if p == nil {
+		return nil, fmt.Errorf("pool not found")
+	}
+
+	p.StorageTransport = transport
+	if err := client.UpdateResourcePool(*p); err != nil {
+		return nil, err
+	}
+
+	return a.GetResourcePool(id)
+}
+
 // Removes an existing pool
 func (a *api) RemoveResourcePool(id string) error {
 	client, err := a.connectMaster()
@@ -17,11 +17,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 
 	"github.com/control-center/serviced/commons"
 	"github.com/control-center/serviced/dao"
 	"github.com/control-center/serviced/domain"
 	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/serviceconfigfile"
 	"github.com/control-center/serviced/domain/servicedefinition"
 	"github.com/control-center/serviced/domain/servicestate"
 )
@@ -38,6 +40,7 @@ type ServiceConfig struct {
 	Command         string
 	LocalPorts      *PortMap
 	RemotePorts     *PortMap
+	Definition      string // service definition file, in JSON or YAML, used instead of the image and command
 }
 
 // IPConfig is the deserialized object from the command-line
@@ -46,6 +49,23 @@ type IPConfig struct {
 	IPAddress string
 }
 
+// ResolveConfigConfig is the deserialized object from the command-line
+type ResolveConfigConfig struct {
+	ServiceID   string
+	Filename    string
+	UseTemplate bool
+	Content     io.Reader
+}
+
+// ApplyConfig is the deserialized object from the command-line
+type ApplyConfig struct {
+	Definition string // service definition file, in JSON or YAML
+	TenantID   string // found by the name of the definition if empty
+	Prune      bool
+	Overwrite  bool // the instances, context values and config files of the file win
+	DryRun     bool
+}
+
 // RunningService contains the service for a state
 type RunningService struct {
 	Service *service.Service
@@ -129,7 +149,8 @@ func (a *api) GetServicesByName(name string) ([]service.Service, error) {
 	return services, nil
 }
 
-// Adds a new service
+// Adds a new service, or a service and its children from a service definition
+// file
 func (a *api) AddService(config ServiceConfig) (*service.Service, error) {
 	client, err := a.connectDAO()
 	if err != nil {
@@ -149,13 +170,24 @@ func (a *api) AddService(config ServiceConfig) (*service.Service, error) {
 		i++
 	}
 
-	sd := &servicedefinition.ServiceDefinition{
-		Name:      config.Name,
-		Command:   config.Command,
-		Instances: domain.MinMax{Min: 1, Max: 1, Default: 1},
-		ImageID:   config.ImageID,
-		Launch:    commons.AUTO,
-		Endpoints: endpoints,
+	var sd *servicedefinition.ServiceDefinition
+	if config.Definition != "" {
+		if sd, err = servicedefinition.ReadFile(config.Definition); err != nil {
+			return nil, err
+		}
+		if config.Name != "" {
+			sd.Name = config.Name
+		}
+		sd.Endpoints = append(sd.Endpoints, endpoints...)
+	} else {
+		sd = &servicedefinition.ServiceDefinition{
+			Name:      config.Name,
+			Command:   config.Command,
+			Instances: domain.MinMax{Min: 1, Max: 1, Default: 1},
+			ImageID:   config.ImageID,
+			Launch:    commons.AUTO,
+			Endpoints: endpoints,
+		}
 	}
 
 	var serviceID string
@@ -189,6 +221,7 @@ func (a *api) UpdateService(reader io.Reader) (*service.Service, error) {
 	if err := json.NewDecoder(reader).Decode(&s); err != nil {
 		return nil, fmt.Errorf("could not unmarshal json: %s", err)
 	}
+	s.UpdatedBy = currentUser()
 
 	// Connect to the client
 	client, err := a.connectDAO()
@@ -265,3 +298,130 @@ func (a *api) AssignIP(config IPConfig) error {
 
 	return nil
 }
+
+// ResolveConfigConflict resolves the conflict of a customized config file with
+// its template, by keeping the customized file, taking the template's file or
+// replacing the file's content.
+func (a *api) ResolveConfigConflict(config ResolveConfigConfig) (*service.Service, error) {
+	svc, err := a.GetService(config.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	conflict, ok := svc.ConfigConflicts[config.Filename]
+	if !ok {
+		return nil, fmt.Errorf("no config conflict found for %s", config.Filename)
+	}
+
+	conf, ok := svc.ConfigFiles[config.Filename]
+	if !ok {
+		conf = conflict.Template
+	}
+	if config.UseTemplate {
+		conf = conflict.Template
+	} else if config.Content != nil {
+		data, err := ioutil.ReadAll(config.Content)
+		if err != nil {
+			return nil, fmt.Errorf("could not read config file: %s", err)
+		}
+		conf.Content = string(data)
+	}
+	if svc.ConfigFiles == nil {
+		svc.ConfigFiles = make(map[string]servicedefinition.ConfigFile)
+	}
+	svc.ConfigFiles[config.Filename] = conf
+	delete(svc.ConfigConflicts, config.Filename)
+	svc.UpdatedBy = currentUser()
+
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.UpdateService(*svc, &unusedInt); err != nil {
+		return nil, err
+	}
+
+	return a.GetService(svc.ID)
+}
+
+// GetServiceConfigVersions returns the versions of a service's config file,
+// oldest first
+func (a *api) GetServiceConfigVersions(serviceID, filename string) ([]serviceconfigfile.ConfigVersion, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	request := dao.ServiceConfigRequest{ServiceID: serviceID, Filename: filename}
+	var versions []serviceconfigfile.ConfigVersion
+	if err := client.GetServiceConfigVersions(request, &versions); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// RevertServiceConfig reverts a service's config file to one of its versions
+func (a *api) RevertServiceConfig(serviceID, filename string, version int) error {
+	client, err := a.connectDAO()
+	if err != nil {
+		return err
+	}
+
+	request := dao.ServiceConfigRequest{
+		ServiceID: serviceID,
+		Filename:  filename,
+		Version:   version,
+		Author:    currentUser(),
+	}
+	return client.RevertServiceConfig(request, &unusedInt)
+}
+
+// ApplyServices makes the services of a tenant match a service definition file
+// and returns the changes that were made, or would be made if DryRun is set,
+// and the snapshot taken before they were made
+func (a *api) ApplyServices(config ApplyConfig) (*dao.ServiceApplyPlan, error) {
+	sd, err := servicedefinition.ReadFile(config.Definition)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantID := config.TenantID
+	if tenantID == "" {
+		svcs, err := a.GetServicesByName(sd.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, svc := range svcs {
+			if svc.ParentServiceID != "" {
+				continue
+			} else if tenantID != "" {
+				return nil, fmt.Errorf("more than one tenant is named %s, specify the tenant ID", sd.Name)
+			}
+			tenantID = svc.ID
+		}
+		if tenantID == "" {
+			return nil, fmt.Errorf("no tenant is named %s", sd.Name)
+		}
+	}
+
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	request := dao.ServiceApplyRequest{
+		TenantID:   tenantID,
+		Definition: *sd,
+		Prune:      config.Prune,
+		Overwrite:  config.Overwrite,
+		DryRun:     config.DryRun,
+		Author:     currentUser(),
+	}
+	var plan dao.ServiceApplyPlan
+	if err := client.ApplyServices(request, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
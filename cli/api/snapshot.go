@@ -15,12 +15,24 @@ package api
 
 import (
 	"fmt"
+	"path/filepath"
+
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/domain/service"
 )
 
 const ()
 
 var ()
 
+// CloneSnapshotConfig is the configuration object to clone a tenant from a
+// snapshot
+type CloneSnapshotConfig struct {
+	SnapshotID   string
+	PoolID       string
+	DeploymentID string
+}
+
 // Lists all snapshots on the DFS
 func (a *api) GetSnapshots() ([]string, error) {
 	services, err := a.GetServices()
@@ -114,3 +126,91 @@ func (a *api) Rollback(snapshotID string) error {
 
 	return nil
 }
+
+// DiffSnapshots lists the changes between two snapshots of the same service
+func (a *api) DiffSnapshots(from, to string) (*dao.SnapshotDiff, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	var diff dao.SnapshotDiff
+	if err := client.DiffSnapshots(dao.SnapshotDiffRequest{From: from, To: to}, &diff); err != nil {
+		return nil, err
+	}
+
+	return &diff, nil
+}
+
+// Clones the tenant of a snapshot into a new deployment
+func (a *api) CloneSnapshot(config CloneSnapshotConfig) (*service.Service, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	req := dao.CloneSnapshotRequest{
+		SnapshotID:   config.SnapshotID,
+		PoolID:       config.PoolID,
+		DeploymentID: config.DeploymentID,
+	}
+
+	var tenantID string
+	if err := client.CloneSnapshot(req, &tenantID); err != nil {
+		return nil, err
+	}
+
+	s, err := a.GetService(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the clone needs its own address assignments
+	if err := a.AssignIP(IPConfig{tenantID, ""}); err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
+
+// Writes a snapshot of a tenant to a file that can be imported into another
+// cluster
+func (a *api) ExportSnapshot(snapshotID, path string) (string, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return "", err
+	}
+
+	fp, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("could not convert '%s' to an absolute file path: %v", path, err)
+	}
+
+	req := dao.ExportSnapshotRequest{SnapshotID: snapshotID, Filename: filepath.Clean(fp)}
+	var filename string
+	if err := client.ExportSnapshot(req, &filename); err != nil {
+		return "", err
+	}
+
+	return filename, nil
+}
+
+// Loads a tenant from a file written by ExportSnapshot
+func (a *api) ImportSnapshot(path string) (*service.Service, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert '%s' to an absolute file path: %v", path, err)
+	}
+
+	var tenantID string
+	if err := client.ImportSnapshot(filepath.Clean(fp), &tenantID); err != nil {
+		return nil, err
+	}
+
+	return a.GetService(tenantID)
+}
@@ -17,11 +17,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/control-center/serviced/commons/docker"
 	"github.com/control-center/serviced/dao"
 	"github.com/control-center/serviced/domain/service"
 	"github.com/control-center/serviced/domain/servicedefinition"
 	template "github.com/control-center/serviced/domain/servicetemplate"
+	"github.com/control-center/serviced/domain/servicetemplate/repository"
+	"github.com/zenoss/glog"
 )
 
 // DeployTemplateConfig is the configuration object to deploy a template
@@ -30,6 +36,15 @@ type DeployTemplateConfig struct {
 	PoolID          string
 	DeploymentID    string
 	ManualAssignIPs bool
+	Values          map[string]string // values of the template parameters
+}
+
+// UpgradeTemplateConfig is the configuration object to upgrade a deployment to
+// a template
+type UpgradeTemplateConfig struct {
+	ID           string
+	DeploymentID string
+	DryRun       bool
 }
 
 // CompileTemplateConfig is the configuration object to conpile a template directory
@@ -38,6 +53,35 @@ type CompileTemplateConfig struct {
 	Map ImageMap
 }
 
+// ExportTemplateConfig is the configuration object to export a tenant as a
+// template
+type ExportTemplateConfig struct {
+	TenantID string
+	Map      ImageMap
+}
+
+// LintTemplateConfig is the configuration object to lint a template
+type LintTemplateConfig struct {
+	Template   string // directory of service definitions or template ID
+	SkipImages bool   // don't check that the images exist
+}
+
+// TemplateEntry is a template in a template source
+type TemplateEntry struct {
+	repository.Entry
+	Status string // installed, drifted or empty if it was never installed
+}
+
+// templateSourcesPath is where the registered template sources are kept
+func templateSourcesPath() string {
+	return filepath.Join(GetVarPath(), "templates", "sources.json")
+}
+
+// templateCachePath is where git template sources are checked out
+func templateCachePath() string {
+	return filepath.Join(GetVarPath(), "templates", "cache")
+}
+
 // Gets all available service templates
 func (a *api) GetServiceTemplates() ([]template.ServiceTemplate, error) {
 	client, err := a.connectDAO()
@@ -125,19 +169,67 @@ func (a *api) CompileServiceTemplate(config CompileTemplateConfig) (*template.Se
 		return nil, err
 	}
 
-	var mapImageNames func(*servicedefinition.ServiceDefinition)
-	mapImageNames = func(svc *servicedefinition.ServiceDefinition) {
-		if imageID, found := config.Map[svc.ImageID]; found {
+	mapImageNames(st, config.Map)
+	return st, nil
+}
+
+// ExportServiceTemplate builds a template from the services of a tenant
+func (a *api) ExportServiceTemplate(config ExportTemplateConfig) (*template.ServiceTemplate, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	var st template.ServiceTemplate
+	if err := client.ExportTemplate(config.TenantID, &st); err != nil {
+		return nil, err
+	}
+	mapImageNames(&st, config.Map)
+	return &st, nil
+}
+
+// mapImageNames renames the images of the services of a template
+func mapImageNames(st *template.ServiceTemplate, imageMap ImageMap) {
+	var mapImageName func(*servicedefinition.ServiceDefinition)
+	mapImageName = func(svc *servicedefinition.ServiceDefinition) {
+		if imageID, found := imageMap[svc.ImageID]; found {
 			svc.ImageID = imageID
 		}
 		for i := range svc.Services {
-			mapImageNames(&svc.Services[i])
+			mapImageName(&svc.Services[i])
 		}
 	}
 	for idx := range st.Services {
-		mapImageNames(&st.Services[idx])
+		mapImageName(&st.Services[idx])
 	}
-	return st, nil
+}
+
+// LintServiceTemplate validates a directory of service definitions, or an
+// added template if there is no such directory, and returns its problems
+func (a *api) LintServiceTemplate(config LintTemplateConfig) ([]template.LintProblem, error) {
+	var st *template.ServiceTemplate
+	if fi, err := os.Stat(config.Template); err == nil && fi.IsDir() {
+		if st, err = template.BuildFromPath(config.Template); err != nil {
+			return nil, err
+		}
+	} else if st, err = a.GetServiceTemplate(config.Template); err != nil {
+		return nil, err
+	}
+
+	var imageExists template.ImageChecker
+	if !config.SkipImages {
+		imageExists = func(imageID string) (bool, error) {
+			if _, err := docker.FindImage(imageID, false); err == nil {
+				return true, nil
+			} else if err != docker.ErrNoSuchImage {
+				return false, err
+			}
+			// look the tag up in the image's registry, or the Docker Hub,
+			// instead of pulling it
+			return docker.RegistryTagExists(imageID)
+		}
+	}
+	return st.Lint(imageExists), nil
 }
 
 // DeployTemplate deploys a template given its template ID
@@ -151,6 +243,7 @@ func (a *api) DeployServiceTemplate(config DeployTemplateConfig) (*service.Servi
 		PoolID:       config.PoolID,
 		TemplateID:   config.ID,
 		DeploymentID: config.DeploymentID,
+		Values:       config.Values,
 	}
 
 	var id string
@@ -171,3 +264,159 @@ func (a *api) DeployServiceTemplate(config DeployTemplateConfig) (*service.Servi
 
 	return s, nil
 }
+
+// UpgradeServiceTemplate upgrades a deployment to a template and returns the
+// changes that were made, or would be made if DryRun is set
+func (a *api) UpgradeServiceTemplate(config UpgradeTemplateConfig) (*dao.ServiceTemplateUpgradePlan, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	req := dao.ServiceTemplateUpgradeRequest{
+		TemplateID:   config.ID,
+		DeploymentID: config.DeploymentID,
+		DryRun:       config.DryRun,
+	}
+
+	var plan dao.ServiceTemplateUpgradePlan
+	if err := client.UpgradeTemplate(req, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// GetTemplateSources returns the registered template sources
+func (a *api) GetTemplateSources() ([]repository.Source, error) {
+	config, err := repository.LoadConfig(templateSourcesPath())
+	if err != nil {
+		return nil, err
+	}
+	return config.Sources, nil
+}
+
+// AddTemplateSource registers a template source
+func (a *api) AddTemplateSource(source repository.Source) error {
+	config, err := repository.LoadConfig(templateSourcesPath())
+	if err != nil {
+		return err
+	}
+	if err := config.AddSource(source); err != nil {
+		return err
+	}
+	return config.Save(templateSourcesPath())
+}
+
+// RemoveTemplateSource unregisters a template source
+func (a *api) RemoveTemplateSource(name string) error {
+	config, err := repository.LoadConfig(templateSourcesPath())
+	if err != nil {
+		return err
+	}
+	if err := config.RemoveSource(name); err != nil {
+		return err
+	}
+	return config.Save(templateSourcesPath())
+}
+
+// SearchServiceTemplates returns the templates of every source whose name or
+// description contains the query
+func (a *api) SearchServiceTemplates(query string) ([]TemplateEntry, error) {
+	config, err := repository.LoadConfig(templateSourcesPath())
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	var entries []repository.Entry
+	for _, source := range config.Sources {
+		index, err := indexTemplateSource(source)
+		if err != nil {
+			glog.Warningf("Could not search template source %s: %s", source.Name, err)
+			continue
+		}
+		for _, entry := range index {
+			if strings.Contains(strings.ToLower(entry.Name), query) || strings.Contains(strings.ToLower(entry.Description), query) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	repository.Sort(entries)
+
+	result := make([]TemplateEntry, len(entries))
+	for i, entry := range entries {
+		result[i] = TemplateEntry{Entry: entry, Status: config.Status(entry)}
+	}
+	return result, nil
+}
+
+// InstallServiceTemplate fetches a NAME[@VERSION] template from a source,
+// validates it and adds it.  If sourceName is empty, the first source that
+// has the template is used.
+func (a *api) InstallServiceTemplate(ref, sourceName string) (*template.ServiceTemplate, error) {
+	config, err := repository.LoadConfig(templateSourcesPath())
+	if err != nil {
+		return nil, err
+	}
+
+	sources := config.Sources
+	if sourceName != "" {
+		source, ok := config.Source(sourceName)
+		if !ok {
+			return nil, fmt.Errorf("template source %s not found", sourceName)
+		}
+		sources = []repository.Source{*source}
+	}
+
+	name, version := repository.ParseRef(ref)
+	for _, source := range sources {
+		repo, err := repository.Open(source, templateCachePath())
+		if err != nil {
+			return nil, err
+		}
+		index, err := repo.Index()
+		if err != nil {
+			return nil, err
+		}
+		entry, err := repository.Find(index, name, version)
+		if err == repository.ErrTemplateNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		st, err := repo.Fetch(*entry)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := repository.Validate(*entry, st)
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := a.connectDAO()
+		if err != nil {
+			return nil, err
+		}
+		var id string
+		if err := client.AddServiceTemplate(*st, &id); err != nil {
+			return nil, err
+		}
+
+		config.Install(*entry, hash)
+		if err := config.Save(templateSourcesPath()); err != nil {
+			return nil, err
+		}
+		return a.GetServiceTemplate(id)
+	}
+	return nil, fmt.Errorf("template %s not found", ref)
+}
+
+// indexTemplateSource lists the templates of a source
+func indexTemplateSource(source repository.Source) ([]repository.Entry, error) {
+	repo, err := repository.Open(source, templateCachePath())
+	if err != nil {
+		return nil, err
+	}
+	return repo.Index()
+}
@@ -70,6 +70,17 @@ func GetVarPath() string {
 	return path.Join(os.TempDir(), "serviced")
 }
 
+// currentUser returns the name of the user running the command, or of the user
+// that ran it with sudo
+func currentUser() string {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		return name
+	} else if user, err := user.Current(); err == nil {
+		return user.Username
+	}
+	return ""
+}
+
 // GetESStartupTimeout returns the Elastic Search Startup Timeout
 func GetESStartupTimeout() int {
 	var timeout int
@@ -0,0 +1,72 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/domain/service"
+)
+
+// GetVolumeStatus returns the disk usage of every tenant volume
+func (a *api) GetVolumeStatus() ([]dao.VolumeStatus, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	var status []dao.VolumeStatus
+	if err := client.GetVolumeStatus(0, &status); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// SetVolumeLimits sets the limits of a tenant volume (e.g. 100G).  Empty
+// limits are left unchanged and limits of 0 use the daemon default.
+func (a *api) SetVolumeLimits(tenantID, softLimit, hardLimit string) (*service.Service, error) {
+	client, err := a.connectDAO()
+	if err != nil {
+		return nil, err
+	}
+
+	var svc service.Service
+	if err := client.GetService(tenantID, &svc); err != nil {
+		return nil, err
+	} else if svc.ParentServiceID != "" {
+		return nil, fmt.Errorf("service %s is not a tenant", tenantID)
+	}
+
+	quota, err := parseVolumeQuota(softLimit, hardLimit)
+	if err != nil {
+		return nil, err
+	}
+	if softLimit != "" {
+		svc.VolumeSoftLimit = quota.SoftLimit
+	}
+	if hardLimit != "" {
+		svc.VolumeHardLimit = quota.HardLimit
+	}
+	if svc.VolumeHardLimit > 0 && svc.VolumeSoftLimit > svc.VolumeHardLimit {
+		return nil, fmt.Errorf("volume soft limit %d is greater than the hard limit %d", svc.VolumeSoftLimit, svc.VolumeHardLimit)
+	}
+
+	svc.UpdatedBy = currentUser()
+	if err := client.UpdateService(svc, &unusedInt); err != nil {
+		return nil, err
+	}
+	return &svc, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/control-center/serviced/cli/api"
+)
+
+// Initializer for serviced apply
+func (c *ServicedCli) initApply() {
+	c.app.Commands = append(
+		c.app.Commands,
+		cli.Command{
+			Name:        "apply",
+			Usage:       "Makes the services of a tenant match a service definition file",
+			Description: "serviced apply --file FILE [TENANTID]",
+			Action:      c.cmdApply,
+			Flags: []cli.Flag{
+				cli.StringFlag{"file, f", "", "Service definition of the tenant and its child services, in JSON or YAML"},
+				cli.BoolFlag{"prune", "Remove the services that are not in the file"},
+				cli.BoolFlag{"overwrite", "Set the instances, context values and config files to the ones in the file"},
+				cli.BoolFlag{"dry-run", "Show the changes without making them"},
+				cli.BoolFlag{"yes, y", "Make the changes without asking for confirmation"},
+			},
+		},
+	)
+}
+
+// confirm asks the user to confirm the changes on the terminal.  Without a
+// terminal, changes are only made with --yes.
+var confirm = func(prompt string) bool {
+	if !isatty(os.Stdin) {
+		fmt.Fprintln(os.Stderr, "not a terminal, use --yes to make the changes")
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// serviced apply --file FILE [--prune] [--overwrite] [--dry-run] [--yes] [TENANTID]
+func (c *ServicedCli) cmdApply(ctx *cli.Context) {
+	args := ctx.Args()
+	cfg := api.ApplyConfig{
+		Definition: ctx.String("file"),
+		Prune:      ctx.Bool("prune"),
+		Overwrite:  ctx.Bool("overwrite"),
+		DryRun:     true,
+	}
+	if cfg.Definition == "" {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "apply")
+		return
+	}
+	if len(args) > 0 {
+		svc, err := c.searchForService(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		cfg.TenantID = svc.ID
+	}
+
+	// show the plan before making any changes
+	plan, err := c.driver.ApplyServices(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	} else if plan == nil || len(plan.Actions) == 0 {
+		fmt.Fprintln(os.Stderr, "services are up to date")
+		return
+	}
+
+	tablePlan := newtable(0, 8, 2)
+	tablePlan.printrow("ACTION", "SERVICE", "SERVICEID", "CHANGES")
+	for _, a := range plan.Actions {
+		tablePlan.printrow(a.Action, a.Path, a.ServiceID, strings.Join(a.Changes, ", "))
+	}
+	tablePlan.flush()
+	if ctx.Bool("dry-run") {
+		return
+	} else if !ctx.Bool("yes") && !confirm(fmt.Sprintf("Make %d changes?", len(plan.Actions))) {
+		fmt.Fprintln(os.Stderr, "no changes were made")
+		return
+	}
+
+	cfg.DryRun = false
+	fmt.Fprintln(os.Stderr, "Applying changes - please wait...")
+	applied, err := c.driver.ApplyServices(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Applied %d changes\n", len(applied.Actions))
+	if applied.Snapshot != "" {
+		fmt.Fprintf(os.Stderr, "Snapshot taken before the changes: %s\n", applied.Snapshot)
+	}
+}
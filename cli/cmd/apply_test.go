@@ -0,0 +1,138 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/control-center/serviced/cli/api"
+	"github.com/control-center/serviced/dao"
+)
+
+var DefaultApplyAPITest = ApplyAPITest{ServiceAPITest: DefaultServiceAPITest}
+
+var ErrInvalidDefinition = errors.New("tree.json:3: Services[0].Name: expected a string")
+
+type ApplyAPITest struct {
+	ServiceAPITest
+}
+
+func InitApplyAPITest(args ...string) {
+	New(DefaultApplyAPITest).Run(args)
+}
+
+func (t ApplyAPITest) ApplyServices(cfg api.ApplyConfig) (*dao.ServiceApplyPlan, error) {
+	switch cfg.Definition {
+	case "invalid.json":
+		return nil, ErrInvalidDefinition
+	case "current.json":
+		return nil, nil
+	}
+
+	tenantID := cfg.TenantID
+	if tenantID == "" {
+		tenantID = "test-service-1"
+	}
+	actions := []dao.ServiceUpgradeAction{
+		{Action: "update", Path: "Zenoss", ServiceID: tenantID, Changes: []string{"Startup"}},
+		{Action: "add", Path: "Zenoss/redis"},
+	}
+	if cfg.Definition == "update.json" {
+		actions = actions[:1]
+	}
+	if cfg.Prune {
+		actions = append(actions, dao.ServiceUpgradeAction{Action: "remove", Path: "Zenoss/zencommand", ServiceID: "test-service-3"})
+	}
+	if cfg.Overwrite {
+		actions[0].Changes = append(actions[0].Changes, "Instances")
+	}
+	plan := &dao.ServiceApplyPlan{Actions: actions}
+	if !cfg.DryRun {
+		plan.Snapshot = tenantID + "_20141201-120000"
+	}
+	return plan, nil
+}
+
+func ExampleServicedCLI_CmdApply() {
+	// Gofmt cleans up the spaces at the end of each row
+	InitApplyAPITest("serviced", "apply", "-f", "tree.json", "--prune", "--dry-run")
+	pipeStderr(InitApplyAPITest, "serviced", "apply", "--file", "tree.yaml", "--prune", "--yes", "Zope")
+}
+
+func ExampleServicedCLI_CmdApply_yes() {
+	pipeStderr(InitApplyAPITest, "serviced", "apply", "-f", "update.json", "-y")
+
+	// Output:
+	// ACTION	SERVICE		SERVICEID	CHANGES
+	// update	Zenoss		test-service-1	Startup
+	// Applying changes - please wait...
+	// Applied 1 changes
+	// Snapshot taken before the changes: test-service-1_20141201-120000
+}
+
+func ExampleServicedCLI_CmdApply_confirm() {
+	defer func(f func(string) bool) { confirm = f }(confirm)
+	confirm = func(prompt string) bool {
+		fmt.Println(prompt)
+		return false
+	}
+
+	pipeStderr(InitApplyAPITest, "serviced", "apply", "-f", "update.json")
+
+	// Output:
+	// ACTION	SERVICE		SERVICEID	CHANGES
+	// update	Zenoss		test-service-1	Startup
+	// Make 1 changes?
+	// no changes were made
+}
+
+func ExampleServicedCLI_CmdApply_usage() {
+	InitApplyAPITest("serviced", "apply")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    apply - Makes the services of a tenant match a service definition file
+	//
+	// USAGE:
+	//    command apply [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced apply --file FILE [TENANTID]
+	//
+	// OPTIONS:
+	//    --file, -f 	Service definition of the tenant and its child services, in JSON or YAML
+	//    --prune	Remove the services that are not in the file
+	//    --overwrite	Set the instances, context values and config files to the ones in the file
+	//    --dry-run	Show the changes without making them
+	//    --yes, -y 	Make the changes without asking for confirmation
+}
+
+func ExampleServicedCLI_CmdApply_fail() {
+	pipeStderr(InitApplyAPITest, "serviced", "apply", "-f", "invalid.json")
+
+	// Output:
+	// tree.json:3: Services[0].Name: expected a string
+}
+
+func ExampleServicedCLI_CmdApply_err() {
+	pipeStderr(InitApplyAPITest, "serviced", "apply", "-f", "current.json")
+	pipeStderr(InitApplyAPITest, "serviced", "apply", "-f", "tree.json", "test-service-0")
+
+	// Output:
+	// services are up to date
+	// service not found
+}
@@ -18,6 +18,7 @@ import (
 	"os"
 
 	"github.com/codegangsta/cli"
+	"github.com/control-center/serviced/cli/api"
 )
 
 // Initializer for serviced backup and serviced restore
@@ -29,22 +30,37 @@ func (c *ServicedCli) initBackup() {
 			Usage:       "Dump all templates and services to a tgz file",
 			Description: "serviced backup DIRPATH",
 			Action:      c.cmdBackup,
+			Subcommands: []cli.Command{
+				{
+					Name:        "verify",
+					Usage:       "Verify that a backup file can be restored",
+					Description: "serviced backup verify FILEPATH",
+					Action:      c.cmdBackupVerify,
+				},
+			},
 		},
 		cli.Command{
 			Name:        "restore",
 			Usage:       "Restore templates and services from a tgz file",
 			Description: "serviced restore FILEPATH",
 			Action:      c.cmdRestore,
+			Flags: []cli.Flag{
+				cli.StringFlag{"tenant", "", "Restore only the given tenant"},
+				cli.StringFlag{"new-tenant", "", "Restore the tenant under a new tenant ID"},
+				cli.StringFlag{"pool", "", "Restore the tenant into the given pool"},
+				cli.StringFlag{"deployment-id", "", "Unique id of the deployment of a new tenant"},
+			},
 		},
 	)
 }
 
 // serviced backup DIRPATH
+// A directory named like a subcommand, e.g. verify, is given as ./verify
 func (c *ServicedCli) cmdBackup(ctx *cli.Context) {
 	args := ctx.Args()
 	if len(args) < 1 {
 		fmt.Printf("Incorrect Usage.\n\n")
-		cli.ShowCommandHelp(ctx, "backup")
+		cli.ShowAppHelp(ctx)
 		return
 	}
 
@@ -57,6 +73,22 @@ func (c *ServicedCli) cmdBackup(ctx *cli.Context) {
 	}
 }
 
+// serviced backup verify FILEPATH
+func (c *ServicedCli) cmdBackupVerify(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "verify")
+		return
+	}
+
+	if err := c.driver.VerifyBackup(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", args[0], err)
+	} else {
+		fmt.Printf("%s: OK\n", args[0])
+	}
+}
+
 // serviced restore FILEPATH
 func (c *ServicedCli) cmdRestore(ctx *cli.Context) {
 	args := ctx.Args()
@@ -66,6 +98,33 @@ func (c *ServicedCli) cmdRestore(ctx *cli.Context) {
 		return
 	}
 
+	if tenantID := ctx.String("tenant"); tenantID != "" {
+		cfg := api.RestoreTenantConfig{
+			Path:         args[0],
+			TenantID:     tenantID,
+			NewTenantID:  ctx.String("new-tenant"),
+			PoolID:       ctx.String("pool"),
+			DeploymentID: ctx.String("deployment-id"),
+		}
+
+		if cfg.NewTenantID != "" && cfg.NewTenantID != tenantID && cfg.DeploymentID == "" {
+			fmt.Fprintln(os.Stderr, "--new-tenant requires --deployment-id")
+			return
+		}
+
+		if service, err := c.driver.RestoreTenant(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else if service == nil {
+			fmt.Fprintln(os.Stderr, "received nil service")
+		} else {
+			fmt.Println(service.ID)
+		}
+		return
+	} else if ctx.String("new-tenant") != "" || ctx.String("pool") != "" || ctx.String("deployment-id") != "" {
+		fmt.Fprintln(os.Stderr, "--new-tenant, --pool and --deployment-id require --tenant")
+		return
+	}
+
 	err := c.driver.Restore(args[0])
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
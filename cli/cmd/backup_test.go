@@ -19,6 +19,7 @@ import (
 	"path"
 
 	"github.com/control-center/serviced/cli/api"
+	"github.com/control-center/serviced/domain/service"
 )
 
 const (
@@ -31,6 +32,7 @@ var DefaultBackupAPITest = BackupAPITest{}
 var (
 	ErrBackupFailed  = errors.New("backup failed")
 	ErrRestoreFailed = errors.New("restore failed")
+	ErrVerifyFailed  = errors.New("verify failed")
 )
 
 type BackupAPITest struct {
@@ -68,29 +70,60 @@ func ExampleServicedCli_cmdBackup() {
 	InitBackupAPITest("serviced", "backup", NilPath)
 	// Success
 	InitBackupAPITest("serviced", "backup", "path/to/dir")
+	// A directory named like the verify subcommand
+	InitBackupAPITest("serviced", "backup", "./verify")
 
 	// Output:
 	// dir.tgz
+	// verify.tgz
 }
 
-func ExampleServicedCLI_CmdBackup_usage() {
-	InitBackupAPITest("serviced", "backup")
+func ExampleServicedCLI_CmdBackupVerify_usage() {
+	InitBackupAPITest("serviced", "backup", "verify")
 
 	// Output:
 	// Incorrect Usage.
 	//
 	// NAME:
-	//    backup - Dump all templates and services to a tgz file
+	//    verify - Verify that a backup file can be restored
 	//
 	// USAGE:
-	//    command backup [command options] [arguments...]
+	//    command verify [command options] [arguments...]
 	//
 	// DESCRIPTION:
-	//    serviced backup DIRPATH
+	//    serviced backup verify FILEPATH
 	//
 	// OPTIONS:
 }
 
+func (t BackupAPITest) RestoreTenant(cfg api.RestoreTenantConfig) (*service.Service, error) {
+	switch cfg.Path {
+	case PathNotFound:
+		return nil, ErrRestoreFailed
+	default:
+		if cfg.NewTenantID != "" {
+			return &service.Service{ID: cfg.NewTenantID}, nil
+		}
+		return &service.Service{ID: cfg.TenantID}, nil
+	}
+}
+
+func (t BackupAPITest) VerifyBackup(path string) error {
+	switch path {
+	case PathNotFound:
+		return ErrVerifyFailed
+	default:
+		return nil
+	}
+}
+
+func ExampleServicedCli_cmdBackupVerify() {
+	InitBackupAPITest("serviced", "backup", "verify", "path/to/file")
+
+	// Output:
+	// path/to/file: OK
+}
+
 func ExampleServicedCli_cmdRestore() {
 	InitBackupAPITest("serviced", "restore", PathNotFound)
 	InitBackupAPITest("serviced", "restore", "path/to/file")
@@ -98,6 +131,23 @@ func ExampleServicedCli_cmdRestore() {
 	// Output:
 }
 
+func ExampleServicedCli_cmdRestore_tenant() {
+	InitBackupAPITest("serviced", "restore", "--tenant", "tenant", PathNotFound)
+	InitBackupAPITest("serviced", "restore", "--tenant", "tenant", "path/to/file")
+	InitBackupAPITest("serviced", "restore", "--tenant", "tenant", "--new-tenant", "clone", "--pool", "staging", "--deployment-id", "clone", "path/to/file")
+
+	// Output:
+	// tenant
+	// clone
+}
+
+func ExampleServicedCli_cmdRestore_newTenantNoDeploymentID() {
+	pipeStderr(InitBackupAPITest, "serviced", "restore", "--tenant", "tenant", "--new-tenant", "clone", "path/to/file")
+
+	// Output:
+	// --new-tenant requires --deployment-id
+}
+
 func ExampleServicedCLI_CmdRestore_usage() {
 	InitBackupAPITest("serviced", "restore")
 
@@ -114,4 +164,8 @@ func ExampleServicedCLI_CmdRestore_usage() {
 	//    serviced restore FILEPATH
 	//
 	// OPTIONS:
+	//    --tenant 	Restore only the given tenant
+	//    --new-tenant 	Restore the tenant under a new tenant ID
+	//    --pool 	Restore the tenant into the given pool
+	//    --deployment-id 	Unique id of the deployment of a new tenant
 }
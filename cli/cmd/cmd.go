@@ -21,6 +21,7 @@ import (
 
 	"github.com/codegangsta/cli"
 	"github.com/control-center/serviced/cli/api"
+	"github.com/control-center/serviced/commons/layer"
 	"github.com/control-center/serviced/isvcs"
 	"github.com/control-center/serviced/servicedversion"
 	"github.com/control-center/serviced/utils"
@@ -157,6 +158,7 @@ func New(driver api.API) *ServicedCli {
 		cli.StringSliceFlag{"docker-dns", &dockerDNS, "docker dns configuration used for running containers"},
 		cli.BoolFlag{"master", "run in master mode, i.e., the control center service"},
 		cli.BoolFlag{"agent", "run in agent mode, i.e., a host in a resource pool"},
+		cli.BoolFlag{"storage-standby", "run in storage standby mode, i.e., replicate the DFS and export it if the master's storage fails"},
 		cli.IntFlag{"mux", configInt("MUX_PORT", 22250), "multiplexing port"},
 		cli.BoolTFlag{"tls", "enable TLS"},
 		cli.StringFlag{"var", configEnv("VARPATH", varPath), "path to store serviced data"},
@@ -166,7 +168,7 @@ func New(driver api.API) *ServicedCli {
 		// TODO: 1.1
 		// cli.StringSliceFlag{"remote-zk", &remotezks, "Specify a zookeeper instance to connect to (e.g. -remote-zk remote:2181)"},
 		cli.StringSliceFlag{"mount", &cli.StringSlice{}, "bind mount: DOCKER_IMAGE,HOST_PATH[,CONTAINER_PATH]"},
-		cli.StringFlag{"vfs", "rsync", "filesystem for container volumes"},
+		cli.StringFlag{"vfs", "rsync", "filesystem for container volumes (rsync, btrfs, lvm)"},
 		cli.StringSliceFlag{"alias", &aliases, "list of aliases for this host, e.g., localhost"},
 		cli.IntFlag{"es-startup-timeout", esStartupTimeout, "time to wait on elasticsearch startup before bailing"},
 		cli.IntFlag{"max-container-age", configInt("MAX_CONTAINER_AGE", 60*60*24), "maximum age (seconds) of a stopped container before removing"},
@@ -174,6 +176,16 @@ func New(driver api.API) *ServicedCli {
 		cli.StringFlag{"virtual-address-subnet", configEnv("VIRTUAL_ADDRESS_SUBNET", "10.3"), "/16 subnet for virtual addresses"},
 		cli.StringFlag{"master-pool-id", configEnv("MASTER_POOLID", "default"), "master's pool ID"},
 		cli.StringFlag{"admin-group", configEnv("ADMIN_GROUP", defaultAdminGroup), "system group that can log in to control center"},
+		cli.StringFlag{"backup-passphrase-file", configEnv("BACKUP_PASSPHRASE_FILE", ""), "file containing the passphrase used to encrypt backups"},
+		cli.StringFlag{"backup-public-key", configEnv("BACKUP_PUBLIC_KEY", ""), "RSA public key (PEM) used to encrypt backups"},
+		cli.StringFlag{"backup-private-key", configEnv("BACKUP_PRIVATE_KEY", ""), "RSA private key (PEM) used to decrypt backups"},
+		cli.StringFlag{"volume-soft-limit", configEnv("VOLUME_SOFT_LIMIT", ""), "default size of a tenant volume after which warnings are logged (e.g. 50G)"},
+		cli.StringFlag{"volume-hard-limit", configEnv("VOLUME_HARD_LIMIT", ""), "default size of a tenant volume after which snapshots are refused (e.g. 100G)"},
+		cli.IntFlag{"image-gc-interval", configInt("IMAGE_GC_INTERVAL", 0), "hours between removals of unused images, 0 to disable"},
+		cli.IntFlag{"squash-layer-limit", configInt("SQUASH_LAYER_LIMIT", layer.WARN_LAYER_COUNT), "number of layers at which a committed image is squashed, 0 to disable"},
+		cli.BoolFlag{"strict-images", "refuse to start containers whose image differs from the one pinned to the service"},
+		cli.StringFlag{"storage-ssh-key", configEnv("STORAGE_SSH_KEY", ""), "ssh private key used to sync the DFS to agents in rsync pools"},
+		cli.StringFlag{"storage-known-hosts", configEnv("STORAGE_KNOWN_HOSTS", "/etc/serviced/storage_known_hosts"), "ssh known hosts file of the agents in rsync pools, maintained by the operator"},
 
 		cli.BoolTFlag{"report-stats", "report container statistics"},
 		cli.StringFlag{"host-stats", configEnv("STATS_PORT", "127.0.0.1:8443"), "container statistics for host:port"},
@@ -201,7 +213,9 @@ func New(driver api.API) *ServicedCli {
 	c.initHost()
 	c.initTemplate()
 	c.initService()
+	c.initApply()
 	c.initSnapshot()
+	c.initVolume()
 	c.initLog()
 	c.initBackup()
 	c.initDocker()
@@ -225,6 +239,7 @@ func (c *ServicedCli) cmdInit(ctx *cli.Context) error {
 		DockerDNS:            ctx.GlobalStringSlice("docker-dns"),
 		Master:               ctx.GlobalBool("master"),
 		Agent:                ctx.GlobalBool("agent"),
+		StorageStandby:       ctx.GlobalBool("storage-standby"),
 		MuxPort:              ctx.GlobalInt("mux"),
 		TLS:                  ctx.GlobalBool("tls"),
 		VarPath:              ctx.GlobalString("var"),
@@ -252,6 +267,16 @@ func (c *ServicedCli) cmdInit(ctx *cli.Context) error {
 		LogstashMaxDays:      ctx.GlobalInt("logstash-max-days"),
 		DebugPort:            ctx.GlobalInt("debug-port"),
 		AdminGroup:           ctx.GlobalString("admin-group"),
+		BackupPassphraseFile: ctx.GlobalString("backup-passphrase-file"),
+		BackupPublicKey:      ctx.GlobalString("backup-public-key"),
+		BackupPrivateKey:     ctx.GlobalString("backup-private-key"),
+		VolumeSoftLimit:      ctx.GlobalString("volume-soft-limit"),
+		VolumeHardLimit:      ctx.GlobalString("volume-hard-limit"),
+		StorageSSHKey:        ctx.GlobalString("storage-ssh-key"),
+		StorageKnownHosts:    ctx.GlobalString("storage-known-hosts"),
+		ImageGCInterval:      ctx.GlobalInt("image-gc-interval"),
+		SquashLayerLimit:     ctx.GlobalInt("squash-layer-limit"),
+		StrictImages:         ctx.GlobalBool("strict-images"),
 	}
 	if os.Getenv("SERVICED_MASTER") == "1" {
 		options.Master = true
@@ -259,6 +284,12 @@ func (c *ServicedCli) cmdInit(ctx *cli.Context) error {
 	if os.Getenv("SERVICED_AGENT") == "1" {
 		options.Agent = true
 	}
+	if os.Getenv("SERVICED_STORAGE_STANDBY") == "1" {
+		options.StorageStandby = true
+	}
+	if os.Getenv("SERVICED_STRICT_IMAGES") == "1" {
+		options.StrictImages = true
+	}
 
 	if err := validation.IsSubnet16(options.VirtualAddressSubnet); err != nil {
 		fmt.Fprintf(os.Stderr, "error validating virtual-address-subnet: %s\n", err)
@@ -282,7 +313,7 @@ func (c *ServicedCli) cmdInit(ctx *cli.Context) error {
 	}
 
 	// Start server mode
-	if (options.Master || options.Agent) && len(ctx.Args()) == 0 {
+	if (options.Master || options.Agent || options.StorageStandby) && len(ctx.Args()) == 0 {
 		c.driver.StartServer()
 		return fmt.Errorf("running server mode")
 	}
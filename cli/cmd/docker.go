@@ -18,6 +18,7 @@ import (
 	"github.com/zenoss/glog"
 
 	"fmt"
+	"os"
 )
 
 // initDocker is the initializer for serviced docker
@@ -46,6 +47,15 @@ func (c *ServicedCli) initDocker() {
 					cli.StringFlag{"endpoint", "unix:///var/run/docker.sock", "docker endpoint"},
 				},
 			},
+			{
+				Name:        "gc",
+				Usage:       "serviced docker gc",
+				Description: "gc removes the tenant images that are not used by a service or a snapshot from docker and the local registry",
+				Action:      c.cmdImageGC,
+				Flags: []cli.Flag{
+					cli.BoolFlag{"dry-run", "list the images that would be removed without removing them"},
+				},
+			},
 		},
 	})
 }
@@ -86,3 +96,19 @@ func (c *ServicedCli) cmdRegistrySync(ctx *cli.Context) {
 		glog.Fatalf("error syncing docker images to local registry: %s", err)
 	}
 }
+
+// serviced docker gc [--dry-run]
+func (c *ServicedCli) cmdImageGC(ctx *cli.Context) {
+	images, err := c.driver.CollectImages(ctx.Bool("dry-run"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	} else if len(images) == 0 {
+		fmt.Fprintln(os.Stderr, "no unused images found")
+		return
+	}
+
+	for _, image := range images {
+		fmt.Println(image)
+	}
+}
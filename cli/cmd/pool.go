@@ -48,6 +48,9 @@ func (c *ServicedCli) initPool() {
 				Description:  "serviced pool add POOLID PRIORITY",
 				BashComplete: nil,
 				Action:       c.cmdPoolAdd,
+				Flags: []cli.Flag{
+					cli.StringFlag{"storage-transport", "", "How hosts in the pool reach the DFS (nfs or rsync)"},
+				},
 			}, {
 				Name:         "remove",
 				ShortName:    "rm",
@@ -64,6 +67,12 @@ func (c *ServicedCli) initPool() {
 				Flags: []cli.Flag{
 					cli.BoolFlag{"verbose, v", "Show JSON format"},
 				},
+			}, {
+				Name:         "set-storage-transport",
+				Usage:        "Sets how hosts in a pool reach the DFS",
+				Description:  "serviced pool set-storage-transport POOLID TRANSPORT",
+				BashComplete: c.printPoolsFirst,
+				Action:       c.cmdPoolSetStorageTransport,
 			}, {
 				Name:         "add-virtual-ip",
 				Usage:        "Add a virtual IP address to a pool",
@@ -197,6 +206,8 @@ func (c *ServicedCli) cmdPoolAdd(ctx *cli.Context) {
 		return
 	}
 
+	cfg.StorageTransport = ctx.String("storage-transport")
+
 	/* TODO: 1.1
 	if len(args) > 2 {
 		cfg.Realm = args[2]
@@ -212,6 +223,24 @@ func (c *ServicedCli) cmdPoolAdd(ctx *cli.Context) {
 	}
 }
 
+// serviced pool set-storage-transport POOLID TRANSPORT
+func (c *ServicedCli) cmdPoolSetStorageTransport(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "set-storage-transport")
+		return
+	}
+
+	if pool, err := c.driver.SetStorageTransport(args[0], args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else if pool == nil {
+		fmt.Fprintln(os.Stderr, "received nil resource pool")
+	} else {
+		fmt.Println(pool.ID)
+	}
+}
+
 // serviced pool remove POOLID ...
 func (c *ServicedCli) cmdPoolRemove(ctx *cli.Context) {
 	args := ctx.Args()
@@ -136,6 +136,18 @@ func (t PoolAPITest) RemoveResourcePool(id string) error {
 	return nil
 }
 
+func (t PoolAPITest) SetStorageTransport(id, transport string) (*pool.ResourcePool, error) {
+	if p, err := t.GetResourcePool(id); err != nil {
+		return nil, err
+	} else if p == nil {
+		return nil, ErrNoPoolFound
+	} else if transport != pool.StorageNFS && transport != pool.StorageRsync {
+		return nil, ErrInvalidPool
+	} else {
+		return p, nil
+	}
+}
+
 func (t PoolAPITest) GetPoolIPs(id string) (*facade.PoolIPs, error) {
 	p, err := t.GetResourcePool(id)
 	if err != nil {
@@ -263,6 +275,7 @@ func ExampleServicedCLI_CmdPoolAdd_usage() {
 	//    serviced pool add POOLID PRIORITY
 	//
 	// OPTIONS:
+	//    --storage-transport 	How hosts in the pool reach the DFS (nfs or rsync)
 }
 
 func ExampleServicedCLI_CmdPoolAdd_err() {
@@ -272,6 +285,40 @@ func ExampleServicedCLI_CmdPoolAdd_err() {
 	// received nil resource pool
 }
 
+func ExampleServicedCLI_CmdPoolSetStorageTransport() {
+	InitPoolAPITest("serviced", "pool", "set-storage-transport", "test-pool-id-1", "rsync")
+
+	// Output:
+	// test-pool-id-1
+}
+
+func ExampleServicedCLI_CmdPoolSetStorageTransport_usage() {
+	InitPoolAPITest("serviced", "pool", "set-storage-transport", "test-pool-id-1")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    set-storage-transport - Sets how hosts in a pool reach the DFS
+	//
+	// USAGE:
+	//    command set-storage-transport [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced pool set-storage-transport POOLID TRANSPORT
+	//
+	// OPTIONS:
+}
+
+func ExampleServicedCLI_CmdPoolSetStorageTransport_err() {
+	pipeStderr(InitPoolAPITest, "serviced", "pool", "set-storage-transport", "test-pool-id-0", "rsync")
+	pipeStderr(InitPoolAPITest, "serviced", "pool", "set-storage-transport", "test-pool-id-1", "ftp")
+
+	// Output:
+	// no pool found
+	// invalid pool
+}
+
 func ExampleServicedCLI_CmdPoolRemove() {
 	InitPoolAPITest("serviced", "pool", "remove", "test-pool-id-1")
 
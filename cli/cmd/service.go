@@ -19,6 +19,8 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"text/template"
@@ -26,6 +28,7 @@ import (
 
 	"github.com/codegangsta/cli"
 	"github.com/control-center/serviced/cli/api"
+	"github.com/control-center/serviced/commons/diff"
 	dockerclient "github.com/control-center/serviced/commons/docker"
 	"github.com/control-center/serviced/dao"
 	"github.com/control-center/serviced/dfs"
@@ -76,12 +79,13 @@ func (c *ServicedCli) initService() {
 			}, {
 				Name:        "add",
 				Usage:       "Adds a new service",
-				Description: "serviced service add NAME IMAGEID COMMAND",
+				Description: "serviced service add {NAME IMAGEID COMMAND | --file FILE [NAME]}",
 				Action:      c.cmdServiceAdd,
 				Flags: []cli.Flag{
 					cli.GenericFlag{"p", &api.PortMap{}, "Expose a port for this service (e.g. -p tcp:3306:mysql)"},
 					cli.GenericFlag{"q", &api.PortMap{}, "Map a remote service port (e.g. -q tcp:3306:mysql)"},
 					cli.StringFlag{"parent-id", "", "Parent service ID for which this service relates"},
+					cli.StringFlag{"file", "", "Add the service and its child services from a service definition in JSON or YAML"},
 				},
 			}, {
 				Name:         "remove",
@@ -209,6 +213,47 @@ func (c *ServicedCli) initService() {
 				Description:  "serviced service snapshot SERVICEID",
 				BashComplete: c.printServicesFirst,
 				Action:       c.cmdServiceSnapshot,
+			}, {
+				Name:         "config-conflicts",
+				Usage:        "Lists the customized config files that conflict with their template",
+				Description:  "serviced service config-conflicts SERVICEID [FILENAME]",
+				BashComplete: c.printServicesFirst,
+				Action:       c.cmdServiceConfigConflicts,
+			}, {
+				Name:         "resolve-config",
+				Usage:        "Resolves the conflict of a customized config file with its template",
+				Description:  "serviced service resolve-config SERVICEID FILENAME",
+				BashComplete: c.printServicesFirst,
+				Action:       c.cmdServiceResolveConfig,
+				Flags: []cli.Flag{
+					cli.BoolFlag{"template", "Replace the customized file with the template's file"},
+					cli.StringFlag{"file", "", "Replace the content of the customized file with the content of a file (example: the edited merge)"},
+				},
+			}, {
+				Name:        "config",
+				Usage:       "Shows the history of a service's config file and reverts it",
+				Description: "",
+				Subcommands: []cli.Command{
+					{
+						Name:         "history",
+						Usage:        "Lists the versions of a service's config file",
+						Description:  "serviced service config history SERVICEID FILENAME",
+						BashComplete: c.printServicesFirst,
+						Action:       c.cmdServiceConfigHistory,
+					}, {
+						Name:         "diff",
+						Usage:        "Shows the changes between versions of a service's config file",
+						Description:  "serviced service config diff SERVICEID FILENAME [VERSION [VERSION]]",
+						BashComplete: c.printServicesFirst,
+						Action:       c.cmdServiceConfigDiff,
+					}, {
+						Name:         "revert",
+						Usage:        "Reverts a service's config file to one of its versions",
+						Description:  "serviced service config revert SERVICEID FILENAME VERSION",
+						BashComplete: c.printServicesFirst,
+						Action:       c.cmdServiceConfigRevert,
+					},
+				},
 			},
 		},
 	})
@@ -626,10 +671,11 @@ func (c *ServicedCli) cmdServiceList(ctx *cli.Context) {
 	}
 }
 
-// serviced service add [[-p PORT]...] [[-q REMOTE]...] [--parent-id SERVICEID] NAME IMAGEID COMMAND
+// serviced service add [[-p PORT]...] [[-q REMOTE]...] [--parent-id SERVICEID] {NAME IMAGEID COMMAND | --file FILE [NAME]}
 func (c *ServicedCli) cmdServiceAdd(ctx *cli.Context) {
 	args := ctx.Args()
-	if len(args) < 3 {
+	definition := ctx.String("file")
+	if definition == "" && len(args) < 3 {
 		fmt.Printf("Incorrect Usage.\n\n")
 		cli.ShowCommandHelp(ctx, "add")
 		return
@@ -648,12 +694,15 @@ func (c *ServicedCli) cmdServiceAdd(ctx *cli.Context) {
 	}
 
 	cfg := api.ServiceConfig{
-		Name:            args[0],
-		ImageID:         args[1],
-		Command:         args[2],
 		ParentServiceID: parentService.ID,
 		LocalPorts:      ctx.Generic("p").(*api.PortMap),
 		RemotePorts:     ctx.Generic("q").(*api.PortMap),
+		Definition:      definition,
+	}
+	if definition == "" {
+		cfg.Name, cfg.ImageID, cfg.Command = args[0], args[1], args[2]
+	} else if len(args) > 0 {
+		cfg.Name = args[0]
 	}
 
 	if service, err := c.driver.AddService(cfg); err != nil {
@@ -1298,3 +1347,217 @@ func (c *ServicedCli) cmdServiceSnapshot(ctx *cli.Context) {
 		fmt.Println(snapshot)
 	}
 }
+
+// serviced service config-conflicts SERVICEID [FILENAME]
+func (c *ServicedCli) cmdServiceConfigConflicts(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "config-conflicts")
+		return
+	}
+
+	svc, err := c.searchForService(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	if len(svc.ConfigConflicts) == 0 {
+		fmt.Fprintln(os.Stderr, "no config conflicts found")
+		return
+	}
+
+	if len(args) > 1 {
+		conflict, ok := svc.ConfigConflicts[args[1]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "no config conflict found for %s\n", args[1])
+			return
+		}
+		fmt.Print(conflict.Merged)
+		return
+	}
+
+	filenames := make([]string, 0, len(svc.ConfigConflicts))
+	for filename := range svc.ConfigConflicts {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		fmt.Println(filename)
+	}
+}
+
+// serviced service resolve-config SERVICEID FILENAME [--template | --file PATH]
+func (c *ServicedCli) cmdServiceResolveConfig(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "resolve-config")
+		return
+	}
+
+	if ctx.Bool("template") && ctx.String("file") != "" {
+		fmt.Fprintln(os.Stderr, "--template and --file cannot be used together")
+		return
+	}
+
+	svc, err := c.searchForService(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	cfg := api.ResolveConfigConfig{
+		ServiceID:   svc.ID,
+		Filename:    args[1],
+		UseTemplate: ctx.Bool("template"),
+	}
+
+	if filename := ctx.String("file"); filename != "" {
+		file, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		defer file.Close()
+		cfg.Content = file
+	}
+
+	if service, err := c.driver.ResolveConfigConflict(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else if service == nil {
+		fmt.Fprintln(os.Stderr, "received nil service")
+	} else {
+		fmt.Println(service.ID)
+	}
+}
+
+// serviced service config history SERVICEID FILENAME
+func (c *ServicedCli) cmdServiceConfigHistory(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "history")
+		return
+	}
+
+	svc, err := c.searchForService(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	versions, err := c.driver.GetServiceConfigVersions(svc.ID, args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	} else if len(versions) == 0 {
+		fmt.Fprintln(os.Stderr, "no config file versions found")
+		return
+	}
+
+	tableVersion := newtable(0, 8, 2)
+	tableVersion.printrow("VERSION", "AUTHOR", "CREATED")
+	for _, v := range versions {
+		tableVersion.printrow(v.Version, v.Author, v.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	tableVersion.flush()
+}
+
+// serviced service config diff SERVICEID FILENAME [VERSION [VERSION]]
+func (c *ServicedCli) cmdServiceConfigDiff(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 || len(args) > 4 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "diff")
+		return
+	}
+
+	var numbers []int
+	for _, arg := range args[2:] {
+		number, err := strconv.Atoi(arg)
+		if err != nil || number < 1 {
+			fmt.Fprintf(os.Stderr, "invalid version: %s\n", arg)
+			return
+		}
+		numbers = append(numbers, number)
+	}
+
+	svc, err := c.searchForService(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	filename := args[1]
+	current, ok := svc.ConfigFiles[filename]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "config file %s not found\n", filename)
+		return
+	}
+
+	versions, err := c.driver.GetServiceConfigVersions(svc.ID, filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	content := make(map[int]string)
+	for _, v := range versions {
+		content[v.Version] = v.ConfFile.Content
+	}
+
+	// without versions, show the last change to the file
+	if len(numbers) == 0 {
+		if len(versions) < 2 {
+			fmt.Fprintln(os.Stderr, "no changes found")
+			return
+		}
+		numbers = []int{versions[len(versions)-2].Version}
+	}
+
+	from, to := current.Content, current.Content
+	fromLabel, toLabel := filename+" (current)", filename+" (current)"
+	for i, number := range numbers {
+		text, ok := content[number]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "version %d not found\n", number)
+			return
+		}
+		label := fmt.Sprintf("%s (version %d)", filename, number)
+		if i == 0 {
+			from, fromLabel = text, label
+		} else {
+			to, toLabel = text, label
+		}
+	}
+
+	fmt.Print(diff.Unified(from, to, fromLabel, toLabel, 3))
+}
+
+// serviced service config revert SERVICEID FILENAME VERSION
+func (c *ServicedCli) cmdServiceConfigRevert(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 3 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "revert")
+		return
+	}
+
+	version, err := strconv.Atoi(args[2])
+	if err != nil || version < 1 {
+		fmt.Fprintf(os.Stderr, "invalid version: %s\n", args[2])
+		return
+	}
+
+	svc, err := c.searchForService(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	if err := c.driver.RevertServiceConfig(svc.ID, args[1], version); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else {
+		fmt.Println(svc.ID)
+	}
+}
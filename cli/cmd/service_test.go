@@ -18,14 +18,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/control-center/serviced/cli/api"
 	"github.com/control-center/serviced/domain"
 	"github.com/control-center/serviced/domain/pool"
 	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/serviceconfigfile"
+	"github.com/control-center/serviced/domain/servicedefinition"
 )
 
 const (
@@ -54,6 +58,15 @@ var DefaultTestServices = []service.Service{
 			"hello":   "echo hello world",
 			"goodbye": "echo goodbye world",
 		},
+		ConfigFiles: map[string]servicedefinition.ConfigFile{
+			"/etc/zenoss.conf": {Filename: "/etc/zenoss.conf", Content: "workers = 8\n"},
+		},
+		ConfigConflicts: map[string]service.ConfigConflict{
+			"/etc/zenoss.conf": {
+				Template: servicedefinition.ConfigFile{Filename: "/etc/zenoss.conf", Content: "workers = 4\n"},
+				Merged:   "<<<<<<< customized\nworkers = 8\n=======\nworkers = 4\n>>>>>>> template\n",
+			},
+		},
 	}, {
 		ID:             "test-service-2",
 		Name:           "Zope",
@@ -79,6 +92,25 @@ var DefaultTestServices = []service.Service{
 	},
 }
 
+var DefaultTestConfigVersions = []serviceconfigfile.ConfigVersion{
+	{
+		ID:              "test-version-1",
+		ServiceTenantID: "test-service-1",
+		ServicePath:     "/Zenoss",
+		Version:         1,
+		CreatedAt:       time.Date(2014, 10, 1, 12, 0, 0, 0, time.UTC),
+		ConfFile:        servicedefinition.ConfigFile{Filename: "/etc/zenoss.conf", Content: "workers = 4\n"},
+	}, {
+		ID:              "test-version-2",
+		ServiceTenantID: "test-service-1",
+		ServicePath:     "/Zenoss",
+		Version:         2,
+		Author:          "admin",
+		CreatedAt:       time.Date(2014, 10, 2, 12, 0, 0, 0, time.UTC),
+		ConfFile:        servicedefinition.ConfigFile{Filename: "/etc/zenoss.conf", Content: "workers = 8\n"},
+	},
+}
+
 var (
 	ErrNoServiceFound = errors.New("no service found")
 	ErrInvalidService = errors.New("invalid service")
@@ -145,10 +177,15 @@ func (t ServiceAPITest) AddService(config api.ServiceConfig) (*service.Service,
 		i++
 	}
 
+	name := config.Name
+	if config.Definition != "" && name == "" {
+		name = strings.TrimSuffix(path.Base(config.Definition), path.Ext(config.Definition))
+	}
+
 	s := service.Service{
-		ID:              fmt.Sprintf("%s-%s-%s", config.Name, config.ParentServiceID, config.ImageID),
+		ID:              fmt.Sprintf("%s-%s-%s", name, config.ParentServiceID, config.ImageID),
 		ParentServiceID: config.ParentServiceID,
-		Name:            config.Name,
+		Name:            name,
 		ImageID:         config.ImageID,
 		Endpoints:       endpoints,
 		Startup:         config.Command,
@@ -285,6 +322,48 @@ func (t ServiceAPITest) AddSnapshot(id string) (string, error) {
 	return fmt.Sprintf("%s-snapshot", id), nil
 }
 
+func (t ServiceAPITest) ResolveConfigConflict(config api.ResolveConfigConfig) (*service.Service, error) {
+	s, err := t.GetService(config.ServiceID)
+	if err != nil {
+		return nil, err
+	} else if s == nil {
+		return nil, ErrNoServiceFound
+	}
+
+	if _, ok := s.ConfigConflicts[config.Filename]; !ok {
+		return nil, fmt.Errorf("no config conflict found for %s", config.Filename)
+	}
+
+	return s, nil
+}
+
+func (t ServiceAPITest) GetServiceConfigVersions(serviceID, filename string) ([]serviceconfigfile.ConfigVersion, error) {
+	if t.fail {
+		return nil, ErrInvalidService
+	}
+
+	var versions []serviceconfigfile.ConfigVersion
+	for _, v := range DefaultTestConfigVersions {
+		if v.ServiceTenantID == serviceID && v.ConfFile.Filename == filename {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+func (t ServiceAPITest) RevertServiceConfig(serviceID, filename string, version int) error {
+	versions, err := t.GetServiceConfigVersions(serviceID, filename)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("version %d of config file %s not found", version, filename)
+}
+
 func TestServicedCLI_CmdServiceList_one(t *testing.T) {
 	serviceID := "test-service-1"
 
@@ -379,6 +458,15 @@ func ExampleServicedCLI_CmdServiceAdd() {
 	// test-service-test-service-1-test-image
 }
 
+func ExampleServicedCLI_CmdServiceAdd_file() {
+	InitServiceAPITest("serviced", "service", "add", "--parent-id", "test-service-1", "--file", "defs/test-service.yaml")
+	InitServiceAPITest("serviced", "service", "add", "--parent-id", "test-service-1", "--file", "defs/test-service.yaml", "renamed")
+
+	// Output:
+	// test-service-test-service-1-
+	// renamed-test-service-1-
+}
+
 func ExampleServicedCLI_CmdServiceAdd_usage() {
 	InitServiceAPITest("serviced", "service", "add")
 
@@ -392,12 +480,13 @@ func ExampleServicedCLI_CmdServiceAdd_usage() {
 	//    command add [command options] [arguments...]
 	//
 	// DESCRIPTION:
-	//    serviced service add NAME IMAGEID COMMAND
+	//    serviced service add {NAME IMAGEID COMMAND | --file FILE [NAME]}
 	//
 	// OPTIONS:
 	//    -p 		`-p option -p option` Expose a port for this service (e.g. -p tcp:3306:mysql)
 	//    -q 		`-q option -q option` Map a remote service port (e.g. -q tcp:3306:mysql)
 	//    --parent-id 	Parent service ID for which this service relates
+	//    --file 	Add the service and its child services from a service definition in JSON or YAML
 }
 
 func ExampleServicedCLI_CmdServiceAdd_fail() {
@@ -893,3 +982,171 @@ func ExampleServicedCLI_CmdServiceSnapshot_err() {
 	// Output:
 	// service not found
 }
+
+func ExampleServicedCLI_CmdServiceConfigConflicts() {
+	InitServiceAPITest("serviced", "service", "config-conflicts", "test-service-1")
+
+	// Output:
+	// /etc/zenoss.conf
+}
+
+func ExampleServicedCLI_CmdServiceConfigConflicts_file() {
+	InitServiceAPITest("serviced", "service", "config-conflicts", "test-service-1", "/etc/zenoss.conf")
+
+	// Output:
+	// <<<<<<< customized
+	// workers = 8
+	// =======
+	// workers = 4
+	// >>>>>>> template
+}
+
+func ExampleServicedCLI_CmdServiceConfigConflicts_usage() {
+	InitServiceAPITest("serviced", "service", "config-conflicts")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    config-conflicts - Lists the customized config files that conflict with their template
+	//
+	// USAGE:
+	//    command config-conflicts [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced service config-conflicts SERVICEID [FILENAME]
+	//
+	// OPTIONS:
+}
+
+func ExampleServicedCLI_CmdServiceConfigConflicts_err() {
+	pipeStderr(InitServiceAPITest, "serviced", "service", "config-conflicts", "test-service-2")
+
+	// Output:
+	// no config conflicts found
+}
+
+func ExampleServicedCLI_CmdServiceResolveConfig() {
+	InitServiceAPITest("serviced", "service", "resolve-config", "test-service-1", "/etc/zenoss.conf", "--template")
+
+	// Output:
+	// test-service-1
+}
+
+func ExampleServicedCLI_CmdServiceResolveConfig_usage() {
+	InitServiceAPITest("serviced", "service", "resolve-config", "test-service-1")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    resolve-config - Resolves the conflict of a customized config file with its template
+	//
+	// USAGE:
+	//    command resolve-config [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced service resolve-config SERVICEID FILENAME
+	//
+	// OPTIONS:
+	//    --template	Replace the customized file with the template's file
+	//    --file 	Replace the content of the customized file with the content of a file (example: the edited merge)
+}
+
+func ExampleServicedCLI_CmdServiceResolveConfig_err() {
+	pipeStderr(InitServiceAPITest, "serviced", "service", "resolve-config", "test-service-2", "/etc/zenoss.conf")
+
+	// Output:
+	// no config conflict found for /etc/zenoss.conf
+}
+
+func ExampleServicedCLI_CmdServiceConfigHistory() {
+	// Gofmt cleans up the spaces at the end of each row
+	InitServiceAPITest("serviced", "service", "config", "history", "test-service-1", "/etc/zenoss.conf")
+}
+
+func ExampleServicedCLI_CmdServiceConfigHistory_usage() {
+	InitServiceAPITest("serviced", "service", "config", "history", "test-service-1")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    history - Lists the versions of a service's config file
+	//
+	// USAGE:
+	//    command history [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced service config history SERVICEID FILENAME
+	//
+	// OPTIONS:
+}
+
+func ExampleServicedCLI_CmdServiceConfigHistory_err() {
+	pipeStderr(InitServiceAPITest, "serviced", "service", "config", "history", "test-service-2", "/etc/zenoss.conf")
+
+	// Output:
+	// no config file versions found
+}
+
+func ExampleServicedCLI_CmdServiceConfigDiff() {
+	InitServiceAPITest("serviced", "service", "config", "diff", "test-service-1", "/etc/zenoss.conf")
+
+	// Output:
+	// --- /etc/zenoss.conf (version 1)
+	// +++ /etc/zenoss.conf (current)
+	// @@ -1 +1 @@
+	// -workers = 4
+	// +workers = 8
+}
+
+func ExampleServicedCLI_CmdServiceConfigDiff_versions() {
+	InitServiceAPITest("serviced", "service", "config", "diff", "test-service-1", "/etc/zenoss.conf", "2", "1")
+
+	// Output:
+	// --- /etc/zenoss.conf (version 2)
+	// +++ /etc/zenoss.conf (version 1)
+	// @@ -1 +1 @@
+	// -workers = 8
+	// +workers = 4
+}
+
+func ExampleServicedCLI_CmdServiceConfigDiff_err() {
+	pipeStderr(InitServiceAPITest, "serviced", "service", "config", "diff", "test-service-1", "/etc/zenoss.conf", "3")
+
+	// Output:
+	// version 3 not found
+}
+
+func ExampleServicedCLI_CmdServiceConfigRevert() {
+	InitServiceAPITest("serviced", "service", "config", "revert", "test-service-1", "/etc/zenoss.conf", "1")
+
+	// Output:
+	// test-service-1
+}
+
+func ExampleServicedCLI_CmdServiceConfigRevert_usage() {
+	InitServiceAPITest("serviced", "service", "config", "revert", "test-service-1", "/etc/zenoss.conf")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    revert - Reverts a service's config file to one of its versions
+	//
+	// USAGE:
+	//    command revert [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced service config revert SERVICEID FILENAME VERSION
+	//
+	// OPTIONS:
+}
+
+func ExampleServicedCLI_CmdServiceConfigRevert_err() {
+	pipeStderr(InitServiceAPITest, "serviced", "service", "config", "revert", "test-service-1", "/etc/zenoss.conf", "5")
+
+	// Output:
+	// version 5 of config file /etc/zenoss.conf not found
+}
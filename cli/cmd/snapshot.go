@@ -18,6 +18,8 @@ import (
 	"os"
 
 	"github.com/codegangsta/cli"
+	"github.com/control-center/serviced/cli/api"
+	"github.com/control-center/serviced/dao"
 )
 
 // initSnapshot is the initializer for serviced snapshot
@@ -57,6 +59,33 @@ func (c *ServicedCli) initSnapshot() {
 				Description:  "serviced snapshot rollback SNAPSHOTID",
 				BashComplete: c.printSnapshotsFirst,
 				Action:       c.cmdSnapshotRollback,
+			}, {
+				Name:         "diff",
+				Usage:        "Shows the changes between two snapshots of the same service",
+				Description:  "serviced snapshot diff SNAPSHOTID SNAPSHOTID",
+				BashComplete: c.printSnapshotsAll,
+				Action:       c.cmdSnapshotDiff,
+			}, {
+				Name:         "clone",
+				Usage:        "Deploys a copy of a service from one of its snapshots",
+				Description:  "serviced snapshot clone SNAPSHOTID",
+				BashComplete: c.printSnapshotsFirst,
+				Action:       c.cmdSnapshotClone,
+				Flags: []cli.Flag{
+					cli.StringFlag{"pool", "", "Deploy the clone into the given pool"},
+					cli.StringFlag{"deployment-id", "", "Unique id of the clone's deployment"},
+				},
+			}, {
+				Name:         "export",
+				Usage:        "Writes a snapshot and its images to a file",
+				Description:  "serviced snapshot export SNAPSHOTID FILEPATH",
+				BashComplete: c.printSnapshotsFirst,
+				Action:       c.cmdSnapshotExport,
+			}, {
+				Name:        "import",
+				Usage:       "Loads a service from a snapshot file",
+				Description: "serviced snapshot import FILEPATH",
+				Action:      c.cmdSnapshotImport,
 			},
 		},
 	})
@@ -206,3 +235,103 @@ func (c *ServicedCli) cmdSnapshotRollback(ctx *cli.Context) {
 		fmt.Println(args[0])
 	}
 }
+
+// serviced snapshot diff SNAPSHOTID SNAPSHOTID
+func (c *ServicedCli) cmdSnapshotDiff(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "diff")
+		return
+	}
+
+	diff, err := c.driver.DiffSnapshots(args[0], args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	} else if diff == nil || len(diff.Changes) == 0 {
+		fmt.Fprintln(os.Stderr, "no changes found")
+		return
+	}
+
+	for _, change := range diff.Changes {
+		line := fmt.Sprintf("%s %s %s", changeSymbol(change), change.Type, change.Name)
+		if change.Detail != "" {
+			line += fmt.Sprintf(" (%s)", change.Detail)
+		}
+		fmt.Println(line)
+	}
+}
+
+// serviced snapshot clone SNAPSHOTID --deployment-id DEPLOYMENTID [--pool POOLID]
+func (c *ServicedCli) cmdSnapshotClone(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 || ctx.String("deployment-id") == "" {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "clone")
+		return
+	}
+
+	cfg := api.CloneSnapshotConfig{
+		SnapshotID:   args[0],
+		PoolID:       ctx.String("pool"),
+		DeploymentID: ctx.String("deployment-id"),
+	}
+
+	fmt.Fprintln(os.Stderr, "Cloning snapshot - please wait...")
+	if service, err := c.driver.CloneSnapshot(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else if service == nil {
+		fmt.Fprintln(os.Stderr, "received nil service")
+	} else {
+		fmt.Println(service.ID)
+	}
+}
+
+// serviced snapshot export SNAPSHOTID FILEPATH
+func (c *ServicedCli) cmdSnapshotExport(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "export")
+		return
+	}
+
+	if filename, err := c.driver.ExportSnapshot(args[0], args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else if filename == "" {
+		fmt.Fprintln(os.Stderr, "received nil file")
+	} else {
+		fmt.Println(filename)
+	}
+}
+
+// serviced snapshot import FILEPATH
+func (c *ServicedCli) cmdSnapshotImport(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "import")
+		return
+	}
+
+	if service, err := c.driver.ImportSnapshot(args[0]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else if service == nil {
+		fmt.Fprintln(os.Stderr, "received nil service")
+	} else {
+		fmt.Println(service.ID)
+	}
+}
+
+// changeSymbol returns the diff-style symbol for a snapshot change
+func changeSymbol(change dao.SnapshotChange) string {
+	switch change.Action {
+	case "added":
+		return "+"
+	case "removed":
+		return "-"
+	default:
+		return "M"
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"strings"
 
 	"github.com/control-center/serviced/cli/api"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/domain/service"
 )
 
 const (
@@ -106,6 +108,56 @@ func (t SnapshotAPITest) Rollback(id string) error {
 	return t.RemoveSnapshot(id)
 }
 
+func (t SnapshotAPITest) DiffSnapshots(from, to string) (*dao.SnapshotDiff, error) {
+	for _, id := range []string{from, to} {
+		if ok, err := t.hasSnapshot(id); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, ErrNoSnapshotFound
+		}
+	}
+	if from == to {
+		return &dao.SnapshotDiff{From: from, To: to}, nil
+	}
+	return &dao.SnapshotDiff{
+		From: from,
+		To:   to,
+		Changes: []dao.SnapshotChange{
+			{Type: "file", Action: "added", Name: "etc/zenoss.conf"},
+			{Type: "file", Action: "modified", Name: "var/data.db", Detail: "contents changed"},
+			{Type: "service", Action: "removed", Name: "test-service-3", Detail: "opentsdb"},
+			{Type: "image", Action: "modified", Name: "core", Detail: "1 -> 3"},
+		},
+	}, nil
+}
+
+func (t SnapshotAPITest) CloneSnapshot(cfg api.CloneSnapshotConfig) (*service.Service, error) {
+	if ok, err := t.hasSnapshot(cfg.SnapshotID); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrNoSnapshotFound
+	}
+	return &service.Service{ID: fmt.Sprintf("%s-clone", cfg.DeploymentID)}, nil
+}
+
+func (t SnapshotAPITest) ExportSnapshot(id, path string) (string, error) {
+	if ok, err := t.hasSnapshot(id); err != nil {
+		return "", err
+	} else if !ok {
+		return "", ErrNoSnapshotFound
+	}
+	return path, nil
+}
+
+func (t SnapshotAPITest) ImportSnapshot(path string) (*service.Service, error) {
+	if t.fail {
+		return nil, ErrInvalidSnapshot
+	} else if path == NilSnapshot {
+		return nil, nil
+	}
+	return &service.Service{ID: strings.TrimSuffix(path, ".tgz")}, nil
+}
+
 func ExampleServicedCLI_CmdSnapshotList() {
 	InitSnapshotAPITest("serviced", "snapshot", "list")
 
@@ -294,3 +346,139 @@ func ExampleServicedCLI_CmdSnapshotRollback_err() {
 	// Output:
 	// no snapshot found
 }
+
+func ExampleServicedCLI_CmdSnapshotDiff() {
+	InitSnapshotAPITest("serviced", "snapshot", "diff", "test-service-1-snapshot-1", "test-service-1-snapshot-2")
+
+	// Output:
+	// + file etc/zenoss.conf
+	// M file var/data.db (contents changed)
+	// - service test-service-3 (opentsdb)
+	// M image core (1 -> 3)
+}
+
+func ExampleServicedCLI_CmdSnapshotDiff_usage() {
+	InitSnapshotAPITest("serviced", "snapshot", "diff", "test-service-1-snapshot-1")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    diff - Shows the changes between two snapshots of the same service
+	//
+	// USAGE:
+	//    command diff [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced snapshot diff SNAPSHOTID SNAPSHOTID
+	//
+	// OPTIONS:
+}
+
+func ExampleServicedCLI_CmdSnapshotDiff_err() {
+	pipeStderr(InitSnapshotAPITest, "serviced", "snapshot", "diff", "test-service-1-snapshot-1", "test-service-0-snapshot")
+	pipeStderr(InitSnapshotAPITest, "serviced", "snapshot", "diff", "test-service-1-snapshot-1", "test-service-1-snapshot-1")
+
+	// Output:
+	// no snapshot found
+	// no changes found
+}
+
+func ExampleServicedCLI_CmdSnapshotClone() {
+	InitSnapshotAPITest("serviced", "snapshot", "clone", "--pool", "staging", "--deployment-id", "test1", "test-service-1-snapshot-1")
+
+	// Output:
+	// test1-clone
+}
+
+func ExampleServicedCLI_CmdSnapshotClone_usage() {
+	InitSnapshotAPITest("serviced", "snapshot", "clone", "test-service-1-snapshot-1")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    clone - Deploys a copy of a service from one of its snapshots
+	//
+	// USAGE:
+	//    command clone [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced snapshot clone SNAPSHOTID
+	//
+	// OPTIONS:
+	//    --pool 	Deploy the clone into the given pool
+	//    --deployment-id 	Unique id of the clone's deployment
+}
+
+func ExampleServicedCLI_CmdSnapshotClone_err() {
+	pipeStderr(InitSnapshotAPITest, "serviced", "snapshot", "clone", "--deployment-id", "test1", "test-service-0-snapshot")
+
+	// Output:
+	// Cloning snapshot - please wait...
+	// no snapshot found
+}
+
+func ExampleServicedCLI_CmdSnapshotExport() {
+	InitSnapshotAPITest("serviced", "snapshot", "export", "test-service-1-snapshot-1", "snapshot.tgz")
+
+	// Output:
+	// snapshot.tgz
+}
+
+func ExampleServicedCLI_CmdSnapshotExport_usage() {
+	InitSnapshotAPITest("serviced", "snapshot", "export", "test-service-1-snapshot-1")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    export - Writes a snapshot and its images to a file
+	//
+	// USAGE:
+	//    command export [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced snapshot export SNAPSHOTID FILEPATH
+	//
+	// OPTIONS:
+}
+
+func ExampleServicedCLI_CmdSnapshotExport_err() {
+	pipeStderr(InitSnapshotAPITest, "serviced", "snapshot", "export", "test-service-0-snapshot", "snapshot.tgz")
+
+	// Output:
+	// no snapshot found
+}
+
+func ExampleServicedCLI_CmdSnapshotImport() {
+	InitSnapshotAPITest("serviced", "snapshot", "import", "test-service-1.tgz")
+
+	// Output:
+	// test-service-1
+}
+
+func ExampleServicedCLI_CmdSnapshotImport_usage() {
+	InitSnapshotAPITest("serviced", "snapshot", "import")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    import - Loads a service from a snapshot file
+	//
+	// USAGE:
+	//    command import [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced snapshot import FILEPATH
+	//
+	// OPTIONS:
+}
+
+func ExampleServicedCLI_CmdSnapshotImport_err() {
+	pipeStderr(InitSnapshotAPITest, "serviced", "snapshot", "import", NilSnapshot)
+
+	// Output:
+	// received nil service
+}
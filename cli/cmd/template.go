@@ -22,7 +22,9 @@ import (
 
 	"github.com/codegangsta/cli"
 	"github.com/control-center/serviced/cli/api"
+	"github.com/control-center/serviced/domain/servicedefinition"
 	template "github.com/control-center/serviced/domain/servicetemplate"
+	"github.com/control-center/serviced/domain/servicetemplate/repository"
 	"github.com/control-center/serviced/servicedversion"
 )
 
@@ -62,6 +64,16 @@ func (c *ServicedCli) initTemplate() {
 				Action:       c.cmdTemplateDeploy,
 				Flags: []cli.Flag{
 					cli.BoolFlag{"manual-assign-ips", "Manually assign IP addresses"},
+					cli.StringSliceFlag{"set", &cli.StringSlice{}, "Set a template parameter (NAME=VALUE)"},
+					cli.StringFlag{"values", "", "JSON file of template parameter values"},
+				},
+			}, {
+				Name:        "upgrade",
+				Usage:       "Upgrades a deployment to a template",
+				Description: "serviced template upgrade DEPLOYMENTID TEMPLATEID",
+				Action:      c.cmdTemplateUpgrade,
+				Flags: []cli.Flag{
+					cli.BoolFlag{"dry-run", "Show the changes without making them"},
 				},
 			}, {
 				Name:        "compile",
@@ -72,6 +84,60 @@ func (c *ServicedCli) initTemplate() {
 					cli.GenericFlag{
 						"map", &api.ImageMap{}, "Map a given image name to another (e.g. -map zenoss/zenoss5x:latest,quay.io/zenoss-core:alpha2)"},
 				},
+			}, {
+				Name:        "export",
+				Usage:       "Builds a template from the services of a tenant",
+				Description: "serviced template export TENANTID",
+				Action:      c.cmdTemplateExport,
+				Flags: []cli.Flag{
+					cli.GenericFlag{
+						"map", &api.ImageMap{}, "Map a given image name to another (e.g. -map core5x,quay.io/zenossinc/core5x:5.0.0)"},
+				},
+			}, {
+				Name:        "schema",
+				Usage:       "Prints the JSON Schema of service definitions",
+				Description: "serviced template schema",
+				Action:      c.cmdTemplateSchema,
+			}, {
+				Name:         "lint",
+				Usage:        "Checks a directory of service definitions or a template for problems",
+				Description:  "serviced template lint PATH|TEMPLATEID",
+				BashComplete: c.printTemplatesFirst,
+				Action:       c.cmdTemplateLint,
+				Flags: []cli.Flag{
+					cli.BoolFlag{"skip-images", "Don't check that the images exist"},
+				},
+			}, {
+				Name:        "list-sources",
+				Usage:       "Lists the template sources",
+				Description: "serviced template list-sources",
+				Action:      c.cmdTemplateListSources,
+			}, {
+				Name:        "add-source",
+				Usage:       "Adds a template source",
+				Description: "serviced template add-source NAME URL",
+				Action:      c.cmdTemplateAddSource,
+				Flags: []cli.Flag{
+					cli.StringFlag{"type", repository.TypeHTTP, "Type of source: http (an index.json of template files) or git (NAME/VERSION service definition directories)"},
+				},
+			}, {
+				Name:        "remove-source",
+				Usage:       "Removes a template source",
+				Description: "serviced template remove-source NAME ...",
+				Action:      c.cmdTemplateRemoveSource,
+			}, {
+				Name:        "search",
+				Usage:       "Searches the template sources",
+				Description: "serviced template search [QUERY]",
+				Action:      c.cmdTemplateSearch,
+			}, {
+				Name:        "install",
+				Usage:       "Fetches a template from a template source and adds it",
+				Description: "serviced template install NAME[@VERSION]",
+				Action:      c.cmdTemplateInstall,
+				Flags: []cli.Flag{
+					cli.StringFlag{"source", "", "Name of the source to install from"},
+				},
 			},
 		},
 	})
@@ -219,7 +285,7 @@ func (c *ServicedCli) cmdTemplateRemove(ctx *cli.Context) {
 	}
 }
 
-// serviced template deploy TEMPLATEID POOLID DEPLOYMENTID [--manual-assign-ips]
+// serviced template deploy TEMPLATEID POOLID DEPLOYMENTID [--manual-assign-ips] [--values FILE] [--set NAME=VALUE ...]
 func (c *ServicedCli) cmdTemplateDeploy(ctx *cli.Context) {
 	args := ctx.Args()
 	if len(args) < 3 {
@@ -228,11 +294,18 @@ func (c *ServicedCli) cmdTemplateDeploy(ctx *cli.Context) {
 		return
 	}
 
+	values, err := parseTemplateValues(ctx.String("values"), ctx.StringSlice("set"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
 	cfg := api.DeployTemplateConfig{
 		ID:              args[0],
 		PoolID:          args[1],
 		DeploymentID:    args[2],
 		ManualAssignIPs: ctx.Bool("manual-assign-ips"),
+		Values:          values,
 	}
 
 	fmt.Fprintln(os.Stderr, "Deploying template - please wait...")
@@ -245,6 +318,80 @@ func (c *ServicedCli) cmdTemplateDeploy(ctx *cli.Context) {
 	}
 }
 
+// parseTemplateValues reads the template parameter values from a JSON file of
+// names to values and from NAME=VALUE settings, which take precedence
+func parseTemplateValues(filename string, settings []string) (map[string]string, error) {
+	values := make(map[string]string)
+	if filename != "" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		var fileValues map[string]interface{}
+		decoder := json.NewDecoder(file)
+		decoder.UseNumber()
+		if err := decoder.Decode(&fileValues); err != nil {
+			return nil, fmt.Errorf("could not read values from %s: %s", filename, err)
+		}
+		for name, value := range fileValues {
+			switch value.(type) {
+			case string, bool, json.Number:
+				values[name] = fmt.Sprint(value)
+			default:
+				return nil, fmt.Errorf("invalid value for %s in %s", name, filename)
+			}
+		}
+	}
+	for _, setting := range settings {
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid setting %s, expected NAME=VALUE", setting)
+		}
+		values[parts[0]] = parts[1]
+	}
+	return values, nil
+}
+
+// serviced template upgrade DEPLOYMENTID TEMPLATEID [--dry-run]
+func (c *ServicedCli) cmdTemplateUpgrade(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "upgrade")
+		return
+	}
+
+	cfg := api.UpgradeTemplateConfig{
+		ID:           args[1],
+		DeploymentID: args[0],
+		DryRun:       ctx.Bool("dry-run"),
+	}
+
+	if !cfg.DryRun {
+		fmt.Fprintln(os.Stderr, "Upgrading deployment - please wait...")
+	}
+	plan, err := c.driver.UpgradeServiceTemplate(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	} else if plan == nil || len(plan.Actions) == 0 {
+		fmt.Fprintln(os.Stderr, "deployment is up to date")
+		return
+	}
+
+	tablePlan := newtable(0, 8, 2)
+	tablePlan.printrow("ACTION", "SERVICE", "SERVICEID", "CHANGES")
+	for _, a := range plan.Actions {
+		tablePlan.printrow(a.Action, a.Path, a.ServiceID, strings.Join(a.Changes, ", "))
+	}
+	tablePlan.flush()
+	for _, snapshotID := range plan.Snapshots {
+		fmt.Fprintf(os.Stderr, "Snapshot taken before the upgrade: %s\n", snapshotID)
+	}
+}
+
 type metaTemplate struct {
 	template.ServiceTemplate
 	ServicedVersion servicedversion.ServicedVersion
@@ -305,3 +452,160 @@ func (c *ServicedCli) cmdTemplateCompile(ctx *cli.Context) {
 		}
 	}
 }
+
+// serviced template export TENANTID [[--map IMAGE,IMAGE] ...]
+func (c *ServicedCli) cmdTemplateExport(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "export")
+		return
+	}
+
+	cfg := api.ExportTemplateConfig{
+		TenantID: args[0],
+		Map:      *ctx.Generic("map").(*api.ImageMap),
+	}
+
+	if template, err := c.driver.ExportServiceTemplate(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else if template == nil {
+		fmt.Fprintln(os.Stderr, "received nil template")
+	} else if jsonTemplate, err := json.MarshalIndent(template, " ", "  "); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to marshal template: %s\n", err)
+	} else {
+		fmt.Println(string(jsonTemplate))
+	}
+}
+
+// serviced template schema
+func (c *ServicedCli) cmdTemplateSchema(ctx *cli.Context) {
+	if jsonSchema, err := json.MarshalIndent(servicedefinition.Schema(), "", "  "); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to marshal schema: %s\n", err)
+	} else {
+		fmt.Println(string(jsonSchema))
+	}
+}
+
+// serviced template lint PATH|TEMPLATEID [--skip-images]
+func (c *ServicedCli) cmdTemplateLint(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "lint")
+		return
+	}
+
+	cfg := api.LintTemplateConfig{
+		Template:   args[0],
+		SkipImages: ctx.Bool("skip-images"),
+	}
+
+	problems, err := c.driver.LintServiceTemplate(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	} else if len(problems) == 0 {
+		fmt.Fprintln(os.Stderr, "no problems found")
+		return
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+}
+
+// serviced template list-sources
+func (c *ServicedCli) cmdTemplateListSources(ctx *cli.Context) {
+	sources, err := c.driver.GetTemplateSources()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	} else if sources == nil || len(sources) == 0 {
+		fmt.Fprintln(os.Stderr, "no template sources found")
+		return
+	}
+
+	tableSource := newtable(0, 8, 2)
+	tableSource.printrow("NAME", "TYPE", "URL")
+	for _, s := range sources {
+		tableSource.printrow(s.Name, s.Type, s.URL)
+	}
+	tableSource.flush()
+}
+
+// serviced template add-source NAME URL [--type TYPE]
+func (c *ServicedCli) cmdTemplateAddSource(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "add-source")
+		return
+	}
+
+	source := repository.Source{
+		Name: args[0],
+		Type: ctx.String("type"),
+		URL:  args[1],
+	}
+	if err := c.driver.AddTemplateSource(source); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else {
+		fmt.Println(source.Name)
+	}
+}
+
+// serviced template remove-source NAME ...
+func (c *ServicedCli) cmdTemplateRemoveSource(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "remove-source")
+		return
+	}
+
+	for _, name := range args {
+		if err := c.driver.RemoveTemplateSource(name); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		} else {
+			fmt.Println(name)
+		}
+	}
+}
+
+// serviced template search [QUERY]
+func (c *ServicedCli) cmdTemplateSearch(ctx *cli.Context) {
+	entries, err := c.driver.SearchServiceTemplates(ctx.Args().First())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	} else if entries == nil || len(entries) == 0 {
+		fmt.Fprintln(os.Stderr, "no templates found")
+		return
+	}
+
+	tableEntry := newtable(0, 8, 2)
+	tableEntry.printrow("SOURCE", "TEMPLATE", "STATUS", "DESCRIPTION")
+	for _, e := range entries {
+		tableEntry.printrow(e.Source, e.Ref(), e.Status, e.Description)
+	}
+	tableEntry.flush()
+}
+
+// serviced template install NAME[@VERSION] [--source NAME]
+func (c *ServicedCli) cmdTemplateInstall(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "install")
+		return
+	}
+
+	if template, err := c.driver.InstallServiceTemplate(args[0], ctx.String("source")); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else if template == nil {
+		fmt.Fprintln(os.Stderr, "received nil template")
+	} else {
+		fmt.Println(template.ID)
+	}
+}
@@ -15,13 +15,20 @@ package cmd
 
 import (
 	"github.com/control-center/serviced/cli/api"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/domain"
 	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicedefinition"
 	template "github.com/control-center/serviced/domain/servicetemplate"
+	"github.com/control-center/serviced/domain/servicetemplate/repository"
 
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
 	"testing"
 )
 
@@ -29,7 +36,7 @@ const (
 	NilTemplate = "NilTemplate"
 )
 
-var DefaultTemplateAPITest = TemplateAPITest{templates: DefaultTestTemplates}
+var DefaultTemplateAPITest = TemplateAPITest{templates: DefaultTestTemplates, sources: DefaultTestTemplateSources}
 
 var DefaultTestTemplates = []template.ServiceTemplate{
 	{
@@ -47,6 +54,14 @@ var DefaultTestTemplates = []template.ServiceTemplate{
 	},
 }
 
+var DefaultTestTemplateSources = []repository.Source{
+	{
+		Name: "test-source-1",
+		Type: repository.TypeHTTP,
+		URL:  "http://templates.example.com/",
+	},
+}
+
 var (
 	ErrNoTemplateFound = errors.New("no templates found")
 	ErrInvalidTemplate = errors.New("invalid template")
@@ -56,6 +71,7 @@ type TemplateAPITest struct {
 	api.API
 	fail      bool
 	templates []template.ServiceTemplate
+	sources   []repository.Source
 }
 
 func InitTemplateAPITest(args ...string) {
@@ -115,6 +131,45 @@ func (t TemplateAPITest) CompileServiceTemplate(cfg api.CompileTemplateConfig) (
 	return &tpl, nil
 }
 
+func (t TemplateAPITest) ExportServiceTemplate(cfg api.ExportTemplateConfig) (*template.ServiceTemplate, error) {
+	if t.fail {
+		return nil, ErrInvalidTemplate
+	} else if cfg.TenantID == NilTemplate {
+		return nil, nil
+	}
+
+	imageID := "core"
+	if mapped, ok := cfg.Map[imageID]; ok {
+		imageID = mapped
+	}
+	tpl := template.ServiceTemplate{
+		Name: "Zenoss",
+		Services: []servicedefinition.ServiceDefinition{
+			{Name: "Zenoss", ImageID: imageID, Instances: domain.MinMax{Min: 1, Max: 1, Default: 1}},
+		},
+	}
+	return &tpl, nil
+}
+
+func (t TemplateAPITest) LintServiceTemplate(cfg api.LintTemplateConfig) ([]template.LintProblem, error) {
+	if tpl, err := t.GetServiceTemplate(cfg.Template); err != nil {
+		return nil, err
+	} else if tpl == nil {
+		return nil, ErrNoTemplateFound
+	} else if tpl.ID != "test-template-1" {
+		return nil, nil
+	}
+
+	problems := []template.LintProblem{
+		{Path: "Alpha/Zope", Message: "health check running has no interval"},
+		{Path: "Alpha/Zope", Message: "endpoint zep imports zep, which no service exports"},
+	}
+	if !cfg.SkipImages {
+		problems = append(problems, template.LintProblem{Path: "Alpha/Zope", Message: "image zenoss/core:5.0 does not exist"})
+	}
+	return problems, nil
+}
+
 func (t TemplateAPITest) DeployServiceTemplate(cfg api.DeployTemplateConfig) (*service.Service, error) {
 	tpl, err := t.GetServiceTemplate(cfg.ID)
 	if err != nil {
@@ -216,6 +271,74 @@ func ExampleServicedCLI_CmdTemplateAdd_err() {
 	InitTemplateAPITest("serviced", "template", "add")
 }
 
+func (t TemplateAPITest) UpgradeServiceTemplate(cfg api.UpgradeTemplateConfig) (*dao.ServiceTemplateUpgradePlan, error) {
+	if t.fail {
+		return nil, ErrInvalidTemplate
+	} else if tpl, err := t.GetServiceTemplate(cfg.ID); err != nil {
+		return nil, err
+	} else if tpl == nil {
+		return nil, ErrNoTemplateFound
+	}
+	plan := dao.ServiceTemplateUpgradePlan{TemplateID: cfg.ID, DeploymentID: cfg.DeploymentID}
+	if cfg.ID == "test-template-2" {
+		plan.Actions = []dao.ServiceUpgradeAction{
+			{Action: "update", Path: "Beta", ServiceID: "test-service-1", Changes: []string{"Startup", "ImageID"}},
+		}
+		if !cfg.DryRun {
+			plan.Snapshots = []string{"test-service-1_20150101-000000"}
+		}
+	}
+	return &plan, nil
+}
+
+func (t TemplateAPITest) GetTemplateSources() ([]repository.Source, error) {
+	if t.fail {
+		return nil, ErrInvalidTemplate
+	}
+	return t.sources, nil
+}
+
+func (t TemplateAPITest) AddTemplateSource(source repository.Source) error {
+	if t.fail {
+		return ErrInvalidTemplate
+	}
+	return source.Validate()
+}
+
+func (t TemplateAPITest) RemoveTemplateSource(name string) error {
+	for _, s := range t.sources {
+		if s.Name == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("template source %s not found", name)
+}
+
+func (t TemplateAPITest) SearchServiceTemplates(query string) ([]api.TemplateEntry, error) {
+	if t.fail {
+		return nil, ErrInvalidTemplate
+	}
+	var entries []api.TemplateEntry
+	for _, tpl := range t.templates {
+		entry := repository.Entry{Source: "test-source-1", Name: tpl.Name, Version: "1.0", Description: tpl.Description}
+		entries = append(entries, api.TemplateEntry{Entry: entry})
+	}
+	return entries, nil
+}
+
+func (t TemplateAPITest) InstallServiceTemplate(ref, source string) (*template.ServiceTemplate, error) {
+	if t.fail {
+		return nil, ErrInvalidTemplate
+	}
+	name, _ := repository.ParseRef(ref)
+	for i, tpl := range t.templates {
+		if tpl.Name == name {
+			return &t.templates[i], nil
+		}
+	}
+	return nil, fmt.Errorf("template %s not found", ref)
+}
+
 func ExampleServicedCLI_CmdTemplateRemove() {
 	InitTemplateAPITest("serviced", "template", "remove", "test-template-1")
 
@@ -272,6 +395,8 @@ func ExampleServicedCLI_CmdTemplateDeploy_usage() {
 	//
 	// OPTIONS:
 	//    --manual-assign-ips	Manually assign IP addresses
+	//    --set '--set option --set option'	Set a template parameter (NAME=VALUE)
+	//    --values 	JSON file of template parameter values
 }
 
 func ExampleServicedCLI_CmdTemplateDeploy_fail() {
@@ -292,6 +417,37 @@ func ExampleServicedCLI_CmdTemplateDeploy_err() {
 	// received nil service definition
 }
 
+func ExampleServicedCLI_CmdTemplateDeploy_badSetting() {
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "deploy", "--set", "workers", "test-template-1", "test-pool", "deployment-id")
+
+	// Output:
+	// invalid setting workers, expected NAME=VALUE
+}
+
+func TestServicedCLI_parseTemplateValues(t *testing.T) {
+	file, err := ioutil.TempFile("", "values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	fmt.Fprint(file, `{"workers": 4, "memory": "1G", "debug": true, "ratio": 0.5}`)
+	file.Close()
+
+	values, err := parseTemplateValues(file.Name(), []string{"workers=8", "image=zenoss/core:5.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]string{"workers": "8", "memory": "1G", "debug": "true", "ratio": "0.5", "image": "zenoss/core:5.1"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+
+	ioutil.WriteFile(file.Name(), []byte(`{"workers": [4]}`), 0644)
+	if _, err := parseTemplateValues(file.Name(), nil); err == nil {
+		t.Errorf("expected an error for a list value")
+	}
+}
+
 func TestServicedCLI_CmdTemplateCompile(t *testing.T) {
 	dir := "/path/to/template"
 
@@ -346,3 +502,256 @@ func ExampleServicedCLI_CmdTemplateCompile_err() {
 	// Output:
 	// received nil template
 }
+
+func TestServicedCLI_CmdTemplateExport(t *testing.T) {
+	output := pipe(InitTemplateAPITest, "serviced", "template", "export", "test-service-1", "--map", "core,zenoss/core:5.0.0")
+
+	var tpl template.ServiceTemplate
+	if err := json.Unmarshal(output, &tpl); err != nil {
+		t.Fatalf("could not unmarshal the exported template: %s", err)
+	}
+	if len(tpl.Services) != 1 {
+		t.Fatalf("expected 1 service, got %d", len(tpl.Services))
+	}
+	if imageID := tpl.Services[0].ImageID; imageID != "zenoss/core:5.0.0" {
+		t.Errorf("expected image zenoss/core:5.0.0, got %s", imageID)
+	}
+	if instances := tpl.Services[0].Instances.Default; instances != 1 {
+		t.Errorf("expected 1 instance, got %d", instances)
+	}
+}
+
+func ExampleServicedCLI_CmdTemplateExport_usage() {
+	InitTemplateAPITest("serviced", "template", "export")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    export - Builds a template from the services of a tenant
+	//
+	// USAGE:
+	//    command export [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced template export TENANTID
+	//
+	// OPTIONS:
+	//    --map 	`-map option -map option` Map a given image name to another (e.g. -map core5x,quay.io/zenossinc/core5x:5.0.0)
+}
+
+func ExampleServicedCLI_CmdTemplateExport_fail() {
+	DefaultTemplateAPITest.fail = true
+	defer func() { DefaultTemplateAPITest.fail = false }()
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "export", "test-service-1")
+
+	// Output:
+	// invalid template
+}
+
+func ExampleServicedCLI_CmdTemplateExport_err() {
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "export", NilTemplate)
+
+	// Output:
+	// received nil template
+}
+
+func ExampleServicedCLI_CmdTemplateLint() {
+	InitTemplateAPITest("serviced", "template", "lint", "test-template-1")
+
+	// Output:
+	// Alpha/Zope: health check running has no interval
+	// Alpha/Zope: endpoint zep imports zep, which no service exports
+	// Alpha/Zope: image zenoss/core:5.0 does not exist
+}
+
+func ExampleServicedCLI_CmdTemplateLint_skipImages() {
+	InitTemplateAPITest("serviced", "template", "lint", "--skip-images", "test-template-1")
+
+	// Output:
+	// Alpha/Zope: health check running has no interval
+	// Alpha/Zope: endpoint zep imports zep, which no service exports
+}
+
+func ExampleServicedCLI_CmdTemplateLint_usage() {
+	InitTemplateAPITest("serviced", "template", "lint")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    lint - Checks a directory of service definitions or a template for problems
+	//
+	// USAGE:
+	//    command lint [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced template lint PATH|TEMPLATEID
+	//
+	// OPTIONS:
+	//    --skip-images	Don't check that the images exist
+}
+
+func ExampleServicedCLI_CmdTemplateLint_ok() {
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "lint", "test-template-2")
+
+	// Output:
+	// no problems found
+}
+
+func ExampleServicedCLI_CmdTemplateLint_fail() {
+	DefaultTemplateAPITest.fail = true
+	defer func() { DefaultTemplateAPITest.fail = false }()
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "lint", "test-template-1")
+
+	// Output:
+	// invalid template
+}
+
+func ExampleServicedCLI_CmdTemplateListSources() {
+	// Gofmt cleans up the spaces at the end of each row
+	InitTemplateAPITest("serviced", "template", "list-sources")
+}
+
+func ExampleServicedCLI_CmdTemplateListSources_err() {
+	DefaultTemplateAPITest.sources = nil
+	defer func() { DefaultTemplateAPITest.sources = DefaultTestTemplateSources }()
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "list-sources")
+
+	// Output:
+	// no template sources found
+}
+
+func ExampleServicedCLI_CmdTemplateAddSource() {
+	InitTemplateAPITest("serviced", "template", "add-source", "test-source-2", "/opt/templates", "--type", "git")
+
+	// Output:
+	// test-source-2
+}
+
+func ExampleServicedCLI_CmdTemplateAddSource_usage() {
+	InitTemplateAPITest("serviced", "template", "add-source", "test-source-2")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    add-source - Adds a template source
+	//
+	// USAGE:
+	//    command add-source [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced template add-source NAME URL
+	//
+	// OPTIONS:
+	//    --type 'http'	Type of source: http (an index.json of template files) or git (NAME/VERSION service definition directories)
+}
+
+func ExampleServicedCLI_CmdTemplateAddSource_err() {
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "add-source", "test-source-2", "/opt/templates", "--type", "svn")
+
+	// Output:
+	// repository: invalid template source
+}
+
+func ExampleServicedCLI_CmdTemplateRemoveSource() {
+	InitTemplateAPITest("serviced", "template", "remove-source", "test-source-1")
+
+	// Output:
+	// test-source-1
+}
+
+func ExampleServicedCLI_CmdTemplateRemoveSource_err() {
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "remove-source", "test-source-0")
+
+	// Output:
+	// test-source-0: template source test-source-0 not found
+}
+
+func ExampleServicedCLI_CmdTemplateSearch() {
+	// Gofmt cleans up the spaces at the end of each row
+	InitTemplateAPITest("serviced", "template", "search", "alpha")
+}
+
+func ExampleServicedCLI_CmdTemplateSearch_fail() {
+	DefaultTemplateAPITest.fail = true
+	defer func() { DefaultTemplateAPITest.fail = false }()
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "search")
+
+	// Output:
+	// invalid template
+}
+
+func ExampleServicedCLI_CmdTemplateInstall() {
+	InitTemplateAPITest("serviced", "template", "install", "Alpha@1.0")
+
+	// Output:
+	// test-template-1
+}
+
+func ExampleServicedCLI_CmdTemplateInstall_usage() {
+	InitTemplateAPITest("serviced", "template", "install")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    install - Fetches a template from a template source and adds it
+	//
+	// USAGE:
+	//    command install [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced template install NAME[@VERSION]
+	//
+	// OPTIONS:
+	//    --source 	Name of the source to install from
+}
+
+func ExampleServicedCLI_CmdTemplateInstall_err() {
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "install", "Delta@1.0")
+
+	// Output:
+	// template Delta@1.0 not found
+}
+
+func ExampleServicedCLI_CmdTemplateUpgrade() {
+	// Gofmt cleans up the spaces at the end of each row
+	InitTemplateAPITest("serviced", "template", "upgrade", "deployment-id", "test-template-2", "--dry-run")
+}
+
+func ExampleServicedCLI_CmdTemplateUpgrade_usage() {
+	InitTemplateAPITest("serviced", "template", "upgrade", "deployment-id")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    upgrade - Upgrades a deployment to a template
+	//
+	// USAGE:
+	//    command upgrade [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced template upgrade DEPLOYMENTID TEMPLATEID
+	//
+	// OPTIONS:
+	//    --dry-run	Show the changes without making them
+}
+
+func ExampleServicedCLI_CmdTemplateUpgrade_fail() {
+	DefaultTemplateAPITest.fail = true
+	defer func() { DefaultTemplateAPITest.fail = false }()
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "upgrade", "deployment-id", "test-template-2", "--dry-run")
+
+	// Output:
+	// invalid template
+}
+
+func ExampleServicedCLI_CmdTemplateUpgrade_err() {
+	pipeStderr(InitTemplateAPITest, "serviced", "template", "upgrade", "deployment-id", "test-template-1", "--dry-run")
+
+	// Output:
+	// deployment is up to date
+}
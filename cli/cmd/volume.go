@@ -0,0 +1,126 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/control-center/serviced/dao"
+)
+
+// initVolume is the initializer for serviced volume
+func (c *ServicedCli) initVolume() {
+	c.app.Commands = append(c.app.Commands, cli.Command{
+		Name:        "volume",
+		Usage:       "Administers tenant volumes",
+		Description: "",
+		Subcommands: []cli.Command{
+			{
+				Name:        "status",
+				Usage:       "Displays the disk usage of the tenant volumes",
+				Description: "serviced volume status",
+				Action:      c.cmdVolumeStatus,
+				Flags: []cli.Flag{
+					cli.BoolFlag{"verbose, v", "Show JSON format"},
+				},
+			}, {
+				Name:        "limit",
+				Usage:       "Sets the limits of a tenant volume",
+				Description: "serviced volume limit TENANTID [--soft SIZE] [--hard SIZE]",
+				Action:      c.cmdVolumeLimit,
+				Flags: []cli.Flag{
+					cli.StringFlag{"soft", "", "Size after which warnings are logged (e.g. 100G), 0 for the daemon default"},
+					cli.StringFlag{"hard", "", "Size after which snapshots are refused (e.g. 200G), 0 for the daemon default"},
+				},
+			},
+		},
+	})
+}
+
+// serviced volume status [--verbose, -v]
+func (c *ServicedCli) cmdVolumeStatus(ctx *cli.Context) {
+	status, err := c.driver.GetVolumeStatus()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	} else if status == nil || len(status) == 0 {
+		fmt.Fprintln(os.Stderr, "no volumes found")
+		return
+	}
+
+	if ctx.Bool("verbose") {
+		if jsonStatus, err := json.MarshalIndent(status, " ", "  "); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to marshal volume status: %s", err)
+		} else {
+			fmt.Println(string(jsonStatus))
+		}
+	} else {
+		tableStatus := newtable(0, 8, 2)
+		tableStatus.printrow("TENANT", "NAME", "DRIVER", "USED", "SOFT LIMIT", "HARD LIMIT", "STATUS")
+		for _, s := range status {
+			tableStatus.printrow(s.TenantID, s.Name, s.Driver, bytesize(s.UsedBytes), bytesize(s.SoftLimit), bytesize(s.HardLimit), volumeState(s))
+		}
+		tableStatus.flush()
+	}
+}
+
+// serviced volume limit TENANTID [--soft SIZE] [--hard SIZE]
+func (c *ServicedCli) cmdVolumeLimit(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 1 || (ctx.String("soft") == "" && ctx.String("hard") == "") {
+		fmt.Printf("Incorrect Usage.\n\n")
+		cli.ShowCommandHelp(ctx, "limit")
+		return
+	}
+
+	if svc, err := c.driver.SetVolumeLimits(args[0], ctx.String("soft"), ctx.String("hard")); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else if svc == nil {
+		fmt.Fprintln(os.Stderr, "received nil service")
+	} else {
+		fmt.Println(svc.ID)
+	}
+}
+
+// volumeState describes a volume's usage with respect to its limits
+func volumeState(s dao.VolumeStatus) string {
+	switch {
+	case s.OverHardLimit():
+		return "over hard limit"
+	case s.OverSoftLimit():
+		return "over soft limit"
+	default:
+		return "ok"
+	}
+}
+
+// bytesize formats a number of bytes using binary units, or "-" for zero
+// limits
+func bytesize(bytes uint64) string {
+	if bytes == 0 {
+		return "-"
+	}
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	size, i := float64(bytes), 0
+	for ; size >= 1024 && i < len(units)-1; i++ {
+		size /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", bytes, units[0])
+	}
+	return fmt.Sprintf("%.1f%s", size, units[i])
+}
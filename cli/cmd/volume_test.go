@@ -0,0 +1,165 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/control-center/serviced/cli/api"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/domain/service"
+)
+
+var DefaultVolumeAPITest = VolumeAPITest{status: DefaultTestVolumeStatus}
+
+var DefaultTestVolumeStatus = []dao.VolumeStatus{
+	{
+		TenantID:  "test-tenant-1",
+		Name:      "Zenoss",
+		Driver:    "rsync",
+		Path:      "/opt/serviced/var/volumes/test-tenant-1",
+		UsedBytes: 1536,
+	}, {
+		TenantID:  "test-tenant-2",
+		Name:      "Zenoss.core",
+		Driver:    "btrfs",
+		Path:      "/opt/serviced/var/volumes/test-tenant-2",
+		UsedBytes: 3 << 30,
+		SoftLimit: 2 << 30,
+		HardLimit: 4 << 30,
+	},
+}
+
+var ErrInvalidVolume = errors.New("invalid volume")
+
+type VolumeAPITest struct {
+	api.API
+	fail   bool
+	status []dao.VolumeStatus
+}
+
+func InitVolumeAPITest(args ...string) {
+	New(DefaultVolumeAPITest).Run(args)
+}
+
+func (t VolumeAPITest) GetVolumeStatus() ([]dao.VolumeStatus, error) {
+	if t.fail {
+		return nil, ErrInvalidVolume
+	}
+	return t.status, nil
+}
+
+func (t VolumeAPITest) SetVolumeLimits(tenantID, softLimit, hardLimit string) (*service.Service, error) {
+	for _, s := range t.status {
+		if s.TenantID == tenantID {
+			return &service.Service{ID: tenantID}, nil
+		}
+	}
+	return nil, ErrInvalidVolume
+}
+
+func TestServicedCLI_CmdVolumeStatus_verbose(t *testing.T) {
+	var actual []dao.VolumeStatus
+	output := pipe(InitVolumeAPITest, "serviced", "volume", "status", "--verbose")
+	if err := json.Unmarshal(output, &actual); err != nil {
+		t.Fatalf("error unmarshalling volume status: %s", err)
+	}
+
+	if !reflect.DeepEqual(actual, DefaultTestVolumeStatus) {
+		t.Fatalf("\ngot:\n%+v\nwant:\n%+v", actual, DefaultTestVolumeStatus)
+	}
+}
+
+func TestServicedCLI_volumeState(t *testing.T) {
+	expected := []string{"ok", "over soft limit"}
+	for i, s := range DefaultTestVolumeStatus {
+		if actual := volumeState(s); actual != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], actual)
+		}
+	}
+
+	s := DefaultTestVolumeStatus[1]
+	s.UsedBytes = s.HardLimit + 1
+	if actual := volumeState(s); actual != "over hard limit" {
+		t.Errorf("Expected %s, got %s", "over hard limit", actual)
+	}
+}
+
+func TestServicedCLI_bytesize(t *testing.T) {
+	for bytes, expected := range map[uint64]string{
+		0:       "-",
+		512:     "512B",
+		1536:    "1.5KiB",
+		3 << 30: "3.0GiB",
+	} {
+		if actual := bytesize(bytes); actual != expected {
+			t.Errorf("Expected %s for %d, got %s", expected, bytes, actual)
+		}
+	}
+}
+
+func ExampleServicedCLI_CmdVolumeStatus_fail() {
+	DefaultVolumeAPITest.fail = true
+	defer func() { DefaultVolumeAPITest.fail = false }()
+	pipeStderr(InitVolumeAPITest, "serviced", "volume", "status")
+
+	// Output:
+	// invalid volume
+}
+
+func ExampleServicedCLI_CmdVolumeStatus_err() {
+	DefaultVolumeAPITest.status = nil
+	defer func() { DefaultVolumeAPITest.status = DefaultTestVolumeStatus }()
+	pipeStderr(InitVolumeAPITest, "serviced", "volume", "status")
+
+	// Output:
+	// no volumes found
+}
+
+func ExampleServicedCLI_CmdVolumeLimit() {
+	InitVolumeAPITest("serviced", "volume", "limit", "test-tenant-1", "--soft", "100G", "--hard", "200G")
+
+	// Output:
+	// test-tenant-1
+}
+
+func ExampleServicedCLI_CmdVolumeLimit_usage() {
+	InitVolumeAPITest("serviced", "volume", "limit", "test-tenant-1")
+
+	// Output:
+	// Incorrect Usage.
+	//
+	// NAME:
+	//    limit - Sets the limits of a tenant volume
+	//
+	// USAGE:
+	//    command limit [command options] [arguments...]
+	//
+	// DESCRIPTION:
+	//    serviced volume limit TENANTID [--soft SIZE] [--hard SIZE]
+	//
+	// OPTIONS:
+	//    --soft 	Size after which warnings are logged (e.g. 100G), 0 for the daemon default
+	//    --hard 	Size after which snapshots are refused (e.g. 200G), 0 for the daemon default
+}
+
+func ExampleServicedCLI_CmdVolumeLimit_err() {
+	pipeStderr(InitVolumeAPITest, "serviced", "volume", "limit", "test-tenant-0", "--hard", "200G")
+
+	// Output:
+	// invalid volume
+}
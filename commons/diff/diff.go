@@ -0,0 +1,81 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff compares and merges text line by line, for config files.
+package diff
+
+import (
+	"strings"
+)
+
+// Lines splits text into lines, keeping the line endings
+func Lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// match returns, for each line of a, the index of the line of b it is matched
+// to in a longest common subsequence of a and b, or -1 if it is not matched.
+func match(a, b []string) []int {
+	// lcs[i][j] is the length of the lcs of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) {
+		if j < len(b) && a[i] == b[j] {
+			matches[i] = j
+			i++
+			j++
+		} else if j < len(b) && lcs[i][j+1] > lcs[i+1][j] {
+			j++
+		} else {
+			matches[i] = -1
+			i++
+		}
+	}
+	return matches
+}
+
+// equal compares two sets of lines
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
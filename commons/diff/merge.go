@@ -0,0 +1,78 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"strings"
+)
+
+// Merge3 merges the changes that mine and theirs each made to base.  Lines
+// that both of them changed differently are conflicts, which are written
+// between conflict markers labeled with mineLabel and theirsLabel.  Returns
+// the merged text and the number of conflicts.
+func Merge3(base, mine, theirs, mineLabel, theirsLabel string) (string, int) {
+	baseLines, mineLines, theirsLines := Lines(base), Lines(mine), Lines(theirs)
+	mineMatches := match(baseLines, mineLines)
+	theirsMatches := match(baseLines, theirsLines)
+
+	var merged []string
+	conflicts := 0
+	i, m, t := 0, 0, 0
+	for {
+		// find the next base line that is unchanged in both versions
+		j := i
+		for j < len(baseLines) && (mineMatches[j] < 0 || theirsMatches[j] < 0) {
+			j++
+		}
+		mineEnd, theirsEnd := len(mineLines), len(theirsLines)
+		if j < len(baseLines) {
+			mineEnd, theirsEnd = mineMatches[j], theirsMatches[j]
+		}
+
+		baseChunk := baseLines[i:j]
+		mineChunk, theirsChunk := mineLines[m:mineEnd], theirsLines[t:theirsEnd]
+		switch {
+		case equal(mineChunk, baseChunk), equal(mineChunk, theirsChunk):
+			merged = append(merged, theirsChunk...)
+		case equal(theirsChunk, baseChunk):
+			merged = append(merged, mineChunk...)
+		default:
+			conflicts++
+			merged = append(merged, "<<<<<<< "+mineLabel+"\n")
+			merged = append(merged, terminate(mineChunk)...)
+			merged = append(merged, "=======\n")
+			merged = append(merged, terminate(theirsChunk)...)
+			merged = append(merged, ">>>>>>> "+theirsLabel+"\n")
+		}
+
+		if j == len(baseLines) {
+			break
+		}
+		merged = append(merged, baseLines[j])
+		i, m, t = j+1, mineEnd+1, theirsEnd+1
+	}
+	return strings.Join(merged, ""), conflicts
+}
+
+// terminate makes sure that the last line ends with a newline, so that it
+// doesn't run into a conflict marker
+func terminate(lines []string) []string {
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		result := make([]string, n)
+		copy(result, lines)
+		result[n-1] += "\n"
+		return result
+	}
+	return lines
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"testing"
+)
+
+const base = `[main]
+threads = 4
+timeout = 30
+log = /var/log/app.log
+`
+
+func TestMerge3(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		mine      string
+		theirs    string
+		expected  string
+		conflicts int
+	}{
+		{
+			name:     "unchanged",
+			mine:     base,
+			theirs:   base,
+			expected: base,
+		}, {
+			name:     "only mine changed",
+			mine:     "[main]\nthreads = 8\ntimeout = 30\nlog = /var/log/app.log\n",
+			theirs:   base,
+			expected: "[main]\nthreads = 8\ntimeout = 30\nlog = /var/log/app.log\n",
+		}, {
+			name:     "only theirs changed",
+			mine:     base,
+			theirs:   "[main]\nthreads = 4\ntimeout = 60\nlog = /var/log/app.log\n",
+			expected: "[main]\nthreads = 4\ntimeout = 60\nlog = /var/log/app.log\n",
+		}, {
+			name:     "different lines changed",
+			mine:     "[main]\nthreads = 8\ntimeout = 30\nlog = /var/log/app.log\n",
+			theirs:   "[main]\nthreads = 4\ntimeout = 30\nlog = /var/log/app.log\nretries = 3\n",
+			expected: "[main]\nthreads = 8\ntimeout = 30\nlog = /var/log/app.log\nretries = 3\n",
+		}, {
+			name:     "same change",
+			mine:     "[main]\nthreads = 8\ntimeout = 30\nlog = /var/log/app.log\n",
+			theirs:   "[main]\nthreads = 8\ntimeout = 30\nlog = /var/log/app.log\n",
+			expected: "[main]\nthreads = 8\ntimeout = 30\nlog = /var/log/app.log\n",
+		}, {
+			name:      "conflict",
+			mine:      "[main]\nthreads = 8\ntimeout = 30\nlog = /var/log/app.log\n",
+			theirs:    "[main]\nthreads = 16\ntimeout = 30\nlog = /var/log/app.log",
+			expected:  "[main]\n<<<<<<< mine\nthreads = 8\n=======\nthreads = 16\n>>>>>>> theirs\ntimeout = 30\nlog = /var/log/app.log",
+			conflicts: 1,
+		},
+	} {
+		merged, conflicts := Merge3(base, tc.mine, tc.theirs, "mine", "theirs")
+		if merged != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, merged)
+		}
+		if conflicts != tc.conflicts {
+			t.Errorf("%s: expected %d conflicts, got %d", tc.name, tc.conflicts, conflicts)
+		}
+	}
+}
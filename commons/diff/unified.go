@@ -0,0 +1,122 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// edit is a line of a diff: kept (' '), removed ('-') or added ('+')
+type edit struct {
+	op   byte
+	line string
+}
+
+// Unified returns the changes from a to b in unified diff format, with
+// context lines of context around each change.  Returns an empty string if a
+// and b are the same.
+func Unified(a, b, aLabel, bLabel string, context int) string {
+	aLines, bLines := Lines(a), Lines(b)
+	matches := match(aLines, bLines)
+
+	var edits []edit
+	i, j := 0, 0
+	for i < len(aLines) || j < len(bLines) {
+		switch {
+		case i < len(aLines) && matches[i] < 0:
+			edits = append(edits, edit{'-', aLines[i]})
+			i++
+		case j < len(bLines) && (i == len(aLines) || j < matches[i]):
+			edits = append(edits, edit{'+', bLines[j]})
+			j++
+		default:
+			edits = append(edits, edit{' ', aLines[i]})
+			i++
+			j++
+		}
+	}
+
+	var buffer bytes.Buffer
+	for start := 0; start < len(edits); {
+		// find the next change
+		for start < len(edits) && edits[start].op == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+
+		// extend the hunk while the changes are close enough together
+		end := start + 1
+		for k := end; k < len(edits) && k-end <= 2*context; k++ {
+			if edits[k].op != ' ' {
+				end = k + 1
+			}
+		}
+		first, last := start-context, end+context
+		if first < 0 {
+			first = 0
+		}
+		if last > len(edits) {
+			last = len(edits)
+		}
+
+		if buffer.Len() == 0 {
+			fmt.Fprintf(&buffer, "--- %s\n+++ %s\n", aLabel, bLabel)
+		}
+		aStart, bStart := 1, 1
+		for _, e := range edits[:first] {
+			if e.op != '+' {
+				aStart++
+			}
+			if e.op != '-' {
+				bStart++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, e := range edits[first:last] {
+			if e.op != '+' {
+				aCount++
+			}
+			if e.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&buffer, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, e := range edits[first:last] {
+			buffer.WriteByte(e.op)
+			buffer.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				buffer.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = last
+	}
+	return buffer.String()
+}
+
+// hunkRange formats the start and length of a hunk, where an empty hunk
+// starts at the line before it
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		a        string
+		b        string
+		expected string
+	}{
+		{
+			name:     "unchanged",
+			a:        base,
+			b:        base,
+			expected: "",
+		}, {
+			name:     "changed line",
+			a:        base,
+			b:        "[main]\nthreads = 8\ntimeout = 30\nlog = /var/log/app.log\n",
+			expected: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n [main]\n-threads = 4\n+threads = 8\n timeout = 30\n log = /var/log/app.log\n",
+		}, {
+			name:     "added line",
+			a:        base,
+			b:        base + "retries = 3",
+			expected: "--- a\n+++ b\n@@ -2,3 +2,4 @@\n threads = 4\n timeout = 30\n log = /var/log/app.log\n+retries = 3\n\\ No newline at end of file\n",
+		}, {
+			name:     "new file",
+			a:        "",
+			b:        "threads = 4\n",
+			expected: "--- a\n+++ b\n@@ -0,0 +1 @@\n+threads = 4\n",
+		}, {
+			name:     "separate hunks",
+			a:        "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:        "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			expected: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+	} {
+		if actual := Unified(tc.a, tc.b, "a", "b", 3); actual != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, actual)
+		}
+	}
+}
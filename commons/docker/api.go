@@ -16,6 +16,7 @@ package docker
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 	"regexp"
@@ -23,6 +24,7 @@ import (
 	"time"
 
 	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/commons/layer"
 	"github.com/zenoss/glog"
 	dockerclient "github.com/zenoss/go-dockerclient"
 )
@@ -530,6 +532,110 @@ func (img *Image) Delete() error {
 	return dc.RemoveImage(img.ID.String())
 }
 
+// DanglingImages returns the UUIDs of the untagged images in the local
+// repository
+func DanglingImages() ([]string, error) {
+	dc, err := dockerclient.NewClient(dockerep)
+	if err != nil {
+		return nil, err
+	}
+	imgs, err := dc.ListImages(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp []string
+	for _, img := range imgs {
+		if len(img.RepoTags) == 0 || (len(img.RepoTags) == 1 && img.RepoTags[0] == "<none>:<none>") {
+			resp = append(resp, img.ID)
+		}
+	}
+	return resp, nil
+}
+
+// RemoveImage removes an image from the local repository by its UUID
+func RemoveImage(uuid string) error {
+	dc, err := dockerclient.NewClient(dockerep)
+	if err != nil {
+		return err
+	}
+	return dc.RemoveImage(uuid)
+}
+
+// DeleteRegistryTag removes the tag of an image from its registry.  Layers
+// that are no longer tagged are left to the registry to clean up.
+func DeleteRegistryTag(repotag string) error {
+	url, err := registryTagURL(repotag)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("registry returned %s deleting %s", resp.Status, repotag)
+	}
+}
+
+// dockerHub is the registry of the images that are not named with a registry
+var dockerHub = "https://index.docker.io"
+
+// RegistryTagExists reports whether the tag of an image is in its registry,
+// or in the Docker Hub if the image has no registry, without pulling the
+// image
+func RegistryTagExists(repotag string) (bool, error) {
+	url, err := registryTagURL(repotag)
+	if err != nil {
+		return false, err
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("registry returned %s looking up %s", resp.Status, repotag)
+	}
+}
+
+// registryTagURL returns the url of the tag of an image in its registry
+func registryTagURL(repotag string) (string, error) {
+	iid, err := commons.ParseImageID(repotag)
+	if err != nil {
+		return "", err
+	}
+	registry := dockerHub
+	if iid.Registry() != "" {
+		registry = "http://" + iid.Registry()
+	}
+
+	user := iid.User
+	if user == "" {
+		user = "library"
+	}
+	tag := iid.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	return fmt.Sprintf("%s/v1/repositories/%s/%s/tags/%s", registry, user, iid.Repo, tag), nil
+}
+
 // Tag tags an image in the local repository
 func (img *Image) Tag(tag string) (*Image, error) {
 
@@ -598,6 +704,17 @@ func (img *Image) History() ([]*dockerclient.Image, error) {
 	return ImageHistory(img.UUID)
 }
 
+// SquashImage flattens the layers of an image above downToLayer into one and
+// tags the result with the image's repotag.  Returns the UUID of the squashed
+// image.
+func SquashImage(repotag, downToLayer string) (string, error) {
+	dc, err := dockerclient.NewClient(dockerep)
+	if err != nil {
+		return "", err
+	}
+	return layer.Squash(dc, repotag, downToLayer, "", "")
+}
+
 func onContainerEvent(event, id string, action ContainerActionFunc) error {
 	ec := make(chan error, 1)
 
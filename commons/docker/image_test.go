@@ -19,6 +19,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"regexp"
@@ -344,3 +345,42 @@ WaitForCompletion:
 	}
 	return regid, result
 }
+
+func TestRegistryTagExists(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != "GET":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/v1/repositories/library/busybox/tags/latest":
+			fmt.Fprint(w, `"8c2e06607696bd4afb3d03b687e361cc43cf8ec1a4a725bc96e39f05ba97dd55"`)
+		case r.URL.Path == "/v1/repositories/zenoss/core/tags/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+	host := strings.TrimPrefix(registry.URL, "http://")
+
+	if ok, err := RegistryTagExists(host + "/busybox"); err != nil || !ok {
+		t.Errorf("expected busybox to exist, got %v, %v", ok, err)
+	}
+	if ok, err := RegistryTagExists(host + "/zenoss/core:5.0"); err != nil || ok {
+		t.Errorf("expected zenoss/core:5.0 not to exist, got %v, %v", ok, err)
+	}
+	if _, err := RegistryTagExists(host + "/zenoss/core:broken"); err == nil {
+		t.Errorf("expected an error for a broken registry")
+	}
+
+	// images without a registry are looked up in the Docker Hub
+	defer func(hub string) {
+		dockerHub = hub
+	}(dockerHub)
+	dockerHub = registry.URL
+	if ok, err := RegistryTagExists("busybox"); err != nil || !ok {
+		t.Errorf("expected busybox to exist in the hub, got %v, %v", ok, err)
+	}
+	if ok, err := RegistryTagExists("zenoss/core:5.0"); err != nil || ok {
+		t.Errorf("expected zenoss/core:5.0 not to exist in the hub, got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonschema generates JSON Schemas from go types and checks YAML and
+// JSON documents against them.
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"math"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Draft is the version of JSON Schema of the generated schemas
+const Draft = "http://json-schema.org/draft-04/schema#"
+
+// Schema is a JSON Schema, or the part of one that describes a value
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// Types of values
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Reflect returns the schema of the JSON encoding of a type.  Structs are
+// added to the definitions of the schema by name, so that types can refer to
+// themselves.  Types that encode themselves can't be reflected, so their
+// schemas are looked up in custom; any value is allowed if they aren't there.
+func Reflect(t reflect.Type, custom map[reflect.Type]*Schema) *Schema {
+	r := &reflector{
+		custom:      custom,
+		definitions: make(map[string]*Schema),
+		names:       make(map[reflect.Type]string),
+	}
+	s := r.reflect(t)
+	s.Schema = Draft
+	s.Definitions = r.definitions
+	return s
+}
+
+type reflector struct {
+	custom      map[reflect.Type]*Schema
+	definitions map[string]*Schema
+	names       map[reflect.Type]string
+}
+
+func (r *reflector) reflect(t reflect.Type) *Schema {
+	if s, ok := r.custom[t]; ok {
+		c := *s
+		return &c
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: TypeString, Format: "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case reflect.PtrTo(t).Implements(textUnmarshalerType):
+		return &Schema{Type: TypeString}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return r.reflect(t.Elem())
+	case reflect.Struct:
+		return &Schema{Ref: "#/definitions/" + r.define(t)}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return &Schema{}
+		}
+		return &Schema{Type: TypeObject, AdditionalProperties: r.reflect(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoded in base64
+			return &Schema{Type: TypeString}
+		}
+		return &Schema{Type: TypeArray, Items: r.reflect(t.Elem())}
+	case reflect.Bool:
+		return &Schema{Type: TypeBoolean}
+	case reflect.Int8:
+		return integer(math.MinInt8, math.MaxInt8)
+	case reflect.Int16:
+		return integer(math.MinInt16, math.MaxInt16)
+	case reflect.Int32:
+		return integer(math.MinInt32, math.MaxInt32)
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: TypeInteger}
+	case reflect.Uint8:
+		return integer(0, math.MaxUint8)
+	case reflect.Uint16:
+		return integer(0, math.MaxUint16)
+	case reflect.Uint32:
+		return integer(0, math.MaxUint32)
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		min := 0.0
+		return &Schema{Type: TypeInteger, Minimum: &min}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: TypeNumber}
+	case reflect.String:
+		return &Schema{Type: TypeString}
+	}
+	// interfaces, and anything else that can't be encoded
+	return &Schema{}
+}
+
+func integer(min, max float64) *Schema {
+	return &Schema{Type: TypeInteger, Minimum: &min, Maximum: &max}
+}
+
+// define adds a struct to the definitions and returns its name
+func (r *reflector) define(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := r.definitions[name]; taken || name == "" {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	r.names[t] = name
+
+	s := &Schema{Type: TypeObject, Properties: make(map[string]*Schema)}
+	r.definitions[name] = s
+	r.properties(t, s.Properties)
+	return name
+}
+
+// properties adds the schemas of the fields of a struct, including the fields
+// of embedded structs, as json does
+func (r *reflector) properties(t reflect.Type, properties map[string]*Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.properties(ft, properties)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = r.reflect(field.Type)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type level string
+
+type limits struct {
+	Min, Max int
+}
+
+type node struct {
+	limits
+	Name     string
+	Port     uint16 `json:"port"`
+	Weight   float64
+	Enabled  bool
+	Level    level
+	Tags     []string
+	Labels   map[string]string
+	Children []node
+	Created  time.Time
+	Data     interface{}
+	Ignored  string `json:"-"`
+	private  string
+}
+
+var nodeSchema = Reflect(reflect.TypeOf(node{}), map[reflect.Type]*Schema{
+	reflect.TypeOf(level("")): {Type: TypeString, Enum: []string{"low", "high"}},
+})
+
+func TestReflect(t *testing.T) {
+	zero, max := 0.0, 65535.0
+	expected := &Schema{
+		Schema: Draft,
+		Ref:    "#/definitions/node",
+		Definitions: map[string]*Schema{
+			"node": {
+				Type: TypeObject,
+				Properties: map[string]*Schema{
+					"Min":      {Type: TypeInteger},
+					"Max":      {Type: TypeInteger},
+					"Name":     {Type: TypeString},
+					"port":     {Type: TypeInteger, Minimum: &zero, Maximum: &max},
+					"Weight":   {Type: TypeNumber},
+					"Enabled":  {Type: TypeBoolean},
+					"Level":    {Type: TypeString, Enum: []string{"low", "high"}},
+					"Tags":     {Type: TypeArray, Items: &Schema{Type: TypeString}},
+					"Labels":   {Type: TypeObject, AdditionalProperties: &Schema{Type: TypeString}},
+					"Children": {Type: TypeArray, Items: &Schema{Ref: "#/definitions/node"}},
+					"Created":  {Type: TypeString, Format: "date-time"},
+					"Data":     {},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(nodeSchema, expected) {
+		actualJSON, _ := json.MarshalIndent(nodeSchema, "", "  ")
+		expectedJSON, _ := json.MarshalIndent(expected, "", "  ")
+		t.Errorf("expected:\n%s\ngot:\n%s", expectedJSON, actualJSON)
+	}
+}
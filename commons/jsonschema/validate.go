@@ -0,0 +1,244 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error is a value of a document that doesn't match its schema
+type Error struct {
+	Line    int    // Line of the value in the document
+	Path    string // Path of the value, e.g. Services[0].Endpoints[1].PortNumber
+	Message string
+}
+
+func (e Error) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+}
+
+// Unmarshal checks a YAML or JSON document against the schema and, if it
+// matches, decodes it into v like a JSON document.  Returns the values that
+// don't match, in the order of the document, or an error if the document
+// can't be parsed.  Scalars are accepted as strings wherever the schema
+// expects a string, so that YAML authors don't have to quote values such as
+// file permissions.
+func (s *Schema) Unmarshal(data []byte, v interface{}) ([]Error, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		// tabs can't indent YAML, but JSON doesn't allow them in strings, so
+		// they are all whitespace
+		data = bytes.Replace(data, []byte("\t"), []byte(" "), -1)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	} else if len(doc.Content) == 0 {
+		return nil, errors.New("empty document")
+	}
+
+	c := &checker{root: s}
+	value := c.check(s, doc.Content[0], "")
+	if len(c.errors) > 0 {
+		return c.errors, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return nil, json.Unmarshal(encoded, v)
+}
+
+// checker converts the nodes of a document to JSON values and collects the
+// ones that don't match the schema
+type checker struct {
+	root   *Schema
+	errors []Error
+}
+
+func (c *checker) fail(node *yaml.Node, path, format string, args ...interface{}) {
+	c.errors = append(c.errors, Error{Line: node.Line, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// resolve follows the reference of a schema to a definition
+func (c *checker) resolve(s *Schema) *Schema {
+	for s.Ref != "" {
+		def, ok := c.root.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+		if !ok {
+			return &Schema{}
+		}
+		s = def
+	}
+	return s
+}
+
+func (c *checker) check(s *Schema, node *yaml.Node, path string) interface{} {
+	s = c.resolve(s)
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+
+	switch s.Type {
+	case TypeObject:
+		if node.Kind != yaml.MappingNode {
+			c.fail(node, path, "expected an object")
+			return nil
+		}
+		object := make(map[string]interface{})
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, value := node.Content[i].Value, node.Content[i+1]
+			if prop := s.property(name); prop != nil {
+				object[name] = c.check(prop, value, join(path, name))
+			} else if s.AdditionalProperties != nil {
+				object[name] = c.check(s.AdditionalProperties, value, fmt.Sprintf("%s[%q]", path, name))
+			} else {
+				// ignored when it is decoded
+				object[name] = c.check(&Schema{}, value, join(path, name))
+			}
+		}
+		return object
+	case TypeArray:
+		if node.Kind != yaml.SequenceNode {
+			c.fail(node, path, "expected a list")
+			return nil
+		}
+		if s.MaxItems != nil && len(node.Content) > *s.MaxItems {
+			c.fail(node, path, "must have at most %d items", *s.MaxItems)
+		}
+		array := make([]interface{}, len(node.Content))
+		for i, item := range node.Content {
+			array[i] = c.check(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+		return array
+	case TypeString:
+		if node.Kind != yaml.ScalarNode {
+			c.fail(node, path, "expected a string")
+			return nil
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, node.Value) {
+			c.fail(node, path, "%q is not one of %s", node.Value, strings.Join(quote(s.Enum), ", "))
+		}
+		return node.Value
+	case TypeInteger, TypeNumber:
+		expected := "a number"
+		if s.Type == TypeInteger {
+			expected = "an integer"
+		}
+		var number float64
+		if node.Kind != yaml.ScalarNode || !(node.Tag == "!!int" || node.Tag == "!!float" && s.Type == TypeNumber) ||
+			node.Decode(&number) != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+			c.fail(node, path, "expected %s", expected)
+			return nil
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			c.fail(node, path, "must be at least %s", strconv.FormatFloat(*s.Minimum, 'f', -1, 64))
+		} else if s.Maximum != nil && number > *s.Maximum {
+			c.fail(node, path, "must be at most %s", strconv.FormatFloat(*s.Maximum, 'f', -1, 64))
+		}
+		if node.Tag == "!!int" {
+			// keep the precision of large integers
+			var integer int64
+			if err := node.Decode(&integer); err == nil {
+				return json.Number(strconv.FormatInt(integer, 10))
+			}
+			var unsigned uint64
+			if err := node.Decode(&unsigned); err == nil {
+				return json.Number(strconv.FormatUint(unsigned, 10))
+			}
+		}
+		return number
+	case TypeBoolean:
+		var b bool
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" || node.Decode(&b) != nil {
+			c.fail(node, path, "expected true or false")
+			return nil
+		}
+		return b
+	}
+	return c.any(node, path)
+}
+
+// any converts a node that can have any value
+func (c *checker) any(node *yaml.Node, path string) interface{} {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return c.check(&Schema{Type: TypeObject, AdditionalProperties: &Schema{}}, node, path)
+	case yaml.SequenceNode:
+		return c.check(&Schema{Type: TypeArray, Items: &Schema{}}, node, path)
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!bool":
+			return c.check(&Schema{Type: TypeBoolean}, node, path)
+		case "!!int":
+			return c.check(&Schema{Type: TypeInteger}, node, path)
+		case "!!float":
+			return c.check(&Schema{Type: TypeNumber}, node, path)
+		}
+		return node.Value
+	}
+	return nil
+}
+
+// property returns the schema of a property, whose name is matched
+// case-insensitively like json does
+func (s *Schema) property(name string) *Schema {
+	if prop, ok := s.Properties[name]; ok {
+		return prop
+	}
+	for propName, prop := range s.Properties {
+		if strings.EqualFold(propName, name) {
+			return prop
+		}
+	}
+	return nil
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func quote(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return quoted
+}
@@ -0,0 +1,120 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshal_yaml(t *testing.T) {
+	doc := `
+name: root
+port: 8080
+weight: 0.5
+level: high
+labels:
+  owner: zenoss
+  mode: 0660
+children:
+  - Name: child
+    Enabled: true
+    Max: 3
+    Porpose: ignored
+`
+	var actual node
+	if problems, err := nodeSchema.Unmarshal([]byte(doc), &actual); err != nil || len(problems) > 0 {
+		t.Fatalf("unexpected problems %v, error %v", problems, err)
+	}
+	expected := node{
+		Name:     "root",
+		Port:     8080,
+		Weight:   0.5,
+		Level:    "high",
+		Labels:   map[string]string{"owner": "zenoss", "mode": "0660"},
+		Children: []node{{Name: "child", Enabled: true, limits: limits{Max: 3}}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestUnmarshal_json(t *testing.T) {
+	doc := "{\n\t\"Name\": \"root\",\n\t\"Tags\": [\"a\", \"b\"],\n\t\"Data\": {\"x\": [1, 2.5, \"y\"]}\n}\n"
+	var actual node
+	if problems, err := nodeSchema.Unmarshal([]byte(doc), &actual); err != nil || len(problems) > 0 {
+		t.Fatalf("unexpected problems %v, error %v", problems, err)
+	}
+	expected := node{
+		Name: "root",
+		Tags: []string{"a", "b"},
+		Data: map[string]interface{}{"x": []interface{}{1.0, 2.5, "y"}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestUnmarshal_problems(t *testing.T) {
+	doc := `name: [root]
+port: 70000
+weight: heavy
+level: medium
+tags: a
+children:
+  - name: child
+    enabled: 1
+    min: 1.5
+labels:
+  owner: {name: zenoss}
+`
+	var actual node
+	problems, err := nodeSchema.Unmarshal([]byte(doc), &actual)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []Error{
+		{1, "name", "expected a string"},
+		{2, "port", "must be at most 65535"},
+		{3, "weight", "expected a number"},
+		{4, "level", `"medium" is not one of "low", "high"`},
+		{5, "tags", "expected a list"},
+		{8, "children[0].enabled", "expected true or false"},
+		{9, "children[0].min", "expected an integer"},
+		{11, `labels["owner"]`, "expected a string"},
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected %v, got %v", expected, problems)
+	}
+	if actual.Name != "" {
+		t.Errorf("document was decoded")
+	}
+
+	if _, err := nodeSchema.Unmarshal([]byte("name: [root"), &actual); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+	if _, err := nodeSchema.Unmarshal([]byte(""), &actual); err == nil {
+		t.Errorf("expected an error for an empty document")
+	}
+	if problems, _ := nodeSchema.Unmarshal([]byte("- a\n"), &actual); len(problems) != 1 || problems[0].Error() != "line 1: expected an object" {
+		t.Errorf("unexpected problems %v", problems)
+	}
+
+	one := 1
+	list := &Schema{Type: TypeArray, Items: &Schema{Type: TypeString}, MaxItems: &one}
+	var tags []string
+	if problems, _ := list.Unmarshal([]byte("[a, b]"), &tags); len(problems) != 1 || problems[0].Error() != "line 1: must have at most 1 items" {
+		t.Errorf("unexpected problems %v", problems)
+	}
+}
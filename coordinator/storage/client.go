@@ -21,6 +21,7 @@ import (
 	"github.com/control-center/serviced/coordinator/client"
 	"github.com/control-center/serviced/dfs/nfs"
 	"github.com/control-center/serviced/domain/host"
+	"github.com/control-center/serviced/domain/pool"
 	"github.com/control-center/serviced/zzk"
 	"github.com/zenoss/glog"
 )
@@ -28,24 +29,29 @@ import (
 type nfsMountT func(string, string) error
 
 var nfsMount = nfs.Mount
+var nfsUnmount = nfs.Unmount
 var mkdirAll = os.MkdirAll
 
 // Client is a storage client that manges discovering and mounting filesystems
 type Client struct {
 	host      *host.Host
 	localPath string
+	transport string
 	closing   chan struct{}
 	mounted   chan string
 }
 
-// NewClient returns a Client that manages remote mounts
-func NewClient(host *host.Host, localPath string) (*Client, error) {
+// NewClient returns a Client that manages remote mounts.  If transport is
+// neither empty nor nfs, nothing is mounted and the storage leader instead
+// copies the files under localPath over that transport.
+func NewClient(host *host.Host, localPath, transport string) (*Client, error) {
 	if err := mkdirAll(localPath, 0755); err != nil {
 		return nil, err
 	}
 	c := &Client{
 		host:      host,
 		localPath: localPath,
+		transport: transport,
 		mounted:   make(chan string, 1),
 		closing:   make(chan struct{}),
 	}
@@ -71,6 +77,8 @@ func (c *Client) Close() {
 func (c *Client) loop() {
 	var err error
 	var e <-chan client.Event
+	var leaderEvt <-chan client.Event
+	var mountedPath string
 	node := &Node{
 		Host:    *c.host,
 		version: nil,
@@ -115,6 +123,8 @@ func (c *Client) loop() {
 			}
 		}
 		node.Host = *c.host
+		node.Transport = c.transport
+		node.LocalPath = c.localPath
 		if err := conn.Set(nodePath, node); err != nil {
 			glog.Errorf("problem updating %s: %s", nodePath, err)
 			continue
@@ -125,12 +135,27 @@ func (c *Client) loop() {
 			glog.Errorf("err getting node %s: %s", nodePath, err)
 			continue
 		}
+		// watch for the leader to change, so the new leader can be mounted
+		if _, leaderEvt, err = conn.ChildrenW("/storage/leader"); err != nil {
+			glog.Errorf("err watching storage leader: %s", err)
+			continue
+		}
 		if err = leader.Current(leaderNode); err != nil {
 			glog.Errorf("err getting current leader: %s", err)
 			continue
 		}
 
-		if leaderNode.IPAddr != c.host.IPAddr {
+		if c.transport != "" && c.transport != pool.StorageNFS {
+			glog.Infof("skipping nfs mounting, the storage leader syncs %s over %s", c.localPath, c.transport)
+		} else if leaderNode.IPAddr != c.host.IPAddr {
+			if mountedPath != "" && mountedPath != leaderNode.ExportPath {
+				glog.Infof("storage leader changed from %s to %s, remounting %s", mountedPath, leaderNode.ExportPath, c.localPath)
+				if err = nfsUnmount(c.localPath); err != nil {
+					glog.Errorf("problem unmounting %s: %s", c.localPath, err)
+					continue
+				}
+				mountedPath = ""
+			}
 			err = nfsMount(leaderNode.ExportPath, c.localPath)
 			if err != nil {
 				if err == nfs.ErrNfsMountingUnsupported {
@@ -139,6 +164,7 @@ func (c *Client) loop() {
 				glog.Errorf("problem mouting %s: %s", leaderNode.ExportPath, err)
 				continue
 			}
+			mountedPath = leaderNode.ExportPath
 		} else {
 			glog.Info("skipping nfs mounting, server is localhost")
 		}
@@ -151,6 +177,9 @@ func (c *Client) loop() {
 		case evt := <-e:
 			glog.Errorf("got zk event: %s", evt)
 			continue
+		case evt := <-leaderEvt:
+			glog.Infof("got storage leader event: %s", evt)
+			continue
 		}
 	}
 }
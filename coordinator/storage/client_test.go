@@ -69,7 +69,7 @@ func TestClient(t *testing.T) {
 		t.Fatalf("could not create tempdir: %s", err)
 	}
 	defer os.RemoveAll(dir)
-	c, err := NewClient(h, dir)
+	c, err := NewClient(h, dir, "")
 	if err != nil {
 		t.Fatalf("unexpected error creating client: %s", err)
 	}
@@ -22,6 +22,8 @@ type Node struct {
 	host.Host
 	Network    string
 	ExportPath string
+	Transport  string // how a client gets the exported file system, empty for the default
+	LocalPath  string // where a client keeps the exported file system
 	version    interface{}
 }
 
@@ -15,6 +15,7 @@ package storage
 
 import (
 	"fmt"
+	"path"
 	"time"
 
 	"github.com/control-center/serviced/coordinator/client"
@@ -31,6 +32,10 @@ type Server struct {
 	closing chan struct{}
 	driver  StorageDriver
 	debug   chan string
+
+	// transports are the drivers for clients that don't use the default
+	// driver, keyed by the name of the transport
+	transports map[string]StorageDriver
 }
 
 // StorageDriver is an interface that storage subsystem must implement to be used
@@ -41,8 +46,37 @@ type StorageDriver interface {
 	Sync() error
 }
 
-// NewServer returns a Server object to manage the exported file system
-func NewServer(driver StorageDriver, host *host.Host, zclient *client.Client) (*Server, error) {
+// Replicator is implemented by storage drivers that can copy the export of
+// another server.  While another server is the storage leader, a server keeps a
+// copy of its export so that it can take over if the leader goes away.
+type Replicator interface {
+	Replicate(source string) error
+}
+
+// NodeSyncer is implemented by transports that copy the exported file system
+// to each client rather than share it, and so need to know where each client
+// keeps its copy and how to verify the client's host.
+type NodeSyncer interface {
+	SetNodes(nodes ...*Node)
+}
+
+// lastLeaderPath is where the storage leader records itself, so that a server
+// that was not the last leader knows to copy the export of the leader before
+// taking the lead
+const lastLeaderPath = "/storage/lastleader"
+
+// ReplicationInterval is how often a standby copies the export of the storage
+// leader
+var ReplicationInterval = time.Minute
+
+// TransportSyncInterval is how often the transports sync their clients, since
+// unlike the default driver they copy the file system rather than share it
+var TransportSyncInterval = time.Minute
+
+// NewServer returns a Server object to manage the exported file system.
+// Clients that ask for one of the given transports are synced by that driver
+// instead of the default one.
+func NewServer(driver StorageDriver, host *host.Host, zclient *client.Client, transports map[string]StorageDriver) (*Server, error) {
 	if len(driver.ExportPath()) < 9 {
 		return nil, fmt.Errorf("export path can not be empty")
 	}
@@ -53,6 +87,8 @@ func NewServer(driver StorageDriver, host *host.Host, zclient *client.Client) (*
 		closing: make(chan struct{}),
 		driver:  driver,
 		debug:   make(chan string),
+
+		transports: transports,
 	}
 
 	go s.loop()
@@ -79,7 +115,24 @@ func (s *Server) loop() {
 		ExportPath: fmt.Sprintf("%s:%s", s.host.IPAddr, s.driver.ExportPath()),
 		version:    nil,
 	}
+	// A server that was not the last storage leader is fenced: it exports to
+	// no one and replicates the leader's export until it has a copy of the
+	// last leader's data, and only then may it take the lead.
+	replicator, canReplicate := s.driver.(Replicator)
+	var leading bool
+	var resyncedFrom string
+	replicated := make(chan string, 1)
+	var stopReplicating chan struct{}
+	stopReplication := func() {
+		if stopReplicating != nil {
+			close(stopReplicating)
+			stopReplicating = nil
+		}
+	}
+	defer stopReplication()
+
 	reconnect := func() error {
+		stopReplication()
 		conn, err = zzk.GetLocalConnection("/")
 		if err != nil {
 			glog.Errorf("Error in getting a connection: %v", err)
@@ -109,11 +162,70 @@ restart:
 			continue
 		}
 
+		clientPath := fmt.Sprintf("/storage/clients/%s", s.host.IPAddr)
+		if !leading {
+			var fenced bool
+			if fenced, err = s.fenced(conn, resyncedFrom); err != nil {
+				glog.Errorf("err getting the last storage leader: %s", err)
+				continue
+			} else if fenced || canReplicate {
+				// the leader only exports to its clients
+				if err = conn.Create(clientPath, &Node{Host: *s.host}); err != nil && err != client.ErrNodeExists {
+					glog.Errorf("err creating %s: %s", clientPath, err)
+					continue
+				}
+				if canReplicate && stopReplicating == nil {
+					stopReplicating = make(chan struct{})
+					go s.replicate(replicator, storageLead, stopReplicating, replicated)
+				}
+			}
+			if fenced {
+				if !canReplicate {
+					glog.Errorf("storage.server: another server was the storage leader and %s cannot copy its export", s.host.IPAddr)
+				}
+				select {
+				case <-s.closing:
+					return
+				case resyncedFrom = <-replicated:
+				case <-time.After(ReplicationInterval):
+				}
+				continue
+			}
+		}
+
 		leadEventC, err = storageLead.TakeLead()
 		if err != nil && err != zookeeper.ErrDeadlock {
 			glog.Errorf("err taking lead: %s", err)
 			continue
 		}
+		if !leading {
+			// another server may have led while we waited for the lead
+			select {
+			case resyncedFrom = <-replicated:
+			default:
+			}
+			var fenced bool
+			if fenced, err = s.fenced(conn, resyncedFrom); err != nil || fenced {
+				glog.Warningf("storage.server: %s must copy the export of the last storage leader before it can lead (%v)", s.host.IPAddr, err)
+				storageLead.ReleaseLead()
+				err = reconnect()
+				continue
+			}
+			if err = s.setLastLeader(conn); err != nil {
+				glog.Errorf("err setting the last storage leader: %s", err)
+				storageLead.ReleaseLead()
+				err = reconnect()
+				continue
+			}
+			glog.Infof("storage.server: %s is the storage leader", s.host.IPAddr)
+			leading = true
+			resyncedFrom = ""
+			stopReplication()
+			if err = conn.Delete(clientPath); err != nil && err != client.ErrNoNode {
+				glog.Warningf("storage.server: could not remove %s: %s", clientPath, err)
+			}
+			err = nil
+		}
 
 		children, e, err = conn.ChildrenW("/storage/clients")
 		if err != nil {
@@ -121,11 +233,18 @@ restart:
 			continue
 		}
 
-		s.driver.SetClients(children...)
+		clients := s.groupClients(conn, children)
+		s.driver.SetClients(addressesOf(clients[""])...)
 		if err = s.driver.Sync(); err != nil {
 			glog.Errorf("err syncing driver: %s", err)
 			continue
 		}
+		s.syncTransports(clients)
+
+		var syncC <-chan time.Time
+		if len(s.transports) > 0 {
+			syncC = time.After(TransportSyncInterval)
+		}
 
 		select {
 		case <-s.closing:
@@ -138,8 +257,138 @@ restart:
 		case event := <-leadEventC:
 			glog.Info("storage.server: received event on lock: %s", event)
 			storageLead.ReleaseLead()
+			// another server may take the lead while we are away
+			s.fence()
+			leading = false
 			err = reconnect()
 			continue
+		case <-syncC:
+			continue
+		}
+	}
+}
+
+// groupClients returns the clients of each transport.  Clients of the default
+// driver are listed under "".
+func (s *Server) groupClients(conn client.Connection, children []string) map[string][]*Node {
+	clients := make(map[string][]*Node)
+	for _, child := range children {
+		node := &Node{}
+		if err := conn.Get(path.Join("/storage/clients", child), node); err != nil && err != client.ErrEmptyNode {
+			glog.Warningf("storage.server: could not get client %s: %s", child, err)
+		}
+		node.IPAddr = child
+		transport := s.transportOf(node)
+		clients[transport] = append(clients[transport], node)
+	}
+	return clients
+}
+
+// addressesOf returns the IP addresses of the nodes
+func addressesOf(nodes []*Node) []string {
+	addrs := make([]string, len(nodes))
+	for i, node := range nodes {
+		addrs[i] = node.IPAddr
+	}
+	return addrs
+}
+
+// transportOf returns the name of the transport that syncs the client, or ""
+// if it uses the default driver.
+func (s *Server) transportOf(node *Node) string {
+	if node.Transport == "" {
+		return ""
+	} else if _, ok := s.transports[node.Transport]; !ok {
+		glog.V(1).Infof("storage.server: no driver for transport %q of %s, using the default", node.Transport, node.IPAddr)
+		return ""
+	}
+	return node.Transport
+}
+
+// syncTransports updates the clients of each transport and syncs them.
+// Failures are logged and retried at the next sync.
+func (s *Server) syncTransports(clients map[string][]*Node) {
+	for name, driver := range s.transports {
+		if syncer, ok := driver.(NodeSyncer); ok {
+			syncer.SetNodes(clients[name]...)
+		} else {
+			driver.SetClients(addressesOf(clients[name])...)
+		}
+		if err := driver.Sync(); err != nil {
+			glog.Errorf("storage.server: err syncing %s clients: %s", name, err)
+		}
+	}
+}
+
+// fenced returns true if another server was the last storage leader and its
+// export has not been copied since.
+func (s *Server) fenced(conn client.Connection, resyncedFrom string) (bool, error) {
+	last := &Node{}
+	if err := conn.Get(lastLeaderPath, last); err == client.ErrNoNode {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return last.IPAddr != s.host.IPAddr && last.IPAddr != resyncedFrom, nil
+}
+
+// setLastLeader records this server as the last storage leader
+func (s *Server) setLastLeader(conn client.Connection) error {
+	last := &Node{}
+	if err := conn.Get(lastLeaderPath, last); err == client.ErrNoNode {
+		return conn.Create(lastLeaderPath, &Node{Host: *s.host})
+	} else if err != nil {
+		return err
+	}
+	last.Host = *s.host
+	return conn.Set(lastLeaderPath, last)
+}
+
+// fence stops exporting to all clients, so that they cannot write to this
+// server after it has lost the lead.
+func (s *Server) fence() {
+	glog.Warningf("storage.server: %s lost the storage lead, no longer exporting to clients", s.host.IPAddr)
+	s.driver.SetClients()
+	if err := s.driver.Sync(); err != nil {
+		glog.Errorf("storage.server: err fencing driver: %s", err)
+	}
+	s.syncTransports(nil)
+}
+
+// replicate copies the export of the current storage leader every
+// ReplicationInterval until cancel is closed.  The address of the leader is
+// sent to replicated after each successful copy.
+func (s *Server) replicate(replicator Replicator, lead client.Leader, cancel <-chan struct{}, replicated chan string) {
+	wait := time.Duration(0)
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-cancel:
+			return
+		case <-time.After(wait):
+		}
+		wait = ReplicationInterval
+
+		leader := &Node{}
+		if err := lead.Current(leader); err != nil {
+			glog.Warningf("storage.server: could not find the storage leader: %s", err)
+			continue
+		} else if leader.IPAddr == s.host.IPAddr {
+			continue
+		}
+
+		glog.V(1).Infof("storage.server: replicating %s", leader.ExportPath)
+		if err := replicator.Replicate(leader.ExportPath); err != nil {
+			glog.Errorf("storage.server: could not replicate %s: %s", leader.ExportPath, err)
+			continue
+		}
+
+		// keep only the latest leader copied
+		select {
+		case <-replicated:
+		default:
 		}
+		replicated <- leader.IPAddr
 	}
 }
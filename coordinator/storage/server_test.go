@@ -113,7 +113,7 @@ func TestServer(t *testing.T) {
 	}
 
 	// TODO: this gets stuck at server.go:90 call to conn.CreateDir hangs
-	s, err := NewServer(mockNfsDriver, hostServer, zClient)
+	s, err := NewServer(mockNfsDriver, hostServer, zClient, nil)
 	if err != nil {
 		t.Fatalf("unexpected error creating Server: %s", err)
 	}
@@ -134,7 +134,7 @@ func TestServer(t *testing.T) {
 		t.Fatalf("could not create tempdir: %s", err)
 	}
 	defer os.RemoveAll(tmpVar)
-	c1, err := NewClient(hostClient1, tmpVar)
+	c1, err := NewClient(hostClient1, tmpVar, "")
 	if err != nil {
 		t.Fatalf("could not create client: %s", err)
 	}
@@ -160,6 +160,230 @@ func TestServer(t *testing.T) {
 	c1.Close()
 }
 
+type mockLeader struct {
+	node Node
+}
+
+func (l *mockLeader) TakeLead() (<-chan client.Event, error) {
+	return nil, nil
+}
+
+func (l *mockLeader) ReleaseLead() error {
+	return nil
+}
+
+func (l *mockLeader) Current(node client.Node) error {
+	*node.(*Node) = l.node
+	return nil
+}
+
+type mockReplicator struct {
+	sources chan string
+}
+
+func (r *mockReplicator) Replicate(source string) error {
+	r.sources <- source
+	return nil
+}
+
+func TestServer_replicate(t *testing.T) {
+	defer func(interval time.Duration) {
+		ReplicationInterval = interval
+	}(ReplicationInterval)
+	ReplicationInterval = 10 * time.Millisecond
+
+	hostStandby := host.New()
+	hostStandby.IPAddr = "192.168.1.51"
+	s := &Server{host: hostStandby, closing: make(chan struct{})}
+
+	leader := &mockLeader{}
+	leader.node.IPAddr = "192.168.1.50"
+	leader.node.ExportPath = "192.168.1.50:/serviced_var"
+	replicator := &mockReplicator{sources: make(chan string, 10)}
+
+	cancel := make(chan struct{})
+	done := make(chan struct{})
+	replicated := make(chan string, 1)
+	go func() {
+		s.replicate(replicator, leader, cancel, replicated)
+		close(done)
+	}()
+
+	// replicates right away and then periodically
+	for i := 0; i < 2; i++ {
+		select {
+		case source := <-replicator.sources:
+			if source != leader.node.ExportPath {
+				t.Fatalf("expected to replicate %s, got %s", leader.node.ExportPath, source)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for replication %d", i)
+		}
+	}
+	select {
+	case ip := <-replicated:
+		if ip != leader.node.IPAddr {
+			t.Fatalf("expected to have replicated %s, got %s", leader.node.IPAddr, ip)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the replicated leader")
+	}
+
+	close(cancel)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("replication did not stop")
+	}
+
+	// a standby doesn't replicate itself
+	leader.node.IPAddr = hostStandby.IPAddr
+	replicator.sources = make(chan string, 10)
+	done = make(chan struct{})
+	go func() {
+		s.replicate(replicator, leader, make(chan struct{}), make(chan string, 1))
+		close(done)
+	}()
+	select {
+	case source := <-replicator.sources:
+		t.Fatalf("unexpected replication of %s", source)
+	case <-time.After(5 * ReplicationInterval):
+	}
+
+	s.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("replication did not stop on close")
+	}
+}
+
+// mockConn keeps the nodes of a connection in memory
+type mockConn struct {
+	client.Connection
+	nodes map[string]Node
+}
+
+func (c *mockConn) Get(path string, node client.Node) error {
+	n, ok := c.nodes[path]
+	if !ok {
+		return client.ErrNoNode
+	}
+	*node.(*Node) = n
+	return nil
+}
+
+func (c *mockConn) Create(path string, node client.Node) error {
+	if _, ok := c.nodes[path]; ok {
+		return client.ErrNodeExists
+	}
+	c.nodes[path] = *node.(*Node)
+	return nil
+}
+
+func (c *mockConn) Set(path string, node client.Node) error {
+	if _, ok := c.nodes[path]; !ok {
+		return client.ErrNoNode
+	}
+	c.nodes[path] = *node.(*Node)
+	return nil
+}
+
+func TestServer_fenced(t *testing.T) {
+	master := &Server{host: &host.Host{IPAddr: "192.168.1.50"}}
+	standby := &Server{host: &host.Host{IPAddr: "192.168.1.51"}}
+	conn := &mockConn{nodes: make(map[string]Node)}
+
+	// no server has led yet
+	if fenced, err := standby.fenced(conn, ""); err != nil || fenced {
+		t.Fatalf("expected standby not to be fenced, got %v (%v)", fenced, err)
+	}
+
+	if err := master.setLastLeader(conn); err != nil {
+		t.Fatalf("could not set the last leader: %s", err)
+	}
+	if fenced, err := master.fenced(conn, ""); err != nil || fenced {
+		t.Fatalf("expected master not to be fenced, got %v (%v)", fenced, err)
+	}
+	if fenced, err := standby.fenced(conn, ""); err != nil || !fenced {
+		t.Fatalf("expected standby to be fenced, got %v (%v)", fenced, err)
+	}
+	if fenced, err := standby.fenced(conn, master.host.IPAddr); err != nil || fenced {
+		t.Fatalf("expected resynced standby not to be fenced, got %v (%v)", fenced, err)
+	}
+
+	// once the standby has led, the old master must resync from it
+	if err := standby.setLastLeader(conn); err != nil {
+		t.Fatalf("could not set the last leader: %s", err)
+	}
+	if fenced, err := master.fenced(conn, ""); err != nil || !fenced {
+		t.Fatalf("expected old master to be fenced, got %v (%v)", fenced, err)
+	}
+	if fenced, err := master.fenced(conn, standby.host.IPAddr); err != nil || fenced {
+		t.Fatalf("expected resynced master not to be fenced, got %v (%v)", fenced, err)
+	}
+}
+
+func TestServer_fence(t *testing.T) {
+	driver := &mockNfsDriverT{clients: []string{"192.168.1.100"}}
+	rsyncDriver := &mockNfsDriverT{clients: []string{"192.168.1.102"}}
+	s := &Server{
+		host:       &host.Host{IPAddr: "192.168.1.50"},
+		driver:     driver,
+		transports: map[string]StorageDriver{"rsync": rsyncDriver},
+	}
+
+	s.fence()
+	if len(driver.clients) != 0 || !driver.syncCalled {
+		t.Errorf("expected the driver to export to no clients, got %v (synced: %v)", driver.clients, driver.syncCalled)
+	}
+	if len(rsyncDriver.clients) != 0 || !rsyncDriver.syncCalled {
+		t.Errorf("expected the transport to sync no clients, got %v (synced: %v)", rsyncDriver.clients, rsyncDriver.syncCalled)
+	}
+}
+
+// mockNodeSyncer is a transport that gets the nodes of its clients
+type mockNodeSyncer struct {
+	mockNfsDriverT
+	nodes []*Node
+}
+
+func (m *mockNodeSyncer) SetNodes(nodes ...*Node) {
+	m.nodes = nodes
+}
+
+func TestServer_syncTransports(t *testing.T) {
+	rsyncDriver := &mockNodeSyncer{}
+	otherDriver := &mockNfsDriverT{}
+	s := &Server{transports: map[string]StorageDriver{"rsync": rsyncDriver, "other": otherDriver}}
+
+	nodes := []*Node{
+		{Host: host.Host{IPAddr: "192.168.1.100"}},
+		{Host: host.Host{IPAddr: "192.168.1.101"}, Transport: "nfs"},
+		{Host: host.Host{IPAddr: "192.168.1.102"}, Transport: "rsync", LocalPath: "/var/lib/serviced"},
+		{Host: host.Host{IPAddr: "192.168.1.103"}, Transport: "other"},
+	}
+	clients := make(map[string][]*Node)
+	for _, node := range nodes {
+		transport := s.transportOf(node)
+		clients[transport] = append(clients[transport], node)
+	}
+	if len(clients[""]) != 2 {
+		t.Fatalf("expected 2 clients of the default driver, got %v", clients[""])
+	}
+
+	s.syncTransports(clients)
+	if !rsyncDriver.syncCalled {
+		t.Fatalf("sync() should have been called")
+	}
+	if len(rsyncDriver.nodes) != 1 || rsyncDriver.nodes[0].LocalPath != "/var/lib/serviced" {
+		t.Fatalf("expected the rsync node of 192.168.1.102, got %v", rsyncDriver.nodes)
+	}
+	if len(otherDriver.clients) != 1 || otherDriver.clients[0] != "192.168.1.103" {
+		t.Fatalf("expected other clients [192.168.1.103], got %v", otherDriver.clients)
+	}
+}
+
 func assertNoError(t *testing.T, err error, msg string) {
 	if err != nil {
 		t.Fatalf(msg+": %s", err)
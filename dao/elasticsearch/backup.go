@@ -14,6 +14,7 @@
 package elasticsearch
 
 import (
+	"github.com/control-center/serviced/dao"
 	"github.com/zenoss/glog"
 
 	"fmt"
@@ -61,6 +62,14 @@ func (this *ControlPlaneDao) Restore(filename string, unused *int) error {
 	return this.dfs.Restore(filename)
 }
 
+// RestoreTenant restores a single tenant from a backup without affecting
+// other tenants
+func (this *ControlPlaneDao) RestoreTenant(request dao.RestoreTenantRequest, unused *int) error {
+	this.dfs.Lock()
+	defer this.dfs.Unlock()
+	return this.dfs.RestoreTenant(request)
+}
+
 // AsyncRestore performs the restore aynchronously
 func (this *ControlPlaneDao) AsyncRestore(filename string, unused *int) error {
 	// TODO: There is a risk of contention here if two backup operations are
@@ -82,6 +91,11 @@ func (this *ControlPlaneDao) AsyncRestore(filename string, unused *int) error {
 	return nil
 }
 
+// VerifyBackup checks that a backup file is complete and restorable
+func (this *ControlPlaneDao) VerifyBackup(filename string, unused *int) error {
+	return this.dfs.VerifyBackup(filename)
+}
+
 // BackupStatus monitors the status of a backup or restore
 func (this *ControlPlaneDao) BackupStatus(unused int, status *string) error {
 	message := make(chan string)
@@ -0,0 +1,38 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/serviceconfigfile"
+)
+
+// GetServiceConfigVersions returns the versions of a service's config file
+func (this *ControlPlaneDao) GetServiceConfigVersions(request dao.ServiceConfigRequest, versions *[]serviceconfigfile.ConfigVersion) error {
+	result, err := this.facade.GetServiceConfigVersions(datastore.Get(), request.ServiceID, request.Filename)
+	if err != nil {
+		return err
+	}
+	*versions = make([]serviceconfigfile.ConfigVersion, len(result))
+	for i, v := range result {
+		(*versions)[i] = *v
+	}
+	return nil
+}
+
+// RevertServiceConfig reverts a service's config file to one of its versions
+func (this *ControlPlaneDao) RevertServiceConfig(request dao.ServiceConfigRequest, unused *int) error {
+	return this.facade.RevertServiceConfig(datastore.Get(), request.ServiceID, request.Filename, request.Version, request.Author)
+}
@@ -26,11 +26,13 @@ import (
 	coordzk "github.com/control-center/serviced/coordinator/client/zookeeper"
 	"github.com/control-center/serviced/dao"
 	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/dfs"
 	"github.com/control-center/serviced/domain"
 	"github.com/control-center/serviced/domain/addressassignment"
 	"github.com/control-center/serviced/domain/host"
 	"github.com/control-center/serviced/domain/pool"
 	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/serviceconfigfile"
 	"github.com/control-center/serviced/domain/servicedefinition"
 	"github.com/control-center/serviced/domain/servicetemplate"
 	userdomain "github.com/control-center/serviced/domain/user"
@@ -109,7 +111,7 @@ func (dt *DaoTest) SetUpSuite(c *C) {
 		c.Fatalf("could not get zk connection %v", err)
 	}
 
-	dt.Dao, err = NewControlSvc("localhost", int(dt.Port), dt.Facade, "/tmp", "rsync", time.Minute*5, "localhost:5000")
+	dt.Dao, err = NewControlSvc("localhost", int(dt.Port), dt.Facade, "/tmp", "rsync", time.Minute*5, "localhost:5000", dfs.BackupKeys{}, dfs.VolumeQuota{}, 0)
 	if err != nil {
 		glog.Fatalf("Could not start es container: %s", err)
 	} else {
@@ -256,6 +258,65 @@ func (dt *DaoTest) TestDao_UpdateServiceWithConfigFile(t *C) {
 
 }
 
+func (dt *DaoTest) TestDao_ServiceConfigVersions(t *C) {
+	confFile := servicedefinition.ConfigFile{Content: "Test content", Filename: "testname"}
+	svc, _ := service.NewService()
+	svc.ID = "default_versions"
+	svc.Name = "default"
+	svc.PoolID = "default"
+	svc.Launch = "auto"
+	svc.OriginalConfigs = map[string]servicedefinition.ConfigFile{"testname": confFile}
+	err := dt.Dao.AddService(*svc, &id)
+	t.Assert(err, IsNil)
+
+	request := dao.ServiceConfigRequest{ServiceID: svc.ID, Filename: "testname"}
+	var versions []serviceconfigfile.ConfigVersion
+	err = dt.Dao.GetServiceConfigVersions(request, &versions)
+	t.Assert(err, IsNil)
+	t.Assert(versions, HasLen, 0)
+
+	//the first change keeps the original as well
+	confFile2 := servicedefinition.ConfigFile{Content: "Test content 2", Filename: "testname"}
+	svc.ConfigFiles = map[string]servicedefinition.ConfigFile{"testname": confFile2}
+	svc.UpdatedBy = "admin"
+	err = dt.Dao.UpdateService(*svc, &unused)
+	t.Assert(err, IsNil)
+	err = dt.Dao.GetServiceConfigVersions(request, &versions)
+	t.Assert(err, IsNil)
+	t.Assert(versions, HasLen, 2)
+	t.Assert(versions[0].Version, Equals, 1)
+	t.Assert(versions[0].ConfFile, DeepEquals, confFile)
+	t.Assert(versions[1].Version, Equals, 2)
+	t.Assert(versions[1].Author, Equals, "admin")
+	t.Assert(versions[1].ConfFile, DeepEquals, confFile2)
+
+	//updates that don't change the file don't add a version
+	err = dt.Dao.UpdateService(*svc, &unused)
+	t.Assert(err, IsNil)
+	err = dt.Dao.GetServiceConfigVersions(request, &versions)
+	t.Assert(err, IsNil)
+	t.Assert(versions, HasLen, 2)
+
+	//reverting to the original removes the customized file
+	request.Version = 1
+	request.Author = "operator"
+	err = dt.Dao.RevertServiceConfig(request, &unused)
+	t.Assert(err, IsNil)
+	result := service.Service{}
+	err = dt.Dao.GetService(svc.ID, &result)
+	t.Assert(err, IsNil)
+	t.Assert(result.ConfigFiles["testname"], DeepEquals, confFile)
+	err = dt.Dao.GetServiceConfigVersions(request, &versions)
+	t.Assert(err, IsNil)
+	t.Assert(versions, HasLen, 3)
+	t.Assert(versions[2].Author, Equals, "operator")
+	t.Assert(versions[2].ConfFile, DeepEquals, confFile)
+
+	request.Version = 10
+	err = dt.Dao.RevertServiceConfig(request, &unused)
+	t.Assert(err, NotNil)
+}
+
 func (dt *DaoTest) TestDao_GetService(t *C) {
 	svc, _ := service.NewService()
 	svc.Name = "testname"
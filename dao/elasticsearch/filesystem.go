@@ -16,6 +16,7 @@ package elasticsearch
 import (
 	"fmt"
 
+	"github.com/control-center/serviced/dao"
 	"github.com/control-center/serviced/datastore"
 	"github.com/control-center/serviced/volume"
 
@@ -39,6 +40,13 @@ func (this *ControlPlaneDao) GetVolume(serviceID string, volume *volume.Volume)
 	return err
 }
 
+// GetVolumeStatus returns the disk usage of every tenant volume
+func (this *ControlPlaneDao) GetVolumeStatus(unused int, status *[]dao.VolumeStatus) error {
+	var err error
+	*status, err = this.dfs.VolumeStatus()
+	return err
+}
+
 // DeleteSnapshot deletes a particular snapshot
 func (this *ControlPlaneDao) DeleteSnapshot(snapshotID string, unused *int) error {
 	this.dfs.Lock()
@@ -120,6 +128,56 @@ func (this *ControlPlaneDao) ListSnapshots(serviceID string, snapshots *[]string
 	return nil
 }
 
+// DiffSnapshots lists the changes between two snapshots of a tenant
+func (this *ControlPlaneDao) DiffSnapshots(request dao.SnapshotDiffRequest, diff *dao.SnapshotDiff) error {
+	result, err := this.dfs.DiffSnapshots(request.From, request.To)
+	if err != nil {
+		glog.Errorf("Could not diff snapshots %s and %s: %s", request.From, request.To, err)
+		return err
+	}
+	*diff = *result
+	return nil
+}
+
+// CloneSnapshot deploys a snapshot of a tenant as a new tenant
+func (this *ControlPlaneDao) CloneSnapshot(request dao.CloneSnapshotRequest, tenantID *string) error {
+	this.dfs.Lock()
+	defer this.dfs.Unlock()
+
+	var err error
+	if *tenantID, err = this.dfs.CloneSnapshot(request); err != nil {
+		glog.Errorf("Could not clone snapshot %s: %s", request.SnapshotID, err)
+		return err
+	}
+	return nil
+}
+
+// ExportSnapshot writes a snapshot of a tenant to a file
+func (this *ControlPlaneDao) ExportSnapshot(request dao.ExportSnapshotRequest, filename *string) error {
+	this.dfs.Lock()
+	defer this.dfs.Unlock()
+
+	var err error
+	if *filename, err = this.dfs.ExportSnapshot(request.SnapshotID, request.Filename); err != nil {
+		glog.Errorf("Could not export snapshot %s: %s", request.SnapshotID, err)
+		return err
+	}
+	return nil
+}
+
+// ImportSnapshot loads a tenant from a file written by ExportSnapshot
+func (this *ControlPlaneDao) ImportSnapshot(filename string, tenantID *string) error {
+	this.dfs.Lock()
+	defer this.dfs.Unlock()
+
+	var err error
+	if *tenantID, err = this.dfs.ImportSnapshot(filename); err != nil {
+		glog.Errorf("Could not import snapshot from %s: %s", filename, err)
+		return err
+	}
+	return nil
+}
+
 // Commit commits a container to a particular tenant and snapshots the resulting image
 func (this *ControlPlaneDao) Commit(containerID string, snapshotID *string) error {
 	this.dfs.Lock()
@@ -130,6 +188,33 @@ func (this *ControlPlaneDao) Commit(containerID string, snapshotID *string) erro
 	return err
 }
 
+// CollectImages removes the tenant images that are not used by a service or
+// a snapshot
+func (this *ControlPlaneDao) CollectImages(dryRun bool, images *[]string) error {
+	this.dfs.Lock()
+	defer this.dfs.Unlock()
+
+	var err error
+	if *images, err = this.dfs.CollectImages(dryRun); err != nil {
+		glog.Errorf("Could not collect unused images: %s", err)
+		return err
+	}
+	return nil
+}
+
+// GetKeptImages returns the images of the registry that are used by a service
+// or a snapshot, so that agents can remove the others.  The dfs is not locked:
+// an agent that removes an image tagged meanwhile only has to pull it again.
+func (this *ControlPlaneDao) GetKeptImages(unused int, kept *dao.KeptImages) error {
+	result, err := this.dfs.KeptImages()
+	if err != nil {
+		glog.Errorf("Could not get the images to keep: %s", err)
+		return err
+	}
+	*kept = *result
+	return nil
+}
+
 // ReadyDFS verifies that no other dfs operations are in progress
 func (this *ControlPlaneDao) ReadyDFS(unused bool, unusedint *int) (err error) {
 	if locked, err := this.dfs.IsLocked(); err != nil {
@@ -128,3 +128,35 @@ func (this *ControlPlaneDao) StopService(id string, unused *int) error {
 func (this *ControlPlaneDao) AssignIPs(assignmentRequest dao.AssignmentRequest, _ *struct{}) error {
 	return this.facade.AssignIPs(datastore.Get(), assignmentRequest)
 }
+
+// ApplyServices makes the services of a tenant match a service definition, or
+// only plans the changes if it is a dry run.  The tenant is snapshotted before
+// the changes are made.
+func (this *ControlPlaneDao) ApplyServices(request dao.ServiceApplyRequest, plan *dao.ServiceApplyPlan) error {
+	actions, err := this.facade.PlanApply(datastore.Get(), request.TenantID, request.Definition, request.Prune, request.Overwrite)
+	if err != nil {
+		glog.Errorf("Could not plan applying %s to tenant %s: %s", request.Definition.Name, request.TenantID, err)
+		return err
+	}
+	if request.DryRun || len(actions) == 0 {
+		*plan = dao.ServiceApplyPlan{Actions: actions}
+		return nil
+	}
+
+	this.dfs.Lock()
+	defer this.dfs.Unlock()
+
+	snapshotID, err := this.dfs.Snapshot(request.TenantID)
+	if err != nil {
+		glog.Errorf("Could not snapshot %s before applying %s: %s", request.TenantID, request.Definition.Name, err)
+		return err
+	}
+	glog.Infof("Snapshotted %s as %s before applying %s", request.TenantID, snapshotID, request.Definition.Name)
+
+	if actions, err = this.facade.ApplyServiceDefinition(datastore.Get(), request.TenantID, request.Definition, request.Prune, request.Overwrite, request.Author); err != nil {
+		glog.Errorf("Could not apply %s to tenant %s (restore %s to roll back): %s", request.Definition.Name, request.TenantID, snapshotID, err)
+		return err
+	}
+	*plan = dao.ServiceApplyPlan{Actions: actions, Snapshot: snapshotID}
+	return nil
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/control-center/serviced/dao"
 	"github.com/control-center/serviced/datastore"
 	"github.com/control-center/serviced/domain/servicetemplate"
+	"github.com/zenoss/glog"
 )
 
 func (this *ControlPlaneDao) AddServiceTemplate(serviceTemplate servicetemplate.ServiceTemplate, templateID *string) error {
@@ -45,10 +46,62 @@ func (this *ControlPlaneDao) GetServiceTemplates(unused int, templates *map[stri
 
 func (this *ControlPlaneDao) DeployTemplate(request dao.ServiceTemplateDeploymentRequest, tenantID *string) error {
 	var err error
-	*tenantID, err = this.facade.DeployTemplate(datastore.Get(), request.PoolID, request.TemplateID, request.DeploymentID)
+	*tenantID, err = this.facade.DeployTemplate(datastore.Get(), request.PoolID, request.TemplateID, request.DeploymentID, request.Values)
 	return err
 }
 
+// UpgradeTemplate upgrades a deployment to a template.  Each tenant of the
+// deployment is snapshotted before the upgrade is applied.
+func (this *ControlPlaneDao) UpgradeTemplate(request dao.ServiceTemplateUpgradeRequest, plan *dao.ServiceTemplateUpgradePlan) error {
+	this.dfs.Lock()
+	defer this.dfs.Unlock()
+
+	result, err := this.facade.PlanTemplateUpgrade(datastore.Get(), request.DeploymentID, request.TemplateID)
+	if err != nil {
+		glog.Errorf("Could not plan the upgrade of %s to template %s: %s", request.DeploymentID, request.TemplateID, err)
+		return err
+	}
+	if request.DryRun || len(result.Actions) == 0 {
+		*plan = *result
+		return nil
+	}
+
+	tenantIDs, err := this.facade.GetDeploymentTenants(datastore.Get(), request.DeploymentID)
+	if err != nil {
+		glog.Errorf("Could not get the tenants of %s: %s", request.DeploymentID, err)
+		return err
+	}
+	var snapshots []string
+	for _, tenantID := range tenantIDs {
+		snapshotID, err := this.dfs.Snapshot(tenantID)
+		if err != nil {
+			glog.Errorf("Could not snapshot %s before the upgrade: %s", tenantID, err)
+			return err
+		}
+		glog.Infof("Snapshotted %s as %s before upgrading to template %s", tenantID, snapshotID, request.TemplateID)
+		snapshots = append(snapshots, snapshotID)
+	}
+
+	if result, err = this.facade.UpgradeTemplate(datastore.Get(), request.DeploymentID, request.TemplateID); err != nil {
+		glog.Errorf("Could not upgrade %s to template %s (restore %v to roll back): %s", request.DeploymentID, request.TemplateID, snapshots, err)
+		return err
+	}
+	result.Snapshots = snapshots
+	*plan = *result
+	return nil
+}
+
+// ExportTemplate builds a template from the services of a tenant
+func (this *ControlPlaneDao) ExportTemplate(tenantID string, serviceTemplate *servicetemplate.ServiceTemplate) error {
+	template, err := this.facade.ExportTemplate(datastore.Get(), tenantID)
+	if err != nil {
+		glog.Errorf("Could not export tenant %s: %s", tenantID, err)
+		return err
+	}
+	*serviceTemplate = *template
+	return nil
+}
+
 func (this *ControlPlaneDao) DeployTemplateStatus(request dao.ServiceTemplateDeploymentRequest, deployTemplateStatus *string) error {
 	var err error
 	err = this.facade.DeployTemplateStatus(request.DeploymentID, deployTemplateStatus)
@@ -19,6 +19,7 @@ import (
 	"github.com/control-center/serviced/domain"
 	"github.com/control-center/serviced/domain/addressassignment"
 	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/serviceconfigfile"
 	"github.com/control-center/serviced/domain/servicestate"
 	"github.com/control-center/serviced/domain/servicetemplate"
 	"github.com/control-center/serviced/domain/user"
@@ -108,6 +109,15 @@ type ControlPlane interface {
 	// Get the IP addresses assigned to an service
 	GetServiceAddressAssignments(serviceID string, addresses *[]addressassignment.AddressAssignment) error
 
+	// Get the versions of a service's config file, oldest first
+	GetServiceConfigVersions(request ServiceConfigRequest, versions *[]serviceconfigfile.ConfigVersion) error
+
+	// Revert a service's config file to one of its versions
+	RevertServiceConfig(request ServiceConfigRequest, unused *int) error
+
+	// Make the services of a tenant match a service definition, or plan it
+	ApplyServices(request ServiceApplyRequest, plan *ServiceApplyPlan) error
+
 	//---------------------------------------------------------------------------
 	//ServiceState CRUD
 
@@ -156,6 +166,12 @@ type ControlPlane interface {
 	// Deploy an application template in to production
 	DeployTemplate(request ServiceTemplateDeploymentRequest, tenantId *string) error
 
+	// Upgrade a deployment to a new application template, or plan the upgrade
+	UpgradeTemplate(request ServiceTemplateUpgradeRequest, plan *ServiceTemplateUpgradePlan) error
+
+	// Build a template from the services of a tenant
+	ExportTemplate(tenantID string, serviceTemplate *servicetemplate.ServiceTemplate) error
+
 	// Add a new service Template
 	AddServiceTemplate(serviceTemplate servicetemplate.ServiceTemplate, templateId *string) error
 
@@ -186,6 +202,9 @@ type ControlPlane interface {
 	// Volume returns a service's volume
 	GetVolume(serviceID string, volume *volume.Volume) error
 
+	// GetVolumeStatus returns the disk usage of every tenant volume
+	GetVolumeStatus(unused int, status *[]VolumeStatus) error
+
 	// Deletes a particular snapshot
 	DeleteSnapshot(snapshotID string, unused *int) error
 
@@ -204,9 +223,27 @@ type ControlPlane interface {
 	// ListSnapshots lists all the snapshots for a particular service
 	ListSnapshots(serviceID string, snapshots *[]string) error
 
+	// DiffSnapshots lists the changes between two snapshots of a tenant
+	DiffSnapshots(request SnapshotDiffRequest, diff *SnapshotDiff) error
+
+	// CloneSnapshot deploys a snapshot of a tenant as a new tenant
+	CloneSnapshot(request CloneSnapshotRequest, tenantID *string) error
+
+	// ExportSnapshot writes a snapshot of a tenant to a file
+	ExportSnapshot(request ExportSnapshotRequest, filename *string) error
+
+	// ImportSnapshot loads a tenant from a file written by ExportSnapshot
+	ImportSnapshot(filename string, tenantID *string) error
+
 	// Commit commits a docker container to a service image
 	Commit(containerID string, snapshotID *string) error
 
+	// CollectImages removes the tenant images that are no longer needed
+	CollectImages(dryRun bool, images *[]string) error
+
+	// GetKeptImages returns the images of the registry that are still needed
+	GetKeptImages(unused int, kept *KeptImages) error
+
 	// ReadyDFS notifies whether there are any running operations
 	ReadyDFS(bool, *int) error
 
@@ -222,6 +259,12 @@ type ControlPlane interface {
 	// AsyncRestore performs an asynchronous restore
 	AsyncRestore(filename string, unused *int) error
 
+	// RestoreTenant restores a single tenant from a tgz file
+	RestoreTenant(request RestoreTenantRequest, unused *int) error
+
+	// VerifyBackup checks the integrity of a backup file without restoring it
+	VerifyBackup(filename string, unused *int) error
+
 	// BackupStatus monitors the status of a backup or restore
 	BackupStatus(unused int, status *string) error
 }
@@ -57,9 +57,109 @@ type ApplicationEndpoint struct {
 
 // A request to deploy a service template
 type ServiceTemplateDeploymentRequest struct {
-	PoolID       string // Pool Id to deploy service into
-	TemplateID   string // Id of template to be deployed
-	DeploymentID string // Unique id of the instance of this template
+	PoolID       string            // Pool Id to deploy service into
+	TemplateID   string            // Id of template to be deployed
+	DeploymentID string            // Unique id of the instance of this template
+	Values       map[string]string // Values of the template parameters
+}
+
+// A request to upgrade a deployment to a service template
+type ServiceTemplateUpgradeRequest struct {
+	TemplateID   string // Id of the template to upgrade to
+	DeploymentID string // Id of the deployment to upgrade
+	DryRun       bool   // Only plan the upgrade, don't apply it
+}
+
+// A request to make the services of a tenant match a service definition, or
+// to plan the changes
+type ServiceApplyRequest struct {
+	TenantID   string // Id of the tenant the definition describes
+	Definition servicedefinition.ServiceDefinition
+	Prune      bool   // Remove the services that are not in the definition
+	Overwrite  bool   // Set the instances, context values and config files to the definition
+	DryRun     bool   // Only plan the changes, don't make them
+	Author     string // User that applies the definition
+}
+
+// The changes that applying a service definition makes to a tenant
+type ServiceApplyPlan struct {
+	Actions  []ServiceUpgradeAction
+	Snapshot string // Snapshot of the tenant taken before the changes were made
+}
+
+// A change that a template upgrade or an apply makes to a service
+type ServiceUpgradeAction struct {
+	Action    string   // add, update or remove
+	Path      string   // Names of the service and its parents, e.g. Zenoss/Zope
+	ServiceID string   // Id of the existing service, empty if it is added
+	Changes   []string // What changes in the service
+}
+
+// The changes that upgrading a deployment to a service template makes
+type ServiceTemplateUpgradePlan struct {
+	TemplateID   string
+	DeploymentID string
+	Actions      []ServiceUpgradeAction
+	Snapshots    []string // Snapshots of the tenants taken before the upgrade
+}
+
+// A request for the versions of a service's config file, or to revert the
+// file to one of them
+type ServiceConfigRequest struct {
+	ServiceID string // Id of the service
+	Filename  string // Name of the config file
+	Version   int    // Version to revert the config file to
+	Author    string // User that reverts the config file
+}
+
+// A request to restore a single tenant from a backup file
+type RestoreTenantRequest struct {
+	Filename     string // Path to the backup file
+	TenantID     string // Id of the tenant in the backup file
+	NewTenantID  string // Optional id to restore the tenant as
+	PoolID       string // Optional pool to restore the tenant into
+	DeploymentID string // Unique id of the deployment, required with NewTenantID
+}
+
+// The images of the master's registry that are still needed, so that agents
+// can remove the others
+type KeptImages struct {
+	Registry string   // Registry of the master, as host:port
+	Images   []string // Images to keep, as registry/repo:tag
+}
+
+// A request to clone a tenant from one of its snapshots
+type CloneSnapshotRequest struct {
+	SnapshotID   string // Id of the snapshot to clone
+	PoolID       string // Optional pool to deploy the clone into
+	DeploymentID string // Unique id of the clone's deployment
+}
+
+// A request to export a snapshot to a file
+type ExportSnapshotRequest struct {
+	SnapshotID string // Id of the snapshot to export
+	Filename   string // Path to the file to write
+}
+
+// The disk usage of a tenant's DFS volume
+type VolumeStatus struct {
+	TenantID  string // Id of the tenant that owns the volume
+	Name      string // Name of the tenant
+	Driver    string // Volume driver (rsync, btrfs, ...)
+	Path      string // Path to the volume on the master
+	UsedBytes uint64 // Number of bytes used by the volume
+	SoftLimit uint64 // Number of bytes after which warnings are logged, 0 for none
+	HardLimit uint64 // Number of bytes after which snapshots are refused, 0 for none
+}
+
+// OverSoftLimit returns true if the volume has crossed its soft limit
+func (s VolumeStatus) OverSoftLimit() bool {
+	return s.SoftLimit > 0 && s.UsedBytes > s.SoftLimit
+}
+
+// OverHardLimit returns true if the volume has crossed its hard limit
+func (s VolumeStatus) OverHardLimit() bool {
+	return s.HardLimit > 0 && s.UsedBytes > s.HardLimit
 }
 
 // A request to deploy a service from a service definition
@@ -122,6 +222,27 @@ type SnapshotRequest struct {
 	SnapshotError string
 }
 
+// A request to compare two snapshots of the same tenant
+type SnapshotDiffRequest struct {
+	From string // Id of the older snapshot
+	To   string // Id of the newer snapshot
+}
+
+// A single change between two snapshots
+type SnapshotChange struct {
+	Type   string // "file", "service" or "image"
+	Action string // "added", "removed" or "modified"
+	Name   string // Path of the file, id of the service or name of the image repo
+	Detail string // Optional description of the modification
+}
+
+// The changes between two snapshots of the same tenant
+type SnapshotDiff struct {
+	From    string
+	To      string
+	Changes []SnapshotChange
+}
+
 // A new snapshot request instance (SnapshotRequest)
 func NewSnapshotRequest(serviceId string, snapshotLabel string) (snapshotRequest *SnapshotRequest, err error) {
 	snapshotRequest = &SnapshotRequest{}
@@ -0,0 +1,131 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"github.com/control-center/serviced/commons/docker"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/pool"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicestate"
+	"github.com/control-center/serviced/domain/servicetemplate"
+	"github.com/control-center/serviced/zzk"
+	zkservice "github.com/control-center/serviced/zzk/service"
+)
+
+// facadefuncs is the part of the facade that the dfs uses
+type facadefuncs interface {
+	AddService(ctx datastore.Context, svc service.Service) error
+	DeployServices(ctx datastore.Context, svcs []service.Service, poolID, deploymentID string) (string, error)
+	GetResourcePool(ctx datastore.Context, id string) (*pool.ResourcePool, error)
+	GetResourcePools(ctx datastore.Context) ([]*pool.ResourcePool, error)
+	GetService(ctx datastore.Context, id string) (*service.Service, error)
+	GetServices(ctx datastore.Context, request dao.EntityRequest) ([]service.Service, error)
+	GetServiceTemplates(ctx datastore.Context) (map[string]servicetemplate.ServiceTemplate, error)
+	PauseService(ctx datastore.Context, serviceID string) error
+	ReloadLogstashContainer(ctx datastore.Context) error
+	RemoveService(ctx datastore.Context, id string) error
+	StartService(ctx datastore.Context, serviceID string) error
+	UpdateService(ctx datastore.Context, svc service.Service) error
+	UpdateServiceTemplate(ctx datastore.Context, template servicetemplate.ServiceTemplate) error
+}
+
+var zkAPI zkfuncs = &zkf{}
+
+// zkfuncs are the calls that the dfs makes to the coordinator
+type zkfuncs interface {
+	GetServiceStates(poolID, serviceID string) ([]servicestate.ServiceState, error)
+	UpdateServiceState(poolID string, state *servicestate.ServiceState) error
+	WaitPause(cancel <-chan interface{}, poolID, serviceID, stateID string) error
+}
+
+type zkf struct{}
+
+func (zk *zkf) GetServiceStates(poolID, serviceID string) ([]servicestate.ServiceState, error) {
+	conn, err := zzk.GetLocalConnection(zzk.GeneratePoolPath(poolID))
+	if err != nil {
+		return nil, err
+	}
+	return zkservice.GetServiceStates(conn, serviceID)
+}
+
+func (zk *zkf) UpdateServiceState(poolID string, state *servicestate.ServiceState) error {
+	conn, err := zzk.GetLocalConnection(zzk.GeneratePoolPath(poolID))
+	if err != nil {
+		return err
+	}
+	return zkservice.UpdateServiceState(conn, state)
+}
+
+func (zk *zkf) WaitPause(cancel <-chan interface{}, poolID, serviceID, stateID string) error {
+	conn, err := zzk.GetLocalConnection(zzk.GeneratePoolPath(poolID))
+	if err != nil {
+		return err
+	}
+	return zkservice.WaitPause(cancel, conn, serviceID, stateID)
+}
+
+var dockerAPI dockerfuncs = &dockerf{}
+
+// dockerfuncs are the calls that the dfs makes to docker and its registry to
+// keep the images of tenants
+type dockerfuncs interface {
+	Images() ([]*docker.Image, error)
+	FindImage(repotag string) (*docker.Image, error)
+	TagImage(image *docker.Image, tag string) (*docker.Image, error)
+	DeleteImage(image *docker.Image) error
+	SaveImage(imageID, filename string) error
+	ImageHistory(uuid string) ([]string, error)
+	SquashImage(repotag, downToLayer string) (string, error)
+}
+
+type dockerf struct{}
+
+func (d *dockerf) Images() ([]*docker.Image, error) {
+	return docker.Images()
+}
+
+func (d *dockerf) FindImage(repotag string) (*docker.Image, error) {
+	return docker.FindImage(repotag, false)
+}
+
+func (d *dockerf) TagImage(image *docker.Image, tag string) (*docker.Image, error) {
+	return image.Tag(tag)
+}
+
+func (d *dockerf) DeleteImage(image *docker.Image) error {
+	return image.Delete()
+}
+
+func (d *dockerf) SaveImage(imageID, filename string) error {
+	return saveImage(imageID, filename)
+}
+
+// ImageHistory returns the layers of an image, from the top
+func (d *dockerf) ImageHistory(uuid string) ([]string, error) {
+	history, err := docker.ImageHistory(uuid)
+	if err != nil {
+		return nil, err
+	}
+	layers := make([]string, len(history))
+	for i, l := range history {
+		layers[i] = l.ID
+	}
+	return layers, nil
+}
+
+func (d *dockerf) SquashImage(repotag, downToLayer string) (string, error) {
+	return docker.SquashImage(repotag, downToLayer)
+}
@@ -0,0 +1,299 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/commons/docker"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/pool"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicestate"
+	"github.com/control-center/serviced/domain/servicetemplate"
+)
+
+// testFacade keeps services, templates and pools in memory
+type testFacade struct {
+	services  map[string]service.Service
+	templates map[string]servicetemplate.ServiceTemplate
+	pools     []*pool.ResourcePool
+	paused    []string
+	started   []string
+	deployed  []service.Service
+}
+
+func newTestFacade(svcs ...service.Service) *testFacade {
+	f := &testFacade{
+		services:  make(map[string]service.Service),
+		templates: make(map[string]servicetemplate.ServiceTemplate),
+		pools:     []*pool.ResourcePool{{ID: "default"}},
+	}
+	for _, svc := range svcs {
+		f.services[svc.ID] = svc
+	}
+	return f
+}
+
+func (f *testFacade) AddService(ctx datastore.Context, svc service.Service) error {
+	if _, ok := f.services[svc.ID]; ok {
+		return fmt.Errorf("service %s exists", svc.ID)
+	}
+	f.services[svc.ID] = svc
+	return nil
+}
+
+// DeployServices adds copies of the services named clone-<id>
+func (f *testFacade) DeployServices(ctx datastore.Context, svcs []service.Service, poolID, deploymentID string) (string, error) {
+	for _, svc := range f.services {
+		if svc.DeploymentID == deploymentID {
+			return "", fmt.Errorf("deployment ID %s is already in use", deploymentID)
+		}
+	}
+	f.deployed = svcs
+
+	var tenantID string
+	for _, svc := range svcs {
+		svc.ID = "clone-" + svc.ID
+		if svc.ParentServiceID != "" {
+			svc.ParentServiceID = "clone-" + svc.ParentServiceID
+		} else {
+			tenantID = svc.ID
+		}
+		if poolID != "" {
+			svc.PoolID = poolID
+		}
+		svc.DeploymentID = deploymentID
+		if err := f.AddService(ctx, svc); err != nil {
+			return "", err
+		}
+	}
+	return tenantID, nil
+}
+
+func (f *testFacade) GetResourcePool(ctx datastore.Context, id string) (*pool.ResourcePool, error) {
+	for _, p := range f.pools {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *testFacade) GetResourcePools(ctx datastore.Context) ([]*pool.ResourcePool, error) {
+	return f.pools, nil
+}
+
+func (f *testFacade) GetService(ctx datastore.Context, id string) (*service.Service, error) {
+	if svc, ok := f.services[id]; ok {
+		return &svc, nil
+	}
+	return nil, nil
+}
+
+func (f *testFacade) GetServices(ctx datastore.Context, request dao.EntityRequest) ([]service.Service, error) {
+	var ids []string
+	for id := range f.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	svcs := make([]service.Service, len(ids))
+	for i, id := range ids {
+		svcs[i] = f.services[id]
+	}
+	return svcs, nil
+}
+
+func (f *testFacade) GetServiceTemplates(ctx datastore.Context) (map[string]servicetemplate.ServiceTemplate, error) {
+	return f.templates, nil
+}
+
+func (f *testFacade) PauseService(ctx datastore.Context, serviceID string) error {
+	f.paused = append(f.paused, serviceID)
+	return nil
+}
+
+func (f *testFacade) ReloadLogstashContainer(ctx datastore.Context) error {
+	return nil
+}
+
+func (f *testFacade) RemoveService(ctx datastore.Context, id string) error {
+	for _, svc := range f.services {
+		if svc.ParentServiceID == id {
+			f.RemoveService(ctx, svc.ID)
+		}
+	}
+	delete(f.services, id)
+	return nil
+}
+
+func (f *testFacade) StartService(ctx datastore.Context, serviceID string) error {
+	f.started = append(f.started, serviceID)
+	return nil
+}
+
+func (f *testFacade) UpdateService(ctx datastore.Context, svc service.Service) error {
+	if _, ok := f.services[svc.ID]; !ok {
+		return fmt.Errorf("service %s not found", svc.ID)
+	}
+	f.services[svc.ID] = svc
+	return nil
+}
+
+func (f *testFacade) UpdateServiceTemplate(ctx datastore.Context, template servicetemplate.ServiceTemplate) error {
+	f.templates[template.ID] = template
+	return nil
+}
+
+// testZK keeps the running instances of services in memory
+type testZK struct {
+	states map[string][]servicestate.ServiceState
+}
+
+func (zk *testZK) GetServiceStates(poolID, serviceID string) ([]servicestate.ServiceState, error) {
+	return zk.states[serviceID], nil
+}
+
+func (zk *testZK) UpdateServiceState(poolID string, state *servicestate.ServiceState) error {
+	for i, s := range zk.states[state.ServiceID] {
+		if s.ID == state.ID {
+			zk.states[state.ServiceID][i] = *state
+			return nil
+		}
+	}
+	return fmt.Errorf("instance %s not found", state.ID)
+}
+
+func (zk *testZK) WaitPause(cancel <-chan interface{}, poolID, serviceID, stateID string) error {
+	return nil
+}
+
+// testDocker is a docker daemon and registry whose images are only tags
+type testDocker struct {
+	images  []docker.Image
+	layers  map[string][]string // layers of each image, from the top
+	squashs []string            // images that were squashed, as repotag@base
+}
+
+// add tags the image with the docker ID uuid
+func (d *testDocker) add(uuid, tag string) error {
+	imageID, err := commons.ParseImageID(tag)
+	if err != nil {
+		return err
+	}
+	d.remove(imageID.String())
+	d.images = append(d.images, docker.Image{UUID: uuid, ID: *imageID})
+	return nil
+}
+
+func (d *testDocker) remove(tag string) {
+	for i, image := range d.images {
+		if image.ID.String() == tag {
+			d.images = append(d.images[:i], d.images[i+1:]...)
+			return
+		}
+	}
+}
+
+// uuid returns the docker ID of the image tagged tag, or "" if there is none
+func (d *testDocker) uuid(tag string) string {
+	for _, image := range d.images {
+		if image.ID.String() == tag {
+			return image.UUID
+		}
+	}
+	return ""
+}
+
+func (d *testDocker) Images() ([]*docker.Image, error) {
+	images := make([]*docker.Image, len(d.images))
+	for i := range d.images {
+		image := d.images[i]
+		images[i] = &image
+	}
+	return images, nil
+}
+
+func (d *testDocker) FindImage(repotag string) (*docker.Image, error) {
+	imageID, err := commons.ParseImageID(repotag)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range d.images {
+		if image.ID.String() == imageID.String() {
+			return &image, nil
+		}
+	}
+	return nil, fmt.Errorf("image %s not found", repotag)
+}
+
+func (d *testDocker) TagImage(image *docker.Image, tag string) (*docker.Image, error) {
+	if err := d.add(image.UUID, tag); err != nil {
+		return nil, err
+	}
+	return d.FindImage(tag)
+}
+
+func (d *testDocker) DeleteImage(image *docker.Image) error {
+	d.remove(image.ID.String())
+	return nil
+}
+
+func (d *testDocker) SaveImage(imageID, filename string) error {
+	image, err := d.FindImage(imageID)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, []byte(image.UUID), 0644)
+}
+
+func (d *testDocker) ImageHistory(uuid string) ([]string, error) {
+	if layers, ok := d.layers[uuid]; ok {
+		return layers, nil
+	}
+	return []string{uuid}, nil
+}
+
+// SquashImage tags the image with a new image that has the layers of the base
+// and one more
+func (d *testDocker) SquashImage(repotag, downToLayer string) (string, error) {
+	image, err := d.FindImage(repotag)
+	if err != nil {
+		return "", err
+	}
+	base, err := d.ImageHistory(downToLayer)
+	if err != nil {
+		return "", err
+	}
+	uuid := "squashed-" + image.UUID
+	if d.layers == nil {
+		d.layers = make(map[string][]string)
+	}
+	d.layers[uuid] = append([]string{uuid}, base...)
+	d.squashs = append(d.squashs, repotag+"@"+downToLayer)
+	return uuid, d.add(uuid, repotag)
+}
+
+// setTestAPIs replaces the coordinator and docker for a test and returns a
+// function that puts them back
+func setTestAPIs(zk zkfuncs, d dockerfuncs) func() {
+	zkAPI, dockerAPI = zk, d
+	return func() {
+		zkAPI, dockerAPI = &zkf{}, &dockerf{}
+	}
+}
@@ -28,9 +28,6 @@ import (
 	"github.com/control-center/serviced/datastore"
 	"github.com/control-center/serviced/domain/service"
 	"github.com/control-center/serviced/domain/servicetemplate"
-	"github.com/control-center/serviced/facade"
-	"github.com/control-center/serviced/zzk"
-	zkservice "github.com/control-center/serviced/zzk/service"
 	"github.com/zenoss/glog"
 )
 
@@ -40,6 +37,7 @@ const (
 	templateJSON = "templates.json"
 	serviceJSON  = "services.json"
 	imageJSON    = "images.json"
+	squashJSON   = "squash.json"
 )
 
 func (dfs *DistributedFilesystem) Backup(dirpath string) (string, error) {
@@ -118,9 +116,7 @@ func (dfs *DistributedFilesystem) Backup(dirpath string) (string, error) {
 		}
 	}
 
-	dfs.log("Writing backup file")
-	if err := exportTGZ(dirpath, filename); err != nil {
-		glog.Errorf("Could not write backup file %s: %s", filename, err)
+	if filename, err = dfs.writeBackup(dirpath, filename); err != nil {
 		return "", err
 	}
 	dfs.log("Backup file created: %s", filename)
@@ -128,6 +124,33 @@ func (dfs *DistributedFilesystem) Backup(dirpath string) (string, error) {
 	return filename, nil
 }
 
+// writeBackup adds a manifest to dirpath and archives it to filename,
+// encrypting the archive if a backup key is configured.  Returns the name of
+// the file that was written.
+func (dfs *DistributedFilesystem) writeBackup(dirpath, filename string) (string, error) {
+	dfs.log("Writing backup manifest")
+	if err := writeManifest(dirpath, dfs.cipher); err != nil {
+		glog.Errorf("Could not write backup manifest: %s", err)
+		return "", err
+	}
+
+	if dfs.cipher != nil {
+		filename += encryptedSuffix
+		dfs.log("Writing encrypted backup file")
+		if err := exportEncryptedTGZ(dirpath, filename, dfs.cipher); err != nil {
+			glog.Errorf("Could not write encrypted backup file %s: %s", filename, err)
+			return "", err
+		}
+	} else {
+		dfs.log("Writing backup file")
+		if err := exportTGZ(dirpath, filename); err != nil {
+			glog.Errorf("Could not write backup file %s: %s", filename, err)
+			return "", err
+		}
+	}
+	return filename, nil
+}
+
 func (dfs *DistributedFilesystem) Restore(filename string) error {
 	// fail if any services are running
 	dfs.log("Checking running services")
@@ -138,12 +161,7 @@ func (dfs *DistributedFilesystem) Restore(filename string) error {
 	}
 
 	for _, svc := range svcs {
-		conn, err := zzk.GetLocalConnection(zzk.GeneratePoolPath(svc.PoolID))
-		if err != nil {
-			glog.Errorf("Could not acquire connection to coordinator (%s): %s", svc.PoolID, err)
-			return err
-		}
-		if states, err := zkservice.GetServiceStates(conn, svc.ID); err != nil {
+		if states, err := zkAPI.GetServiceStates(svc.PoolID, svc.ID); err != nil {
 			glog.Errorf("Could not look up running instances for %s (%s): %s", svc.Name, svc.ID, err)
 			return err
 		} else if running := len(states); running > 0 {
@@ -156,7 +174,7 @@ func (dfs *DistributedFilesystem) Restore(filename string) error {
 	var reloadLogstashContainer bool
 	defer func() {
 		if reloadLogstashContainer {
-			go facade.LogstashContainerReloader(datastore.Get(), dfs.facade) // don't block main thread
+			go dfs.facade.ReloadLogstashContainer(datastore.Get()) // don't block main thread
 		}
 	}()
 
@@ -177,8 +195,14 @@ func (dfs *DistributedFilesystem) Restore(filename string) error {
 		}
 	}()
 
+	plaintext, cleanup, err := dfs.decryptBackup(dirpath, filename)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
 	dfs.log("Extracting backup file %s", filename)
-	if err := importTGZ(dirpath, filename); err != nil {
+	if err := importTGZ(dirpath, plaintext); err != nil {
 		glog.Errorf("Could not expand %s to %s: %s", filename, dirpath, err)
 		return err
 	}
@@ -246,6 +270,262 @@ func (dfs *DistributedFilesystem) Restore(filename string) error {
 	return nil
 }
 
+// RestoreTenant restores a single tenant from a backup file, optionally under a
+// new tenant ID or into a different pool.  Templates and other tenants are not
+// affected.
+func (dfs *DistributedFilesystem) RestoreTenant(request dao.RestoreTenantRequest) error {
+	filename, tenantID := request.Filename, request.TenantID
+	newTenantID := request.NewTenantID
+	if newTenantID == "" {
+		newTenantID = tenantID
+	}
+
+	// fail if any services of the target tenant are running
+	dfs.log("Checking running services")
+	if err := dfs.checkTenantStopped(newTenantID); err != nil {
+		glog.Errorf("Cannot restore %s from %s: %s", newTenantID, filename, err)
+		return err
+	}
+
+	// a tenant restored under a new id gets its own deployment
+	if newTenantID != tenantID {
+		if request.DeploymentID == "" {
+			return fmt.Errorf("deployment ID is required to restore %s as %s", tenantID, newTenantID)
+		} else if err := dfs.checkDeploymentID(request.DeploymentID); err != nil {
+			return err
+		}
+	}
+
+	// check the pool
+	if request.PoolID != "" {
+		if pool, err := dfs.facade.GetResourcePool(datastore.Get(), request.PoolID); err != nil {
+			glog.Errorf("Could not look up pool %s: %s", request.PoolID, err)
+			return err
+		} else if pool == nil {
+			return fmt.Errorf("pool %s not found", request.PoolID)
+		}
+	}
+
+	dirpath := filepath.Join(getHome(), "restore")
+	cleanup, err := dfs.extractBackup(dirpath, filename)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// find the snapshot of the tenant
+	snapshotFiles, err := ls(filepath.Join(dirpath, snapshotDir))
+	if err != nil {
+		glog.Errorf("Could not list contents of %s: %s", filepath.Join(dirpath, snapshotDir), err)
+		return err
+	}
+	var snapshotID string
+	for _, f := range snapshotFiles {
+		label := strings.TrimSuffix(f, ".tgz")
+		if id, _, err := parseLabel(label); err != nil {
+			glog.Errorf("Cannot restore %s: %s", f, err)
+			return err
+		} else if id == tenantID {
+			snapshotID = label
+		}
+	}
+	if snapshotID == "" {
+		return fmt.Errorf("tenant %s not found in %s", tenantID, filename)
+	}
+
+	if err := dfs.restoreTenant(dirpath, filename, snapshotID, newTenantID, request.PoolID, request.DeploymentID); err != nil {
+		return err
+	}
+	dfs.log("Successfully restored %s as %s", tenantID, newTenantID)
+
+	return nil
+}
+
+// checkTenantStopped returns an error if any service of the tenant has
+// running instances.
+func (dfs *DistributedFilesystem) checkTenantStopped(tenantID string) error {
+	svcs, err := dfs.facade.GetServices(datastore.Get(), dao.ServiceRequest{})
+	if err != nil {
+		glog.Errorf("Could not acquire the list of all services: %s", err)
+		return err
+	}
+
+	for _, svc := range getChildServices(tenantID, svcs) {
+		if states, err := zkAPI.GetServiceStates(svc.PoolID, svc.ID); err != nil {
+			glog.Errorf("Could not look up running instances for %s (%s): %s", svc.Name, svc.ID, err)
+			return err
+		} else if running := len(states); running > 0 {
+			return fmt.Errorf("service %s (%s) has %d running instances", svc.Name, svc.ID, running)
+		}
+	}
+	return nil
+}
+
+// extractBackup expands a (possibly encrypted) backup file into dirpath and
+// returns a function that removes the expanded files.
+func (dfs *DistributedFilesystem) extractBackup(dirpath, filename string) (func(), error) {
+	if err := os.RemoveAll(dirpath); err != nil {
+		glog.Errorf("Could not remove %s: %s", dirpath, err)
+		return nil, err
+	}
+
+	if err := mkdir(dirpath); err != nil {
+		glog.Errorf("Could neither find nor create %s: %s", dirpath, err)
+		return nil, err
+	}
+
+	cleanup := func() {
+		if err := os.RemoveAll(dirpath); err != nil {
+			glog.Warningf("Could not remove %s: %s", dirpath, err)
+		}
+	}
+
+	plaintext, cleanupPlaintext, err := dfs.decryptBackup(dirpath, filename)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	defer cleanupPlaintext()
+
+	dfs.log("Extracting backup file %s", filename)
+	if err := importTGZ(dirpath, plaintext); err != nil {
+		glog.Errorf("Could not expand %s to %s: %s", filename, dirpath, err)
+		cleanup()
+		return nil, err
+	}
+
+	return cleanup, nil
+}
+
+// restoreTenant loads the images, services and volume of a tenant snapshot
+// from an extracted backup at dirpath as newTenantID.  The services keep their
+// deployment ID unless deploymentID is set.
+func (dfs *DistributedFilesystem) restoreTenant(dirpath, filename, snapshotID, newTenantID, poolID, deploymentID string) error {
+	tenantID, timestamp, err := parseLabel(snapshotID)
+	if err != nil {
+		glog.Errorf("Cannot restore %s: %s", snapshotID, err)
+		return err
+	}
+
+	// restore the docker images of the tenant
+	dfs.log("Loading docker images")
+	var images []imagemeta
+	if err := importJSON(filepath.Join(dirpath, imageJSON), &images); err != nil {
+		glog.Errorf("Could not read images from %s: %s", filename, err)
+		return err
+	}
+
+	images, err = relabelImages(images, tenantID, newTenantID)
+	if err != nil {
+		glog.Errorf("Could not read images for %s from %s: %s", tenantID, filename, err)
+		return err
+	}
+
+	if err := dfs.importImages(filepath.Join(dirpath, imageDir), images, map[string]struct{}{newTenantID: struct{}{}}); err != nil {
+		glog.Errorf("Could not import images from %s: %s", filename, err)
+		return err
+	}
+	dfs.log("Docker image load successful")
+
+	// restore the services
+	dfs.log("Loading %s", snapshotID)
+	snapshotPath := filepath.Join(dirpath, snapshotDir, snapshotID)
+	if err := importTGZ(snapshotPath, filepath.Join(dirpath, snapshotDir, snapshotID+".tgz")); err != nil {
+		glog.Errorf("Could not extract %s: %s", snapshotID, err)
+		return err
+	}
+
+	var restore []*service.Service
+	if err := importJSON(filepath.Join(snapshotPath, serviceJSON), &restore); err != nil {
+		glog.Errorf("Could not acquire services from %s: %s", snapshotID, err)
+		return err
+	}
+
+	if err := relabelServices(restore, tenantID, newTenantID, poolID); err != nil {
+		glog.Errorf("Could not relabel services from %s: %s", snapshotID, err)
+		return err
+	}
+
+	if deploymentID != "" {
+		for _, svc := range restore {
+			svc.DeploymentID = deploymentID
+		}
+	}
+
+	if err := dfs.restoreServices(restore); err != nil {
+		glog.Errorf("Could not restore services from %s: %s", snapshotID, err)
+		return err
+	}
+
+	// restore the volume
+	snapshotVolume, err := dfs.GetVolume(newTenantID)
+	if err != nil {
+		glog.Errorf("Could not acquire the volume for %s: %s", newTenantID, err)
+		return err
+	}
+
+	label := fmt.Sprintf("%s_%s", newTenantID, timestamp)
+	if err := os.Rename(snapshotPath, snapshotVolume.SnapshotPath(label)); err != nil {
+		glog.Errorf("Could not move snapshot volume: %s", err)
+		return err
+	}
+
+	defer func() {
+		if err := dfs.DeleteSnapshot(label); err != nil {
+			glog.Warningf("Could not delete snapshot %s while restoring %s: %s", label, newTenantID, err)
+		}
+	}()
+
+	if err := snapshotVolume.Rollback(label); err != nil {
+		glog.Errorf("Could not roll back volume of %s to %s: %s", newTenantID, label, err)
+		return err
+	}
+	dfs.reapplyLimit(newTenantID, snapshotVolume)
+
+	return nil
+}
+
+// VerifyBackup checks that a backup file is complete and that there is enough
+// disk space to restore it.  It does not modify the system.
+func (dfs *DistributedFilesystem) VerifyBackup(filename string) error {
+	plaintext, err := dfs.openBackup(filename)
+	if err != nil {
+		glog.Errorf("Could not open %s: %s", filename, err)
+		return err
+	}
+	defer plaintext.Close()
+
+	dfs.log("Verifying backup file %s", filename)
+	size, err := verifyArchiveStream(plaintext, filename)
+	if err != nil {
+		glog.Errorf("Could not verify %s: %s", filename, err)
+		return err
+	}
+
+	dfs.log("Checking available disk space")
+	expandedSize := size / 1024
+
+	home := getHome()
+	if err := mkdir(home); err != nil {
+		glog.Errorf("Could neither find nor create %s: %s", home, err)
+		return err
+	}
+
+	disk, err := checkDisk(home, 1024)
+	if err != nil {
+		glog.Errorf("Could not acquire disk information for %s: %s", home, err)
+		return err
+	}
+
+	if disk.Available < 2*expandedSize {
+		glog.Errorf("Not enough space on disk to restore from backup (uncompressed: %dK) (available: %dK)", expandedSize, disk.Available)
+		return fmt.Errorf("insufficient disk space")
+	}
+	dfs.log("Backup file %s verified", filename)
+
+	return nil
+}
+
 func (dfs *DistributedFilesystem) exportSnapshots(dirpath string, tenant *service.Service) (string, error) {
 	glog.V(1).Infof("Exporting %s", tenant.ID)
 	snapshotID, err := dfs.Snapshot(tenant.ID)
@@ -27,6 +27,11 @@ import (
 	"time"
 
 	"github.com/control-center/serviced/commons/docker"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicedefinition"
+	"github.com/control-center/serviced/domain/servicetemplate"
+	"github.com/control-center/serviced/volume/dir"
 
 	"github.com/zenoss/glog"
 )
@@ -201,6 +206,107 @@ func TestBackup_parseTarInfo(t *testing.T) {
 	}
 }
 
+func TestBackup_verifyArchive(t *testing.T) {
+	tgzDir, e := ioutil.TempDir("", "test-tgz")
+	if e != nil {
+		t.Fatalf("Failed to create temporary directory: %s", e)
+	}
+	defer os.RemoveAll(tgzDir)
+
+	dataDir, e := ioutil.TempDir("", "test-data")
+	if e != nil {
+		t.Fatalf("Failed to create temporary directory: %s", e)
+	}
+	defer os.RemoveAll(dataDir)
+
+	for _, dir := range []string{imageDir, snapshotDir} {
+		if e := mkdir(filepath.Join(dataDir, dir)); e != nil {
+			t.Fatalf("Failed to create directory %s: %s", dir, e)
+		}
+	}
+	files := map[string]string{
+		templateJSON:                     "{}\n",
+		imageJSON:                        "[{\"UUID\":\"abc123\",\"Tags\":[\"localhost:5000/tenant/repo:latest\"],\"Filename\":\"0.tar\"}]\n",
+		filepath.Join(imageDir, "0.tar"): "image data",
+		filepath.Join(snapshotDir, "tenant_20141022-120000.tgz"): "snapshot data",
+	}
+	for name, data := range files {
+		if e := ioutil.WriteFile(filepath.Join(dataDir, name), []byte(data), 0644); e != nil {
+			t.Fatalf("Failed writing file %s: %s", name, e)
+		}
+	}
+
+	if e := writeManifest(dataDir, nil); e != nil {
+		t.Fatalf("Failed writing manifest: %s", e)
+	}
+
+	tgzFile := filepath.Join(tgzDir, "backup.tgz")
+	if e := exportTGZ(dataDir, tgzFile); e != nil {
+		t.Fatalf("Failed writing directory %s to %s: %s", dataDir, tgzFile, e)
+	}
+	if e := verifyArchive(tgzFile); e != nil {
+		t.Errorf("Expected valid archive: %s", e)
+	}
+
+	// verify an encrypted copy as it is decrypted
+	data, e := ioutil.ReadFile(tgzFile)
+	if e != nil {
+		t.Fatalf("Failed to read %s: %s", tgzFile, e)
+	}
+	dfs := &DistributedFilesystem{cipher: &backupCipher{passphrase: []byte("macaroni and cheese")}}
+	encrypted := encryptToFile(t, dfs.cipher, data)
+	defer os.Remove(encrypted)
+	plaintext, e := dfs.openBackup(encrypted)
+	if e != nil {
+		t.Fatalf("Failed to open %s: %s", encrypted, e)
+	}
+	var expected int64
+	for name := range files {
+		expected += int64(len(files[name]))
+	}
+	if info, e := os.Stat(filepath.Join(dataDir, manifestJSON)); e == nil {
+		expected += info.Size()
+	}
+	if size, e := verifyArchiveStream(plaintext, encrypted); e != nil {
+		t.Errorf("Expected valid encrypted archive: %s", e)
+	} else if size != expected {
+		t.Errorf("Expected size %d, got %d", expected, size)
+	}
+	plaintext.Close()
+
+	// a wrong passphrase fails verification
+	dfs.cipher = &backupCipher{passphrase: []byte("peanut butter")}
+	if plaintext, e = dfs.openBackup(encrypted); e != nil {
+		t.Fatalf("Failed to open %s: %s", encrypted, e)
+	}
+	if _, e := verifyArchiveStream(plaintext, encrypted); e == nil {
+		t.Errorf("Expected an error verifying with the wrong passphrase")
+	}
+	plaintext.Close()
+
+	// corrupt the image and verify again
+	if e := ioutil.WriteFile(filepath.Join(dataDir, imageDir, "0.tar"), []byte("corrupt"), 0644); e != nil {
+		t.Fatalf("Failed writing file: %s", e)
+	}
+	if e := exportTGZ(dataDir, tgzFile); e != nil {
+		t.Fatalf("Failed writing directory %s to %s: %s", dataDir, tgzFile, e)
+	}
+	if e := verifyArchive(tgzFile); e == nil {
+		t.Errorf("Expected checksum mismatch")
+	}
+
+	// remove the manifest and verify again
+	if e := os.Remove(filepath.Join(dataDir, manifestJSON)); e != nil {
+		t.Fatalf("Failed to remove manifest: %s", e)
+	}
+	if e := exportTGZ(dataDir, tgzFile); e != nil {
+		t.Fatalf("Failed writing directory %s to %s: %s", dataDir, tgzFile, e)
+	}
+	if e := verifyArchive(tgzFile); e == nil {
+		t.Errorf("Expected missing manifest")
+	}
+}
+
 func TestBackup_getDockerImageNameIds(t *testing.T) {
 	t.Skip("TODO: write unit test")
 }
@@ -226,7 +332,66 @@ func TestBackup_readDirFileNames(t *testing.T) {
 }
 
 func TestBackup_Backup(t *testing.T) {
-	t.Skip("TODO: write unit test")
+	varpath, err := ioutil.TempDir("", "dfs-backup-test")
+	if err != nil {
+		t.Fatalf("Could not create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(varpath)
+
+	f := newTestFacade(service.Service{ID: "tenant", Name: "tenant", PoolID: "default", ImageID: "localhost:5000/tenant/core"})
+	f.templates["template"] = servicetemplate.ServiceTemplate{
+		ID:       "template",
+		Services: []servicedefinition.ServiceDefinition{{Name: "tenant", ImageID: "zenoss/core:5.0"}},
+	}
+	d := &testDocker{}
+	d.add("abc", "localhost:5000/tenant/core:latest")
+	d.add("def", "zenoss/core:5.0")
+	defer setTestAPIs(&testZK{}, d)()
+
+	dfs := &DistributedFilesystem{vfs: dir.DriverName, varpath: varpath, dockerHost: "localhost", dockerPort: 5000, facade: f, timeout: time.Minute}
+	v, err := dfs.GetVolume("tenant")
+	if err != nil {
+		t.Fatalf("Could not get volume: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(v.Path(), "backedup.txt"), []byte("cheese and crackers"), 0600); err != nil {
+		t.Fatalf("Failed writing file: %s", err)
+	}
+
+	filename, err := dfs.Backup(filepath.Join(varpath, "backups"))
+	if err != nil {
+		t.Fatalf("Could not back up: %s", err)
+	}
+	if err := verifyArchive(filename); err != nil {
+		t.Errorf("Could not verify %s: %s", filename, err)
+	}
+
+	// the archive has the templates, both images and a snapshot of the tenant
+	tf, err := readTarFile(filename)
+	if err != nil {
+		t.Fatalf("Could not read %s: %s", filename, err)
+	}
+	var images, snapshots int
+	for _, info := range *tf {
+		switch {
+		case strings.HasPrefix(info.Filename, "./images/") && strings.HasSuffix(info.Filename, ".tar"):
+			images++
+		case strings.HasPrefix(info.Filename, "./snapshots/tenant_") && strings.HasSuffix(info.Filename, ".tgz"):
+			snapshots++
+		}
+	}
+	if images != 2 || snapshots != 1 {
+		t.Errorf("Expected 2 images and 1 snapshot, got %d and %d: %v", images, snapshots, *tf)
+	}
+
+	// the snapshot taken for the backup is gone, along with its image tags
+	if labels, err := v.Snapshots(); err != nil {
+		t.Fatalf("Could not list snapshots: %s", err)
+	} else if len(labels) > 0 {
+		t.Errorf("Expected no snapshots, got %v", labels)
+	}
+	if len(d.images) != 2 {
+		t.Errorf("Expected only the original images, got %v", d.images)
+	}
 }
 
 func TestBackup_Restore(t *testing.T) {
@@ -308,6 +473,23 @@ func get_docker_image_tags(t log, imageId string) (map[string]bool, error) {
 	return result, nil
 }
 
+func TestBackup_RestoreTenantDeploymentID(t *testing.T) {
+	f := newTestFacade(service.Service{ID: "tenant", Name: "tenant", PoolID: "default", DeploymentID: "prod"})
+	defer setTestAPIs(&testZK{}, &testDocker{})()
+	dfs := &DistributedFilesystem{facade: f, timeout: time.Minute}
+
+	// a tenant restored under a new id needs its own deployment
+	request := dao.RestoreTenantRequest{Filename: "backup.tgz", TenantID: "tenant", NewTenantID: "clone"}
+	if err := dfs.RestoreTenant(request); err == nil {
+		t.Errorf("Expected an error restoring a new tenant without a deployment ID")
+	}
+
+	request.DeploymentID = "prod"
+	if err := dfs.RestoreTenant(request); err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("Expected an error restoring a new tenant into a used deployment, got %v", err)
+	}
+}
+
 func TestBackup_IntegrationTest(t *testing.T) {
 	t.Skip("TODO: Fix this broken test. Maybe a race condition?")
 	/*
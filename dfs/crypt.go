@@ -0,0 +1,466 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.google.com/p/go.crypto/pbkdf2"
+	"github.com/zenoss/glog"
+)
+
+const (
+	encryptedMagic      = "SERVICED-ENCRYPTED-BACKUP\n"
+	encryptedVersion    = 1
+	encryptedSuffix     = ".enc"
+	encryptPassphrase   = "passphrase"
+	encryptRSA          = "rsa"
+	pbkdf2Iterations    = 100000
+	encryptionKeyLength = 32
+	macKeyLength        = 32
+)
+
+var (
+	// ErrNoBackupKey is returned when a backup is encrypted but no suitable key is configured
+	ErrNoBackupKey = errors.New("no key configured to decrypt backup")
+	// ErrBackupTampered is returned when the authentication code of an encrypted backup does not match
+	ErrBackupTampered = errors.New("backup failed authentication; wrong key or corrupt file")
+)
+
+// BackupKeys are the paths to the keys used to encrypt and decrypt backups.
+// Any of them may be empty.
+type BackupKeys struct {
+	PassphraseFile string // file containing the passphrase
+	PublicKeyFile  string // PEM encoded RSA public key used to encrypt backups
+	PrivateKeyFile string // PEM encoded RSA private key used to decrypt backups
+}
+
+// encryptionHeader is written in the clear at the start of an encrypted backup
+type encryptionHeader struct {
+	Version     int
+	Method      string
+	Fingerprint string `json:",omitempty"`
+	Salt        []byte `json:",omitempty"`
+	Iterations  int    `json:",omitempty"`
+	WrappedKey  []byte `json:",omitempty"`
+	IV          []byte
+}
+
+// backupCipher encrypts and decrypts backup streams
+type backupCipher struct {
+	passphrase []byte
+	public     *rsa.PublicKey
+	private    *rsa.PrivateKey
+}
+
+// loadBackupCipher reads the configured keys.  It returns nil if no keys are
+// configured.
+func loadBackupCipher(keys BackupKeys) (*backupCipher, error) {
+	var c backupCipher
+
+	if keys.PassphraseFile != "" {
+		data, err := ioutil.ReadFile(keys.PassphraseFile)
+		if err != nil {
+			glog.Errorf("Could not read backup passphrase file %s: %s", keys.PassphraseFile, err)
+			return nil, err
+		}
+		if c.passphrase = bytes.TrimRight(data, "\r\n"); len(c.passphrase) == 0 {
+			return nil, fmt.Errorf("backup passphrase file %s is empty", keys.PassphraseFile)
+		}
+	}
+
+	if keys.PublicKeyFile != "" {
+		block, err := readPEM(keys.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			glog.Errorf("Could not parse backup public key %s: %s", keys.PublicKeyFile, err)
+			return nil, err
+		}
+		var ok bool
+		if c.public, ok = key.(*rsa.PublicKey); !ok {
+			return nil, fmt.Errorf("backup public key %s is not an RSA key", keys.PublicKeyFile)
+		}
+	}
+
+	if keys.PrivateKeyFile != "" {
+		block, err := readPEM(keys.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if c.private, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			glog.Errorf("Could not parse backup private key %s: %s", keys.PrivateKeyFile, err)
+			return nil, err
+		}
+		if c.public == nil {
+			c.public = &c.private.PublicKey
+		}
+	}
+
+	if c.passphrase == nil && c.public == nil {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func readPEM(filename string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		glog.Errorf("Could not read key file %s: %s", filename, err)
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", filename)
+	}
+	return block, nil
+}
+
+// Method is the encryption method used for new backups.  A public key takes
+// precedence over a passphrase.
+func (c *backupCipher) Method() string {
+	if c.public != nil {
+		return encryptRSA
+	}
+	return encryptPassphrase
+}
+
+// Fingerprint identifies the public key used for new backups.  Passphrases
+// have no fingerprint, as anything derived from the passphrase and stored in
+// the clear would let its guesses be checked offline; a wrong passphrase is
+// found by the authentication of the backup instead.
+func (c *backupCipher) Fingerprint() (string, error) {
+	if c.public != nil {
+		return publicKeyFingerprint(c.public)
+	}
+	return "", nil
+}
+
+func publicKeyFingerprint(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return "SHA256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Encrypt reads the plaintext from r and writes an encrypted backup stream to
+// w.  The stream is authenticated with an HMAC that is appended to the end.
+func (c *backupCipher) Encrypt(w io.Writer, r io.Reader) error {
+	fingerprint, err := c.Fingerprint()
+	if err != nil {
+		return err
+	}
+	header := encryptionHeader{Version: encryptedVersion, Method: c.Method(), Fingerprint: fingerprint}
+
+	var keys []byte
+	switch header.Method {
+	case encryptRSA:
+		keys = make([]byte, encryptionKeyLength+macKeyLength)
+		if _, err := io.ReadFull(rand.Reader, keys); err != nil {
+			return err
+		}
+		if header.WrappedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, c.public, keys, nil); err != nil {
+			return err
+		}
+	case encryptPassphrase:
+		header.Salt, header.Iterations = make([]byte, 16), pbkdf2Iterations
+		if _, err := io.ReadFull(rand.Reader, header.Salt); err != nil {
+			return err
+		}
+		keys = pbkdf2.Key(c.passphrase, header.Salt, header.Iterations, encryptionKeyLength+macKeyLength, sha256.New)
+	}
+
+	header.IV = make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, header.IV); err != nil {
+		return err
+	}
+
+	block, err := aes.NewCipher(keys[:encryptionKeyLength])
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, keys[encryptionKeyLength:])
+
+	headerData, err := json.Marshal(&header)
+	if err != nil {
+		return err
+	}
+	headerData = append([]byte(encryptedMagic), append(headerData, '\n')...)
+	mac.Write(headerData)
+	if _, err := w.Write(headerData); err != nil {
+		return err
+	}
+
+	stream := &cipher.StreamWriter{S: cipher.NewCTR(block, header.IV), W: io.MultiWriter(w, mac)}
+	if _, err := io.Copy(stream, r); err != nil {
+		return err
+	}
+
+	_, err = w.Write(mac.Sum(nil))
+	return err
+}
+
+// Decrypt authenticates the encrypted backup in filename and writes its
+// plaintext to w.  Nothing is written if authentication fails.
+func (c *backupCipher) Decrypt(w io.Writer, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, headerData, err := readEncryptionHeader(file)
+	if err != nil {
+		return err
+	}
+
+	var keys []byte
+	switch header.Method {
+	case encryptRSA:
+		if c == nil || c.private == nil {
+			return ErrNoBackupKey
+		} else if fingerprint, err := publicKeyFingerprint(&c.private.PublicKey); err != nil {
+			return err
+		} else if fingerprint != header.Fingerprint {
+			return fmt.Errorf("backup was encrypted with key %s, configured key is %s", header.Fingerprint, fingerprint)
+		}
+		if keys, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, c.private, header.WrappedKey, nil); err != nil {
+			return err
+		}
+	case encryptPassphrase:
+		if c == nil || c.passphrase == nil {
+			return ErrNoBackupKey
+		}
+		keys = pbkdf2.Key(c.passphrase, header.Salt, header.Iterations, encryptionKeyLength+macKeyLength, sha256.New)
+	default:
+		return fmt.Errorf("unsupported encryption method %s", header.Method)
+	}
+	if len(keys) != encryptionKeyLength+macKeyLength {
+		return fmt.Errorf("invalid key length")
+	}
+
+	offset := int64(len(headerData))
+	length := info.Size() - offset - sha256.Size
+	if length < 0 {
+		return fmt.Errorf("encrypted backup is truncated")
+	}
+
+	// authenticate before decrypting anything
+	mac := hmac.New(sha256.New, keys[encryptionKeyLength:])
+	mac.Write(headerData)
+	if _, err := io.Copy(mac, io.NewSectionReader(file, offset, length)); err != nil {
+		return err
+	}
+	expected := make([]byte, sha256.Size)
+	if _, err := file.ReadAt(expected, offset+length); err != nil {
+		return err
+	}
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrBackupTampered
+	}
+
+	block, err := aes.NewCipher(keys[:encryptionKeyLength])
+	if err != nil {
+		return err
+	}
+	stream := &cipher.StreamReader{S: cipher.NewCTR(block, header.IV), R: io.NewSectionReader(file, offset, length)}
+	_, err = io.Copy(w, stream)
+	return err
+}
+
+// readEncryptionHeader returns the header of an encrypted backup and its raw
+// bytes.
+func readEncryptionHeader(r io.Reader) (*encryptionHeader, []byte, error) {
+	reader := bufio.NewReader(r)
+	magic, err := reader.ReadString('\n')
+	if err != nil || magic != encryptedMagic {
+		return nil, nil, fmt.Errorf("not an encrypted backup")
+	}
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read encryption header: %s", err)
+	}
+
+	var header encryptionHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return nil, nil, fmt.Errorf("could not read encryption header: %s", err)
+	} else if header.Version > encryptedVersion {
+		return nil, nil, fmt.Errorf("unsupported encryption version %d", header.Version)
+	}
+	return &header, append([]byte(magic), line...), nil
+}
+
+// readBackupHeader returns the encryption header of a backup file
+func readBackupHeader(filename string) (*encryptionHeader, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header, _, err := readEncryptionHeader(file)
+	return header, err
+}
+
+// isEncrypted returns true if the file is an encrypted backup
+func isEncrypted(filename string) (bool, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(file, magic); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return string(magic) == encryptedMagic, nil
+}
+
+// exportEncryptedTGZ writes the contents of src to an encrypted tgz file
+// without writing the plaintext to disk.
+func exportEncryptedTGZ(src, filename string, c *backupCipher) error {
+	cmd, err := commandAsRoot("tar", "-czf", "-", "-C", src, ".")
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	file, err := os.Create(filename)
+	if err != nil {
+		glog.Errorf("Could not create file %s: %s", filename, err)
+		return err
+	}
+	defer file.Close()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := c.Encrypt(file, stdout); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		glog.Errorf("Could not encrypt %s: %s", filename, err)
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		glog.Errorf("Unable to write encrypted tgz cmd:%+v error:%v output:%s", cmd, err, stderr.String())
+		return err
+	}
+	return file.Close()
+}
+
+// decryptBackup writes the plaintext of an encrypted backup file next to
+// dirpath and returns its path along with a function to remove it.
+// Unencrypted backup files are returned as is.
+func (dfs *DistributedFilesystem) decryptBackup(dirpath, filename string) (string, func(), error) {
+	if encrypted, err := isEncrypted(filename); err != nil {
+		glog.Errorf("Could not read %s: %s", filename, err)
+		return "", nil, err
+	} else if !encrypted {
+		return filename, func() {}, nil
+	}
+
+	plaintext := fmt.Sprintf("%s.tgz", filepath.Clean(dirpath))
+	cleanup := func() {
+		if err := os.Remove(plaintext); err != nil && !os.IsNotExist(err) {
+			glog.Warningf("Could not remove %s: %s", plaintext, err)
+		}
+	}
+
+	file, err := os.OpenFile(plaintext, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		glog.Errorf("Could not create %s: %s", plaintext, err)
+		return "", nil, err
+	}
+	defer file.Close()
+
+	dfs.log("Decrypting backup file %s", filename)
+	if err := dfs.cipher.Decrypt(file, filename); err != nil {
+		glog.Errorf("Could not decrypt %s: %s", filename, err)
+		cleanup()
+		dfs.logBackupKey(filename)
+		return "", nil, err
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return plaintext, cleanup, nil
+}
+
+// openBackup returns a reader for the plaintext of a backup file.  Encrypted
+// backup files are decrypted as they are read, so no plaintext is written to
+// disk.
+func (dfs *DistributedFilesystem) openBackup(filename string) (io.ReadCloser, error) {
+	encrypted, err := isEncrypted(filename)
+	if err != nil {
+		glog.Errorf("Could not read %s: %s", filename, err)
+		return nil, err
+	} else if !encrypted {
+		return os.Open(filename)
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		err := dfs.cipher.Decrypt(w, filename)
+		if err != nil && err != io.ErrClosedPipe {
+			glog.Errorf("Could not decrypt %s: %s", filename, err)
+			dfs.logBackupKey(filename)
+		}
+		w.CloseWithError(err)
+	}()
+	return r, nil
+}
+
+// logBackupKey tells the operator which key decrypts a backup file
+func (dfs *DistributedFilesystem) logBackupKey(filename string) {
+	if header, err := readBackupHeader(filename); err == nil && header.Fingerprint != "" {
+		dfs.log("Backup file %s requires %s key %s", filename, header.Method, header.Fingerprint)
+	} else if err == nil {
+		dfs.log("Backup file %s requires a %s", filename, header.Method)
+	}
+}
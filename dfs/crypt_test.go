@@ -0,0 +1,127 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func encryptToFile(t *testing.T, c *backupCipher, data []byte) string {
+	file, err := ioutil.TempFile("", "test-encrypt")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %s", err)
+	}
+	defer file.Close()
+
+	if err := c.Encrypt(file, bytes.NewReader(data)); err != nil {
+		t.Fatalf("Failed to encrypt: %s", err)
+	}
+	return file.Name()
+}
+
+func TestCrypt_passphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-crypt")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	passphraseFile := filepath.Join(dir, "passphrase")
+	if err := ioutil.WriteFile(passphraseFile, []byte("macaroni and cheese\n"), 0600); err != nil {
+		t.Fatalf("Failed writing file %s: %s", passphraseFile, err)
+	}
+
+	c, err := loadBackupCipher(BackupKeys{PassphraseFile: passphraseFile})
+	if err != nil {
+		t.Fatalf("Failed to load keys: %s", err)
+	} else if c.Method() != encryptPassphrase {
+		t.Fatalf("Expected method %s, got %s", encryptPassphrase, c.Method())
+	}
+
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	filename := encryptToFile(t, c, data)
+	defer os.Remove(filename)
+
+	if encrypted, err := isEncrypted(filename); err != nil || !encrypted {
+		t.Fatalf("Expected encrypted file (%s)", err)
+	}
+
+	// nothing derived from the passphrase may be stored in the clear, apart
+	// from the key derivation's random salt
+	if header, err := readBackupHeader(filename); err != nil {
+		t.Fatalf("Failed to read header: %s", err)
+	} else if header.Fingerprint != "" {
+		t.Errorf("Expected no fingerprint, got %s", header.Fingerprint)
+	}
+
+	var plaintext bytes.Buffer
+	if err := c.Decrypt(&plaintext, filename); err != nil {
+		t.Fatalf("Failed to decrypt: %s", err)
+	} else if !bytes.Equal(plaintext.Bytes(), data) {
+		t.Errorf("Expected %s, got %s", data, plaintext.Bytes())
+	}
+
+	// a different passphrase must fail authentication
+	other := &backupCipher{passphrase: []byte("spam and eggs")}
+	if err := other.Decrypt(&plaintext, filename); err != ErrBackupTampered {
+		t.Errorf("Expected %s, got %v", ErrBackupTampered, err)
+	}
+
+	// no key at all
+	var none *backupCipher
+	if err := none.Decrypt(&plaintext, filename); err != ErrNoBackupKey {
+		t.Errorf("Expected %s, got %v", ErrNoBackupKey, err)
+	}
+}
+
+func TestCrypt_rsa(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	c := &backupCipher{public: &key.PublicKey, private: key}
+
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	filename := encryptToFile(t, c, data)
+	defer os.Remove(filename)
+
+	var plaintext bytes.Buffer
+	if err := c.Decrypt(&plaintext, filename); err != nil {
+		t.Fatalf("Failed to decrypt: %s", err)
+	} else if !bytes.Equal(plaintext.Bytes(), data) {
+		t.Errorf("Expected %s, got %s", data, plaintext.Bytes())
+	}
+
+	// flip a bit in the ciphertext
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %s", filename, err)
+	}
+	contents[len(contents)-40] ^= 1
+	if err := ioutil.WriteFile(filename, contents, 0600); err != nil {
+		t.Fatalf("Failed to write %s: %s", filename, err)
+	}
+	plaintext.Reset()
+	if err := c.Decrypt(&plaintext, filename); err != ErrBackupTampered {
+		t.Errorf("Expected %s, got %v", ErrBackupTampered, err)
+	} else if plaintext.Len() > 0 {
+		t.Errorf("Expected no plaintext to be written")
+	}
+}
@@ -32,7 +32,7 @@ type DistributedFilesystem struct {
 	varpath    string
 	dockerHost string
 	dockerPort int
-	facade     *facade.Facade
+	facade     facadefuncs
 	timeout    time.Duration
 
 	// locking
@@ -41,21 +41,38 @@ type DistributedFilesystem struct {
 
 	// logging
 	logger *logger
+
+	// backup encryption
+	cipher *backupCipher
+
+	// volume limits, and the hard limit applied to each tenant volume
+	quota      VolumeQuota
+	limits     map[string]uint64
+	limitsLock sync.Mutex
+
+	// number of image layers at which commits squash the image, 0 to never
+	// squash
+	squashLimit int
 }
 
-func NewDistributedFilesystem(vfs, varpath, dockerRegistry string, facade *facade.Facade, timeout time.Duration) (*DistributedFilesystem, error) {
+func NewDistributedFilesystem(vfs, varpath, dockerRegistry string, facade *facade.Facade, timeout time.Duration, keys BackupKeys, quota VolumeQuota, squashLimit int) (*DistributedFilesystem, error) {
 	host, port, err := parseRegistry(dockerRegistry)
 	if err != nil {
 		return nil, err
 	}
 
+	cipher, err := loadBackupCipher(keys)
+	if err != nil {
+		return nil, err
+	}
+
 	conn, err := zzk.GetLocalConnection("/")
 	if err != nil {
 		return nil, err
 	}
 	lock := zkservice.ServiceLock(conn)
 
-	return &DistributedFilesystem{vfs: vfs, varpath: varpath, dockerHost: host, dockerPort: port, facade: facade, timeout: timeout, lock: lock}, nil
+	return &DistributedFilesystem{vfs: vfs, varpath: varpath, dockerHost: host, dockerPort: port, facade: facade, timeout: timeout, lock: lock, cipher: cipher, quota: quota, squashLimit: squashLimit}, nil
 }
 
 func (dfs *DistributedFilesystem) Lock() error {
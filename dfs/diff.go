@@ -0,0 +1,392 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/control-center/serviced/commons/docker"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/zenoss/glog"
+)
+
+const (
+	changeFile    = "file"
+	changeService = "service"
+	changeImage   = "image"
+
+	changeAdded    = "added"
+	changeRemoved  = "removed"
+	changeModified = "modified"
+)
+
+// ignoredServiceFields change whenever a service is saved or started, so they
+// are not reported as modifications
+var ignoredServiceFields = map[string]struct{}{
+	"CreatedAt":       struct{}{},
+	"UpdatedAt":       struct{}{},
+	"DesiredState":    struct{}{},
+	"DatabaseVersion": struct{}{},
+}
+
+// DiffSnapshots lists the files, services and image tags that changed between
+// two snapshots of the same tenant
+func (dfs *DistributedFilesystem) DiffSnapshots(from, to string) (*dao.SnapshotDiff, error) {
+	tenantID, fromTag, err := parseLabel(from)
+	if err != nil {
+		glog.Errorf("Could not parse snapshot ID %s: %s", from, err)
+		return nil, err
+	}
+	toTenantID, toTag, err := parseLabel(to)
+	if err != nil {
+		glog.Errorf("Could not parse snapshot ID %s: %s", to, err)
+		return nil, err
+	} else if tenantID != toTenantID {
+		return nil, fmt.Errorf("snapshots %s and %s belong to different tenants", from, to)
+	}
+
+	tenant, err := dfs.facade.GetService(datastore.Get(), tenantID)
+	if err != nil {
+		glog.Errorf("Could not find service %s: %s", tenantID, err)
+		return nil, err
+	} else if tenant == nil {
+		glog.Errorf("Service %s not found", tenantID)
+		return nil, fmt.Errorf("service not found")
+	}
+
+	snapshotVolume, err := dfs.GetVolume(tenant.ID)
+	if err != nil {
+		glog.Errorf("Could not find volume for service %s: %s", tenantID, err)
+		return nil, err
+	}
+
+	labels, err := snapshotVolume.Snapshots()
+	if err != nil {
+		glog.Errorf("Could not list snapshots for %s: %s", tenantID, err)
+		return nil, err
+	}
+	for _, label := range []string{from, to} {
+		if !hasLabel(labels, label) {
+			return nil, fmt.Errorf("snapshot %s not found", label)
+		}
+	}
+
+	diff := &dao.SnapshotDiff{From: from, To: to}
+
+	// compare the volume
+	files, err := diffFiles(snapshotVolume.SnapshotPath(from), snapshotVolume.SnapshotPath(to))
+	if err != nil {
+		glog.Errorf("Could not compare files in %s and %s: %s", from, to, err)
+		return nil, err
+	}
+	diff.Changes = append(diff.Changes, files...)
+
+	// compare the services
+	var fromSvcs, toSvcs []*service.Service
+	if err := importJSON(filepath.Join(snapshotVolume.SnapshotPath(from), serviceJSON), &fromSvcs); err != nil {
+		glog.Errorf("Could not acquire services from %s: %s", from, err)
+		return nil, err
+	}
+	if err := importJSON(filepath.Join(snapshotVolume.SnapshotPath(to), serviceJSON), &toSvcs); err != nil {
+		glog.Errorf("Could not acquire services from %s: %s", to, err)
+		return nil, err
+	}
+	services, err := diffServices(fromSvcs, toSvcs)
+	if err != nil {
+		glog.Errorf("Could not compare services in %s and %s: %s", from, to, err)
+		return nil, err
+	}
+	diff.Changes = append(diff.Changes, services...)
+
+	// compare the image tags
+	fromImages, err := findImages(tenantID, fromTag)
+	if err != nil {
+		glog.Errorf("Could not find images for snapshot %s: %s", from, err)
+		return nil, err
+	}
+	toImages, err := findImages(tenantID, toTag)
+	if err != nil {
+		glog.Errorf("Could not find images for snapshot %s: %s", to, err)
+		return nil, err
+	}
+	diff.Changes = append(diff.Changes, diffImages(fromImages, toImages)...)
+
+	return diff, nil
+}
+
+// diffFiles compares the contents of two snapshot directories.  The exported
+// services at the root of each snapshot are not included.
+func diffFiles(from, to string) ([]dao.SnapshotChange, error) {
+	fromFiles, err := listFiles(from)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := listFiles(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []dao.SnapshotChange
+	var fromNames, toNames []string
+	for name := range fromFiles {
+		fromNames = append(fromNames, name)
+	}
+	for name := range toFiles {
+		toNames = append(toNames, name)
+	}
+
+	for _, name := range union(fromNames, toNames) {
+		fromInfo, inFrom := fromFiles[name]
+		toInfo, inTo := toFiles[name]
+		switch {
+		case !inTo:
+			changes = append(changes, dao.SnapshotChange{Type: changeFile, Action: changeRemoved, Name: name})
+		case !inFrom:
+			changes = append(changes, dao.SnapshotChange{Type: changeFile, Action: changeAdded, Name: name})
+		default:
+			detail, err := fileChange(filepath.Join(from, name), fromInfo, filepath.Join(to, name), toInfo)
+			if err != nil {
+				return nil, err
+			} else if detail != "" {
+				changes = append(changes, dao.SnapshotChange{Type: changeFile, Action: changeModified, Name: name, Detail: detail})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// listFiles returns the info of every file below root, keyed by its relative
+// path
+func listFiles(root string) (map[string]os.FileInfo, error) {
+	files := make(map[string]os.FileInfo)
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		} else if name == "." || name == serviceJSON {
+			return nil
+		}
+		files[filepath.ToSlash(name)] = info
+		return nil
+	})
+	return files, err
+}
+
+// fileChange describes how a file changed between two snapshots, or returns
+// an empty string if it did not change
+func fileChange(fromPath string, fromInfo os.FileInfo, toPath string, toInfo os.FileInfo) (string, error) {
+	fromMode, toMode := fromInfo.Mode(), toInfo.Mode()
+
+	switch {
+	case fromMode&os.ModeType != toMode&os.ModeType:
+		return "type changed", nil
+	case fromMode.IsDir():
+		// directory contents are compared separately
+		return "", nil
+	case fromMode.Perm() != toMode.Perm():
+		return fmt.Sprintf("mode %s -> %s", fromMode.Perm(), toMode.Perm()), nil
+	case fromMode&os.ModeSymlink != 0:
+		fromLink, err := os.Readlink(fromPath)
+		if err != nil {
+			return "", err
+		}
+		toLink, err := os.Readlink(toPath)
+		if err != nil {
+			return "", err
+		}
+		if fromLink != toLink {
+			return fmt.Sprintf("link %s -> %s", fromLink, toLink), nil
+		}
+		return "", nil
+	case !fromMode.IsRegular():
+		return "", nil
+	case fromInfo.Size() != toInfo.Size():
+		return fmt.Sprintf("size %d -> %d", fromInfo.Size(), toInfo.Size()), nil
+	case fromInfo.ModTime().Equal(toInfo.ModTime()):
+		return "", nil
+	}
+
+	if same, err := sameContents(fromPath, toPath); err != nil {
+		return "", err
+	} else if !same {
+		return "contents changed", nil
+	}
+	return "", nil
+}
+
+// sameContents compares two files of the same size byte by byte
+func sameContents(fromPath, toPath string) (bool, error) {
+	fromFile, err := os.Open(fromPath)
+	if err != nil {
+		return false, err
+	}
+	defer fromFile.Close()
+	toFile, err := os.Open(toPath)
+	if err != nil {
+		return false, err
+	}
+	defer toFile.Close()
+
+	fromBuf, toBuf := make([]byte, 32*1024), make([]byte, 32*1024)
+	for {
+		n, fromErr := io.ReadFull(fromFile, fromBuf)
+		m, toErr := io.ReadFull(toFile, toBuf)
+		if !bytes.Equal(fromBuf[:n], toBuf[:m]) {
+			return false, nil
+		}
+		if fromErr == io.EOF || fromErr == io.ErrUnexpectedEOF {
+			return toErr == fromErr, nil
+		} else if fromErr != nil {
+			return false, fromErr
+		} else if toErr != nil {
+			return false, toErr
+		}
+	}
+}
+
+// diffServices compares the services exported to two snapshots
+func diffServices(from, to []*service.Service) ([]dao.SnapshotChange, error) {
+	var fromIDs, toIDs []string
+	fromSvcs := make(map[string]*service.Service)
+	for _, svc := range from {
+		fromSvcs[svc.ID] = svc
+		fromIDs = append(fromIDs, svc.ID)
+	}
+	toSvcs := make(map[string]*service.Service)
+	for _, svc := range to {
+		toSvcs[svc.ID] = svc
+		toIDs = append(toIDs, svc.ID)
+	}
+
+	var changes []dao.SnapshotChange
+	for _, id := range union(fromIDs, toIDs) {
+		fromSvc, inFrom := fromSvcs[id]
+		toSvc, inTo := toSvcs[id]
+		switch {
+		case !inTo:
+			changes = append(changes, dao.SnapshotChange{Type: changeService, Action: changeRemoved, Name: id, Detail: fromSvc.Name})
+		case !inFrom:
+			changes = append(changes, dao.SnapshotChange{Type: changeService, Action: changeAdded, Name: id, Detail: toSvc.Name})
+		default:
+			fields, err := changedFields(fromSvc, toSvc)
+			if err != nil {
+				return nil, err
+			} else if len(fields) > 0 {
+				changes = append(changes, dao.SnapshotChange{Type: changeService, Action: changeModified, Name: id, Detail: strings.Join(fields, ", ")})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// changedFields returns the names of the top level fields that differ between
+// the JSON representations of two services
+func changedFields(from, to *service.Service) ([]string, error) {
+	fromFields, err := jsonFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := jsonFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var fromNames, toNames []string
+	for field := range fromFields {
+		fromNames = append(fromNames, field)
+	}
+	for field := range toFields {
+		toNames = append(toNames, field)
+	}
+
+	var fields []string
+	for _, field := range union(fromNames, toNames) {
+		if _, ok := ignoredServiceFields[field]; ok {
+			continue
+		}
+		if !reflect.DeepEqual(fromFields[field], toFields[field]) {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+func jsonFields(svc *service.Service) (map[string]interface{}, error) {
+	data, err := json.Marshal(svc)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// diffImages compares the images tagged for two snapshots by repo
+func diffImages(from, to []*docker.Image) []dao.SnapshotChange {
+	var fromNames, toNames []string
+	fromRepos := make(map[string]string)
+	for _, image := range from {
+		fromRepos[image.ID.Repo] = image.UUID
+		fromNames = append(fromNames, image.ID.Repo)
+	}
+	toRepos := make(map[string]string)
+	for _, image := range to {
+		toRepos[image.ID.Repo] = image.UUID
+		toNames = append(toNames, image.ID.Repo)
+	}
+
+	var changes []dao.SnapshotChange
+	for _, repo := range union(fromNames, toNames) {
+		fromUUID, inFrom := fromRepos[repo]
+		toUUID, inTo := toRepos[repo]
+		switch {
+		case !inTo:
+			changes = append(changes, dao.SnapshotChange{Type: changeImage, Action: changeRemoved, Name: repo})
+		case !inFrom:
+			changes = append(changes, dao.SnapshotChange{Type: changeImage, Action: changeAdded, Name: repo})
+		case fromUUID != toUUID:
+			changes = append(changes, dao.SnapshotChange{Type: changeImage, Action: changeModified, Name: repo, Detail: fmt.Sprintf("%s -> %s", fromUUID, toUUID)})
+		}
+	}
+	return changes
+}
+
+// union returns the distinct strings of both lists in sorted order
+func union(a, b []string) []string {
+	seen := make(map[string]struct{})
+	var result []string
+	for _, list := range [][]string{a, b} {
+		for _, s := range list {
+			if _, ok := seen[s]; !ok {
+				seen[s] = struct{}{}
+				result = append(result, s)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
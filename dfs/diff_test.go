@@ -0,0 +1,131 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/commons/docker"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/domain/service"
+)
+
+func TestDiff_diffFiles(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test-diff")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	mtime := time.Now().Add(-time.Hour)
+	write := func(name, data string) {
+		filename := filepath.Join(tmpdir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %s", filename, err)
+		} else if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed writing file %s: %s", filename, err)
+		} else if err := os.Chtimes(filename, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set times on %s: %s", filename, err)
+		}
+	}
+
+	write("from/same.txt", "cheese and crackers")
+	write("from/removed.txt", "cheese and crackers")
+	write("from/resized.txt", "cheese and crackers")
+	write("from/edited.txt", "cheese and crackers")
+	write("from/touched.txt", "cheese and crackers")
+	write("from/"+serviceJSON, "[]")
+
+	write("to/same.txt", "cheese and crackers")
+	write("to/resized.txt", "peanut butter and jelly")
+	write("to/edited.txt", "cheese and crockers")
+	write("to/touched.txt", "cheese and crackers")
+	write("to/dir/added.txt", "peanut butter and jelly")
+	write("to/"+serviceJSON, "[{}]")
+
+	// same size, different mtime
+	now := time.Now()
+	for _, name := range []string{"to/edited.txt", "to/touched.txt"} {
+		if err := os.Chtimes(filepath.Join(tmpdir, name), now, now); err != nil {
+			t.Fatalf("Failed to set times on %s: %s", name, err)
+		}
+	}
+
+	actual, err := diffFiles(filepath.Join(tmpdir, "from"), filepath.Join(tmpdir, "to"))
+	if err != nil {
+		t.Fatalf("Could not diff files: %s", err)
+	}
+
+	expected := []dao.SnapshotChange{
+		{Type: changeFile, Action: changeAdded, Name: "dir"},
+		{Type: changeFile, Action: changeAdded, Name: "dir/added.txt"},
+		{Type: changeFile, Action: changeModified, Name: "edited.txt", Detail: "contents changed"},
+		{Type: changeFile, Action: changeRemoved, Name: "removed.txt"},
+		{Type: changeFile, Action: changeModified, Name: "resized.txt", Detail: "size 19 -> 23"},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Mismatch (Expected: %v) (Actual: %v)", expected, actual)
+	}
+}
+
+func TestDiff_diffServices(t *testing.T) {
+	from := []*service.Service{
+		{ID: "tenant", Name: "tenant", Startup: "run", UpdatedAt: time.Now().Add(-time.Hour)},
+		{ID: "removed", Name: "removed", ParentServiceID: "tenant"},
+	}
+	to := []*service.Service{
+		{ID: "tenant", Name: "tenant", Startup: "run --fast", Instances: 2, UpdatedAt: time.Now()},
+		{ID: "added", Name: "added", ParentServiceID: "tenant"},
+	}
+
+	actual, err := diffServices(from, to)
+	if err != nil {
+		t.Fatalf("Could not diff services: %s", err)
+	}
+
+	expected := []dao.SnapshotChange{
+		{Type: changeService, Action: changeAdded, Name: "added", Detail: "added"},
+		{Type: changeService, Action: changeRemoved, Name: "removed", Detail: "removed"},
+		{Type: changeService, Action: changeModified, Name: "tenant", Detail: "Instances, Startup"},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Mismatch (Expected: %v) (Actual: %v)", expected, actual)
+	}
+}
+
+func TestDiff_diffImages(t *testing.T) {
+	from := []*docker.Image{
+		{UUID: "1", ID: commons.ImageID{User: "tenant", Repo: "core", Tag: "20141010-101010"}},
+		{UUID: "2", ID: commons.ImageID{User: "tenant", Repo: "opentsdb", Tag: "20141010-101010"}},
+	}
+	to := []*docker.Image{
+		{UUID: "3", ID: commons.ImageID{User: "tenant", Repo: "core", Tag: "20141011-101010"}},
+		{UUID: "4", ID: commons.ImageID{User: "tenant", Repo: "hbase", Tag: "20141011-101010"}},
+	}
+
+	expected := []dao.SnapshotChange{
+		{Type: changeImage, Action: changeModified, Name: "core", Detail: "1 -> 3"},
+		{Type: changeImage, Action: changeAdded, Name: "hbase"},
+		{Type: changeImage, Action: changeRemoved, Name: "opentsdb"},
+	}
+	if actual := diffImages(from, to); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Mismatch (Expected: %v) (Actual: %v)", expected, actual)
+	}
+}
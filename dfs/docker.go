@@ -28,8 +28,6 @@ import (
 	"github.com/control-center/serviced/domain/service"
 	"github.com/control-center/serviced/domain/servicedefinition"
 	"github.com/control-center/serviced/domain/servicetemplate"
-	"github.com/control-center/serviced/zzk"
-	zkservice "github.com/control-center/serviced/zzk/service"
 	"github.com/zenoss/glog"
 	dockerclient "github.com/zenoss/go-dockerclient"
 )
@@ -44,6 +42,16 @@ type imagemeta struct {
 	Filename string
 }
 
+// squashmeta records in a snapshot that the commit before it squashed the
+// image of the tenant
+type squashmeta struct {
+	Image  string // repotag of the squashed image
+	Base   string // layer that the image was squashed down to
+	UUID   string // docker ID of the squashed image
+	Before int    // number of layers before squashing
+	After  int    // number of layers after squashing
+}
+
 // Commit will merge a container into existing services' image
 func (dfs *DistributedFilesystem) Commit(dockerID string) (string, error) {
 	// get the container and verify that it is not running
@@ -75,10 +83,10 @@ func (dfs *DistributedFilesystem) Commit(dockerID string) (string, error) {
 	}
 
 	// check the number of image layers
-	if layers, err := image.History(); err != nil {
+	if layers, err := dockerAPI.ImageHistory(image.UUID); err != nil {
 		glog.Errorf("Could not check history for image %s: %s", image.ID, err)
 		return "", err
-	} else if numLayers := len(layers); numLayers >= layer.WARN_LAYER_COUNT {
+	} else if numLayers := len(layers); numLayers >= layer.WARN_LAYER_COUNT && dfs.squashLimit <= 0 {
 		glog.Warningf("Image %s has %d layers and is approaching the maximum (%d). Please squash image layers.",
 			image.ID, numLayers, layer.MAX_LAYER_COUNT)
 	} else {
@@ -86,18 +94,32 @@ func (dfs *DistributedFilesystem) Commit(dockerID string) (string, error) {
 	}
 
 	// commit the container to the image and tag
-	if _, err := ctr.Commit(image.ID.BaseName()); err != nil {
+	committed, err := ctr.Commit(image.ID.BaseName())
+	if err != nil {
 		glog.Errorf("Error trying to commit %s to %s: %s", dockerID, image.ID, err)
 		return "", err
 	}
 
+	// squash the image before it runs out of layers
+	squashed, err := dfs.squash(tenantID, committed)
+	if err != nil {
+		glog.Errorf("Could not squash image %s: %s", committed.ID, err)
+		return "", err
+	}
+
+	// record the new image on the services that use it
+	if err := dfs.pinImage(image.ID); err != nil {
+		glog.Errorf("Could not update the image of services using %s: %s", image.ID, err)
+		return "", err
+	}
+
 	// desynchronize any running containers
 	if err := dfs.desynchronize(image.ID, time.Now()); err != nil {
 		glog.Warningf("Could not denote all desynchronized services: %s", err)
 	}
 
 	// snapshot the filesystem and images
-	snapshotID, err := dfs.Snapshot(tenantID)
+	snapshotID, err := dfs.snapshot(tenantID, squashed)
 	if err != nil {
 		glog.Errorf("Could not create a snapshot of the new image %s: %s", tenantID, err)
 		return "", err
@@ -106,6 +128,82 @@ func (dfs *DistributedFilesystem) Commit(dockerID string) (string, error) {
 	return snapshotID, nil
 }
 
+// squash flattens the layers that a tenant added to its base image once the
+// image has squashLimit layers.  The base image is the closest ancestor that
+// is tagged outside of the tenant, e.g. the image of the template.  Returns
+// nil if the image was not squashed.
+func (dfs *DistributedFilesystem) squash(tenantID string, image *docker.Image) (*squashmeta, error) {
+	if dfs.squashLimit <= 0 {
+		return nil, nil
+	}
+	imageID := image.ID
+	if imageID.Tag == "" {
+		imageID.Tag = DockerLatest
+	}
+
+	layers, err := dockerAPI.ImageHistory(image.UUID)
+	if err != nil {
+		return nil, err
+	} else if len(layers) < dfs.squashLimit {
+		return nil, nil
+	}
+
+	images, err := dockerAPI.Images()
+	if err != nil {
+		return nil, err
+	}
+	bases := make(map[string]struct{})
+	for _, img := range images {
+		if img.ID.User != tenantID {
+			bases[img.UUID] = struct{}{}
+		}
+	}
+
+	base, ok := squashBase(layers, bases, dfs.squashLimit)
+	if !ok {
+		glog.Warningf("Image %s has %d layers, but has no base image with fewer than %d layers to squash it to. Please squash image layers.", imageID, len(layers), dfs.squashLimit)
+		return nil, nil
+	}
+
+	dfs.log("Squashing image %s down to %s", imageID, base)
+	uuid, err := dockerAPI.SquashImage(imageID.String(), base)
+	if err != nil {
+		return nil, err
+	}
+	if docker.UseRegistry() {
+		if err := docker.PushImage(imageID.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	squashed, err := dockerAPI.ImageHistory(uuid)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("Squashed image %s from %d to %d layers (%s)", imageID, len(layers), len(squashed), uuid)
+	dfs.log("Squashed image %s from %d to %d layers", imageID, len(layers), len(squashed))
+	return &squashmeta{Image: imageID.String(), Base: base, UUID: uuid, Before: len(layers), After: len(squashed)}, nil
+}
+
+// squashBase returns the closest of the layers (ordered from the top) that is
+// one of the bases and is far enough below the limit to leave room for more
+// commits after squashing.
+func squashBase(layers []string, bases map[string]struct{}, limit int) (string, bool) {
+	for i := 1; i < len(layers); i++ {
+		if _, ok := bases[layers[i]]; !ok {
+			continue
+		}
+		// the squashed image has the layers of the base, plus one that adds
+		// the tenant's files and, if the tenant deleted any files of the
+		// base, one that removes them
+		if len(layers)-i+2 < limit {
+			return layers[i], true
+		}
+		return "", false
+	}
+	return "", false
+}
+
 func (dfs *DistributedFilesystem) desynchronize(imageID commons.ImageID, commit time.Time) error {
 	svcs, err := dfs.facade.GetServices(datastore.Get(), dao.ServiceRequest{})
 	if err != nil {
@@ -114,12 +212,6 @@ func (dfs *DistributedFilesystem) desynchronize(imageID commons.ImageID, commit
 	}
 
 	for _, svc := range svcs {
-		conn, err := zzk.GetLocalConnection(zzk.GeneratePoolPath(svc.PoolID))
-		if err != nil {
-			glog.Errorf("Could not acquire connection to coordinator (%s): %s", svc.PoolID, err)
-			return err
-		}
-
 		// figure out which services use the provided image
 		img, err := commons.ParseImageID(svc.ImageID)
 		if err != nil {
@@ -131,7 +223,7 @@ func (dfs *DistributedFilesystem) desynchronize(imageID commons.ImageID, commit
 			continue
 		}
 
-		states, err := zkservice.GetServiceStates(conn, svc.ID)
+		states, err := zkAPI.GetServiceStates(svc.PoolID, svc.ID)
 		if err != nil {
 			glog.Errorf("Could not get running services for %s (%s): %s", svc.Name, svc.ID)
 			return err
@@ -141,7 +233,7 @@ func (dfs *DistributedFilesystem) desynchronize(imageID commons.ImageID, commit
 			// check if the instance has been running since before the commit
 			if state.IsRunning() && state.Started.Before(commit) {
 				state.InSync = false
-				if err := zkservice.UpdateServiceState(conn, &state); err != nil {
+				if err := zkAPI.UpdateServiceState(svc.PoolID, &state); err != nil {
 					glog.Errorf("Could not update service state %s for %s (%s) as out of sync: %s", state.ID, svc.Name, svc.ID, err)
 					return err
 				}
@@ -151,6 +243,40 @@ func (dfs *DistributedFilesystem) desynchronize(imageID commons.ImageID, commit
 	return nil
 }
 
+// pinImage records the docker ID of the latest image on every service that
+// uses it, so that agents can verify the image they run.
+func (dfs *DistributedFilesystem) pinImage(imageID commons.ImageID) error {
+	latest := imageID
+	latest.Tag = DockerLatest
+	img, err := dockerAPI.FindImage(latest.String())
+	if err != nil {
+		glog.Errorf("Could not find image %s: %s", latest, err)
+		return err
+	}
+
+	svcs, err := dfs.facade.GetServices(datastore.Get(), dao.ServiceRequest{})
+	if err != nil {
+		glog.Errorf("Could not get all services: %s", err)
+		return err
+	}
+
+	for _, svc := range svcs {
+		if svcImageID, err := commons.ParseImageID(svc.ImageID); err != nil || !svcImageID.Equals(imageID) {
+			continue
+		} else if svc.ImageDigest == img.UUID {
+			continue
+		}
+
+		svc.ImageDigest = img.UUID
+		if err := dfs.facade.UpdateService(datastore.Get(), svc); err != nil {
+			glog.Errorf("Could not update the image of %s (%s): %s", svc.Name, svc.ID, err)
+			return err
+		}
+		glog.V(2).Infof("Pinned %s (%s) to image %s", svc.Name, svc.ID, img.UUID)
+	}
+	return nil
+}
+
 func (dfs *DistributedFilesystem) exportImages(dirpath string, templates map[string]servicetemplate.ServiceTemplate, services []service.Service) ([]imagemeta, error) {
 	tRepos, sRepos := getImageRefs(templates, services)
 	imageTags, err := getImageTags(tRepos, sRepos)
@@ -180,7 +306,7 @@ func (dfs *DistributedFilesystem) exportImages(dirpath string, templates map[str
 			}
 		}
 
-		if err := saveImage(tag, filename); err == dockerclient.ErrNoSuchImage {
+		if err := dockerAPI.SaveImage(tag, filename); err == dockerclient.ErrNoSuchImage {
 			glog.Warningf("Docker image %s was referenced, but does not exist. Skipping.", tag)
 			continue
 		} else if err != nil {
@@ -221,7 +347,7 @@ func (dfs *DistributedFilesystem) importImages(dirpath string, images []imagemet
 }
 
 func findImage(tenantID, uuid, tag string) (*docker.Image, error) {
-	images, err := docker.Images()
+	images, err := dockerAPI.Images()
 	if err != nil {
 		return nil, err
 	}
@@ -236,7 +362,7 @@ func findImage(tenantID, uuid, tag string) (*docker.Image, error) {
 }
 
 func findImages(tenantID, tag string) ([]*docker.Image, error) {
-	images, err := docker.Images()
+	images, err := dockerAPI.Images()
 	if err != nil {
 		return nil, err
 	}
@@ -251,7 +377,7 @@ func findImages(tenantID, tag string) ([]*docker.Image, error) {
 }
 
 func searchImagesByTenantID(tenantID string) ([]*docker.Image, error) {
-	images, err := docker.Images()
+	images, err := dockerAPI.Images()
 	if err != nil {
 		return nil, err
 	}
@@ -273,11 +399,11 @@ func tag(tenantID, oldtag, newtag string) error {
 
 	var tagged []*docker.Image
 	for _, image := range images {
-		t, err := image.Tag(fmt.Sprintf("%s:%s", image.ID.BaseName(), newtag))
+		t, err := dockerAPI.TagImage(image, fmt.Sprintf("%s:%s", image.ID.BaseName(), newtag))
 		if err != nil {
 			glog.Errorf("Error while adding tags; rolling back: %s", err)
 			for _, t := range tagged {
-				if err := t.Delete(); err != nil {
+				if err := dockerAPI.DeleteImage(t); err != nil {
 					glog.Errorf("Could not untag image %s: %s", t.ID, err)
 				}
 			}
@@ -290,7 +416,7 @@ func tag(tenantID, oldtag, newtag string) error {
 
 func getImageTags(templateRepos []string, serviceRepos []string) (map[string][]string, error) {
 	// make a map of all docker images
-	images, err := docker.Images()
+	images, err := dockerAPI.Images()
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,142 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/volume/dir"
+)
+
+func TestDocker_squashBase(t *testing.T) {
+	// ordered from the top layer down
+	layers := []string{"commit3", "commit2", "commit1", "template", "os2", "os1"}
+	bases := map[string]struct{}{"template": struct{}{}, "os1": struct{}{}}
+
+	// squashing to the template leaves 4 layers, or 5 if the commits removed
+	// files of the template
+	if base, ok := squashBase(layers, bases, 6); !ok || base != "template" {
+		t.Errorf("Expected base template, got %q (%t)", base, ok)
+	}
+
+	// not enough room below the limit if files were removed
+	if base, ok := squashBase(layers, bases, 5); ok {
+		t.Errorf("Expected no base, got %q", base)
+	}
+	if base, ok := squashBase(layers, bases, 4); ok {
+		t.Errorf("Expected no base, got %q", base)
+	}
+
+	// the top layer is never a base
+	if base, ok := squashBase(layers, map[string]struct{}{"commit3": struct{}{}}, 10); ok {
+		t.Errorf("Expected no base, got %q", base)
+	}
+
+	// no base image at all
+	if base, ok := squashBase(layers, map[string]struct{}{}, 10); ok {
+		t.Errorf("Expected no base, got %q", base)
+	}
+}
+
+func TestDocker_squash(t *testing.T) {
+	varpath, err := ioutil.TempDir("", "dfs-squash-test")
+	if err != nil {
+		t.Fatalf("Could not create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(varpath)
+
+	f := newTestFacade(service.Service{ID: "tenant", Name: "tenant", PoolID: "default", ImageID: "localhost:5000/tenant/core"})
+	d := &testDocker{layers: map[string][]string{
+		"commit3":  {"commit3", "commit2", "commit1", "template", "os1"},
+		"template": {"template", "os1"},
+	}}
+	d.add("template", "zenoss/core:latest")
+	d.add("commit3", "localhost:5000/tenant/core:latest")
+	defer setTestAPIs(&testZK{}, d)()
+
+	dfs := &DistributedFilesystem{vfs: dir.DriverName, varpath: varpath, dockerHost: "localhost", dockerPort: 5000, facade: f, timeout: time.Minute, squashLimit: 6}
+	image, err := d.FindImage("localhost:5000/tenant/core:latest")
+	if err != nil {
+		t.Fatalf("Could not find image: %s", err)
+	}
+
+	// below the limit, nothing is squashed
+	if squashed, err := dfs.squash("tenant", image); err != nil || squashed != nil {
+		t.Fatalf("Expected no squash, got %+v (%v)", squashed, err)
+	}
+
+	dfs.squashLimit = 5
+	squashed, err := dfs.squash("tenant", image)
+	if err != nil {
+		t.Fatalf("Could not squash: %s", err)
+	}
+	expected := &squashmeta{Image: "localhost:5000/tenant/core:latest", Base: "template", UUID: "squashed-commit3", Before: 5, After: 3}
+	if !reflect.DeepEqual(squashed, expected) {
+		t.Fatalf("Mismatch (Expected: %+v) (Actual: %+v)", expected, squashed)
+	}
+	if uuid := d.uuid("localhost:5000/tenant/core:latest"); uuid != "squashed-commit3" {
+		t.Errorf("Expected the squashed image to be tagged latest, got %q", uuid)
+	}
+
+	// the snapshot after the commit records the squash
+	label, err := dfs.snapshot("tenant", squashed)
+	if err != nil {
+		t.Fatalf("Could not snapshot: %s", err)
+	}
+	v, err := dfs.GetVolume("tenant")
+	if err != nil {
+		t.Fatalf("Could not get volume: %s", err)
+	}
+	var recorded squashmeta
+	if err := importJSON(filepath.Join(v.SnapshotPath(label), squashJSON), &recorded); err != nil {
+		t.Fatalf("Could not read the squash record: %s", err)
+	} else if !reflect.DeepEqual(&recorded, expected) {
+		t.Errorf("Mismatch (Expected: %+v) (Actual: %+v)", expected, &recorded)
+	}
+}
+
+func TestDocker_pinImage(t *testing.T) {
+	f := newTestFacade(
+		service.Service{ID: "tenant", ImageID: "localhost:5000/tenant/core", ImageDigest: "abc"},
+		service.Service{ID: "child", ParentServiceID: "tenant", ImageID: "localhost:5000/tenant/core:latest"},
+		service.Service{ID: "other", ParentServiceID: "tenant", ImageID: "localhost:5000/tenant/other", ImageDigest: "xyz"},
+	)
+	d := &testDocker{}
+	d.add("def", "localhost:5000/tenant/core:latest")
+	d.add("uvw", "localhost:5000/tenant/other:latest")
+	defer setTestAPIs(&testZK{}, d)()
+
+	dfs := &DistributedFilesystem{facade: f}
+	imageID, err := commons.ParseImageID("localhost:5000/tenant/core")
+	if err != nil {
+		t.Fatalf("Could not parse image: %s", err)
+	}
+	if err := dfs.pinImage(*imageID); err != nil {
+		t.Fatalf("Could not pin image: %s", err)
+	}
+
+	// only the services of the committed image are pinned to its new digest
+	for id, digest := range map[string]string{"tenant": "def", "child": "def", "other": "xyz"} {
+		if actual := f.services[id].ImageDigest; actual != digest {
+			t.Errorf("Expected %s to be pinned to %q, got %q", id, digest, actual)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/servicetemplate"
+	"github.com/zenoss/glog"
+)
+
+// ExportSnapshot writes a single tenant snapshot, along with its docker
+// images, to a file that can be imported into another serviced cluster.  The
+// file has the same layout as a backup that contains only that tenant and no
+// templates.  Returns the name of the file that was written.
+func (dfs *DistributedFilesystem) ExportSnapshot(snapshotID, filename string) (string, error) {
+	tenantID, timestamp, err := parseLabel(snapshotID)
+	if err != nil {
+		glog.Errorf("Could not export snapshot %s: %s", snapshotID, err)
+		return "", err
+	}
+
+	tenant, err := dfs.facade.GetService(datastore.Get(), tenantID)
+	if err != nil {
+		glog.Errorf("Could not find service %s: %s", tenantID, err)
+		return "", err
+	} else if tenant == nil {
+		glog.Errorf("Service %s not found", tenantID)
+		return "", fmt.Errorf("service not found")
+	}
+
+	snapshotVolume, err := dfs.GetVolume(tenant.ID)
+	if err != nil {
+		glog.Errorf("Could not acquire the volume for %s (%s): %s", tenant.Name, tenant.ID, err)
+		return "", err
+	}
+
+	if snapshots, err := snapshotVolume.Snapshots(); err != nil {
+		glog.Errorf("Could not list snapshots for %s (%s): %s", tenant.Name, tenant.ID, err)
+		return "", err
+	} else if !hasLabel(snapshots, snapshotID) {
+		return "", fmt.Errorf("snapshot %s not found", snapshotID)
+	}
+
+	dirpath := filepath.Join(getHome(), "export", snapshotID)
+	if err := os.RemoveAll(dirpath); err != nil {
+		glog.Errorf("Could not remove %s: %s", dirpath, err)
+		return "", err
+	}
+
+	defer func() {
+		if err := os.RemoveAll(dirpath); err != nil {
+			glog.Warningf("Could not remove %s: %s", dirpath, err)
+		}
+	}()
+
+	for _, dir := range []string{imageDir, snapshotDir} {
+		p := filepath.Join(dirpath, dir)
+		if err := mkdir(p); err != nil {
+			glog.Errorf("Could not create %s: %s", p, err)
+			return "", err
+		}
+	}
+
+	// snapshot exports don't carry templates, but the file is still a valid
+	// backup
+	if err := exportJSON(filepath.Join(dirpath, templateJSON), map[string]servicetemplate.ServiceTemplate{}); err != nil {
+		glog.Errorf("Could not export service templates: %s", err)
+		return "", err
+	}
+
+	// export the images of the snapshot, tagged as both the snapshot and the
+	// latest image of the tenant
+	dfs.log("Exporting docker images")
+	images, err := findImages(tenantID, timestamp)
+	if err != nil {
+		glog.Errorf("Could not find images for %s: %s", snapshotID, err)
+		return "", err
+	}
+
+	var imageTags []imagemeta
+	for i, image := range images {
+		latest := image.ID
+		latest.Tag = DockerLatest
+		metadata := imagemeta{
+			Filename: fmt.Sprintf("%d.tar", i),
+			UUID:     image.UUID,
+			Tags:     []string{image.ID.String(), latest.String()},
+		}
+		if err := dockerAPI.SaveImage(image.ID.String(), filepath.Join(dirpath, imageDir, metadata.Filename)); err != nil {
+			glog.Errorf("Could not export %s: %s", image.ID, err)
+			return "", err
+		}
+		imageTags = append(imageTags, metadata)
+	}
+	if err := exportJSON(filepath.Join(dirpath, imageJSON), &imageTags); err != nil {
+		glog.Errorf("Could not export images: %s", err)
+		return "", err
+	}
+	dfs.log("Docker image export successful")
+
+	// export the volume and service definitions
+	dfs.log("Exporting %s", snapshotID)
+	src := snapshotVolume.SnapshotPath(snapshotID)
+	if err := exportTGZ(src, filepath.Join(dirpath, snapshotDir, fmt.Sprintf("%s.tgz", snapshotID))); err != nil {
+		glog.Errorf("Could not export %s: %s", src, err)
+		return "", err
+	}
+
+	if filename, err = dfs.writeBackup(dirpath, filename); err != nil {
+		return "", err
+	}
+	dfs.log("Snapshot %s exported to %s", snapshotID, filename)
+
+	return filename, nil
+}
+
+// ImportSnapshot loads a tenant snapshot that was written by ExportSnapshot.
+// The services, images and volume of the tenant are replaced with the
+// contents of the snapshot; other tenants are not affected.  Returns the ID of
+// the tenant.
+func (dfs *DistributedFilesystem) ImportSnapshot(filename string) (string, error) {
+	dirpath := filepath.Join(getHome(), "import")
+	cleanup, err := dfs.extractBackup(dirpath, filename)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	snapshotFiles, err := ls(filepath.Join(dirpath, snapshotDir))
+	if err != nil {
+		glog.Errorf("Could not list contents of %s: %s", filepath.Join(dirpath, snapshotDir), err)
+		return "", err
+	} else if len(snapshotFiles) != 1 {
+		return "", fmt.Errorf("expected 1 snapshot in %s, found %d", filename, len(snapshotFiles))
+	}
+
+	snapshotID := strings.TrimSuffix(snapshotFiles[0], ".tgz")
+	tenantID, _, err := parseLabel(snapshotID)
+	if err != nil {
+		glog.Errorf("Cannot import %s: %s", snapshotFiles[0], err)
+		return "", err
+	}
+
+	// fail if any services of the tenant are running
+	dfs.log("Checking running services")
+	if err := dfs.checkTenantStopped(tenantID); err != nil {
+		glog.Errorf("Cannot import %s from %s: %s", snapshotID, filename, err)
+		return "", err
+	}
+
+	if err := dfs.restoreTenant(dirpath, filename, snapshotID, tenantID, "", ""); err != nil {
+		return "", err
+	}
+	dfs.log("Successfully imported %s", snapshotID)
+
+	return tenantID, nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/commons/docker"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/zenoss/glog"
+)
+
+// CollectImages removes the tenant images in the local registry that are no
+// longer needed, from both docker and the registry.  The latest image of each
+// tenant, the images of its retained snapshots and any image used by a
+// service are kept.  If dryRun is set, nothing is removed.  Returns the images
+// that were (or would be) removed.
+func (dfs *DistributedFilesystem) CollectImages(dryRun bool) ([]string, error) {
+	_, _, garbage, err := dfs.findGarbage()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]string, len(garbage))
+	for i, image := range garbage {
+		removed[i] = image.ID.String()
+		if dryRun {
+			continue
+		}
+
+		glog.Infof("Removing unused image %s (%s)", image.ID, image.UUID)
+		if err := dockerAPI.DeleteImage(image); err != nil {
+			glog.Warningf("Could not remove image %s: %s", image.ID, err)
+		}
+		if docker.UseRegistry() {
+			if err := docker.DeleteRegistryTag(image.ID.String()); err != nil {
+				glog.Warningf("Could not remove %s from the registry: %s", image.ID, err)
+			}
+		}
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
+
+// KeptImages returns the images of the local registry that CollectImages
+// keeps, so that the agents can remove the other images of the registry from
+// their own docker.
+func (dfs *DistributedFilesystem) KeptImages() (*dao.KeptImages, error) {
+	svcs, images, garbage, err := dfs.findGarbage()
+	if err != nil {
+		return nil, err
+	}
+
+	registry := fmt.Sprintf("%s:%d", dfs.dockerHost, dfs.dockerPort)
+	kept := usedImages(svcs)
+	for _, image := range images {
+		if image.ID.Registry() == registry {
+			kept[image.ID.String()] = struct{}{}
+		}
+	}
+	for _, image := range garbage {
+		delete(kept, image.ID.String())
+	}
+
+	result := &dao.KeptImages{Registry: registry, Images: make([]string, 0, len(kept))}
+	for imageID := range kept {
+		result.Images = append(result.Images, imageID)
+	}
+	sort.Strings(result.Images)
+	return result, nil
+}
+
+// findGarbage returns all services, the images in docker and the images that
+// are no longer needed
+func (dfs *DistributedFilesystem) findGarbage() ([]service.Service, []*docker.Image, []*docker.Image, error) {
+	svcs, err := dfs.facade.GetServices(datastore.Get(), dao.ServiceRequest{})
+	if err != nil {
+		glog.Errorf("Could not get all services: %s", err)
+		return nil, nil, nil, err
+	}
+
+	// find the snapshots that are retained by each tenant
+	snapshots := make(map[string]map[string]struct{})
+	for _, svc := range svcs {
+		if svc.ParentServiceID != "" {
+			continue
+		}
+		v, err := dfs.GetVolume(svc.ID)
+		if err != nil {
+			glog.Errorf("Could not get the volume for %s (%s): %s", svc.Name, svc.ID, err)
+			return nil, nil, nil, err
+		}
+		labels, err := v.Snapshots()
+		if err != nil {
+			glog.Errorf("Could not list snapshots for %s (%s): %s", svc.Name, svc.ID, err)
+			return nil, nil, nil, err
+		}
+		snapshots[svc.ID] = make(map[string]struct{})
+		for _, label := range labels {
+			if _, timestamp, err := parseLabel(label); err == nil {
+				snapshots[svc.ID][timestamp] = struct{}{}
+			}
+		}
+	}
+
+	images, err := dockerAPI.Images()
+	if err != nil {
+		glog.Errorf("Could not get docker images: %s", err)
+		return nil, nil, nil, err
+	}
+
+	registry := fmt.Sprintf("%s:%d", dfs.dockerHost, dfs.dockerPort)
+	return svcs, images, imageGarbage(images, registry, svcs, snapshots), nil
+}
+
+// imageGarbage returns the tenant images in the registry that are not used by
+// a service, are not the latest image of a tenant and are not tagged for a
+// retained snapshot.  snapshots has the snapshot timestamps of every tenant.
+// Images of tenants that no longer exist are only removed from repos that
+// have snapshot tags, so that other images in the registry are left alone.
+func imageGarbage(images []*docker.Image, registry string, svcs []service.Service, snapshots map[string]map[string]struct{}) []*docker.Image {
+	used := usedImages(svcs)
+
+	// repos that were tagged by a snapshot
+	snapshotRepos := make(map[string]struct{})
+	for _, image := range images {
+		if isSnapshotTag(image.ID.Tag) {
+			snapshotRepos[image.ID.BaseName()] = struct{}{}
+		}
+	}
+
+	var result []*docker.Image
+	for _, image := range images {
+		if image.ID.Registry() != registry {
+			continue
+		} else if _, ok := used[image.ID.String()]; ok {
+			continue
+		}
+
+		if timestamps, ok := snapshots[image.ID.User]; ok {
+			// the tenant still exists
+			if _, ok := timestamps[image.ID.Tag]; ok || !isSnapshotTag(image.ID.Tag) {
+				continue
+			}
+		} else if _, ok := snapshotRepos[image.ID.BaseName()]; !ok {
+			continue
+		} else if image.ID.Tag != DockerLatest && !isSnapshotTag(image.ID.Tag) {
+			continue
+		}
+		result = append(result, image)
+	}
+	return result
+}
+
+// usedImages returns the images of the services, tagged latest if they have
+// no tag
+func usedImages(svcs []service.Service) map[string]struct{} {
+	used := make(map[string]struct{})
+	for _, svc := range svcs {
+		if imageID, err := commons.ParseImageID(svc.ImageID); err == nil {
+			if imageID.Tag == "" {
+				imageID.Tag = DockerLatest
+			}
+			used[imageID.String()] = struct{}{}
+		}
+	}
+	return used
+}
+
+// isSnapshotTag returns true if the tag is the timestamp of a snapshot
+func isSnapshotTag(tag string) bool {
+	_, err := time.Parse(timeFormat, tag)
+	return err == nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/commons/docker"
+	"github.com/control-center/serviced/domain/service"
+)
+
+func TestGC_imageGarbage(t *testing.T) {
+	var images []*docker.Image
+	for _, tag := range []string{
+		"localhost:5000/tenant/core:latest",
+		"localhost:5000/tenant/core:20141201-120000", // retained snapshot
+		"localhost:5000/tenant/core:20141101-120000", // deleted snapshot
+		"localhost:5000/tenant/core:custom",
+		"localhost:5000/tenant/pinned:20141001-120000", // used by a service
+		"localhost:5000/removed/core:latest",
+		"localhost:5000/removed/core:20141101-120000",
+		"localhost:5000/removed/other:latest", // never snapshotted
+		"zenoss/core:20141101-120000",         // not in the registry
+	} {
+		imageID, err := commons.ParseImageID(tag)
+		if err != nil {
+			t.Fatalf("Could not parse %s: %s", tag, err)
+		}
+		images = append(images, &docker.Image{UUID: tag, ID: *imageID})
+	}
+
+	svcs := []service.Service{
+		{ID: "tenant", ImageID: "localhost:5000/tenant/core"},
+		{ID: "child", ParentServiceID: "tenant", ImageID: "localhost:5000/tenant/pinned:20141001-120000"},
+	}
+	snapshots := map[string]map[string]struct{}{
+		"tenant": {"20141201-120000": struct{}{}},
+	}
+
+	var actual []string
+	for _, image := range imageGarbage(images, "localhost:5000", svcs, snapshots) {
+		actual = append(actual, image.ID.String())
+	}
+
+	expected := []string{
+		"localhost:5000/tenant/core:20141101-120000",
+		"localhost:5000/removed/core:latest",
+		"localhost:5000/removed/core:20141101-120000",
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Mismatch (Expected: %v) (Actual: %v)", expected, actual)
+	}
+}
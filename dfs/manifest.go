@@ -0,0 +1,231 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/control-center/serviced/commons"
+	"github.com/zenoss/glog"
+)
+
+const (
+	manifestJSON    = "manifest.json"
+	manifestVersion = 1
+)
+
+// manifest describes the contents of a backup file
+type manifest struct {
+	Version        int
+	Created        time.Time
+	Checksums      map[string]string // sha256 of each file, keyed by its path in the archive
+	Encryption     string            `json:",omitempty"` // method used to encrypt the backup file
+	KeyFingerprint string            `json:",omitempty"` // fingerprint of the public key needed to decrypt the backup file
+}
+
+// writeManifest computes the checksum of every file below dirpath and writes
+// the result to the manifest file at the root of dirpath.  If the backup is
+// going to be encrypted, the key fingerprint is recorded as well.
+func writeManifest(dirpath string, c *backupCipher) error {
+	m := manifest{Version: manifestVersion, Created: time.Now().UTC(), Checksums: make(map[string]string)}
+	if c != nil {
+		fingerprint, err := c.Fingerprint()
+		if err != nil {
+			return err
+		}
+		m.Encryption, m.KeyFingerprint = c.Method(), fingerprint
+	}
+
+	err := filepath.Walk(dirpath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(dirpath, p)
+		if err != nil {
+			return err
+		} else if name == manifestJSON {
+			return nil
+		}
+
+		file, err := os.Open(p)
+		if err != nil {
+			glog.Errorf("Could not open %s: %s", p, err)
+			return err
+		}
+		defer file.Close()
+
+		checksum, err := sha256sum(file)
+		if err != nil {
+			glog.Errorf("Could not compute checksum of %s: %s", p, err)
+			return err
+		}
+		m.Checksums[filepath.ToSlash(name)] = checksum
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return exportJSON(filepath.Join(dirpath, manifestJSON), &m)
+}
+
+// verifyArchive reads the backup file and checks its structure, the checksums
+// in its manifest, and the image metadata.  Nothing is extracted to disk.
+func verifyArchive(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		glog.Errorf("Could not open %s: %s", filename, err)
+		return err
+	}
+	defer file.Close()
+
+	_, err = verifyArchiveStream(file, filename)
+	return err
+}
+
+// verifyArchiveStream checks the backup read from r like verifyArchive and
+// returns the total size of the files in it.
+func verifyArchiveStream(r io.Reader, filename string) (int64, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a gzip file: %s", filename, err)
+	}
+	defer gz.Close()
+
+	var (
+		m         *manifest
+		images    []imagemeta
+		checksums = make(map[string]string)
+		size      int64
+	)
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("could not read %s: %s", filename, err)
+		}
+
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		size += hdr.Size
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		switch name {
+		case manifestJSON:
+			m = new(manifest)
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return 0, fmt.Errorf("could not read manifest: %s", err)
+			}
+		case imageJSON:
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return 0, fmt.Errorf("could not read %s: %s", name, err)
+			} else if err := json.Unmarshal(data, &images); err != nil {
+				return 0, fmt.Errorf("could not read image metadata: %s", err)
+			}
+			checksums[name] = sha256hex(data)
+		default:
+			checksum, err := sha256sum(tr)
+			if err != nil {
+				return 0, fmt.Errorf("could not read %s: %s", name, err)
+			}
+			checksums[name] = checksum
+		}
+	}
+
+	// verify the structure of the archive
+	if m == nil {
+		return 0, fmt.Errorf("%s not found; backup was created without a manifest", manifestJSON)
+	} else if m.Version > manifestVersion {
+		return 0, fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	for _, name := range []string{templateJSON, imageJSON} {
+		if _, ok := checksums[name]; !ok {
+			return 0, fmt.Errorf("missing %s", name)
+		}
+	}
+
+	// verify the checksums
+	for name, expected := range m.Checksums {
+		if actual, ok := checksums[name]; !ok {
+			return 0, fmt.Errorf("missing %s", name)
+		} else if actual != expected {
+			return 0, fmt.Errorf("checksum mismatch for %s", name)
+		}
+	}
+	for name := range checksums {
+		if _, ok := m.Checksums[name]; !ok {
+			return 0, fmt.Errorf("unexpected file %s", name)
+		}
+	}
+
+	// verify the image metadata
+	for _, metadata := range images {
+		if metadata.UUID == "" {
+			return 0, fmt.Errorf("image %s has no uuid", metadata.Filename)
+		} else if len(metadata.Tags) == 0 {
+			return 0, fmt.Errorf("image %s (%s) has no tags", metadata.Filename, metadata.UUID)
+		} else if _, ok := checksums[path.Join(imageDir, metadata.Filename)]; !ok {
+			return 0, fmt.Errorf("image %s (%s) not found", metadata.Filename, metadata.UUID)
+		}
+		for _, tag := range metadata.Tags {
+			if _, err := commons.ParseImageID(tag); err != nil {
+				return 0, fmt.Errorf("image %s (%s) has an invalid tag %s: %s", metadata.Filename, metadata.UUID, tag, err)
+			}
+		}
+	}
+
+	// verify the snapshots
+	for name := range checksums {
+		if dir, file := path.Split(name); path.Clean(dir) == snapshotDir {
+			if _, _, err := parseLabel(strings.TrimSuffix(file, ".tgz")); err != nil {
+				return 0, fmt.Errorf("invalid snapshot %s: %s", file, err)
+			}
+		}
+	}
+
+	return size, nil
+}
+
+func sha256sum(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sha256hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -109,3 +109,12 @@ func Mount(nfsPath, localPath string) error {
 	return nil
 }
 
+// Unmount lazily unmounts the nfs mount at localPath, so that a new export
+// can be mounted in its place even if the old server is unreachable
+func Unmount(localPath string) error {
+	cmd := commandFactory("umount", "-f", "-l", localPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/zenoss/glog"
+)
+
+var mkdirTemp = ioutil.TempDir
+
+// volumesDir is the directory of the export that holds the tenant volumes
+const volumesDir = "volumes"
+
+// ReplicaServer is a Server that can copy the export of another host.  While
+// another host is the storage leader, it keeps a copy of the tenant volumes
+// of the leader's export in its base path so that it can take over exporting
+// it.
+type ReplicaServer struct {
+	*Server
+}
+
+// NewReplicaServer returns a nfs.ReplicaServer that exports basePath once it
+// becomes the storage leader.
+func NewReplicaServer(basePath, exportedName, network string) (*ReplicaServer, error) {
+	server, err := NewServer(basePath, exportedName, network)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplicaServer{server}, nil
+}
+
+// Replicate mounts the nfs export at source and copies its tenant volumes to
+// the base path of the server, removing any files that no longer exist at the
+// source.  The rest of the export belongs to the services running on the
+// leader, so it is not copied.
+func (c *ReplicaServer) Replicate(source string) error {
+	mountPath, err := mkdirTemp("", "serviced-replica-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(mountPath)
+
+	if err := Mount(source, mountPath); err != nil {
+		return err
+	}
+	defer func() {
+		if err := Unmount(mountPath); err != nil {
+			glog.Warningf("Could not unmount %s: %s", mountPath, err)
+		}
+	}()
+
+	volumesPath := path.Join(c.basePath, volumesDir)
+	if err := os.MkdirAll(volumesPath, 0755); err != nil {
+		return err
+	}
+	cmd := commandFactory("rsync", "-a", "--delete", "--numeric-ids", path.Join(mountPath, volumesDir)+"/", volumesPath+"/")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("could not replicate %s: %s: %s", source, err, strings.TrimSpace(string(output)))
+	}
+	glog.V(1).Infof("Replicated %s to %s", source, volumesPath)
+	return nil
+}
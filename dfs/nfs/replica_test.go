@@ -0,0 +1,78 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestReplicate(t *testing.T) {
+	defer func(c func(string, ...string) command, look func(string) (string, error)) {
+		commandFactory = c
+		lookPath = look
+	}(commandFactory, lookPath)
+
+	var commands []*mockCommand
+	var rsyncErr error
+	commandFactory = func(name string, args ...string) command {
+		cmd := &mockCommand{name: name, args: args}
+		if name == "rsync" {
+			cmd.err = rsyncErr
+		}
+		commands = append(commands, cmd)
+		return cmd
+	}
+	lookPath = func(name string) (string, error) {
+		return "/sbin/mount.nfs4", nil
+	}
+
+	basePath, err := ioutil.TempDir("", "serviced-replica-test-")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(basePath)
+
+	server := &ReplicaServer{&Server{basePath: basePath}}
+	if err := server.Replicate("192.168.1.50:/serviced_var"); err != nil {
+		t.Fatalf("unexpected error replicating: %s", err)
+	}
+
+	if len(commands) != 3 {
+		t.Fatalf("expected 3 commands, got %d", len(commands))
+	}
+	mountPath := commands[0].args[len(commands[0].args)-1]
+	expected := []mockCommand{
+		{name: "mount.nfs4", args: []string{"-o", "intr", "192.168.1.50:/serviced_var", mountPath}},
+		{name: "rsync", args: []string{"-a", "--delete", "--numeric-ids", mountPath + "/volumes/", basePath + "/volumes/"}},
+		{name: "umount", args: []string{"-f", "-l", mountPath}},
+	}
+	for i, cmd := range commands {
+		if cmd.name != expected[i].name || !reflect.DeepEqual(cmd.args, expected[i].args) {
+			t.Errorf("expected command %s %v, got %s %v", expected[i].name, expected[i].args, cmd.name, cmd.args)
+		}
+	}
+
+	// the export is unmounted even if the copy fails
+	commands, rsyncErr = nil, errors.New("exit status 23")
+	if err := server.Replicate("192.168.1.50:/serviced_var"); err == nil {
+		t.Fatalf("expected error replicating")
+	}
+	if n := len(commands); n != 3 || commands[n-1].name != "umount" {
+		t.Fatalf("expected the replica to be unmounted, got %+v", commands)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rsync is a storage driver that copies the tenant volumes to agents
+// with rsync over ssh, for hosts that cannot mount the nfs export of the
+// master.
+package rsync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/control-center/serviced/coordinator/storage"
+	"github.com/control-center/serviced/domain/host"
+	"github.com/zenoss/glog"
+)
+
+// exec.Cmd interface subset we need
+type command interface {
+	CombinedOutput() ([]byte, error)
+}
+
+// locally plugable command interface
+var commandFactory = func(name string, args ...string) command {
+	return exec.Command(name, args...)
+}
+
+// ErrInvalidBasePath is returned when the local path to sync is not absolute
+var ErrInvalidBasePath = errors.New("rsync server: invalid base path")
+
+// ErrInvalidKnownHosts is returned when the known hosts file is not absolute
+var ErrInvalidKnownHosts = errors.New("rsync server: invalid known hosts file")
+
+// Server pushes a directory to each of its clients, into the client's own
+// local path.  The server is the only source of truth: files that differ or
+// no longer exist on the server are overwritten or deleted on the clients.
+//
+// The clients are reached as root over ssh.  Their host keys are read from a
+// known hosts file that the operator maintains; hosts whose keys are missing
+// from it or do not match are not synced.
+type Server struct {
+	basePath   string
+	sshKey     string
+	knownHosts string
+
+	mu      sync.Mutex
+	clients []*storage.Node
+}
+
+// NewServer returns a rsync.Server that syncs basePath as root on each client,
+// verifying the clients against the knownHosts file.  If sshKey is empty, ssh
+// uses its default identity.
+func NewServer(basePath, sshKey, knownHosts string) (*Server, error) {
+	if len(basePath) < 2 || !strings.HasPrefix(basePath, "/") {
+		return nil, ErrInvalidBasePath
+	}
+	if !strings.HasPrefix(knownHosts, "/") {
+		return nil, ErrInvalidKnownHosts
+	}
+	return &Server{
+		basePath:   strings.TrimSuffix(basePath, "/"),
+		sshKey:     sshKey,
+		knownHosts: knownHosts,
+	}, nil
+}
+
+// ExportPath returns the path that is synced to the clients
+func (c *Server) ExportPath() string {
+	return c.basePath
+}
+
+// Clients returns the IP addresses of the current clients
+func (c *Server) Clients() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clients := make([]string, len(c.clients))
+	for i, node := range c.clients {
+		clients[i] = node.IPAddr
+	}
+	return clients
+}
+
+// SetClients replaces the clients that are synced.  Clients that are set by
+// address alone cannot be synced, since their local path is unknown; use
+// SetNodes instead.
+func (c *Server) SetClients(clients ...string) {
+	nodes := make([]*storage.Node, len(clients))
+	for i, ip := range clients {
+		nodes[i] = &storage.Node{Host: host.Host{IPAddr: ip}}
+	}
+	c.SetNodes(nodes...)
+}
+
+// SetNodes replaces the clients that are synced
+func (c *Server) SetNodes(nodes ...*storage.Node) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clients = nodes
+}
+
+// Sync copies the base path to every client.  All clients are synced even if
+// some of them fail.
+func (c *Server) Sync() error {
+	c.mu.Lock()
+	clients := make([]*storage.Node, len(c.clients))
+	copy(clients, c.clients)
+	c.mu.Unlock()
+
+	if _, err := os.Stat(c.knownHosts); err != nil {
+		glog.Errorf("Could not read known hosts file %s: %s", c.knownHosts, err)
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(clients))
+	for i, node := range clients {
+		wg.Add(1)
+		go func(i int, node *storage.Node) {
+			defer wg.Done()
+			errs[i] = c.syncClient(node)
+		}(i, node)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			glog.Errorf("Could not sync %s to %s: %s", c.basePath, clients[i].IPAddr, err)
+			failed = append(failed, clients[i].IPAddr)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not sync %s to %s", c.basePath, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// syncClient pushes the base path to the same directory under the local path
+// of the client, deleting the files that are not on the server.
+func (c *Server) syncClient(node *storage.Node) error {
+	if node.LocalPath == "" {
+		return fmt.Errorf("local path of %s is unknown", node.IPAddr)
+	}
+
+	local := c.basePath + "/"
+	remote := fmt.Sprintf("root@%s:%s/", node.IPAddr, path.Join(node.LocalPath, path.Base(c.basePath)))
+	if err := c.rsync(local, remote); err != nil {
+		return err
+	}
+	glog.V(1).Infof("Synced %s to %s", c.basePath, remote)
+	return nil
+}
+
+func (c *Server) rsync(src, dst string) error {
+	ssh := "ssh -o BatchMode=yes -o StrictHostKeyChecking=yes -o UserKnownHostsFile=" + c.knownHosts
+	if c.sshKey != "" {
+		ssh += " -i " + c.sshKey
+	}
+	cmd := commandFactory("rsync", "-a", "--delete", "--numeric-ids", "-e", ssh, src, dst)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("rsync %s %s: %s: %s", src, dst, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rsync
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/control-center/serviced/coordinator/storage"
+	"github.com/control-center/serviced/domain/host"
+)
+
+type mockCommand struct {
+	name string
+	args []string
+	err  error
+}
+
+func (c *mockCommand) CombinedOutput() ([]byte, error) {
+	return nil, c.err
+}
+
+func TestNewServer(t *testing.T) {
+	if _, err := NewServer("relative/path", "", "/etc/serviced/known_hosts"); err != ErrInvalidBasePath {
+		t.Fatalf("expected %s, got %v", ErrInvalidBasePath, err)
+	}
+	if _, err := NewServer("/opt/serviced/var/volumes", "", "known_hosts"); err != ErrInvalidKnownHosts {
+		t.Fatalf("expected %s, got %v", ErrInvalidKnownHosts, err)
+	}
+	server, err := NewServer("/opt/serviced/var/volumes/", "", "/etc/serviced/known_hosts")
+	if err != nil {
+		t.Fatalf("unexpected error creating server: %s", err)
+	}
+	if path := server.ExportPath(); path != "/opt/serviced/var/volumes" {
+		t.Fatalf("expected export path /opt/serviced/var/volumes, got %s", path)
+	}
+}
+
+func TestServer_Sync(t *testing.T) {
+	defer func(c func(string, ...string) command) {
+		commandFactory = c
+	}(commandFactory)
+
+	var mu sync.Mutex
+	var commands []string
+	commandFactory = func(name string, args ...string) command {
+		cmd := &mockCommand{name: name, args: args}
+		if strings.Contains(strings.Join(args, " "), "192.168.1.101") {
+			cmd.err = errors.New("connection refused")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return cmd
+	}
+
+	tmpDir, err := ioutil.TempDir("", "rsync-server-test-")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	knownHosts := filepath.Join(tmpDir, "known_hosts")
+
+	server, err := NewServer("/opt/serviced/var/volumes", "/root/.ssh/serviced", knownHosts)
+	if err != nil {
+		t.Fatalf("unexpected error creating server: %s", err)
+	}
+
+	server.SetNodes(&storage.Node{
+		Host:      host.Host{IPAddr: "192.168.1.100"},
+		LocalPath: "/var/lib/serviced",
+	})
+
+	// nothing is synced until the operator provides the known hosts file
+	if err := server.Sync(); !os.IsNotExist(err) {
+		t.Fatalf("expected a missing known hosts file, got %v", err)
+	}
+	if len(commands) != 0 {
+		t.Fatalf("expected no commands, got %v", commands)
+	}
+	hosts := "192.168.1.100 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5\n"
+	if err := ioutil.WriteFile(knownHosts, []byte(hosts), 0600); err != nil {
+		t.Fatalf("could not write known hosts: %s", err)
+	}
+
+	if err := server.Sync(); err != nil {
+		t.Fatalf("unexpected error syncing: %s", err)
+	}
+
+	// the files are pushed one way, into the client's own var path
+	ssh := "ssh -o BatchMode=yes -o StrictHostKeyChecking=yes -o UserKnownHostsFile=" + knownHosts + " -i /root/.ssh/serviced"
+	expected := []string{
+		"rsync -a --delete --numeric-ids -e " + ssh + " /opt/serviced/var/volumes/ root@192.168.1.100:/var/lib/serviced/volumes/",
+	}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Fatalf("expected commands %v, got %v", expected, commands)
+	}
+	// the known hosts file is left as the operator wrote it
+	if data, err := ioutil.ReadFile(knownHosts); err != nil {
+		t.Fatalf("could not read known hosts: %s", err)
+	} else if string(data) != hosts {
+		t.Fatalf("expected known hosts %q, got %q", hosts, string(data))
+	}
+
+	// a failed client doesn't stop the others from syncing, and clients
+	// without a local path are not synced
+	commands = nil
+	server.SetNodes(
+		&storage.Node{Host: host.Host{IPAddr: "192.168.1.101"}, LocalPath: "/opt/serviced/var"},
+		&storage.Node{Host: host.Host{IPAddr: "192.168.1.102"}, LocalPath: "/opt/serviced/var"},
+		&storage.Node{Host: host.Host{IPAddr: "192.168.1.103"}},
+	)
+	if err := server.Sync(); err == nil {
+		t.Fatalf("expected an error syncing")
+	} else if msg := err.Error(); !strings.HasSuffix(msg, " 192.168.1.101, 192.168.1.103") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(commands) != 2 {
+		t.Fatalf("expected 2 commands, got %v", commands)
+	}
+
+	// clients set by address alone are not synced
+	commands = nil
+	server.SetClients("192.168.1.104")
+	if clients := server.Clients(); !reflect.DeepEqual(clients, []string{"192.168.1.104"}) {
+		t.Fatalf("expected clients [192.168.1.104], got %v", clients)
+	}
+	if err := server.Sync(); err == nil || !strings.HasSuffix(err.Error(), " 192.168.1.104") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commands) != 0 {
+		t.Fatalf("expected no commands, got %v", commands)
+	}
+}
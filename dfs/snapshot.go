@@ -22,13 +22,11 @@ import (
 	"time"
 
 	"github.com/control-center/serviced/commons"
-	"github.com/control-center/serviced/commons/docker"
-	"github.com/control-center/serviced/coordinator/client"
 	"github.com/control-center/serviced/dao"
 	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/addressassignment"
 	"github.com/control-center/serviced/domain/service"
-	"github.com/control-center/serviced/zzk"
-	zkservice "github.com/control-center/serviced/zzk/service"
+	"github.com/control-center/serviced/utils"
 	"github.com/zenoss/glog"
 )
 
@@ -37,6 +35,12 @@ const timeFormat = "20060102-150405"
 // Snapshot takes a snapshot of the dfs as well as the docker images for the
 // given service ID
 func (dfs *DistributedFilesystem) Snapshot(tenantID string) (string, error) {
+	return dfs.snapshot(tenantID, nil)
+}
+
+// snapshot takes a snapshot of the tenant and, if the image of the tenant was
+// squashed since the last snapshot, records the squash in the snapshot
+func (dfs *DistributedFilesystem) snapshot(tenantID string, squashed *squashmeta) (string, error) {
 	// Get the tenant (parent) service
 	tenant, err := dfs.facade.GetService(datastore.Get(), tenantID)
 	if err != nil {
@@ -48,6 +52,13 @@ func (dfs *DistributedFilesystem) Snapshot(tenantID string) (string, error) {
 		return "", err
 	}
 
+	// Snapshots add to the size of the volume, so don't take one if the
+	// volume is already over its limit
+	if err := dfs.checkHardLimit(tenant.ID, tenant.Name); err != nil {
+		glog.Errorf("Could not snapshot %s (%s): %s", tenant.Name, tenant.ID, err)
+		return "", err
+	}
+
 	// Pause all running services
 	svcs, err := dfs.facade.GetServices(datastore.Get(), dao.ServiceRequest{})
 	if err != nil {
@@ -70,14 +81,7 @@ func (dfs *DistributedFilesystem) Snapshot(tenantID string) (string, error) {
 			processing[svc.ID] = struct{}{}
 
 			go func(poolID, serviceID string) {
-				conn, err := zzk.GetLocalConnection(zzk.GeneratePoolPath(poolID))
-				if err != nil {
-					glog.Errorf("Could not acquire connection to coordinator (%s): %s", poolID, err)
-					done <- status{serviceID, err}
-					return
-				}
-
-				err = dfs.pause(cancel, conn, serviceID)
+				err := dfs.pause(cancel, poolID, serviceID)
 				done <- status{serviceID, err}
 			}(svc.PoolID, svc.ID)
 		}
@@ -137,6 +141,12 @@ func (dfs *DistributedFilesystem) Snapshot(tenantID string) (string, error) {
 		glog.Errorf("Could not export existing services at %s: %s", snapshotVolume.SnapshotPath(label), err)
 		return "", err
 	}
+	if squashed != nil {
+		if err := exportJSON(filepath.Join(snapshotVolume.SnapshotPath(label), squashJSON), squashed); err != nil {
+			glog.Errorf("Could not record the squashed image at %s: %s", snapshotVolume.SnapshotPath(label), err)
+			return "", err
+		}
+	}
 
 	return label, nil
 }
@@ -157,12 +167,7 @@ func (dfs *DistributedFilesystem) Rollback(snapshotID string) error {
 	}
 
 	for _, svc := range svcs {
-		conn, err := zzk.GetLocalConnection(zzk.GeneratePoolPath(svc.PoolID))
-		if err != nil {
-			glog.Errorf("Could not acquire connection to coordinator (%s): %s", svc.PoolID, err)
-			return err
-		}
-		if states, err := zkservice.GetServiceStates(conn, svc.ID); err != nil {
+		if states, err := zkAPI.GetServiceStates(svc.PoolID, svc.ID); err != nil {
 			glog.Errorf("Could not look up running instances for %s (%s): %s", svc.Name, svc.ID, err)
 			return err
 		} else if running := len(states); running > 0 {
@@ -194,6 +199,7 @@ func (dfs *DistributedFilesystem) Rollback(snapshotID string) error {
 		glog.Errorf("Error while trying to roll back to %s: %s", snapshotID, err)
 		return err
 	}
+	dfs.reapplyLimit(tenant.ID, snapshotVolume)
 
 	// restore the tags
 	glog.V(0).Infof("Restoring image tags for %s", snapshotID)
@@ -217,6 +223,128 @@ func (dfs *DistributedFilesystem) Rollback(snapshotID string) error {
 	return nil
 }
 
+// CloneSnapshot creates a new tenant from a snapshot.  The services of the
+// snapshot are deployed through the facade, which gives them new IDs and tags
+// the snapshot's images for the clone; address assignments are dropped.  The
+// clone gets its own copy of the volume and is removed again if that fails.
+// Returns the ID of the new tenant.
+func (dfs *DistributedFilesystem) CloneSnapshot(request dao.CloneSnapshotRequest) (string, error) {
+	snapshotID := request.SnapshotID
+	tenantID, timestamp, err := parseLabel(snapshotID)
+	if err != nil {
+		glog.Errorf("Could not clone snapshot %s: %s", snapshotID, err)
+		return "", err
+	}
+
+	if request.DeploymentID == "" {
+		return "", fmt.Errorf("deployment ID is required")
+	}
+
+	// check the snapshot
+	tenant, err := dfs.facade.GetService(datastore.Get(), tenantID)
+	if err != nil {
+		glog.Errorf("Could not find service %s: %s", tenantID, err)
+		return "", err
+	} else if tenant == nil {
+		glog.Errorf("Service %s not found", tenantID)
+		return "", fmt.Errorf("service not found")
+	}
+
+	snapshotVolume, err := dfs.GetVolume(tenant.ID)
+	if err != nil {
+		glog.Errorf("Could not find volume for service %s: %s", tenantID, err)
+		return "", err
+	}
+
+	snapshotPath := snapshotVolume.SnapshotPath(snapshotID)
+	var svcs []service.Service
+	if err := importJSON(filepath.Join(snapshotPath, serviceJSON), &svcs); err != nil {
+		glog.Errorf("Could not acquire services from %s: %s", snapshotID, err)
+		return "", err
+	}
+
+	// deploy the services from the images of the snapshot
+	for i, svc := range svcs {
+		if svc.ImageID == "" {
+			continue
+		}
+		imageID, err := commons.ParseImageID(svc.ImageID)
+		if err != nil {
+			glog.Errorf("Invalid image %s for %s (%s): %s", svc.ImageID, svc.Name, svc.ID, err)
+			return "", err
+		}
+		imageID.Tag = timestamp
+		svcs[i].ImageID = imageID.String()
+	}
+
+	glog.V(0).Infof("Cloning %s (%s) from %s", tenant.Name, tenant.ID, snapshotID)
+	newTenantID, err := dfs.facade.DeployServices(datastore.Get(), svcs, request.PoolID, request.DeploymentID)
+	if err != nil {
+		glog.Errorf("Could not deploy the services of %s: %s", snapshotID, err)
+		return "", err
+	}
+
+	if err := dfs.cloneVolume(snapshotPath, newTenantID, timestamp); err != nil {
+		glog.Errorf("Could not clone the volume of %s; rolling back: %s", snapshotID, err)
+		dfs.removeClone(newTenantID)
+		return "", err
+	}
+
+	return newTenantID, nil
+}
+
+// cloneVolume populates the volume of a new tenant from a copy of a snapshot
+func (dfs *DistributedFilesystem) cloneVolume(snapshotPath, newTenantID, timestamp string) error {
+	cloneVolume, err := dfs.GetVolume(newTenantID)
+	if err != nil {
+		glog.Errorf("Could not acquire the volume for %s: %s", newTenantID, err)
+		return err
+	}
+
+	label := fmt.Sprintf("%s_%s", newTenantID, timestamp)
+	cmd, err := commandAsRoot("cp", "-a", snapshotPath, cloneVolume.SnapshotPath(label))
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		glog.Errorf("Could not copy %s: %s (%s)", snapshotPath, string(output), err)
+		return err
+	}
+
+	defer func() {
+		if err := dfs.DeleteSnapshot(label); err != nil {
+			glog.Warningf("Could not delete snapshot %s while cloning %s: %s", label, snapshotPath, err)
+		}
+	}()
+
+	if err := cloneVolume.Rollback(label); err != nil {
+		glog.Errorf("Could not roll back volume of %s to %s: %s", newTenantID, label, err)
+		return err
+	}
+	dfs.reapplyLimit(newTenantID, cloneVolume)
+
+	return nil
+}
+
+// removeClone removes the services and images of a tenant that could not be
+// cloned
+func (dfs *DistributedFilesystem) removeClone(tenantID string) {
+	if err := dfs.facade.RemoveService(datastore.Get(), tenantID); err != nil {
+		glog.Warningf("Could not remove services of %s: %s", tenantID, err)
+	}
+
+	images, err := searchImagesByTenantID(tenantID)
+	if err != nil {
+		glog.Warningf("Could not find images of %s: %s", tenantID, err)
+		return
+	}
+	for _, image := range images {
+		if err := dockerAPI.DeleteImage(image); err != nil {
+			glog.Warningf("Could not remove image %s: %s", image.ID, err)
+		}
+	}
+}
+
 // ListSnapshots lists all the snapshots for a particular tenant
 func (dfs *DistributedFilesystem) ListSnapshots(tenantID string) ([]string, error) {
 	// Get the tenant (parent) service
@@ -277,12 +405,12 @@ func (dfs *DistributedFilesystem) DeleteSnapshot(snapshotID string) error {
 	for _, image := range images {
 		imageID := image.ID
 		imageID.Tag = timestamp
-		img, err := docker.FindImage(imageID.String(), false)
+		img, err := dockerAPI.FindImage(imageID.String())
 		if err != nil {
 			glog.Errorf("Could not remove tag from image %s: %s", imageID, err)
 			continue
 		}
-		img.Delete()
+		dockerAPI.DeleteImage(img)
 	}
 
 	return nil
@@ -309,7 +437,7 @@ func (dfs *DistributedFilesystem) DeleteSnapshots(tenantID string) error {
 	}
 
 	for _, image := range images {
-		if err := image.Delete(); err != nil {
+		if err := dockerAPI.DeleteImage(image); err != nil {
 			glog.Warningf("Could not delete image %s (%s): %s", image.ID, image.UUID, err)
 		}
 	}
@@ -317,19 +445,19 @@ func (dfs *DistributedFilesystem) DeleteSnapshots(tenantID string) error {
 	return nil
 }
 
-func (dfs *DistributedFilesystem) pause(cancel <-chan interface{}, conn client.Connection, serviceID string) error {
+func (dfs *DistributedFilesystem) pause(cancel <-chan interface{}, poolID, serviceID string) error {
 	if err := dfs.facade.PauseService(datastore.Get(), serviceID); err != nil {
 		return err
 	}
 
-	states, err := zkservice.GetServiceStates(conn, serviceID)
+	states, err := zkAPI.GetServiceStates(poolID, serviceID)
 	if err != nil {
 		glog.Errorf("Could not get service states for service %s: %s", serviceID, err)
 		return err
 	}
 
 	for _, state := range states {
-		if err := zkservice.WaitPause(cancel, conn, serviceID, state.ID); err != nil {
+		if err := zkAPI.WaitPause(cancel, poolID, serviceID, state.ID); err != nil {
 			return fmt.Errorf("could not pause %s (%s): %s", serviceID, state.ID, err)
 		}
 		select {
@@ -451,6 +579,103 @@ func (dfs *DistributedFilesystem) restoreServices(svcs []*service.Service) error
 	return nil
 }
 
+// checkDeploymentID returns an error if any service is already deployed under
+// deploymentID.
+func (dfs *DistributedFilesystem) checkDeploymentID(deploymentID string) error {
+	svcs, err := dfs.facade.GetServices(datastore.Get(), dao.ServiceRequest{})
+	if err != nil {
+		glog.Errorf("Could not acquire the list of all services: %s", err)
+		return err
+	}
+	for _, svc := range svcs {
+		if svc.DeploymentID == deploymentID {
+			return fmt.Errorf("deployment ID %s is already in use", deploymentID)
+		}
+	}
+	return nil
+}
+
+// relabelServices moves a tree of services belonging to tenantID to
+// newTenantID and, if set, to poolID.  When the tenant changes, every service
+// gets a new ID so the tree can be added alongside the original.
+func relabelServices(svcs []*service.Service, tenantID, newTenantID, poolID string) error {
+	if newTenantID == tenantID && poolID == "" {
+		return nil
+	}
+
+	ids := make(map[string]string)
+	for _, svc := range svcs {
+		if svc.ID == tenantID {
+			ids[svc.ID] = newTenantID
+		} else if newTenantID == tenantID {
+			ids[svc.ID] = svc.ID
+		} else if id, err := utils.NewUUID36(); err != nil {
+			return err
+		} else {
+			ids[svc.ID] = id
+		}
+	}
+
+	for _, svc := range svcs {
+		svc.ID = ids[svc.ID]
+		if svc.ParentServiceID != "" {
+			parentID, ok := ids[svc.ParentServiceID]
+			if !ok {
+				return fmt.Errorf("parent %s of service %s not found", svc.ParentServiceID, svc.Name)
+			}
+			svc.ParentServiceID = parentID
+		}
+
+		if poolID != "" {
+			svc.PoolID = poolID
+		}
+
+		if svc.ImageID != "" {
+			image, err := commons.ParseImageID(svc.ImageID)
+			if err != nil {
+				glog.Errorf("Invalid image %s for %s: %s", svc.ImageID, svc.Name, err)
+				return err
+			}
+			if image.User == tenantID {
+				image.User = newTenantID
+			}
+			svc.ImageID = image.String()
+		}
+
+		// address assignments are not valid for a new tenant or pool
+		for i := range svc.Endpoints {
+			svc.Endpoints[i].AddressAssignment = addressassignment.AddressAssignment{}
+		}
+	}
+
+	return nil
+}
+
+// relabelImages returns the images that belong to tenantID, tagged for
+// newTenantID.
+func relabelImages(images []imagemeta, tenantID, newTenantID string) ([]imagemeta, error) {
+	var result []imagemeta
+	for _, metadata := range images {
+		var tags []string
+		for _, tag := range metadata.Tags {
+			imageID, err := commons.ParseImageID(tag)
+			if err != nil {
+				glog.Errorf("Could not parse %s: %s", tag, err)
+				return nil, err
+			}
+			if imageID.User == tenantID {
+				imageID.User = newTenantID
+				tags = append(tags, imageID.String())
+			}
+		}
+		if len(tags) > 0 {
+			metadata.Tags = tags
+			result = append(result, metadata)
+		}
+	}
+	return result, nil
+}
+
 func NewLabel(tenantID string) string {
 	return fmt.Sprintf("%s_%s", tenantID, time.Now().UTC().Format(timeFormat))
 }
@@ -463,6 +688,16 @@ func parseLabel(snapshotID string) (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
+// hasLabel returns true if label is one of labels
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
 func getChildServices(tenantID string, svcs []service.Service) []service.Service {
 	var result []service.Service
 
@@ -0,0 +1,254 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/domain/addressassignment"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicestate"
+	"github.com/control-center/serviced/volume/dir"
+)
+
+func TestSnapshot_relabelServices(t *testing.T) {
+	svcs := []*service.Service{
+		{ID: "tenant", PoolID: "default", ImageID: "localhost:5000/tenant/repo"},
+		{ID: "child", ParentServiceID: "tenant", PoolID: "default", ImageID: "localhost:5000/tenant/repo",
+			Endpoints: []service.ServiceEndpoint{{AddressAssignment: addressassignment.AddressAssignment{IPAddr: "10.0.0.1"}}}},
+		{ID: "grandchild", ParentServiceID: "child", PoolID: "default"},
+	}
+
+	if err := relabelServices(svcs, "tenant", "clone", "staging"); err != nil {
+		t.Fatalf("Could not relabel services: %s", err)
+	}
+
+	if svcs[0].ID != "clone" {
+		t.Errorf("Expected tenant id %s, got %s", "clone", svcs[0].ID)
+	}
+	if svcs[1].ID == "child" || svcs[1].ParentServiceID != "clone" {
+		t.Errorf("Child service was not relabeled: %+v", svcs[1])
+	}
+	if svcs[2].ParentServiceID != svcs[1].ID {
+		t.Errorf("Expected parent %s, got %s", svcs[1].ID, svcs[2].ParentServiceID)
+	}
+	for _, svc := range svcs {
+		if svc.PoolID != "staging" {
+			t.Errorf("Expected pool %s, got %s", "staging", svc.PoolID)
+		}
+	}
+	if svcs[1].ImageID != "localhost:5000/clone/repo" {
+		t.Errorf("Expected image %s, got %s", "localhost:5000/clone/repo", svcs[1].ImageID)
+	}
+	if svcs[1].Endpoints[0].AddressAssignment.IPAddr != "" {
+		t.Errorf("Expected address assignment to be cleared")
+	}
+}
+
+func TestSnapshot_relabelImages(t *testing.T) {
+	images := []imagemeta{
+		{UUID: "1", Tags: []string{"zenoss/core:5.0", "localhost:5000/tenant/core"}, Filename: "0.tar"},
+		{UUID: "2", Tags: []string{"localhost:5000/other/core"}, Filename: "1.tar"},
+	}
+
+	actual, err := relabelImages(images, "tenant", "clone")
+	if err != nil {
+		t.Fatalf("Could not relabel images: %s", err)
+	}
+
+	expected := []imagemeta{
+		{UUID: "1", Tags: []string{"localhost:5000/clone/core"}, Filename: "0.tar"},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Mismatch (Expected: %v) (Actual: %v)", expected, actual)
+	}
+}
+
+func TestSnapshot_snapshotAndRollback(t *testing.T) {
+	varpath, err := ioutil.TempDir("", "dfs-snapshot-test")
+	if err != nil {
+		t.Fatalf("Could not create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(varpath)
+
+	f := newTestFacade(
+		service.Service{ID: "tenant", Name: "tenant", PoolID: "default", ImageID: "localhost:5000/tenant/core", DesiredState: service.SVCRun},
+		service.Service{ID: "child", Name: "child", ParentServiceID: "tenant", PoolID: "default", DesiredState: service.SVCStop},
+	)
+	zk := &testZK{states: map[string][]servicestate.ServiceState{"tenant": {{ID: "instance", ServiceID: "tenant"}}}}
+	d := &testDocker{}
+	d.add("abc", "localhost:5000/tenant/core:latest")
+	defer setTestAPIs(zk, d)()
+
+	dfs := &DistributedFilesystem{vfs: dir.DriverName, varpath: varpath, dockerHost: "localhost", dockerPort: 5000, facade: f, timeout: time.Minute}
+	v, err := dfs.GetVolume("tenant")
+	if err != nil {
+		t.Fatalf("Could not get volume: %s", err)
+	}
+	dataFile := filepath.Join(v.Path(), "backedup.txt")
+	data := []byte("cheese and crackers")
+	if err := ioutil.WriteFile(dataFile, data, 0600); err != nil {
+		t.Fatalf("Failed writing file %s: %s", dataFile, err)
+	}
+
+	label, err := dfs.Snapshot("tenant")
+	if err != nil {
+		t.Fatalf("Could not snapshot: %s", err)
+	}
+	_, timestamp, err := parseLabel(label)
+	if err != nil {
+		t.Fatalf("Could not parse label %s: %s", label, err)
+	}
+
+	// running services are paused for the snapshot and started again
+	if !reflect.DeepEqual(f.paused, []string{"tenant"}) || !reflect.DeepEqual(f.started, []string{"tenant"}) {
+		t.Errorf("Expected tenant to be paused and started, got %v and %v", f.paused, f.started)
+	}
+	if uuid := d.uuid("localhost:5000/tenant/core:" + timestamp); uuid != "abc" {
+		t.Errorf("Expected the snapshot to tag image abc, got %q", uuid)
+	}
+
+	// change everything that the snapshot keeps
+	if err := ioutil.WriteFile(dataFile, []byte("peanut butter and jelly"), 0600); err != nil {
+		t.Fatalf("Failed writing file %s: %s", dataFile, err)
+	}
+	d.add("def", "localhost:5000/tenant/core:latest")
+	child := f.services["child"]
+	child.Startup = "changed"
+	f.services["child"] = child
+	f.services["tenant"] = service.Service{ID: "tenant", Name: "renamed", PoolID: "default"}
+
+	// services must be stopped to roll back
+	if err := dfs.Rollback(label); err == nil {
+		t.Errorf("Expected an error rolling back with running instances")
+	}
+	zk.states = nil
+
+	if err := dfs.Rollback(label); err != nil {
+		t.Fatalf("Could not roll back to %s: %s", label, err)
+	}
+	if actual, err := ioutil.ReadFile(dataFile); err != nil {
+		t.Fatalf("Could not read %s: %s", dataFile, err)
+	} else if !reflect.DeepEqual(actual, data) {
+		t.Errorf("Expected %s, got %s", data, actual)
+	}
+	if uuid := d.uuid("localhost:5000/tenant/core:latest"); uuid != "abc" {
+		t.Errorf("Expected latest to be image abc again, got %q", uuid)
+	}
+	if name := f.services["tenant"].Name; name != "tenant" {
+		t.Errorf("Expected the tenant to be named tenant again, got %s", name)
+	}
+	if startup := f.services["child"].Startup; startup != "" {
+		t.Errorf("Expected the startup of child to be restored, got %s", startup)
+	}
+	if state := f.services["tenant"].DesiredState; state != service.SVCStop {
+		t.Errorf("Expected restored services to be stopped, got %d", state)
+	}
+}
+
+func TestSnapshot_CloneSnapshot(t *testing.T) {
+	varpath, err := ioutil.TempDir("", "dfs-snapshot-test")
+	if err != nil {
+		t.Fatalf("Could not create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(varpath)
+
+	f := newTestFacade(
+		service.Service{ID: "tenant", Name: "tenant", PoolID: "default", DeploymentID: "prod", ImageID: "localhost:5000/tenant/core"},
+		service.Service{ID: "child", Name: "child", ParentServiceID: "tenant", PoolID: "default", DeploymentID: "prod"},
+	)
+	d := &testDocker{}
+	d.add("abc", "localhost:5000/tenant/core:latest")
+	defer setTestAPIs(&testZK{}, d)()
+
+	dfs := &DistributedFilesystem{vfs: dir.DriverName, varpath: varpath, dockerHost: "localhost", dockerPort: 5000, facade: f, timeout: time.Minute}
+	v, err := dfs.GetVolume("tenant")
+	if err != nil {
+		t.Fatalf("Could not get volume: %s", err)
+	}
+	data := []byte("cheese and crackers")
+	if err := ioutil.WriteFile(filepath.Join(v.Path(), "cloned.txt"), data, 0600); err != nil {
+		t.Fatalf("Failed writing file: %s", err)
+	}
+
+	label, err := dfs.Snapshot("tenant")
+	if err != nil {
+		t.Fatalf("Could not snapshot: %s", err)
+	}
+	_, timestamp, err := parseLabel(label)
+	if err != nil {
+		t.Fatalf("Could not parse label %s: %s", label, err)
+	}
+	request := dao.CloneSnapshotRequest{SnapshotID: label, DeploymentID: "clone"}
+
+	// the clone is removed again if its volume can't be populated; the file
+	// is in the way of the clone's volume
+	blocker := filepath.Join(varpath, "volumes", "clone-tenant")
+	if err := ioutil.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatalf("Failed writing file: %s", err)
+	}
+	d.add("abc", "localhost:5000/clone-tenant/core:latest")
+	if _, err := dfs.CloneSnapshot(request); err == nil {
+		t.Fatalf("Expected an error cloning into a volume that can't be created")
+	}
+	if _, ok := f.services["clone-tenant"]; ok {
+		t.Errorf("Expected the services of the clone to be removed")
+	} else if _, ok := f.services["clone-child"]; ok {
+		t.Errorf("Expected the services of the clone to be removed")
+	}
+	if uuid := d.uuid("localhost:5000/clone-tenant/core:latest"); uuid != "" {
+		t.Errorf("Expected the images of the clone to be removed")
+	}
+	if err := os.Remove(blocker); err != nil {
+		t.Fatalf("Failed to remove %s: %s", blocker, err)
+	}
+
+	tenantID, err := dfs.CloneSnapshot(request)
+	if err != nil {
+		t.Fatalf("Could not clone %s: %s", label, err)
+	} else if tenantID != "clone-tenant" {
+		t.Errorf("Expected tenant clone-tenant, got %s", tenantID)
+	}
+
+	// the services are deployed from the images of the snapshot
+	if len(f.deployed) != 2 {
+		t.Fatalf("Expected 2 services to be deployed, got %d", len(f.deployed))
+	}
+	for _, svc := range f.deployed {
+		if svc.ID == "tenant" && svc.ImageID != "localhost:5000/tenant/core:"+timestamp {
+			t.Errorf("Expected image of snapshot %s, got %s", label, svc.ImageID)
+		}
+	}
+
+	cloneVolume, err := dfs.GetVolume(tenantID)
+	if err != nil {
+		t.Fatalf("Could not get volume: %s", err)
+	}
+	if actual, err := ioutil.ReadFile(filepath.Join(cloneVolume.Path(), "cloned.txt")); err != nil {
+		t.Errorf("Could not read cloned file: %s", err)
+	} else if !reflect.DeepEqual(actual, data) {
+		t.Errorf("Expected %s, got %s", data, actual)
+	}
+
+	// deployment IDs are unique
+	if _, err := dfs.CloneSnapshot(request); err == nil {
+		t.Errorf("Expected an error cloning into a deployment that is in use")
+	}
+}
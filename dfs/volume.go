@@ -22,10 +22,20 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/datastore"
 	"github.com/control-center/serviced/volume"
 	"github.com/zenoss/glog"
 )
 
+// VolumeQuota limits the number of bytes used by each tenant volume.  A limit
+// of zero means no limit.  The quota of the daemon is the default for tenants
+// that do not set their own limits.
+type VolumeQuota struct {
+	SoftLimit uint64
+	HardLimit uint64
+}
+
 func (dfs *DistributedFilesystem) GetVolume(serviceID string) (*volume.Volume, error) {
 	v, err := GetSubvolume(dfs.vfs, dfs.varpath, serviceID)
 	if err != nil {
@@ -37,9 +47,129 @@ func (dfs *DistributedFilesystem) GetVolume(serviceID string) (*volume.Volume, e
 		return nil, err
 	}
 
+	dfs.applyLimit(serviceID, v)
 	return v, nil
 }
 
+// applyLimit sets the hard limit on the tenant volume if the driver can
+// enforce it and the limit has not already been applied since the volume was
+// mounted or the limit changed.
+func (dfs *DistributedFilesystem) applyLimit(tenantID string, v *volume.Volume) {
+	limiter, ok := v.Conn.(volume.Limiter)
+	if !ok {
+		return
+	}
+
+	hardLimit := dfs.tenantQuota(tenantID).HardLimit
+
+	dfs.limitsLock.Lock()
+	defer dfs.limitsLock.Unlock()
+	if limit, ok := dfs.limits[tenantID]; ok && limit == hardLimit {
+		return
+	} else if !ok && hardLimit == 0 {
+		return
+	}
+
+	if err := limiter.SetLimit(hardLimit); err != nil {
+		glog.Warningf("Could not set the hard limit on volume %s: %s", v.Path(), err)
+		return
+	}
+	if dfs.limits == nil {
+		dfs.limits = make(map[string]uint64)
+	}
+	dfs.limits[tenantID] = hardLimit
+}
+
+// tenantQuota returns the limits of a tenant volume.  Limits that are not set
+// on the tenant default to the quota of the daemon.
+func (dfs *DistributedFilesystem) tenantQuota(tenantID string) VolumeQuota {
+	quota := dfs.quota
+	tenant, err := dfs.facade.GetService(datastore.Get(), tenantID)
+	if err != nil {
+		glog.Warningf("Could not look up the volume limits of %s, using the defaults: %s", tenantID, err)
+	} else if tenant != nil {
+		if tenant.VolumeSoftLimit > 0 {
+			quota.SoftLimit = tenant.VolumeSoftLimit
+		}
+		if tenant.VolumeHardLimit > 0 {
+			quota.HardLimit = tenant.VolumeHardLimit
+		}
+	}
+	return quota
+}
+
+// reapplyLimit sets the hard limit on a tenant volume that has been replaced,
+// as with a rollback, since the limit does not carry over to the new volume.
+func (dfs *DistributedFilesystem) reapplyLimit(tenantID string, v *volume.Volume) {
+	dfs.limitsLock.Lock()
+	delete(dfs.limits, tenantID)
+	dfs.limitsLock.Unlock()
+	dfs.applyLimit(tenantID, v)
+}
+
+// VolumeStatus returns the disk usage of every tenant volume
+func (dfs *DistributedFilesystem) VolumeStatus() ([]dao.VolumeStatus, error) {
+	svcs, err := dfs.facade.GetServices(datastore.Get(), dao.ServiceRequest{})
+	if err != nil {
+		glog.Errorf("Could not get all services: %s", err)
+		return nil, err
+	}
+
+	var result []dao.VolumeStatus
+	for _, svc := range svcs {
+		if svc.ParentServiceID != "" {
+			continue
+		}
+		status, err := dfs.volumeStatus(svc.ID, svc.Name)
+		if err != nil {
+			glog.Errorf("Could not get volume status for service %s (%s): %s", svc.Name, svc.ID, err)
+			return nil, err
+		}
+		result = append(result, *status)
+	}
+	return result, nil
+}
+
+// volumeStatus gets the disk usage of a tenant volume
+func (dfs *DistributedFilesystem) volumeStatus(tenantID, name string) (*dao.VolumeStatus, error) {
+	v, err := dfs.GetVolume(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := v.Usage()
+	if err != nil {
+		glog.Errorf("Could not get usage of volume %s: %s", v.Path(), err)
+		return nil, err
+	}
+
+	quota := dfs.tenantQuota(tenantID)
+	return &dao.VolumeStatus{
+		TenantID:  tenantID,
+		Name:      name,
+		Driver:    dfs.vfs,
+		Path:      v.Path(),
+		UsedBytes: used,
+		SoftLimit: quota.SoftLimit,
+		HardLimit: quota.HardLimit,
+	}, nil
+}
+
+// checkHardLimit returns an error if the tenant volume is over its hard limit
+func (dfs *DistributedFilesystem) checkHardLimit(tenantID, name string) error {
+	if dfs.tenantQuota(tenantID).HardLimit == 0 {
+		return nil
+	}
+
+	status, err := dfs.volumeStatus(tenantID, name)
+	if err != nil {
+		return err
+	} else if status.OverHardLimit() {
+		return fmt.Errorf("volume is using %d bytes, which is over its hard limit of %d bytes", status.UsedBytes, status.HardLimit)
+	}
+	return nil
+}
+
 // GetSubvolume gets the path of the *local* volume on the host
 func GetSubvolume(vfs, varpath, serviceID string) (*volume.Volume, error) {
 	baseDir, err := filepath.Abs(path.Join(varpath, "volumes"))
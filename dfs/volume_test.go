@@ -0,0 +1,180 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/volume"
+	"github.com/control-center/serviced/volume/dir"
+)
+
+const limitDriverName = "dfs-test-limit"
+
+// limitDriver is a dir volume driver that records the limits set on its
+// volumes
+type limitDriver struct {
+	*dir.DirDriver
+	limits map[string][]uint64
+}
+
+type limitConn struct {
+	volume.Conn
+	driver *limitDriver
+}
+
+func (d *limitDriver) Mount(volumeName, rootDir string) (volume.Conn, error) {
+	conn, err := d.DirDriver.Mount(volumeName, rootDir)
+	if err != nil {
+		return nil, err
+	}
+	return &limitConn{conn, d}, nil
+}
+
+func (c *limitConn) SetLimit(bytes uint64) error {
+	c.driver.limits[c.Name()] = append(c.driver.limits[c.Name()], bytes)
+	return nil
+}
+
+var testLimitDriver = &limitDriver{limits: make(map[string][]uint64)}
+
+func init() {
+	d, err := dir.New()
+	if err != nil {
+		panic(err)
+	}
+	testLimitDriver.DirDriver = d
+	volume.Register(limitDriverName, testLimitDriver)
+}
+
+func TestVolume_snapshotAndRollback(t *testing.T) {
+	varpath, err := ioutil.TempDir("", "dfs-volume-test")
+	if err != nil {
+		t.Fatalf("Could not create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(varpath)
+
+	dfs := &DistributedFilesystem{vfs: dir.DriverName, varpath: varpath}
+	v, err := dfs.GetVolume("tenant")
+	if err != nil {
+		t.Fatalf("Could not get volume: %s", err)
+	} else if expected := filepath.Join(varpath, "volumes", "tenant"); v.Path() != expected {
+		t.Errorf("Expected volume path %s, got %s", expected, v.Path())
+	}
+
+	dataFile := filepath.Join(v.Path(), "backedup.txt")
+	data := []byte("cheese and crackers")
+	if err := ioutil.WriteFile(dataFile, data, 0600); err != nil {
+		t.Fatalf("Failed writing file %s: %s", dataFile, err)
+	}
+
+	// take a snapshot the way dfs does, with the services alongside the data
+	label := NewLabel("tenant")
+	if err := v.Snapshot(label); err != nil {
+		t.Fatalf("Could not snapshot %s: %s", label, err)
+	}
+	svcs := []service.Service{{ID: "tenant", Name: "tenant"}, {ID: "child", ParentServiceID: "tenant"}}
+	if err := exportJSON(filepath.Join(v.SnapshotPath(label), serviceJSON), svcs); err != nil {
+		t.Fatalf("Could not write services to %s: %s", label, err)
+	}
+
+	if snapshots, err := v.Snapshots(); err != nil {
+		t.Fatalf("Could not list snapshots: %s", err)
+	} else if !reflect.DeepEqual(snapshots, []string{label}) {
+		t.Errorf("Expected %v, got %v", []string{label}, snapshots)
+	}
+	if tenantID, _, err := parseLabel(label); err != nil || tenantID != "tenant" {
+		t.Errorf("Could not parse label %s: %s (%v)", label, tenantID, err)
+	}
+
+	if err := ioutil.WriteFile(dataFile, []byte("peanut butter and jelly"), 0600); err != nil {
+		t.Fatalf("Failed writing file %s: %s", dataFile, err)
+	}
+	if err := v.Rollback(label); err != nil {
+		t.Fatalf("Could not roll back to %s: %s", label, err)
+	}
+	if actual, err := ioutil.ReadFile(dataFile); err != nil {
+		t.Fatalf("Could not read %s: %s", dataFile, err)
+	} else if !reflect.DeepEqual(actual, data) {
+		t.Errorf("Expected %s, got %s", data, actual)
+	}
+
+	var restore []service.Service
+	if err := importJSON(filepath.Join(v.SnapshotPath(label), serviceJSON), &restore); err != nil {
+		t.Fatalf("Could not read services from %s: %s", label, err)
+	} else if len(restore) != len(svcs) || restore[1].ParentServiceID != "tenant" {
+		t.Errorf("Mismatch (Expected: %v) (Actual: %v)", svcs, restore)
+	}
+}
+
+func TestVolume_applyLimit(t *testing.T) {
+	varpath, err := ioutil.TempDir("", "dfs-volume-test")
+	if err != nil {
+		t.Fatalf("Could not create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(varpath)
+
+	f := newTestFacade(service.Service{ID: "limited", VolumeSoftLimit: 256, VolumeHardLimit: 512})
+	dfs := &DistributedFilesystem{vfs: limitDriverName, varpath: varpath, facade: f, quota: VolumeQuota{HardLimit: 1024}}
+	for i := 0; i < 3; i++ {
+		if _, err := dfs.volumeStatus("tenant", "tenant"); err != nil {
+			t.Fatalf("Could not get volume status: %s", err)
+		}
+	}
+	if expected := []uint64{1024}; !reflect.DeepEqual(testLimitDriver.limits["tenant"], expected) {
+		t.Errorf("Expected limits %v, got %v", expected, testLimitDriver.limits["tenant"])
+	}
+
+	// the limit is applied again when it changes
+	dfs.quota.HardLimit = 2048
+	if _, err := dfs.GetVolume("tenant"); err != nil {
+		t.Fatalf("Could not get volume: %s", err)
+	}
+	if _, err := dfs.GetVolume("other"); err != nil {
+		t.Fatalf("Could not get volume: %s", err)
+	}
+	if expected := []uint64{1024, 2048}; !reflect.DeepEqual(testLimitDriver.limits["tenant"], expected) {
+		t.Errorf("Expected limits %v, got %v", expected, testLimitDriver.limits["tenant"])
+	}
+	if expected := []uint64{2048}; !reflect.DeepEqual(testLimitDriver.limits["other"], expected) {
+		t.Errorf("Expected limits %v, got %v", expected, testLimitDriver.limits["other"])
+	}
+
+	// and when the volume is replaced
+	v, err := dfs.GetVolume("tenant")
+	if err != nil {
+		t.Fatalf("Could not get volume: %s", err)
+	}
+	dfs.reapplyLimit("tenant", v)
+	if expected := []uint64{1024, 2048, 2048}; !reflect.DeepEqual(testLimitDriver.limits["tenant"], expected) {
+		t.Errorf("Expected limits %v, got %v", expected, testLimitDriver.limits["tenant"])
+	}
+
+	// the limits of a tenant take precedence over the ones of the daemon
+	status, err := dfs.volumeStatus("limited", "limited")
+	if err != nil {
+		t.Fatalf("Could not get volume status: %s", err)
+	}
+	if status.SoftLimit != 256 || status.HardLimit != 512 {
+		t.Errorf("Expected limits 256 and 512, got %d and %d", status.SoftLimit, status.HardLimit)
+	}
+	if expected := []uint64{512}; !reflect.DeepEqual(testLimitDriver.limits["limited"], expected) {
+		t.Errorf("Expected limits %v, got %v", expected, testLimitDriver.limits["limited"])
+	}
+}
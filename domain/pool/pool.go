@@ -22,6 +22,12 @@ import (
 	"time"
 )
 
+// Storage transports that agents in a pool can use to reach the DFS
+const (
+	StorageNFS   = "nfs"   // agents mount the DFS export of the master
+	StorageRsync = "rsync" // the master copies the DFS to agents over ssh
+)
+
 type VirtualIP struct {
 	PoolID        string
 	IP            string
@@ -42,6 +48,7 @@ type ResourcePool struct {
 	CoreCapacity      int         // Number of cores available as a sum of all cores on all hosts in the pool
 	MemoryCapacity    uint64      // Amount (bytes) of RAM available as a sum of all memory on all hosts in the pool
 	MemoryCommitment  uint64      // Amount (bytes) of RAM committed to services
+	StorageTransport  string      // How hosts in the pool reach the DFS, "nfs" (default) or "rsync"
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
 	MonitoringProfile domain.MonitorProfile
@@ -101,6 +108,9 @@ func (a *ResourcePool) Equals(b *ResourcePool) bool {
 	if a.MemoryCommitment != b.MemoryCommitment {
 		return false
 	}
+	if a.StorageTransport != b.StorageTransport {
+		return false
+	}
 	if a.CreatedAt.Unix() != b.CreatedAt.Unix() {
 		return false
 	}
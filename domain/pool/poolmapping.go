@@ -33,7 +33,8 @@ var (
         "CreatedAt" :   {"type": "date", "format" : "dateOptionalTime"},
         "UpdatedAt" :   {"type": "date", "format" : "dateOptionalTime"},
         "CoreCapacity": {"type": "long", "format": "not_analyzed"},
-        "MemoryCapacity": {"type": "long", "format": "not_analyzed"}
+        "MemoryCapacity": {"type": "long", "format": "not_analyzed"},
+        "StorageTransport": {"type": "string", "index":"not_analyzed"}
       }
     }
 }
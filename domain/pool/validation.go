@@ -33,6 +33,10 @@ func (p *ResourcePool) ValidEntity() error {
 	violations.Add(validation.NotEmpty("Pool.Realm", p.Realm))
 	violations.Add(validation.StringsEqual(p.Realm, trimmedRealm, "leading and trailing spaces not allowed for pool realm"))
 
+	if p.StorageTransport != "" {
+		violations.Add(validation.StringIn(p.StorageTransport, StorageNFS, StorageRsync))
+	}
+
 	if len(violations.Errors) > 0 {
 		return violations
 	}
@@ -163,6 +163,29 @@ func (service *Service) EvaluateRunsTemplate(gs GetService, fc FindChildService)
 	return
 }
 
+// templateFunctions returns the functions that templates in service
+// definitions can call
+func templateFunctions(gs GetService, fc FindChildService) template.FuncMap {
+	return template.FuncMap{
+		"parent":        parent(gs),
+		"child":         child(fc),
+		"context":       context(gs),
+		"getContext":    getContext(gs),
+		"contextFilter": contextFilter(gs),
+		"percentScale":  percentScale,
+		"bytesToMB":     bytesToMB,
+		"plus":          plus,
+		"each":          each,
+	}
+}
+
+// ParseTemplate checks that a template of a service definition, such as the
+// content of a config file, can be parsed.  It is not evaluated.
+func ParseTemplate(serviceTemplate string) error {
+	_, err := template.New("ServiceDefinitionTemplate").Funcs(templateFunctions(nil, nil)).Parse(serviceTemplate)
+	return err
+}
+
 // evaluateTemplate takes a control center client and template string and evaluates
 // the template using the service as the context. If the template is invalid or there is an error
 // then an empty string is returned.
@@ -177,20 +200,8 @@ func (service *Service) evaluateTemplate(gs GetService, fc FindChildService, ins
 		}
 	}()
 
-	functions := template.FuncMap{
-		"parent":        parent(gs),
-		"child":         child(fc),
-		"context":       context(gs),
-		"getContext":    getContext(gs),
-		"contextFilter": contextFilter(gs),
-		"percentScale":  percentScale,
-		"bytesToMB":     bytesToMB,
-		"plus":          plus,
-		"each":          each,
-	}
-
 	// parse the template
-	t := template.Must(template.New("ServiceDefinitionTemplate").Funcs(functions).Parse(serviceTemplate))
+	t := template.Must(template.New("ServiceDefinitionTemplate").Funcs(templateFunctions(gs, fc)).Parse(serviceTemplate))
 
 	// evaluate it
 	var buffer bytes.Buffer
@@ -38,3 +38,15 @@ func TestRound(t *testing.T) {
 		}
 	}
 }
+
+func TestParseTemplate(t *testing.T) {
+	if err := ParseTemplate(`{{(context (parent .)).zope}} {{percentScale 512 0.5}} {{plus 1 .InstanceID}}`); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := ParseTemplate(`{{(context .).zope`); err == nil {
+		t.Errorf("expected an error for an unclosed action")
+	}
+	if err := ParseTemplate(`{{unknown .}}`); err == nil {
+		t.Errorf("expected an error for an unknown function")
+	}
+}
@@ -47,15 +47,18 @@ type Service struct {
 	Tags              []string
 	OriginalConfigs   map[string]servicedefinition.ConfigFile
 	ConfigFiles       map[string]servicedefinition.ConfigFile
+	ConfigConflicts   map[string]ConfigConflict // customized config files that could not be merged with a template upgrade
 	Instances         int
 	InstanceLimits    domain.MinMax
 	ChangeOptions     []string
 	ImageID           string
+	ImageDigest       string // docker ID of the image that ImageID resolved to when it was deployed or last committed
 	PoolID            string
 	DesiredState      int
 	HostPolicy        servicedefinition.HostPolicy
 	Hostname          string
 	Privileged        bool
+	Network           servicedefinition.NetworkOptions
 	Launch            string
 	Endpoints         []ServiceEndpoint
 	Tasks             []servicedefinition.Task
@@ -63,7 +66,12 @@ type Service struct {
 	Volumes           []servicedefinition.Volume
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
+	UpdatedBy         string // user that last updated the service, who is the author of its config file changes
 	DeploymentID      string
+	TemplateID        string            // template the deployment was deployed or last upgraded from, set on its tenants
+	TemplateValues    map[string]string // template parameter values the deployment was deployed with, set on its tenants
+	VolumeSoftLimit   uint64            // bytes of the tenant volume after which warnings are logged, set on tenants; 0 for the daemon default
+	VolumeHardLimit   uint64            // bytes of the tenant volume after which snapshots are refused, set on tenants; 0 for the daemon default
 	DisableImage      bool
 	LogConfigs        []servicedefinition.LogConfig
 	Snapshot          servicedefinition.SnapshotCommands
@@ -76,10 +84,19 @@ type Service struct {
 	MonitoringProfile domain.MonitorProfile
 	MemoryLimit       float64
 	CPUShares         int64
+	ResourceLimits    servicedefinition.ResourceLimits
 	PIDFile           string
 	datastore.VersionedEntity
 }
 
+// ConfigConflict is a customized config file whose changes conflict with the
+// changes to its template.  The customized file stays in use until the
+// conflict is resolved.
+type ConfigConflict struct {
+	Template servicedefinition.ConfigFile // Config file of the new template
+	Merged   string                       // Merged content, with conflict markers
+}
+
 //ServiceEndpoint endpoint exported or imported by a service
 type ServiceEndpoint struct {
 	servicedefinition.EndpointDefinition
@@ -145,6 +162,7 @@ func BuildService(sd servicedefinition.ServiceDefinition, parentServiceID string
 	svc.HostPolicy = sd.HostPolicy
 	svc.Hostname = sd.Hostname
 	svc.Privileged = sd.Privileged
+	svc.Network = sd.Network
 	svc.OriginalConfigs = sd.ConfigFiles
 	svc.ConfigFiles = sd.ConfigFiles
 	svc.Tasks = sd.Tasks
@@ -177,10 +195,78 @@ func BuildService(sd servicedefinition.ServiceDefinition, parentServiceID string
 	svc.MonitoringProfile = *profile
 	svc.MemoryLimit = sd.MemoryLimit
 	svc.CPUShares = sd.CPUShares
+	svc.ResourceLimits = sd.ResourceLimits
 
 	return &svc, nil
 }
 
+// BuildServiceDefinition builds a service definition from a service, without
+// its child services.  This is the inverse of BuildService: the instance count
+// becomes the default instance count, the endpoints lose their address
+// assignments and get back their application templates, and the monitoring
+// profile loses the queries and tags that identify the service.  The image is
+// kept as it is.  Services do not keep the log filters of their definitions,
+// so the caller has to fill them in.
+func BuildServiceDefinition(svc Service) servicedefinition.ServiceDefinition {
+	sd := servicedefinition.ServiceDefinition{
+		Name:           svc.Name,
+		Title:          svc.Title,
+		Version:        svc.Version,
+		Command:        svc.Startup,
+		Description:    svc.Description,
+		Tags:           svc.Tags,
+		ImageID:        svc.ImageID,
+		Instances:      svc.InstanceLimits,
+		ChangeOptions:  svc.ChangeOptions,
+		Launch:         svc.Launch,
+		HostPolicy:     svc.HostPolicy,
+		Hostname:       svc.Hostname,
+		Privileged:     svc.Privileged,
+		Network:        svc.Network,
+		ConfigFiles:    svc.ConfigFiles,
+		Context:        svc.Context,
+		Tasks:          svc.Tasks,
+		Volumes:        svc.Volumes,
+		LogConfigs:     svc.LogConfigs,
+		Snapshot:       svc.Snapshot,
+		RAMCommitment:  svc.RAMCommitment,
+		CPUCommitment:  svc.CPUCommitment,
+		Runs:           svc.Runs,
+		Actions:        svc.Actions,
+		HealthChecks:   svc.HealthChecks,
+		Prereqs:        svc.Prereqs,
+		PIDFile:        svc.PIDFile,
+		MemoryLimit:    svc.MemoryLimit,
+		CPUShares:      svc.CPUShares,
+		ResourceLimits: svc.ResourceLimits,
+	}
+	sd.Instances.Default = svc.Instances
+
+	for _, ep := range svc.Endpoints {
+		def := ep.EndpointDefinition
+		if def.ApplicationTemplate != "" {
+			def.Application, def.ApplicationTemplate = def.ApplicationTemplate, ""
+		}
+		sd.Endpoints = append(sd.Endpoints, def)
+	}
+
+	profile := svc.MonitoringProfile
+	sd.MonitoringProfile = domain.MonitorProfile{
+		MetricConfigs:    make([]domain.MetricConfig, len(profile.MetricConfigs)),
+		GraphConfigs:     make([]domain.GraphConfig, len(profile.GraphConfigs)),
+		ThresholdConfigs: profile.ThresholdConfigs,
+	}
+	for i, config := range profile.MetricConfigs {
+		config.Query = domain.QueryConfig{}
+		sd.MonitoringProfile.MetricConfigs[i] = config
+	}
+	for i, graph := range profile.GraphConfigs {
+		graph.Tags = nil
+		sd.MonitoringProfile.GraphConfigs[i] = graph
+	}
+	return sd
+}
+
 // GetServiceImports retrieves service endpoints whose purpose is "import"
 func (s *Service) GetServiceImports() []ServiceEndpoint {
 	result := []ServiceEndpoint{}
@@ -358,6 +444,9 @@ func (s *Service) Equals(b *Service) bool {
 	if s.ImageID != b.ImageID {
 		return false
 	}
+	if s.ImageDigest != b.ImageDigest {
+		return false
+	}
 	if s.PoolID != b.PoolID {
 		return false
 	}
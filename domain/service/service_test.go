@@ -24,6 +24,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -148,3 +149,52 @@ func TestBuildServiceBuildsMetricConfigs(t *testing.T) {
 		t.Error("expected != actual")
 	}
 }
+
+func TestBuildServiceDefinition(t *testing.T) {
+	sd := servicedefinition.ServiceDefinition{
+		Name:      "Zope",
+		Command:   "runzope",
+		ImageID:   "zenoss/core:5.0",
+		Instances: domain.MinMax{Min: 1, Max: 4, Default: 2},
+		Launch:    "auto",
+		ConfigFiles: map[string]servicedefinition.ConfigFile{
+			"/etc/zope.conf": {Filename: "/etc/zope.conf", Content: "threads 4\n"},
+		},
+		Endpoints: []servicedefinition.EndpointDefinition{
+			{Name: "zope", Purpose: "export", Protocol: "tcp", PortNumber: 9080, Application: "zope"},
+			{Name: "mysql", Purpose: "import", Protocol: "tcp", PortNumber: 3306, Application: "{{(context (parent .)).mysql}}"},
+		},
+		Volumes: []servicedefinition.Volume{
+			{Owner: "zenoss:zenoss", Permission: "0755", ResourcePath: "zenjobs", ContainerPath: "/opt/zenoss/jobs"},
+		},
+		MonitoringProfile: domain.MonitorProfile{
+			MetricConfigs: []domain.MetricConfig{
+				{ID: "zope", Name: "Zope", Metrics: []domain.Metric{{ID: "zope.requests", Name: "Requests"}}},
+			},
+			GraphConfigs: []domain.GraphConfig{
+				{ID: "requests", Name: "Requests"},
+			},
+		},
+	}
+
+	svc, err := BuildService(sd, "parent", "default", SVCRun, "deployment")
+	if err != nil {
+		t.Fatalf("BuildService Failed w/err=%s", err)
+	}
+	// what deploying the service and assigning its addresses changes
+	svc.Instances = 3
+	for i := range svc.Endpoints {
+		svc.Endpoints[i].ApplicationTemplate = svc.Endpoints[i].Application
+	}
+	svc.Endpoints[1].Application = "mysql"
+	svc.Endpoints[0].AddressAssignment.IPAddr = "10.0.0.1"
+
+	actual := BuildServiceDefinition(*svc)
+	sd.Instances.Default = 3
+	sd.MonitoringProfile.ThresholdConfigs = []domain.ThresholdConfig{}
+	if !reflect.DeepEqual(actual, sd) {
+		t.Logf("expected: %+v", sd)
+		t.Logf("actual: %+v", actual)
+		t.Error("expected != actual")
+	}
+}
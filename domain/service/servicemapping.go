@@ -39,6 +39,7 @@ var (
 		},
 		"DesiredState":    {"type": "long", "index":"not_analyzed"},
 		"ImageID":         {"type": "string", "index":"not_analyzed"},
+		"ImageDigest":     {"type": "string", "index":"not_analyzed"},
 		"PoolID":          {"type": "string", "index":"not_analyzed"},
 		"Launch":          {"type": "string", "index":"not_analyzed"},
 		"HostPolicy":      {"type": "string", "index":"not_analyzed"},
@@ -60,6 +61,11 @@ var (
 			"": {"type": "string", "index": "not_analyzed"}
 		  }
 		},
+		"ConfigConflicts": {"type": "object", "index":"not_analyzed"},
+		"TemplateID":      {"type": "string", "index":"not_analyzed"},
+		"TemplateValues":  {"type": "object", "index":"not_analyzed"},
+		"VolumeSoftLimit": {"type": "long",   "index":"not_analyzed"},
+		"VolumeHardLimit": {"type": "long",   "index":"not_analyzed"},
 		"OriginalConfigs":     {
 		  "properties": {
 			"": {"type": "string", "index": "not_analyzed"},
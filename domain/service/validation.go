@@ -15,6 +15,7 @@ package service
 
 import (
 	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/domain/servicedefinition"
 	"github.com/control-center/serviced/validation"
 	"fmt"
 )
@@ -42,6 +43,16 @@ func (s *Service) ValidEntity() error {
 		}
 	}
 
+	vErr.Add(s.ResourceLimits.ValidEntity())
+	vErr.Add(s.ResourceLimits.ValidSwap(s.MemoryLimit))
+
+	vErr.Add(s.Network.ValidEntity())
+	endpoints := make([]servicedefinition.EndpointDefinition, len(s.Endpoints))
+	for i, ep := range s.Endpoints {
+		endpoints[i] = ep.EndpointDefinition
+	}
+	vErr.Add(s.Network.ValidHostNetwork(s.Hostname, endpoints, s.Instances, s.HostPolicy))
+
 	if vErr.HasError() {
 		return vErr
 	}
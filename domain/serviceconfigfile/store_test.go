@@ -19,6 +19,7 @@ import (
 	"github.com/control-center/serviced/domain/servicedefinition"
 	. "gopkg.in/check.v1"
 
+	"fmt"
 	"testing"
 )
 
@@ -30,7 +31,7 @@ func Test(t *testing.T) {
 var _ = Suite(&S{
 	ElasticTest: elastic.ElasticTest{
 		Index:    "controlplane",
-		Mappings: []elastic.Mapping{MAPPING},
+		Mappings: []elastic.Mapping{MAPPING, VERSION_MAPPING},
 	}})
 
 type S struct {
@@ -120,3 +121,60 @@ func (s *S) Test_GetConfigFiles(t *C) {
 	//	}
 
 }
+
+func (s *S) Test_GetConfigVersions(t *C) {
+	tenant := "test_tenant"
+	path := "/testPath/parts"
+
+	versions, err := s.ps.GetConfigVersions(s.ctx, tenant, path, "testname")
+	t.Assert(err, IsNil)
+	t.Assert(versions, HasLen, 0)
+
+	for _, v := range []int{2, 1, 3} {
+		version, err := NewVersion(tenant, path, v, "admin", servicedefinition.ConfigFile{Content: fmt.Sprintf("version %d", v), Filename: "testname"})
+		t.Assert(err, IsNil)
+		err = s.ps.Put(s.ctx, VersionKey(version.ID), version)
+		t.Assert(err, IsNil)
+	}
+	other, err := NewVersion(tenant, path, 1, "admin", servicedefinition.ConfigFile{Content: "other", Filename: "othername"})
+	t.Assert(err, IsNil)
+	err = s.ps.Put(s.ctx, VersionKey(other.ID), other)
+	t.Assert(err, IsNil)
+
+	versions, err = s.ps.GetConfigVersions(s.ctx, tenant, path, "testname")
+	t.Assert(err, IsNil)
+	t.Assert(versions, HasLen, 3)
+	for i, version := range versions {
+		t.Assert(version.Version, Equals, i+1)
+		t.Assert(version.ConfFile.Content, Equals, fmt.Sprintf("version %d", i+1))
+	}
+}
+
+func (s *S) Test_DeleteConfigVersions(t *C) {
+	tenant := "delete_tenant"
+	path := "/testPath/removed"
+	otherPath := "/testPath/kept"
+
+	for _, p := range []string{path, otherPath} {
+		for _, name := range []string{"testname", "othername"} {
+			version, err := NewVersion(tenant, p, 1, "admin", servicedefinition.ConfigFile{Content: "version 1", Filename: name})
+			t.Assert(err, IsNil)
+			err = s.ps.Put(s.ctx, VersionKey(version.ID), version)
+			t.Assert(err, IsNil)
+		}
+	}
+
+	err := s.ps.DeleteConfigVersions(s.ctx, tenant, path)
+	t.Assert(err, IsNil)
+
+	// only the versions of the removed service are deleted
+	for _, name := range []string{"testname", "othername"} {
+		versions, err := s.ps.GetConfigVersions(s.ctx, tenant, path, name)
+		t.Assert(err, IsNil)
+		t.Assert(versions, HasLen, 0)
+
+		versions, err = s.ps.GetConfigVersions(s.ctx, tenant, otherPath, name)
+		t.Assert(err, IsNil)
+		t.Assert(versions, HasLen, 1)
+	}
+}
@@ -32,10 +32,35 @@ var (
 `
 	//MAPPING is the elastic mapping for a service
 	MAPPING, mappingError = elastic.NewMapping(mappingString)
+
+	versionMappingString = `
+{
+	"svcconfigversion": {
+	  "properties": {
+		"ID" :             {"type": "string", "index":"not_analyzed"},
+		"ServiceTenantID": {"type": "string", "index":"not_analyzed"},
+		"ServicePath":     {"type": "string", "index":"not_analyzed"},
+		"Version":         {"type": "long"},
+		"Author":          {"type": "string", "index":"not_analyzed"},
+		"CreatedAt":       {"type": "date", "format" : "dateOptionalTime"},
+		"ConfFile": {
+		  "properties": {
+			"Filename":    {"type": "string", "index":"not_analyzed"}
+		  }
+		}
+	  }
+	}
+}
+`
+	//VERSION_MAPPING is the elastic mapping for a config file version
+	VERSION_MAPPING, versionMappingError = elastic.NewMapping(versionMappingString)
 )
 
 func init() {
 	if mappingError != nil {
 		glog.Fatalf("error creating svcconfigfile mapping: %v", mappingError)
 	}
+	if versionMappingError != nil {
+		glog.Fatalf("error creating svcconfigversion mapping: %v", versionMappingError)
+	}
 }
@@ -0,0 +1,147 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceconfigfile
+
+import (
+	"sort"
+	"time"
+
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/servicedefinition"
+	"github.com/control-center/serviced/utils"
+	"github.com/control-center/serviced/validation"
+	"github.com/zenoss/elastigo/search"
+)
+
+// ConfigVersion is a version of a service's config file.  A version is kept
+// for every change to the file, so that changes can be traced and reverted.
+type ConfigVersion struct {
+	ID              string
+	ServiceTenantID string
+	ServicePath     string
+	Version         int       // Version number, starting at 1 for the file before its first change
+	Author          string    // User that made the change
+	CreatedAt       time.Time // Time of the change
+	ConfFile        servicedefinition.ConfigFile
+	datastore.VersionedEntity
+}
+
+// NewVersion creates a version of a service's config file
+func NewVersion(tenantID string, svcPath string, version int, author string, conf servicedefinition.ConfigFile) (*ConfigVersion, error) {
+	uuid, err := utils.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	cv := &ConfigVersion{
+		ID:              uuid,
+		ServiceTenantID: tenantID,
+		ServicePath:     svcPath,
+		Version:         version,
+		Author:          author,
+		CreatedAt:       time.Now(),
+		ConfFile:        conf,
+	}
+	if err = cv.ValidEntity(); err != nil {
+		return nil, err
+	}
+	return cv, nil
+}
+
+// ValidEntity validates a ConfigVersion
+func (cv ConfigVersion) ValidEntity() error {
+	vErr := validation.NewValidationError()
+	vErr.Add(validation.NotEmpty("ID", cv.ID))
+	vErr.Add(validation.NotEmpty("ServiceTenantID", cv.ServiceTenantID))
+	vErr.Add(validation.NotEmpty("ServicePath", cv.ServicePath))
+	vErr.Add(validation.NotEmpty("FileName", cv.ConfFile.Filename))
+	if cv.Version < 1 {
+		vErr.AddViolation("field Version must be at least 1")
+	}
+	if vErr.HasError() {
+		return vErr
+	}
+	return nil
+}
+
+// GetConfigVersions returns the versions of a service's config file, oldest
+// first
+func (s *Store) GetConfigVersions(ctx datastore.Context, tenantID string, svcPath string, filename string) ([]*ConfigVersion, error) {
+	search := search.Search("controlplane").Type(versionKind).Size("50000").Filter(
+		"and",
+		search.Filter().Terms("ServiceTenantID", tenantID),
+		search.Filter().Terms("ServicePath", svcPath),
+		search.Filter().Terms("ConfFile.Filename", filename),
+	)
+
+	versions, err := queryVersions(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byVersion(versions))
+	return versions, nil
+}
+
+// DeleteConfigVersions removes the versions of all the config files of a
+// service, once the service is removed
+func (s *Store) DeleteConfigVersions(ctx datastore.Context, tenantID string, svcPath string) error {
+	search := search.Search("controlplane").Type(versionKind).Size("50000").Filter(
+		"and",
+		search.Filter().Terms("ServiceTenantID", tenantID),
+		search.Filter().Terms("ServicePath", svcPath),
+	)
+
+	versions, err := queryVersions(ctx, search)
+	if err != nil {
+		return err
+	}
+	for _, cv := range versions {
+		if err := s.Delete(ctx, VersionKey(cv.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queryVersions(ctx datastore.Context, search *search.SearchDsl) ([]*ConfigVersion, error) {
+	q := datastore.NewQuery(ctx)
+	results, err := q.Execute(search)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]*ConfigVersion, results.Len())
+	for idx := range versions {
+		var cv ConfigVersion
+		if err := results.Get(idx, &cv); err != nil {
+			return nil, err
+		}
+		versions[idx] = &cv
+	}
+	return versions, nil
+}
+
+// VersionKey returns the datastore key of a ConfigVersion
+func VersionKey(id string) datastore.Key {
+	return datastore.NewKey(versionKind, id)
+}
+
+var (
+	versionKind = "svcconfigversion"
+)
+
+type byVersion []*ConfigVersion
+
+func (v byVersion) Len() int           { return len(v) }
+func (v byVersion) Less(i, j int) bool { return v[i].Version < v[j].Version }
+func (v byVersion) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
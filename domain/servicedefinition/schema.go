@@ -0,0 +1,117 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicedefinition
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/control-center/serviced/commons/jsonschema"
+	"github.com/control-center/serviced/domain"
+	"github.com/control-center/serviced/validation"
+)
+
+// Files that a service definition can be read from, in a directory of service
+// definitions
+var definitionFiles = []string{"service.json", "service.yaml", "service.yml"}
+
+// Schema returns the JSON Schema of service definitions
+func Schema() *jsonschema.Schema {
+	schema := jsonschema.Reflect(reflect.TypeOf(ServiceDefinition{}), map[reflect.Type]*jsonschema.Schema{
+		reflect.TypeOf(DEFAULT): {
+			Type: jsonschema.TypeString,
+			Enum: []string{string(DEFAULT), LeastCommitted, PreferSeparate, RequireSeparate},
+		},
+		// intervals and timeouts are in seconds
+		reflect.TypeOf(domain.HealthCheck{}): {
+			Type: jsonschema.TypeObject,
+			Properties: map[string]*jsonschema.Schema{
+				"Script":   {Type: jsonschema.TypeString},
+				"Interval": {Type: jsonschema.TypeNumber},
+				"Timeout":  {Type: jsonschema.TypeNumber},
+			},
+		},
+	})
+	// the pinned docker client can only pass the swap limit, and has no fields
+	// for dns search domains and extra hosts
+	for definition, names := range map[string][]string{
+		"ResourceLimits": {"CPUQuota", "CPUPeriod", "PidsLimit", "BlkioWeight", "BlkioThrottles", "Ulimits"},
+		"NetworkOptions": {"DNSSearch", "ExtraHosts"},
+	} {
+		for _, name := range names {
+			unsupported(schema.Definitions[definition].Properties[name])
+		}
+	}
+	schema.Title = "Service definition"
+	return schema
+}
+
+// unsupported restricts a setting to its zero value
+func unsupported(s *jsonschema.Schema) {
+	if s.Type == jsonschema.TypeArray {
+		none := 0
+		s.MaxItems = &none
+		return
+	}
+	zero := 0.0
+	s.Minimum, s.Maximum = &zero, &zero
+}
+
+// Unmarshal decodes a service definition in JSON or YAML.  If it doesn't match
+// the schema, the error is a *validation.ValidationError of every problem,
+// with the filename and line number where it is.
+func Unmarshal(filename string, data []byte, sd *ServiceDefinition) error {
+	problems, err := Schema().Unmarshal(data, sd)
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	} else if len(problems) == 0 {
+		return nil
+	}
+
+	violations := validation.NewValidationError()
+	for _, p := range problems {
+		if p.Path == "" {
+			violations.AddViolation(fmt.Sprintf("%s:%d: %s", filename, p.Line, p.Message))
+		} else {
+			violations.AddViolation(fmt.Sprintf("%s:%d: %s: %s", filename, p.Line, p.Path, p.Message))
+		}
+	}
+	return violations
+}
+
+// ReadFile reads a service definition, and the definitions of its child
+// services, from a JSON or YAML file
+func ReadFile(filename string) (*ServiceDefinition, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var sd ServiceDefinition
+	if err := Unmarshal(filename, data, &sd); err != nil {
+		return nil, err
+	}
+	if sd.Name == "" {
+		return nil, fmt.Errorf("%s: service definition has no Name", filename)
+	}
+	var normalize func(sd *ServiceDefinition)
+	normalize = func(sd *ServiceDefinition) {
+		sd.NormalizeLaunch()
+		for i := range sd.Services {
+			normalize(&sd.Services[i])
+		}
+	}
+	normalize(&sd)
+	return &sd, nil
+}
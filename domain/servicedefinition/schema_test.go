@@ -0,0 +1,197 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicedefinition
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/control-center/serviced/domain"
+	"github.com/control-center/serviced/validation"
+)
+
+func TestSchemaIsPublished(t *testing.T) {
+	published, err := ioutil.ReadFile("../../doc/servicedefinition.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := json.MarshalIndent(Schema(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(published), schema) {
+		t.Errorf("doc/servicedefinition.schema.json is out of date, regenerate it with serviced template schema")
+	}
+}
+
+const yamlDefinition = `Name: zenoss
+Description: Zenoss Core
+ImageID: zenoss/core:5.0
+HostPolicy: PREFER_SEPARATE
+Instances:
+  Min: 1
+ConfigFiles:
+  /etc/zenoss.conf:
+    Filename: /etc/zenoss.conf
+    Permissions: 0644
+    Content: |
+      workers = {{(context .).workers}}
+HealthChecks:
+  running:
+    Script: pgrep zope
+    Interval: 5
+Services:
+  - Name: Zope
+    Command: zopectl fg
+    Launch: MANUAL
+    Endpoints:
+      - Name: zope
+        Purpose: export
+        Protocol: tcp
+        PortNumber: 9080
+        Application: zope
+`
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicedefinition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "zenoss.yaml")
+	if err := ioutil.WriteFile(filename, []byte(yamlDefinition), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sd, err := ReadFile(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := &ServiceDefinition{
+		Name:        "zenoss",
+		Description: "Zenoss Core",
+		ImageID:     "zenoss/core:5.0",
+		HostPolicy:  PreferSeparate,
+		Launch:      "auto",
+		Instances:   domain.MinMax{Min: 1},
+		ConfigFiles: map[string]ConfigFile{
+			"/etc/zenoss.conf": {Filename: "/etc/zenoss.conf", Permissions: "0644", Content: "workers = {{(context .).workers}}\n"},
+		},
+		HealthChecks: map[string]domain.HealthCheck{
+			"running": {Script: "pgrep zope", Interval: 5 * time.Second},
+		},
+		Services: []ServiceDefinition{
+			{
+				Name:    "Zope",
+				Command: "zopectl fg",
+				Launch:  "manual",
+				Endpoints: []EndpointDefinition{
+					{Name: "zope", Purpose: "export", Protocol: "tcp", PortNumber: 9080, Application: "zope"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(sd, expected) {
+		t.Errorf("expected %+v, got %+v", expected, sd)
+	}
+}
+
+func TestUnmarshal_problems(t *testing.T) {
+	definition := strings.Replace(yamlDefinition, "PREFER_SEPARATE", "ANYWHERE", 1)
+	definition = strings.Replace(definition, "PortNumber: 9080", "PortNumber: zope", 1)
+
+	var sd ServiceDefinition
+	err := Unmarshal("zenoss.yaml", []byte(definition), &sd)
+	violations, ok := err.(*validation.ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	expected := []string{
+		`zenoss.yaml:4: HostPolicy: "ANYWHERE" is not one of "", "LEAST_COMMITTED", "PREFER_SEPARATE", "REQUIRE_SEPARATE"`,
+		`zenoss.yaml:25: Services[0].Endpoints[0].PortNumber: expected an integer`,
+	}
+	if len(violations.Errors) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, violations.Errors)
+	}
+	for i, err := range violations.Errors {
+		if err.Error() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], err)
+		}
+	}
+}
+
+func TestUnmarshal_unsupported(t *testing.T) {
+	definition := `Name: zenoss
+ResourceLimits:
+  MemorySwap: -1
+  CPUQuota: 200000
+  Ulimits:
+    - Name: nofile
+Network:
+  DNSSearch: [example.com]
+`
+	var sd ServiceDefinition
+	err := Unmarshal("zenoss.yaml", []byte(definition), &sd)
+	violations, ok := err.(*validation.ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	expected := []string{
+		"zenoss.yaml:4: ResourceLimits.CPUQuota: must be at most 0",
+		"zenoss.yaml:6: ResourceLimits.Ulimits: must have at most 0 items",
+		"zenoss.yaml:8: Network.DNSSearch: must have at most 0 items",
+	}
+	if len(violations.Errors) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, violations.Errors)
+	}
+	for i, err := range violations.Errors {
+		if err.Error() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], err)
+		}
+	}
+}
+
+func TestBuildFromPath_yaml(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicedefinition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "zenoss")
+	if err := os.MkdirAll(filepath.Join(root, "Zope"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(root, "service.yaml"), []byte("Description: Zenoss Core\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "Zope", "service.json"), []byte(`{"Command": "zopectl fg"}`), 0644)
+
+	sd, err := BuildFromPath(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sd.Name != "zenoss" || sd.Description != "Zenoss Core" || len(sd.Services) != 1 || sd.Services[0].Command != "zopectl fg" {
+		t.Errorf("unexpected service definition %+v", sd)
+	}
+
+	ioutil.WriteFile(filepath.Join(root, "Zope", "service.yml"), []byte("Command: zopectl fg\n"), 0644)
+	if _, err := BuildFromPath(root); err == nil || !strings.Contains(err.Error(), "define the same service") {
+		t.Errorf("expected an error for two definitions of Zope, got %v", err)
+	}
+}
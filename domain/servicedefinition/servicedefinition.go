@@ -34,8 +34,9 @@ type ServiceDefinition struct {
 	ChangeOptions     []string               // Control options for what happens when a running service is changed
 	Launch            string                 // Must be "AUTO", the default, or "MANUAL"
 	HostPolicy        HostPolicy             // Policy for starting up instances
-	Hostname          string                 // Optional hostname which should be set on run
+	Hostname          string                 // Optional hostname which should be set on run, a template evaluated per instance
 	Privileged        bool                   // Whether to run the container with extended privileges
+	Network           NetworkOptions         // Docker networking options beyond the proxy and exported endpoints
 	ConfigFiles       map[string]ConfigFile  // Config file templates
 	Context           map[string]interface{} // Context information for the service
 	Endpoints         []EndpointDefinition   // Comms endpoints used by the service
@@ -54,7 +55,62 @@ type ServiceDefinition struct {
 	MonitoringProfile domain.MonitorProfile         // An optional list of queryable metrics, graphs, and thresholds
 	MemoryLimit       float64
 	CPUShares         int64
-	PIDFile           string // An optional path or command to generate a path for a PID file to which signals are relayed.
+	ResourceLimits    ResourceLimits // Limits on the other resources the service's containers may use
+	PIDFile           string         // An optional path or command to generate a path for a PID file to which signals are relayed.
+}
+
+// ResourceLimits limits the resources that the containers of a service may use,
+// beyond MemoryLimit and CPUShares.  Zero values are not limited.  The pinned
+// docker client can only pass MemorySwap, so the other limits fail validation.
+type ResourceLimits struct {
+	CPUQuota       int64           // microseconds of CPU time per CPU period, e.g. 200000 per 100000 for 2 cores
+	CPUPeriod      int64           // length of a CPU period in microseconds, 100000 if not set
+	MemorySwap     int64           // memory plus swap in bytes, -1 for unlimited swap
+	PidsLimit      int64           // maximum number of processes
+	BlkioWeight    uint16          // relative block I/O weight, from 10 to 1000
+	BlkioThrottles []BlkioThrottle // block I/O rate limits
+	Ulimits        []Ulimit        // process limits, as set by ulimit
+}
+
+// BlkioThrottle limits the rate of block I/O to a device
+type BlkioThrottle struct {
+	Device    string // path of the device, e.g. /dev/sda
+	ReadBps   uint64 // bytes read per second
+	WriteBps  uint64 // bytes written per second
+	ReadIOps  uint64 // reads per second
+	WriteIOps uint64 // writes per second
+}
+
+// Ulimit is a process limit
+type Ulimit struct {
+	Name string // name of the limit, e.g. nofile
+	Soft int64
+	Hard int64
+}
+
+// NetworkOptions requests docker networking features for the containers of a
+// service, beyond the serviced proxy, virtual addresses and the ports of its
+// exported endpoints.  The pinned docker client cannot pass DNSSearch and
+// ExtraHosts, so they fail validation.
+type NetworkOptions struct {
+	HostNetwork bool          // run on the network of the host, e.g. for latency-sensitive collectors
+	Ports       []PortMapping // additional ports published on the host
+	DNSSearch   []string      // DNS search domains
+	ExtraHosts  []ExtraHost   // additional entries in /etc/hosts
+}
+
+// PortMapping publishes a port of a container on the host
+type PortMapping struct {
+	ContainerPort uint16
+	HostPort      uint16 // port on the host, any free port if not set
+	HostIP        string // address on the host to bind to, all addresses if not set
+	Protocol      string // "tcp", the default, or "udp"
+}
+
+// ExtraHost is an entry in the /etc/hosts of a container
+type ExtraHost struct {
+	Hostname string
+	IPAddr   string
 }
 
 // SnapshotCommands commands to be called during and after a snapshot
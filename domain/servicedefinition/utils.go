@@ -16,7 +16,6 @@ package servicedefinition
 import (
 	"github.com/zenoss/glog"
 
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -35,8 +34,19 @@ func getServiceDefinition(path string) (serviceDef *ServiceDefinition, err error
 		return nil, fmt.Errorf("given path is not a directory")
 	}
 
-	// look for service.json
-	serviceFile := fmt.Sprintf("%s/service.json", path)
+	// look for service.json, service.yaml or service.yml
+	var serviceFile string
+	for _, name := range definitionFiles {
+		if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+			if serviceFile != "" {
+				return nil, fmt.Errorf("%s and %s define the same service", serviceFile, filepath.Join(path, name))
+			}
+			serviceFile = filepath.Join(path, name)
+		}
+	}
+	if serviceFile == "" {
+		return nil, fmt.Errorf("no %s in %s", strings.Join(definitionFiles, ", "), path)
+	}
 	blob, err := ioutil.ReadFile(serviceFile)
 	if err != nil {
 		return nil, err
@@ -44,7 +54,7 @@ func getServiceDefinition(path string) (serviceDef *ServiceDefinition, err error
 
 	// load blob
 	svc := ServiceDefinition{}
-	err = json.Unmarshal(blob, &svc)
+	err = Unmarshal(serviceFile, blob, &svc)
 	if err != nil {
 		glog.Errorf("Could not unmarshal service at %s", path)
 		return nil, err
@@ -64,7 +74,7 @@ func getServiceDefinition(path string) (serviceDef *ServiceDefinition, err error
 	}
 	for _, subpath := range subpaths {
 		switch {
-		case subpath.Name() == "service.json":
+		case isDefinitionFile(subpath.Name()):
 			continue
 		case subpath.Name() == "makefile": // ignoring makefiles present in service defs
 			continue
@@ -130,6 +140,15 @@ func getServiceDefinition(path string) (serviceDef *ServiceDefinition, err error
 	return &svc, err
 }
 
+func isDefinitionFile(name string) bool {
+	for _, file := range definitionFiles {
+		if name == file {
+			return true
+		}
+	}
+	return false
+}
+
 // this function takes a filter directory and creates a map
 // of filters by looking at the content in that directory.
 // it is assumed the filter name is the name of the file minus
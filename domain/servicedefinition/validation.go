@@ -42,8 +42,8 @@ func (sd *ServiceDefinition) validate(context *validationContext) error {
 		return fmt.Errorf("service Definition %v: %v", sd.Name, err)
 	}
 
-	if err := validation.StringIn(sd.Launch, commons.AUTO, commons.MANUAL); err != nil {
-		return fmt.Errorf("service definition %v: invalid launch setting %v", sd.Name, err)
+	if err := sd.ValidLaunch(); err != nil {
+		return fmt.Errorf("service definition %v: %v", sd.Name, err)
 	}
 
 	//validate endpoint config
@@ -64,9 +64,31 @@ func (sd *ServiceDefinition) validate(context *validationContext) error {
 	}
 	//TODO: validate LogConfigs
 
+	if err := sd.ResourceLimits.ValidEntity(); err != nil {
+		return fmt.Errorf("service definition %v: %v", sd.Name, err)
+	}
+	if err := sd.ResourceLimits.ValidSwap(sd.MemoryLimit); err != nil {
+		return fmt.Errorf("service definition %v: %v", sd.Name, err)
+	}
+	if err := sd.Network.ValidEntity(); err != nil {
+		return fmt.Errorf("service definition %v: %v", sd.Name, err)
+	}
+	if err := sd.ValidHostNetwork(); err != nil {
+		return fmt.Errorf("service definition %v: %v", sd.Name, err)
+	}
+
 	return validServiceDefinitions(&sd.Services, context)
 }
 
+// ValidLaunch checks that a service definition is launched automatically or
+// manually
+func (sd *ServiceDefinition) ValidLaunch() error {
+	if err := validation.StringIn(sd.Launch, commons.AUTO, commons.MANUAL); err != nil {
+		return fmt.Errorf("invalid launch setting %v", err)
+	}
+	return nil
+}
+
 // validServiceDefinitions validates an array of ServiceDefinition recursively
 func validServiceDefinitions(ds *[]ServiceDefinition, context *validationContext) error {
 	for _, sd := range *ds {
@@ -156,3 +178,209 @@ func (arc *AddressResourceConfig) Normalize() {
 	testProto := strings.Trim(strings.ToLower(arc.Protocol), " ")
 	arc.Protocol = testProto
 }
+
+// ulimits are the names of the limits that ulimit sets
+var ulimits = []string{
+	"core", "cpu", "data", "fsize", "locks", "memlock", "msgqueue", "nice",
+	"nofile", "nproc", "rss", "rtprio", "rttime", "sigpending", "stack",
+}
+
+// Unsupported returns the names of the limits that are set but that the
+// pinned docker client cannot pass to docker.  It only has a field for the
+// swap limit.
+func (rl ResourceLimits) Unsupported() []string {
+	var names []string
+	if rl.CPUQuota != 0 {
+		names = append(names, "CPUQuota")
+	}
+	if rl.CPUPeriod != 0 {
+		names = append(names, "CPUPeriod")
+	}
+	if rl.PidsLimit != 0 {
+		names = append(names, "PidsLimit")
+	}
+	if rl.BlkioWeight != 0 {
+		names = append(names, "BlkioWeight")
+	}
+	if len(rl.BlkioThrottles) > 0 {
+		names = append(names, "BlkioThrottles")
+	}
+	if len(rl.Ulimits) > 0 {
+		names = append(names, "Ulimits")
+	}
+	return names
+}
+
+//ValidEntity used to make sure ResourceLimits are within the ranges the
+//container host accepts, and can be passed to it
+func (rl ResourceLimits) ValidEntity() error {
+	violations := validation.NewValidationError()
+	for _, name := range rl.Unsupported() {
+		violations.AddViolation(fmt.Sprintf("%s is not supported by the docker client", name))
+	}
+	if rl.CPUQuota != 0 && rl.CPUQuota < 1000 {
+		violations.AddViolation(fmt.Sprintf("CPUQuota %d is less than 1000", rl.CPUQuota))
+	}
+	if rl.CPUPeriod != 0 && (rl.CPUPeriod < 1000 || rl.CPUPeriod > 1000000) {
+		violations.AddViolation(fmt.Sprintf("CPUPeriod %d is not between 1000 and 1000000", rl.CPUPeriod))
+	}
+	if rl.MemorySwap < -1 {
+		violations.AddViolation(fmt.Sprintf("MemorySwap %d is less than -1", rl.MemorySwap))
+	}
+	if rl.PidsLimit < 0 {
+		violations.AddViolation(fmt.Sprintf("PidsLimit %d is negative", rl.PidsLimit))
+	}
+	if rl.BlkioWeight != 0 && (rl.BlkioWeight < 10 || rl.BlkioWeight > 1000) {
+		violations.AddViolation(fmt.Sprintf("BlkioWeight %d is not between 10 and 1000", rl.BlkioWeight))
+	}
+
+	devices := make(map[string]struct{})
+	for _, throttle := range rl.BlkioThrottles {
+		if !strings.HasPrefix(throttle.Device, "/") {
+			violations.AddViolation(fmt.Sprintf("BlkioThrottles: device %q is not an absolute path", throttle.Device))
+		} else if _, found := devices[throttle.Device]; found {
+			violations.AddViolation(fmt.Sprintf("BlkioThrottles: device %s is throttled more than once", throttle.Device))
+		}
+		devices[throttle.Device] = struct{}{}
+		if throttle.ReadBps == 0 && throttle.WriteBps == 0 && throttle.ReadIOps == 0 && throttle.WriteIOps == 0 {
+			violations.AddViolation(fmt.Sprintf("BlkioThrottles: device %s has no rates", throttle.Device))
+		}
+	}
+
+	names := make(map[string]struct{})
+	for _, ulimit := range rl.Ulimits {
+		if err := validation.StringIn(ulimit.Name, ulimits...); err != nil {
+			violations.AddViolation(fmt.Sprintf("Ulimits: unknown limit %s", ulimit.Name))
+		} else if _, found := names[ulimit.Name]; found {
+			violations.AddViolation(fmt.Sprintf("Ulimits: %s is set more than once", ulimit.Name))
+		}
+		names[ulimit.Name] = struct{}{}
+		if ulimit.Hard >= 0 && (ulimit.Soft < 0 || ulimit.Soft > ulimit.Hard) {
+			violations.AddViolation(fmt.Sprintf("Ulimits: %s soft limit %d is greater than its hard limit %d", ulimit.Name, ulimit.Soft, ulimit.Hard))
+		}
+	}
+
+	if violations.HasError() {
+		return violations
+	}
+	return nil
+}
+
+//ValidSwap makes sure that MemorySwap, which includes the memory, is not less
+//than the memory limit
+func (rl ResourceLimits) ValidSwap(memoryLimit float64) error {
+	if rl.MemorySwap > 0 && float64(rl.MemorySwap) < memoryLimit {
+		return validation.NewViolation(fmt.Sprintf("MemorySwap %d is less than MemoryLimit %v", rl.MemorySwap, memoryLimit))
+	}
+	return nil
+}
+
+//ValidEntity used to make sure NetworkOptions can be passed to docker
+func (no NetworkOptions) ValidEntity() error {
+	violations := validation.NewValidationError()
+
+	type binding struct {
+		ip       string
+		port     uint16
+		protocol string
+	}
+	containerPorts := make(map[binding]struct{})
+	hostPorts := make(map[binding]struct{})
+	for _, pm := range no.Ports {
+		protocol := pm.Protocol
+		if protocol == "" {
+			protocol = commons.TCP
+		}
+		if err := validation.StringIn(protocol, commons.TCP, commons.UDP); err != nil {
+			violations.AddViolation(fmt.Sprintf("Ports: invalid protocol %s for container port %d", pm.Protocol, pm.ContainerPort))
+		}
+		if pm.ContainerPort == 0 {
+			violations.AddViolation("Ports: container port is not set")
+		} else if _, found := containerPorts[binding{"", pm.ContainerPort, protocol}]; found {
+			violations.AddViolation(fmt.Sprintf("Ports: container port %d/%s is published more than once", pm.ContainerPort, protocol))
+		}
+		containerPorts[binding{"", pm.ContainerPort, protocol}] = struct{}{}
+		if pm.HostIP != "" {
+			if err := validation.IsIP(pm.HostIP); err != nil {
+				violations.AddViolation(fmt.Sprintf("Ports: %s", err))
+			}
+		}
+		if pm.HostPort != 0 {
+			if _, found := hostPorts[binding{pm.HostIP, pm.HostPort, protocol}]; found {
+				violations.AddViolation(fmt.Sprintf("Ports: host port %d/%s is bound more than once", pm.HostPort, protocol))
+			}
+			hostPorts[binding{pm.HostIP, pm.HostPort, protocol}] = struct{}{}
+		}
+	}
+
+	// the pinned docker client has no fields for them
+	if len(no.DNSSearch) > 0 {
+		violations.AddViolation("DNSSearch is not supported by the docker client")
+	}
+	if len(no.ExtraHosts) > 0 {
+		violations.AddViolation("ExtraHosts is not supported by the docker client")
+	}
+
+	for _, domain := range no.DNSSearch {
+		if err := validation.IsHostname(domain); err != nil {
+			violations.AddViolation(fmt.Sprintf("DNSSearch: %s", err))
+		}
+	}
+
+	for _, host := range no.ExtraHosts {
+		if err := validation.IsHostname(host.Hostname); err != nil {
+			violations.AddViolation(fmt.Sprintf("ExtraHosts: %s", err))
+		}
+		if err := validation.IsIP(host.IPAddr); err != nil {
+			violations.AddViolation(fmt.Sprintf("ExtraHosts: %s", err))
+		}
+	}
+
+	if violations.HasError() {
+		return violations
+	}
+	return nil
+}
+
+//ValidHostNetwork makes sure that a service on the network of the host does not
+//ask for what only a network of its own can give it: a hostname, published
+//ports or virtual addresses, which would be set up on the host itself.  Its
+//instances would share the ports of the host, so more than one must be on
+//separate hosts.
+func (no NetworkOptions) ValidHostNetwork(hostname string, endpoints []EndpointDefinition, instances int, hostPolicy HostPolicy) error {
+	if !no.HostNetwork {
+		return nil
+	}
+	violations := validation.NewValidationError()
+	if instances > 1 && hostPolicy != RequireSeparate {
+		violations.AddViolation(fmt.Sprintf("HostNetwork: %d instances on the network of the host need the %s host policy", instances, RequireSeparate))
+	}
+	if hostname != "" {
+		violations.AddViolation("HostNetwork: a hostname cannot be set on the network of the host")
+	}
+	if len(no.Ports) > 0 {
+		violations.AddViolation("HostNetwork: ports cannot be published on the network of the host")
+	}
+	for _, ep := range endpoints {
+		if ep.VirtualAddress != "" {
+			violations.AddViolation(fmt.Sprintf("HostNetwork: endpoint %s cannot have a virtual address on the network of the host", ep.Name))
+		}
+	}
+	if violations.HasError() {
+		return violations
+	}
+	return nil
+}
+
+//ValidHostNetwork checks the host networking of a service definition, with as
+//many instances as it can be deployed with or scaled to
+func (sd ServiceDefinition) ValidHostNetwork() error {
+	instances := sd.Instances.Default
+	if instances == 0 {
+		instances = sd.Instances.Min
+	}
+	if sd.Instances.Max > instances {
+		instances = sd.Instances.Max
+	}
+	return sd.Network.ValidHostNetwork(sd.Hostname, sd.Endpoints, instances, sd.HostPolicy)
+}
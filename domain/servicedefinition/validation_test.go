@@ -15,9 +15,12 @@ package servicedefinition_test
 
 import (
 	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/domain"
 	. "github.com/control-center/serviced/domain/servicedefinition"
 	. "github.com/control-center/serviced/domain/servicedefinition/testutils"
+	"github.com/control-center/serviced/validation"
 
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -135,3 +138,172 @@ func TestServiceDefinitionEmptyEndpointName(t *testing.T) {
 		t.Errorf("Unexpected Error %v", err)
 	}
 }
+
+func TestValidateResourceLimits(t *testing.T) {
+	limits := ResourceLimits{MemorySwap: -1}
+	if err := limits.ValidEntity(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// the docker client can only pass the swap limit
+	limits = ResourceLimits{
+		CPUQuota:       200000,
+		CPUPeriod:      100000,
+		MemorySwap:     -1,
+		PidsLimit:      512,
+		BlkioWeight:    500,
+		BlkioThrottles: []BlkioThrottle{{Device: "/dev/sda", ReadBps: 1 << 20}},
+		Ulimits:        []Ulimit{{Name: "nofile", Soft: 1024, Hard: 4096}, {Name: "core", Soft: -1, Hard: -1}},
+	}
+	unsupported := []string{"CPUQuota", "CPUPeriod", "PidsLimit", "BlkioWeight", "BlkioThrottles", "Ulimits"}
+	if names := limits.Unsupported(); !reflect.DeepEqual(names, unsupported) {
+		t.Errorf("Expected unsupported limits %v, got %v", unsupported, names)
+	}
+	if err := limits.ValidEntity(); err == nil || !strings.Contains(err.Error(), "Ulimits is not supported by the docker client") {
+		t.Errorf("Expected an error for the unsupported limits, got %v", err)
+	}
+
+	limits = ResourceLimits{
+		CPUQuota:       500,
+		CPUPeriod:      2000000,
+		MemorySwap:     -2,
+		PidsLimit:      -1,
+		BlkioWeight:    5,
+		BlkioThrottles: []BlkioThrottle{{Device: "sda", ReadBps: 1}, {Device: "/dev/sdb"}, {Device: "/dev/sdb", WriteIOps: 1}},
+		Ulimits:        []Ulimit{{Name: "files", Soft: 1, Hard: 1}, {Name: "nofile", Soft: 4096, Hard: 1024}, {Name: "nofile"}},
+	}
+	expected := []string{
+		"CPUQuota is not supported by the docker client",
+		"CPUPeriod is not supported by the docker client",
+		"PidsLimit is not supported by the docker client",
+		"BlkioWeight is not supported by the docker client",
+		"BlkioThrottles is not supported by the docker client",
+		"Ulimits is not supported by the docker client",
+		"CPUQuota 500 is less than 1000",
+		"CPUPeriod 2000000 is not between 1000 and 1000000",
+		"MemorySwap -2 is less than -1",
+		"PidsLimit -1 is negative",
+		"BlkioWeight 5 is not between 10 and 1000",
+		`BlkioThrottles: device "sda" is not an absolute path`,
+		"BlkioThrottles: device /dev/sdb has no rates",
+		"BlkioThrottles: device /dev/sdb is throttled more than once",
+		"Ulimits: unknown limit files",
+		"Ulimits: nofile soft limit 4096 is greater than its hard limit 1024",
+		"Ulimits: nofile is set more than once",
+	}
+	err := limits.ValidEntity()
+	verr, ok := err.(*validation.ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if len(verr.Errors) != len(expected) {
+		t.Errorf("Expected %d violations, got %d: %v", len(expected), len(verr.Errors), err)
+	}
+	for _, msg := range expected {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected violation %q, got %v", msg, err)
+		}
+	}
+}
+
+func TestValidateMemorySwap(t *testing.T) {
+	sd := *ValidSvcDef
+	sd.MemoryLimit = 1 << 30
+	sd.ResourceLimits.MemorySwap = 1 << 29
+	if err := sd.ValidEntity(); err == nil || !strings.Contains(err.Error(), "is less than MemoryLimit") {
+		t.Errorf("Expected error for swap below the memory limit, got %v", err)
+	}
+	sd.ResourceLimits.MemorySwap = 2 << 30
+	if err := sd.ValidEntity(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestValidateNetworkOptions(t *testing.T) {
+	network := NetworkOptions{
+		Ports: []PortMapping{{ContainerPort: 514, HostPort: 514, Protocol: "udp"}, {ContainerPort: 514, HostPort: 514}, {ContainerPort: 9000, HostIP: "127.0.0.1"}},
+	}
+	if err := network.ValidEntity(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// the docker client cannot pass dns search domains or extra hosts
+	network = NetworkOptions{
+		DNSSearch:  []string{"example.com"},
+		ExtraHosts: []ExtraHost{{Hostname: "db.example.com", IPAddr: "10.0.0.5"}},
+	}
+	if err := network.ValidEntity(); err == nil || !strings.Contains(err.Error(), "DNSSearch is not supported by the docker client") ||
+		!strings.Contains(err.Error(), "ExtraHosts is not supported by the docker client") {
+		t.Errorf("Expected errors for the unsupported options, got %v", err)
+	}
+
+	network = NetworkOptions{
+		Ports:      []PortMapping{{HostPort: 80}, {ContainerPort: 80, Protocol: "sctp"}, {ContainerPort: 81, HostPort: 8080}, {ContainerPort: 81, HostPort: 8080}, {ContainerPort: 82, HostIP: "localhost"}},
+		DNSSearch:  []string{"example_com"},
+		ExtraHosts: []ExtraHost{{Hostname: "db", IPAddr: "10.0.0"}},
+	}
+	expected := []string{
+		"Ports: container port is not set",
+		"Ports: invalid protocol sctp for container port 80",
+		"Ports: container port 81/tcp is published more than once",
+		"Ports: host port 8080/tcp is bound more than once",
+		"Ports: invalid IP Address localhost",
+		"DNSSearch is not supported by the docker client",
+		"ExtraHosts is not supported by the docker client",
+		`DNSSearch: invalid hostname "example_com"`,
+		"ExtraHosts: invalid IP Address 10.0.0",
+	}
+	err := network.ValidEntity()
+	verr, ok := err.(*validation.ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if len(verr.Errors) != len(expected) {
+		t.Errorf("Expected %d violations, got %d: %v", len(expected), len(verr.Errors), err)
+	}
+	for _, msg := range expected {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected violation %q, got %v", msg, err)
+		}
+	}
+}
+
+func TestValidateHostNetwork(t *testing.T) {
+	sd := *ValidSvcDef
+	sd.Network.HostNetwork = true
+	if err := sd.ValidEntity(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// instances share the ports of the host, so they must be on separate hosts
+	sd.Instances = domain.MinMax{Min: 1, Max: 3}
+	if err := sd.ValidEntity(); err == nil || !strings.Contains(err.Error(), "HostNetwork: 3 instances on the network of the host need the REQUIRE_SEPARATE host policy") {
+		t.Errorf("Expected an error for 3 instances, got %v", err)
+	}
+	sd.HostPolicy = RequireSeparate
+	if err := sd.ValidEntity(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	network := NetworkOptions{HostNetwork: true, Ports: []PortMapping{{ContainerPort: 514}}}
+	endpoints := []EndpointDefinition{{Name: "db", Purpose: "import", VirtualAddress: "db:3306"}}
+	expected := []string{
+		"HostNetwork: 2 instances on the network of the host need the REQUIRE_SEPARATE host policy",
+		"HostNetwork: a hostname cannot be set on the network of the host",
+		"HostNetwork: ports cannot be published on the network of the host",
+		"HostNetwork: endpoint db cannot have a virtual address on the network of the host",
+	}
+	err := network.ValidHostNetwork("collector-{{.InstanceID}}", endpoints, 2, PreferSeparate)
+	verr, ok := err.(*validation.ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if len(verr.Errors) != len(expected) {
+		t.Errorf("Expected %d violations, got %d: %v", len(expected), len(verr.Errors), err)
+	}
+	for _, msg := range expected {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected violation %q, got %v", msg, err)
+		}
+	}
+}
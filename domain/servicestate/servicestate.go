@@ -74,6 +74,12 @@ func (ss ServiceState) Uptime() time.Duration {
 	return 0
 }
 
+// ParsePortTemplate checks that the PortTemplate of an endpoint can be parsed
+func ParsePortTemplate(portTemplate string) error {
+	_, err := template.New("PortTemplate").Funcs(funcmap).Parse(portTemplate)
+	return err
+}
+
 func (ss *ServiceState) evalPortTemplate(portTemplate string) (int, error) {
 	t := template.Must(template.New("PortTemplate").Funcs(funcmap).Parse(portTemplate))
 	b := bytes.Buffer{}
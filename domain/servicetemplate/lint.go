@@ -0,0 +1,281 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicetemplate
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicedefinition"
+	"github.com/control-center/serviced/domain/servicestate"
+	"github.com/control-center/serviced/validation"
+)
+
+// LintProblem is a problem found in a service template
+type LintProblem struct {
+	Path    string // Path of the service definition, e.g. Zenoss.core/Zope, empty for the template itself
+	Message string
+}
+
+func (p LintProblem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// ImageChecker reports whether an image exists
+type ImageChecker func(imageID string) (bool, error)
+
+// Lint validates the whole service tree of a template and returns every
+// problem that it finds, in the order of the services.  The images of the
+// services are only checked if imageExists is set.
+func (st *ServiceTemplate) Lint(imageExists ImageChecker) []LintProblem {
+	l := &linter{vhosts: make(map[string]string)}
+	for i := range st.Services {
+		l.lintTenant(&st.Services[i])
+	}
+
+	params := make(map[string]struct{})
+	for _, p := range st.Parameters {
+		if _, found := params[p.Name]; found {
+			l.add("", "duplicate parameter %s", p.Name)
+		}
+		params[p.Name] = struct{}{}
+		if err := p.validate(st); err != nil {
+			l.add("", "%s", err)
+		}
+	}
+
+	if imageExists != nil {
+		for _, image := range l.images {
+			if ok, err := imageExists(image.id); err != nil {
+				l.add(image.path, "could not check image %s: %s", image.id, err)
+			} else if !ok {
+				l.add(image.path, "image %s does not exist", image.id)
+			}
+		}
+	}
+	return l.problems
+}
+
+type lintImage struct {
+	id   string
+	path string
+}
+
+type lintEndpoint struct {
+	servicedefinition.EndpointDefinition
+	path string
+}
+
+type lintVolume struct {
+	servicedefinition.Volume
+	path string
+}
+
+// linter collects the problems of a template
+type linter struct {
+	problems []LintProblem
+	images   []lintImage
+	vhosts   map[string]string // vhost -> path of the service that defines it
+
+	// per tenant
+	exports []lintEndpoint
+	imports []lintEndpoint
+	volumes map[string]lintVolume // ResourcePath -> first volume with the path
+}
+
+func (l *linter) add(svcPath, format string, args ...interface{}) {
+	l.problems = append(l.problems, LintProblem{Path: svcPath, Message: fmt.Sprintf(format, args...)})
+}
+
+// addViolations adds a problem for each violation of a validation error
+func (l *linter) addViolations(svcPath string, err error) {
+	if verr, ok := err.(*validation.ValidationError); ok {
+		for _, violation := range verr.Errors {
+			l.add(svcPath, "%s", violation)
+		}
+	} else if err != nil {
+		l.add(svcPath, "%s", err)
+	}
+}
+
+// lintTenant checks a top level service definition and its children, and the
+// endpoints that they import and export
+func (l *linter) lintTenant(sd *servicedefinition.ServiceDefinition) {
+	l.exports, l.imports = nil, nil
+	l.volumes = make(map[string]lintVolume)
+	l.lintService(sd, sd.Name, nil)
+
+	exported := make(map[string]string)
+	for _, ep := range l.exports {
+		if previous, found := exported[ep.Name]; found {
+			l.add(ep.path, "endpoint %s is also exported by %s", ep.Name, previous)
+			continue
+		}
+		exported[ep.Name] = ep.path
+	}
+	for _, ep := range l.imports {
+		if !l.isExported(ep.Application) {
+			l.add(ep.path, "endpoint %s imports %s, which no service exports", ep.Name, ep.Application)
+		}
+	}
+}
+
+// isExported reports whether an imported application, which may be a regular
+// expression, matches an export of the tenant.  Applications that are
+// templates are only known at runtime, so they always match.
+func (l *linter) isExported(application string) bool {
+	if strings.Contains(application, "{{") {
+		return true
+	}
+	match, err := regexp.Compile("^(" + application + ")$")
+	for _, ep := range l.exports {
+		if ep.Application == application || strings.Contains(ep.Application, "{{") || (err == nil && match.MatchString(ep.Application)) {
+			return true
+		}
+	}
+	return false
+}
+
+// lintService checks a service definition and its children.  logFilters are
+// the names of the log filters defined by its parents.
+func (l *linter) lintService(sd *servicedefinition.ServiceDefinition, svcPath string, logFilters map[string]struct{}) {
+	if err := sd.Instances.Validate(); err != nil {
+		l.add(svcPath, "%s", err)
+	}
+	if err := sd.ValidLaunch(); err != nil {
+		l.add(svcPath, "%s", err)
+	}
+
+	switch sd.HostPolicy {
+	case servicedefinition.DEFAULT, servicedefinition.LeastCommitted, servicedefinition.PreferSeparate, servicedefinition.RequireSeparate:
+	default:
+		l.add(svcPath, "unknown host policy %s", sd.HostPolicy)
+	}
+
+	l.addViolations(svcPath, sd.ResourceLimits.ValidEntity())
+	l.addViolations(svcPath, sd.ResourceLimits.ValidSwap(sd.MemoryLimit))
+	l.addViolations(svcPath, sd.Network.ValidEntity())
+	l.addViolations(svcPath, sd.ValidHostNetwork())
+
+	if sd.ImageID != "" {
+		l.images = append(l.images, lintImage{sd.ImageID, svcPath})
+	}
+
+	l.lintEndpoints(sd, svcPath)
+
+	var filenames []string
+	for filename := range sd.ConfigFiles {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		if err := service.ParseTemplate(sd.ConfigFiles[filename].Content); err != nil {
+			l.add(svcPath, "config file %s: %s", filename, err)
+		}
+	}
+
+	var checks []string
+	for name := range sd.HealthChecks {
+		checks = append(checks, name)
+	}
+	sort.Strings(checks)
+	for _, name := range checks {
+		if sd.HealthChecks[name].Interval <= 0 {
+			l.add(svcPath, "health check %s has no interval", name)
+		}
+	}
+
+	containerPaths := make(map[string]struct{})
+	for _, vol := range sd.Volumes {
+		if _, found := containerPaths[vol.ContainerPath]; found {
+			l.add(svcPath, "volume %s is mounted more than once", vol.ContainerPath)
+		}
+		containerPaths[vol.ContainerPath] = struct{}{}
+
+		previous, found := l.volumes[vol.ResourcePath]
+		if !found {
+			l.volumes[vol.ResourcePath] = lintVolume{vol, svcPath}
+		} else if vol.Owner != previous.Owner || vol.Permission != previous.Permission || vol.Type != previous.Type {
+			l.add(svcPath, "volume %s has a different owner, permission or type than in %s", vol.ResourcePath, previous.path)
+		}
+	}
+
+	filters := make(map[string]struct{})
+	for name := range logFilters {
+		filters[name] = struct{}{}
+	}
+	for name := range sd.LogFilters {
+		filters[name] = struct{}{}
+	}
+	for _, logConfig := range sd.LogConfigs {
+		for _, filter := range logConfig.Filters {
+			if _, found := filters[filter]; !found {
+				l.add(svcPath, "log %s uses filter %s, which is not in LogFilters", logConfig.Path, filter)
+			}
+		}
+	}
+
+	for i := range sd.Services {
+		l.lintService(&sd.Services[i], path.Join(svcPath, sd.Services[i].Name), filters)
+	}
+}
+
+// lintEndpoints checks the endpoints of a service and records its imports and
+// exports
+func (l *linter) lintEndpoints(sd *servicedefinition.ServiceDefinition, svcPath string) {
+	names := make(map[string]struct{})
+	for _, ep := range sd.Endpoints {
+		if err := ep.ValidEntity(); err != nil {
+			l.add(svcPath, "%s", err)
+		}
+		name := strings.TrimSpace(ep.Name)
+		if _, found := names[name]; found {
+			l.add(svcPath, "endpoint name %s is not unique in the service", name)
+		}
+		names[name] = struct{}{}
+
+		if ep.PortTemplate != "" {
+			if err := servicestate.ParsePortTemplate(ep.PortTemplate); err != nil {
+				l.add(svcPath, "endpoint %s: %s", ep.Name, err)
+			}
+		}
+
+		for _, vhost := range ep.VHosts {
+			if previous, found := l.vhosts[vhost]; found {
+				l.add(svcPath, "vhost %s is also defined by %s", vhost, previous)
+				continue
+			}
+			l.vhosts[vhost] = svcPath
+		}
+
+		application := ep.Application
+		if ep.ApplicationTemplate != "" {
+			application = ep.ApplicationTemplate
+		}
+		ep.Application = application
+		switch ep.Purpose {
+		case "export":
+			l.exports = append(l.exports, lintEndpoint{ep, svcPath})
+		case "import", "import_all":
+			l.imports = append(l.imports, lintEndpoint{ep, svcPath})
+		}
+	}
+}
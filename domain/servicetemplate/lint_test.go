@@ -0,0 +1,206 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicetemplate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/domain"
+	"github.com/control-center/serviced/domain/servicedefinition"
+)
+
+func lintTemplate() *ServiceTemplate {
+	return &ServiceTemplate{
+		Services: []servicedefinition.ServiceDefinition{
+			{
+				Name:       "Zenoss",
+				Launch:     commons.AUTO,
+				LogFilters: map[string]string{"pythondaemon": "filter {}"},
+				Services: []servicedefinition.ServiceDefinition{
+					{
+						Name:       "Zope",
+						Launch:     commons.AUTO,
+						ImageID:    "zenoss/core:5.0",
+						Instances:  domain.MinMax{Min: 1},
+						HostPolicy: "SOMEWHERE",
+						ConfigFiles: map[string]servicedefinition.ConfigFile{
+							"/etc/zope.conf": {Filename: "/etc/zope.conf", Content: "workers {{(context .).workers}}\n"},
+							"/etc/bad.conf":  {Filename: "/etc/bad.conf", Content: "workers {{(context .).workers\n"},
+						},
+						Endpoints: []servicedefinition.EndpointDefinition{
+							{Name: "zope", Purpose: "export", Protocol: "tcp", PortNumber: 9080, Application: "zope"},
+							{Name: "mysql", Purpose: "import", Protocol: "tcp", PortNumber: 3306, Application: "mysql"},
+							{Name: "zep", Purpose: "import", Protocol: "tcp", PortNumber: 8084, Application: "zep"},
+						},
+						HealthChecks: map[string]domain.HealthCheck{
+							"answering": {Script: "curl localhost:9080", Interval: 5 * time.Second},
+							"running":   {Script: "pgrep zope"},
+						},
+						Volumes: []servicedefinition.Volume{
+							{Owner: "zenoss:zenoss", Permission: "0755", ResourcePath: "var", ContainerPath: "/opt/zenoss/var"},
+						},
+						LogConfigs: []servicedefinition.LogConfig{
+							{Path: "/opt/zenoss/log/z2.log", Filters: []string{"pythondaemon", "z2"}},
+						},
+					}, {
+						Name:    "MySQL",
+						Launch:  "sometimes",
+						ImageID: "zenoss/mysql:5.5",
+						Endpoints: []servicedefinition.EndpointDefinition{
+							{Name: "mysql", Purpose: "export", Protocol: "tcp", PortNumber: 3306, Application: "mysql"},
+							{Name: "zope", Purpose: "export", Protocol: "tcp", PortNumber: 9081, PortTemplate: "{{plus 1 .InstanceID}", Application: "zope2"},
+						},
+						Volumes: []servicedefinition.Volume{
+							{Owner: "mysql:mysql", Permission: "0755", ResourcePath: "var", ContainerPath: "/var/lib/mysql"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestLint(t *testing.T) {
+	st := lintTemplate()
+	var checked []string
+	problems := st.Lint(func(imageID string) (bool, error) {
+		checked = append(checked, imageID)
+		return imageID != "zenoss/mysql:5.5", nil
+	})
+
+	// parse errors are only compared up to the template name
+	expected := []string{
+		"Zenoss/Zope: unknown host policy SOMEWHERE",
+		"Zenoss/Zope: config file /etc/bad.conf: template: ServiceDefinitionTemplate:",
+		"Zenoss/Zope: health check running has no interval",
+		"Zenoss/Zope: log /opt/zenoss/log/z2.log uses filter z2, which is not in LogFilters",
+		"Zenoss/MySQL: invalid launch setting string sometimes not in [auto manual]",
+		"Zenoss/MySQL: endpoint zope: template: PortTemplate:",
+		"Zenoss/MySQL: volume var has a different owner, permission or type than in Zenoss/Zope",
+		"Zenoss/MySQL: endpoint zope is also exported by Zenoss/Zope",
+		"Zenoss/Zope: endpoint zep imports zep, which no service exports",
+		"Zenoss/MySQL: image zenoss/mysql:5.5 does not exist",
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %d: %v", len(expected), len(problems), problems)
+	}
+	for i, p := range problems {
+		if !strings.HasPrefix(p.String(), expected[i]) {
+			t.Errorf("expected problem %q, got %q", expected[i], p)
+		}
+	}
+	if !reflect.DeepEqual(checked, []string{"zenoss/core:5.0", "zenoss/mysql:5.5"}) {
+		t.Errorf("unexpected images checked: %v", checked)
+	}
+
+	if problems := st.Lint(nil); len(problems) != len(expected)-1 {
+		t.Errorf("expected images not to be checked, got %v", problems)
+	}
+}
+
+func TestLint_imports(t *testing.T) {
+	st := &ServiceTemplate{
+		Services: []servicedefinition.ServiceDefinition{
+			{
+				Name:   "Zenoss",
+				Launch: commons.AUTO,
+				Endpoints: []servicedefinition.EndpointDefinition{
+					{Name: "collectors", Purpose: "import_all", Protocol: "tcp", PortNumber: 8789, Application: "collector_.*"},
+					{Name: "hub", Purpose: "import", Protocol: "tcp", PortNumber: 8789, Application: "{{(context .).hub}}_hub"},
+				},
+				Services: []servicedefinition.ServiceDefinition{
+					{
+						Name:   "collector",
+						Launch: commons.AUTO,
+						Endpoints: []servicedefinition.EndpointDefinition{
+							{Name: "collector", Purpose: "export", Protocol: "tcp", PortNumber: 8789, Application: "collector_localhost"},
+						},
+					},
+				},
+			},
+		},
+	}
+	if problems := st.Lint(nil); len(problems) > 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+func TestLint_resourceLimits(t *testing.T) {
+	st := &ServiceTemplate{
+		Services: []servicedefinition.ServiceDefinition{
+			{
+				Name:        "Zenoss",
+				Launch:      commons.AUTO,
+				MemoryLimit: 1 << 30,
+				ResourceLimits: servicedefinition.ResourceLimits{
+					CPUPeriod:  500,
+					MemorySwap: 1 << 29,
+					Ulimits:    []servicedefinition.Ulimit{{Name: "files", Soft: 1, Hard: 1}},
+				},
+			},
+		},
+	}
+	expected := []string{
+		"Zenoss: CPUPeriod is not supported by the docker client",
+		"Zenoss: Ulimits is not supported by the docker client",
+		"Zenoss: CPUPeriod 500 is not between 1000 and 1000000",
+		"Zenoss: Ulimits: unknown limit files",
+		"Zenoss: MemorySwap 536870912 is less than MemoryLimit 1.073741824e+09",
+	}
+	problems := st.Lint(nil)
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %d: %v", len(expected), len(problems), problems)
+	}
+	for i, p := range problems {
+		if p.String() != expected[i] {
+			t.Errorf("expected problem %q, got %q", expected[i], p)
+		}
+	}
+}
+
+func TestLint_network(t *testing.T) {
+	st := &ServiceTemplate{
+		Services: []servicedefinition.ServiceDefinition{
+			{
+				Name:      "Zenoss",
+				Launch:    commons.AUTO,
+				Hostname:  "collector-{{.InstanceID}}",
+				Instances: domain.MinMax{Min: 2},
+				Network: servicedefinition.NetworkOptions{
+					HostNetwork: true,
+					DNSSearch:   []string{"example_com"},
+				},
+			},
+		},
+	}
+	expected := []string{
+		"Zenoss: DNSSearch is not supported by the docker client",
+		`Zenoss: DNSSearch: invalid hostname "example_com"`,
+		"Zenoss: HostNetwork: 2 instances on the network of the host need the REQUIRE_SEPARATE host policy",
+		"Zenoss: HostNetwork: a hostname cannot be set on the network of the host",
+	}
+	problems := st.Lint(nil)
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %d: %v", len(expected), len(problems), problems)
+	}
+	for i, p := range problems {
+		if p.String() != expected[i] {
+			t.Errorf("expected problem %q, got %q", expected[i], p)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicetemplate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/control-center/serviced/domain/servicedefinition"
+)
+
+// Types of template parameters
+const (
+	ParamInt    = "int"
+	ParamFloat  = "float"
+	ParamBool   = "bool"
+	ParamString = "string"
+	ParamImage  = "image"
+	ParamMemory = "memory" // bytes, with an optional K, M, G or T suffix
+)
+
+// Fields of a service definition that a template parameter can set, besides
+// Context values
+const (
+	FieldInstances     = "Instances"
+	FieldImageID       = "ImageID"
+	FieldMemoryLimit   = "MemoryLimit"
+	FieldRAMCommitment = "RAMCommitment"
+	FieldCPUCommitment = "CPUCommitment"
+	FieldCPUShares     = "CPUShares"
+	fieldContext       = "Context."
+)
+
+// the types of values that each field accepts
+var fieldTypes = map[string][]string{
+	FieldInstances:     {ParamInt},
+	FieldImageID:       {ParamImage, ParamString},
+	FieldMemoryLimit:   {ParamMemory, ParamInt},
+	FieldRAMCommitment: {ParamMemory, ParamInt},
+	FieldCPUCommitment: {ParamInt},
+	FieldCPUShares:     {ParamInt},
+}
+
+// Parameter is a setting of a template that is given a value when the
+// template is deployed
+type Parameter struct {
+	Name        string
+	Type        string      // int, float, bool, string, image or memory
+	Description string      // Meaningful description of the parameter
+	Default     interface{} // Value used when none is given, the parameter is required if it has none
+	Min         *float64    // Optional lower bound of int, float and memory values
+	Max         *float64    // Optional upper bound of int, float and memory values
+	Options     []string    // Optional list of the allowed values
+	Targets     []ParameterTarget
+}
+
+// ParameterTarget is a field of a service definition that a parameter sets
+type ParameterTarget struct {
+	Service string // Path of the service definition, e.g. Zenoss.core/Zope
+	Field   string // Instances, ImageID, MemoryLimit, RAMCommitment, CPUCommitment, CPUShares or Context.KEY
+}
+
+var paramName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]*$`)
+
+// validate checks the declaration of a parameter
+func (p Parameter) validate(st *ServiceTemplate) error {
+	if !paramName.MatchString(p.Name) {
+		return fmt.Errorf("invalid parameter name %q", p.Name)
+	}
+	switch p.Type {
+	case ParamInt, ParamFloat, ParamBool, ParamString, ParamImage, ParamMemory:
+	default:
+		return fmt.Errorf("parameter %s has invalid type %q", p.Name, p.Type)
+	}
+	if (p.Min != nil || p.Max != nil) && p.Type != ParamInt && p.Type != ParamFloat && p.Type != ParamMemory {
+		return fmt.Errorf("parameter %s of type %s cannot have bounds", p.Name, p.Type)
+	}
+	for _, option := range p.Options {
+		if _, err := p.parse(option); err != nil {
+			return fmt.Errorf("parameter %s has invalid option: %s", p.Name, err)
+		}
+	}
+	if p.Default != nil {
+		if _, err := p.Parse(formatValue(p.Default)); err != nil {
+			return fmt.Errorf("parameter %s has invalid default: %s", p.Name, err)
+		}
+	}
+	if len(p.Targets) == 0 {
+		return fmt.Errorf("parameter %s has no targets", p.Name)
+	}
+	for _, target := range p.Targets {
+		if findDefinition(st.Services, target.Service) == nil {
+			return fmt.Errorf("parameter %s targets unknown service %q", p.Name, target.Service)
+		}
+		if strings.HasPrefix(target.Field, fieldContext) && len(target.Field) > len(fieldContext) {
+			continue
+		}
+		types, ok := fieldTypes[target.Field]
+		if !ok {
+			return fmt.Errorf("parameter %s targets unknown field %q", p.Name, target.Field)
+		}
+		if !contains(types, p.Type) {
+			return fmt.Errorf("parameter %s of type %s cannot set %s", p.Name, p.Type, target.Field)
+		}
+	}
+	return nil
+}
+
+// Parse converts a value of the parameter to its type, and checks that it is
+// allowed
+func (p Parameter) Parse(value string) (interface{}, error) {
+	v, err := p.parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Options) > 0 && !contains(p.Options, value) {
+		return nil, fmt.Errorf("%s must be one of %s", p.Name, strings.Join(p.Options, ", "))
+	}
+	var number float64
+	switch n := v.(type) {
+	case int64:
+		number = float64(n)
+	case float64:
+		number = n
+	default:
+		return v, nil
+	}
+	if p.Min != nil && number < *p.Min {
+		return nil, fmt.Errorf("%s must be at least %s", p.Name, formatValue(*p.Min))
+	}
+	if p.Max != nil && number > *p.Max {
+		return nil, fmt.Errorf("%s must be at most %s", p.Name, formatValue(*p.Max))
+	}
+	return v, nil
+}
+
+func (p Parameter) parse(value string) (interface{}, error) {
+	var v interface{}
+	var err error
+	switch p.Type {
+	case ParamInt:
+		v, err = strconv.ParseInt(value, 10, 64)
+	case ParamFloat:
+		v, err = strconv.ParseFloat(value, 64)
+	case ParamBool:
+		v, err = strconv.ParseBool(value)
+	case ParamMemory:
+		v, err = parseMemory(value)
+	case ParamImage:
+		if strings.TrimSpace(value) == "" || strings.ContainsAny(value, " \t\n") {
+			err = fmt.Errorf("invalid image name")
+		}
+		v = value
+	default:
+		v = value
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value %q for %s", p.Type, value, p.Name)
+	}
+	return v, nil
+}
+
+var memorySize = regexp.MustCompile(`^(?i)(\d+)\s*([kmgt]?)b?$`)
+
+// parseMemory converts a size like 512M to bytes
+func parseMemory(value string) (int64, error) {
+	match := memorySize.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	size, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	shift := uint(strings.Index("kmgt", strings.ToLower(match[2]))+1) * 10
+	if match[2] == "" {
+		shift = 0
+	}
+	return size << shift, nil
+}
+
+// formatValue formats a json value of a parameter, without exponents
+func formatValue(value interface{}) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// ApplyParameters evaluates the values of the template's parameters into its
+// service definitions.  Parameters that are not given a value use their
+// default.  Returns an error if a value is unknown or invalid, or if a
+// parameter that has no default is not given a value.
+func (st *ServiceTemplate) ApplyParameters(values map[string]string) error {
+	params := make(map[string]Parameter)
+	for _, p := range st.Parameters {
+		params[p.Name] = p
+	}
+	var unknown []string
+	for name := range values {
+		if _, ok := params[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("template %s has no parameter %s", st.Name, strings.Join(unknown, ", "))
+	}
+
+	for _, p := range st.Parameters {
+		raw, ok := values[p.Name]
+		if !ok {
+			if p.Default == nil {
+				return fmt.Errorf("parameter %s requires a value", p.Name)
+			}
+			raw = formatValue(p.Default)
+		}
+		value, err := p.Parse(raw)
+		if err != nil {
+			return err
+		}
+		for _, target := range p.Targets {
+			sd := findDefinition(st.Services, target.Service)
+			if sd == nil {
+				return fmt.Errorf("parameter %s targets unknown service %q", p.Name, target.Service)
+			}
+			if err := setField(sd, target.Field, value); err != nil {
+				return fmt.Errorf("parameter %s: %s", p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ParameterValues returns the values that ApplyParameters gives the
+// template's parameters: the given values, and the defaults of the parameters
+// that are not given one.  Parameters that have neither are left out.
+func (st *ServiceTemplate) ParameterValues(values map[string]string) map[string]string {
+	result := make(map[string]string)
+	for _, p := range st.Parameters {
+		if value, ok := values[p.Name]; ok {
+			result[p.Name] = value
+		} else if p.Default != nil {
+			result[p.Name] = formatValue(p.Default)
+		}
+	}
+	return result
+}
+
+// setField sets a field of a service definition to the value of a parameter
+func setField(sd *servicedefinition.ServiceDefinition, field string, value interface{}) error {
+	if strings.HasPrefix(field, fieldContext) {
+		if sd.Context == nil {
+			sd.Context = make(map[string]interface{})
+		}
+		sd.Context[strings.TrimPrefix(field, fieldContext)] = value
+		return nil
+	}
+
+	switch v := value.(type) {
+	case int64:
+		switch field {
+		case FieldInstances:
+			if limits := sd.Instances; (limits.Min > 0 && int(v) < limits.Min) || (limits.Max > 0 && int(v) > limits.Max) {
+				return fmt.Errorf("%s needs between %d and %d instances", sd.Name, limits.Min, limits.Max)
+			}
+			sd.Instances.Default = int(v)
+			return nil
+		case FieldMemoryLimit:
+			sd.MemoryLimit = float64(v)
+			return nil
+		case FieldRAMCommitment:
+			sd.RAMCommitment = uint64(v)
+			return nil
+		case FieldCPUCommitment:
+			sd.CPUCommitment = uint64(v)
+			return nil
+		case FieldCPUShares:
+			sd.CPUShares = v
+			return nil
+		}
+	case string:
+		if field == FieldImageID {
+			sd.ImageID = v
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot set %s of %s to %v", field, sd.Name, value)
+}
+
+// findDefinition returns the service definition at a path of names
+func findDefinition(sds []servicedefinition.ServiceDefinition, svcPath string) *servicedefinition.ServiceDefinition {
+	names := strings.Split(strings.Trim(svcPath, "/"), "/")
+	for i := range sds {
+		if sds[i].Name != names[0] {
+			continue
+		}
+		if len(names) == 1 {
+			return &sds[i]
+		}
+		return findDefinition(sds[i].Services, strings.Join(names[1:], "/"))
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
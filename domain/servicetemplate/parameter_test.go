@@ -0,0 +1,158 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicetemplate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/control-center/serviced/domain"
+	"github.com/control-center/serviced/domain/servicedefinition"
+)
+
+func paramTemplate() *ServiceTemplate {
+	max := 10.0
+	return &ServiceTemplate{
+		Name: "Zenoss.core",
+		Services: []servicedefinition.ServiceDefinition{
+			{
+				Name:    "Zenoss.core",
+				Context: map[string]interface{}{"global.conf.zep-host": "localhost"},
+				Services: []servicedefinition.ServiceDefinition{
+					{Name: "Zope", ImageID: "zenoss/core:5.0", Instances: domain.MinMax{Min: 1, Max: 8}},
+				},
+			},
+		},
+		Parameters: []Parameter{
+			{
+				Name:    "zope.instances",
+				Type:    ParamInt,
+				Default: float64(2),
+				Max:     &max,
+				Targets: []ParameterTarget{{Service: "Zenoss.core/Zope", Field: FieldInstances}},
+			}, {
+				Name:    "zope.memory",
+				Type:    ParamMemory,
+				Default: "1G",
+				Targets: []ParameterTarget{{Service: "Zenoss.core/Zope", Field: FieldMemoryLimit}},
+			}, {
+				Name:    "image",
+				Type:    ParamImage,
+				Default: "zenoss/core:5.0",
+				Targets: []ParameterTarget{{Service: "Zenoss.core/Zope", Field: FieldImageID}},
+			}, {
+				Name:    "workers",
+				Type:    ParamInt,
+				Targets: []ParameterTarget{{Service: "Zenoss.core", Field: "Context.global.conf.workers"}},
+			}, {
+				Name:    "debug",
+				Type:    ParamBool,
+				Default: false,
+				Targets: []ParameterTarget{{Service: "Zenoss.core", Field: "Context.global.conf.debug"}},
+			},
+		},
+	}
+}
+
+func TestParameterValidate(t *testing.T) {
+	st := paramTemplate()
+	for _, p := range st.Parameters {
+		if err := p.validate(st); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		param    Parameter
+		expected string
+	}{
+		{Parameter{Name: "x", Type: "list"}, "invalid type"},
+		{Parameter{Name: "x", Type: ParamString, Default: "a", Options: []string{"b"}}, "invalid default"},
+		{Parameter{Name: "x", Type: ParamInt, Default: "many"}, "invalid default"},
+		{Parameter{Name: "x", Type: ParamInt}, "no targets"},
+		{Parameter{Name: "x", Type: ParamInt, Targets: []ParameterTarget{{Service: "Zenoss.core/Zeo", Field: FieldInstances}}}, "unknown service"},
+		{Parameter{Name: "x", Type: ParamInt, Targets: []ParameterTarget{{Service: "Zenoss.core", Field: "Startup"}}}, "unknown field"},
+		{Parameter{Name: "x", Type: ParamBool, Targets: []ParameterTarget{{Service: "Zenoss.core", Field: FieldInstances}}}, "cannot set"},
+	} {
+		if err := tc.param.validate(st); err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("expected error %q for %+v, got %v", tc.expected, tc.param, err)
+		}
+	}
+}
+
+func TestApplyParameters(t *testing.T) {
+	st := paramTemplate()
+	err := st.ApplyParameters(map[string]string{"workers": "4", "zope.memory": "512M", "debug": "true"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	zenoss := st.Services[0]
+	if zenoss.Context["global.conf.workers"] != int64(4) || zenoss.Context["global.conf.debug"] != true {
+		t.Errorf("unexpected context: %v", zenoss.Context)
+	}
+	if zenoss.Context["global.conf.zep-host"] != "localhost" {
+		t.Errorf("context was not kept: %v", zenoss.Context)
+	}
+	zope := zenoss.Services[0]
+	if zope.Instances.Default != 2 {
+		t.Errorf("expected 2 instances, got %d", zope.Instances.Default)
+	}
+	if zope.MemoryLimit != 512*1024*1024 {
+		t.Errorf("expected a 512M memory limit, got %v", zope.MemoryLimit)
+	}
+	if zope.ImageID != "zenoss/core:5.0" {
+		t.Errorf("unexpected image %s", zope.ImageID)
+	}
+
+	for _, tc := range []struct {
+		values   map[string]string
+		expected string
+	}{
+		{map[string]string{}, "workers requires a value"},
+		{map[string]string{"workers": "4", "zeo.instances": "2"}, "no parameter zeo.instances"},
+		{map[string]string{"workers": "four"}, "invalid int value"},
+		{map[string]string{"workers": "4", "zope.instances": "12"}, "at most 10"},
+		{map[string]string{"workers": "4", "zope.instances": "9"}, "between 1 and 8 instances"},
+	} {
+		if err := paramTemplate().ApplyParameters(tc.values); err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("expected error %q for %v, got %v", tc.expected, tc.values, err)
+		}
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	for value, expected := range map[string]int64{"1024": 1024, "4k": 4096, "512M": 512 << 20, "2GB": 2 << 30, "1t": 1 << 40} {
+		if actual, err := parseMemory(value); err != nil || actual != expected {
+			t.Errorf("expected %s to be %d, got %d (%v)", value, expected, actual, err)
+		}
+	}
+	if _, err := parseMemory("lots"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestParameterValues(t *testing.T) {
+	values := paramTemplate().ParameterValues(map[string]string{"workers": "4", "zope.memory": "512M"})
+	expected := map[string]string{
+		"zope.instances": "2",
+		"zope.memory":    "512M",
+		"image":          "zenoss/core:5.0",
+		"workers":        "4",
+		"debug":          "false",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/control-center/serviced/commons/atomicfile"
+)
+
+// Installed records a template that was installed from a source.  Hash is the
+// template ID it was added with, which changes when the template in the source
+// drifts from the installed copy.
+type Installed struct {
+	Source  string
+	Name    string
+	Version string
+	Hash    string
+}
+
+// Config is the registered sources and the templates installed from them
+type Config struct {
+	Sources   []Source
+	Installed []Installed
+}
+
+// LoadConfig reads the config at path.  A missing file is an empty config.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &config, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("could not read template sources from %s: %s", path, err)
+	}
+	return &config, nil
+}
+
+// Save writes the config to path
+func (c *Config) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// Source returns the source with the given name
+func (c *Config) Source(name string) (*Source, bool) {
+	for i, source := range c.Sources {
+		if source.Name == name {
+			return &c.Sources[i], true
+		}
+	}
+	return nil, false
+}
+
+// AddSource registers a new source
+func (c *Config) AddSource(source Source) error {
+	if err := source.Validate(); err != nil {
+		return err
+	} else if _, ok := c.Source(source.Name); ok {
+		return fmt.Errorf("template source %s already exists", source.Name)
+	}
+	c.Sources = append(c.Sources, source)
+	return nil
+}
+
+// RemoveSource unregisters a source
+func (c *Config) RemoveSource(name string) error {
+	for i, source := range c.Sources {
+		if source.Name == name {
+			c.Sources = append(c.Sources[:i], c.Sources[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("template source %s not found", name)
+}
+
+// Install records the hash of a template installed from a source, replacing
+// any earlier install of the same version
+func (c *Config) Install(entry Entry, hash string) {
+	installed := Installed{Source: entry.Source, Name: entry.Name, Version: entry.Version, Hash: hash}
+	for i, inst := range c.Installed {
+		if inst.Source == entry.Source && inst.Name == entry.Name && inst.Version == entry.Version {
+			c.Installed[i] = installed
+			return
+		}
+	}
+	c.Installed = append(c.Installed, installed)
+}
+
+// Status returns "installed" if the entry was installed and is unchanged,
+// "drifted" if the template in the source no longer matches the installed
+// copy and "" if the entry was never installed.
+func (c *Config) Status(entry Entry) string {
+	for _, inst := range c.Installed {
+		if inst.Source == entry.Source && inst.Name == entry.Name && inst.Version == entry.Version {
+			if entry.Hash != "" && entry.Hash != inst.Hash {
+				return "drifted"
+			}
+			return "installed"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	template "github.com/control-center/serviced/domain/servicetemplate"
+	"github.com/zenoss/glog"
+)
+
+// gitRepository reads the service definition directories of a git checkout,
+// laid out as NAME/VERSION.
+type gitRepository struct {
+	source Source
+	path   string
+}
+
+// openGit uses the url of the source directly if it is a local checkout,
+// otherwise the repository is cloned into dir, or pulled if it was cloned
+// before.
+func openGit(source Source, dir string) (*gitRepository, error) {
+	if fi, err := os.Stat(source.URL); err == nil && fi.IsDir() {
+		return &gitRepository{source: source, path: source.URL}, nil
+	}
+
+	var cmd *exec.Cmd
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		cmd = exec.Command("git", "pull", "--ff-only")
+		cmd.Dir = dir
+	} else {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return nil, err
+		}
+		cmd = exec.Command("git", "clone", "--quiet", source.URL, dir)
+	}
+	glog.V(1).Infof("Updating template source %s: %s", source.Name, strings.Join(cmd.Args, " "))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("could not update template source %s: %s: %s", source.Name, err, strings.TrimSpace(string(output)))
+	}
+	return &gitRepository{source: source, path: dir}, nil
+}
+
+// Index builds every template to get its description and hash
+func (r *gitRepository) Index() ([]Entry, error) {
+	names, err := ioutil.ReadDir(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, name := range names {
+		if !name.IsDir() || strings.HasPrefix(name.Name(), ".") {
+			continue
+		}
+		versions, err := ioutil.ReadDir(filepath.Join(r.path, name.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			if !version.IsDir() {
+				continue
+			}
+			entry := Entry{
+				Source:  r.source.Name,
+				Name:    name.Name(),
+				Version: version.Name(),
+				Path:    filepath.Join(name.Name(), version.Name()),
+			}
+			st, err := r.Fetch(entry)
+			if err != nil {
+				glog.Warningf("Skipping template %s in %s: %s", entry.Ref(), r.source.Name, err)
+				continue
+			}
+			entry.Description = st.Description
+			if entry.Hash, err = st.Hash(); err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *gitRepository) Fetch(entry Entry) (*template.ServiceTemplate, error) {
+	return template.BuildFromPath(filepath.Join(r.path, entry.Path))
+}
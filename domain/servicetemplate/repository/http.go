@@ -0,0 +1,84 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	template "github.com/control-center/serviced/domain/servicetemplate"
+)
+
+// httpIndex is the name of the index file of an http repository
+const httpIndex = "index.json"
+
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// httpRepository reads an index.json at the url of the source, which lists
+// the entries of the repository.  The path of each entry is resolved relative
+// to the index.
+type httpRepository struct {
+	source Source
+}
+
+func (r *httpRepository) Index() ([]Entry, error) {
+	var entries []Entry
+	if err := r.get(httpIndex, &entries); err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Source = r.source.Name
+	}
+	return entries, nil
+}
+
+func (r *httpRepository) Fetch(entry Entry) (*template.ServiceTemplate, error) {
+	var st template.ServiceTemplate
+	if err := r.get(entry.Path, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// get decodes the json at path, relative to the index of the repository
+func (r *httpRepository) get(path string, v interface{}) error {
+	base, err := url.Parse(r.source.URL)
+	if err != nil {
+		return err
+	}
+	if base.Path == "" || base.Path[len(base.Path)-1] != '/' {
+		base.Path += "/"
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+	location := base.ResolveReference(ref).String()
+
+	resp, err := httpClient.Get(location)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get %s: %s", location, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("could not decode %s: %s", location, err)
+	}
+	return nil
+}
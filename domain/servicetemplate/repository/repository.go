@@ -0,0 +1,197 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package repository fetches service templates from remote template
+// repositories, either a git repository of service definition directories or
+// an HTTP index of versioned template JSON.
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	template "github.com/control-center/serviced/domain/servicetemplate"
+)
+
+const (
+	// TypeGit is a git repository with a service definition directory for
+	// each template version, laid out as NAME/VERSION.
+	TypeGit = "git"
+	// TypeHTTP is a web server with an index.json listing the template JSON
+	// files of each version.
+	TypeHTTP = "http"
+)
+
+var (
+	// ErrInvalidSource is returned when a source is missing a name or url or
+	// has an unknown type
+	ErrInvalidSource = errors.New("repository: invalid template source")
+	// ErrTemplateNotFound is returned when no source has the requested template
+	ErrTemplateNotFound = errors.New("repository: template not found")
+)
+
+// Source is a registered template repository
+type Source struct {
+	Name string // Name used to refer to the source
+	Type string // TypeGit or TypeHTTP
+	URL  string // Location of the repository
+}
+
+// Validate checks that the source can be opened
+func (s Source) Validate() error {
+	if strings.TrimSpace(s.Name) == "" || strings.TrimSpace(s.URL) == "" {
+		return ErrInvalidSource
+	}
+	switch s.Type {
+	case TypeGit, TypeHTTP:
+		return nil
+	}
+	return ErrInvalidSource
+}
+
+// Entry is a version of a template in a repository
+type Entry struct {
+	Source      string // Name of the source that has the template
+	Name        string // Name of the template
+	Version     string // Version of the template
+	Description string // Description of the template
+	Path        string // Location of the template, relative to the source
+	Hash        string // Hash of the template, checked when it is fetched
+}
+
+// Ref returns the NAME@VERSION reference of the entry
+func (e Entry) Ref() string {
+	return e.Name + "@" + e.Version
+}
+
+// Repository is a source of service templates
+type Repository interface {
+	// Index returns the templates in the repository
+	Index() ([]Entry, error)
+	// Fetch returns the template of an entry
+	Fetch(entry Entry) (*template.ServiceTemplate, error)
+}
+
+// Open returns the repository of a source.  Git sources that are not already
+// checked out locally are cloned (or pulled) into cacheDir.
+func Open(source Source, cacheDir string) (Repository, error) {
+	if err := source.Validate(); err != nil {
+		return nil, err
+	}
+	switch source.Type {
+	case TypeGit:
+		return openGit(source, filepath.Join(cacheDir, source.Name))
+	default:
+		return &httpRepository{source: source}, nil
+	}
+}
+
+// ParseRef splits a NAME[@VERSION] template reference
+func ParseRef(ref string) (name, version string) {
+	parts := strings.SplitN(ref, "@", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+// Find returns the entry of the named template.  If version is empty, the
+// highest version is returned.
+func Find(entries []Entry, name, version string) (*Entry, error) {
+	var found *Entry
+	for i, entry := range entries {
+		if entry.Name != name {
+			continue
+		} else if version != "" {
+			if entry.Version == version {
+				return &entries[i], nil
+			}
+		} else if found == nil || CompareVersions(found.Version, entry.Version) < 0 {
+			found = &entries[i]
+		}
+	}
+	if found == nil {
+		return nil, ErrTemplateNotFound
+	}
+	return found, nil
+}
+
+// Sort orders entries by name and then by version
+func Sort(entries []Entry) {
+	sort.Sort(entrySort(entries))
+}
+
+type entrySort []Entry
+
+func (s entrySort) Len() int      { return len(s) }
+func (s entrySort) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s entrySort) Less(i, j int) bool {
+	if s[i].Name != s[j].Name {
+		return s[i].Name < s[j].Name
+	}
+	return CompareVersions(s[i].Version, s[j].Version) < 0
+}
+
+// CompareVersions compares two dotted versions, numerically where both parts
+// are numbers.  Returns -1, 0 or 1 if a is lower, equal or higher than b.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		ai, aerr := strconv.Atoi(as[i])
+		bi, berr := strconv.Atoi(bs[i])
+		if aerr == nil && berr == nil {
+			if ai < bi {
+				return -1
+			}
+			return 1
+		} else if as[i] < bs[i] {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// Validate checks a fetched template and returns its hash, which is the ID it
+// gets when it is added.  If the entry has a hash, it must match.
+func Validate(entry Entry, st *template.ServiceTemplate) (string, error) {
+	st.ID = ""
+	hash, err := st.Hash()
+	if err != nil {
+		return "", err
+	}
+	if entry.Hash != "" && entry.Hash != hash {
+		return "", fmt.Errorf("template %s has hash %s, expected %s", entry.Ref(), hash, entry.Hash)
+	}
+
+	// ValidEntity requires the ID that the template will be given
+	check := *st
+	check.ID = hash
+	if err := check.ValidEntity(); err != nil {
+		return "", fmt.Errorf("template %s is not valid: %s", entry.Ref(), err)
+	}
+	return hash, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	template "github.com/control-center/serviced/domain/servicetemplate"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.0", "1.0.1", -1},
+		{"5.0.0-beta", "5.0.0-alpha", 1},
+	} {
+		if actual := CompareVersions(tc.a, tc.b); actual != tc.expected {
+			t.Errorf("CompareVersions(%q, %q): expected %d, got %d", tc.a, tc.b, tc.expected, actual)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	entries := []Entry{
+		{Name: "core", Version: "1.9"},
+		{Name: "core", Version: "1.10"},
+		{Name: "other", Version: "2.0"},
+	}
+	if entry, err := Find(entries, "core", ""); err != nil || entry.Version != "1.10" {
+		t.Errorf("Expected core@1.10, got %+v (%v)", entry, err)
+	}
+	if entry, err := Find(entries, "core", "1.9"); err != nil || entry.Version != "1.9" {
+		t.Errorf("Expected core@1.9, got %+v (%v)", entry, err)
+	}
+	if _, err := Find(entries, "core", "3.0"); err != ErrTemplateNotFound {
+		t.Errorf("Expected %s, got %v", ErrTemplateNotFound, err)
+	}
+}
+
+func TestHTTPRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repository-test-")
+	if err != nil {
+		t.Fatalf("Could not create tempdir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	st := template.ServiceTemplate{Name: "core", Version: "1.0", Description: "the core"}
+	hash, err := st.Hash()
+	if err != nil {
+		t.Fatalf("Could not hash template: %s", err)
+	}
+	entries := []Entry{
+		{Name: "core", Version: "1.0", Path: "core/1.0.json", Hash: hash},
+		{Name: "core", Version: "1.1", Path: "core/1.1.json", Hash: "bogus"},
+	}
+	writeJSON(t, filepath.Join(dir, "index.json"), entries)
+	writeJSON(t, filepath.Join(dir, "core", "1.0.json"), st)
+	writeJSON(t, filepath.Join(dir, "core", "1.1.json"), st)
+
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+
+	repo, err := Open(Source{Name: "test", Type: TypeHTTP, URL: server.URL}, dir)
+	if err != nil {
+		t.Fatalf("Could not open repository: %s", err)
+	}
+	index, err := repo.Index()
+	if err != nil {
+		t.Fatalf("Could not get the index: %s", err)
+	} else if len(index) != 2 || index[0].Source != "test" {
+		t.Fatalf("Unexpected index: %+v", index)
+	}
+
+	fetched, err := repo.Fetch(index[0])
+	if err != nil {
+		t.Fatalf("Could not fetch %s: %s", index[0].Ref(), err)
+	} else if actual, err := Validate(index[0], fetched); err != nil {
+		t.Errorf("Could not validate %s: %s", index[0].Ref(), err)
+	} else if actual != hash {
+		t.Errorf("Expected hash %s, got %s", hash, actual)
+	}
+
+	// the template does not match the hash in the index
+	if fetched, err = repo.Fetch(index[1]); err != nil {
+		t.Fatalf("Could not fetch %s: %s", index[1].Ref(), err)
+	} else if _, err := Validate(index[1], fetched); err == nil {
+		t.Errorf("Expected a hash mismatch for %s", index[1].Ref())
+	}
+
+	if _, err := repo.Fetch(Entry{Path: "missing.json"}); err == nil {
+		t.Errorf("Expected an error fetching a missing template")
+	}
+}
+
+func TestConfig_Status(t *testing.T) {
+	var config Config
+	entry := Entry{Source: "test", Name: "core", Version: "1.0", Hash: "abc"}
+	if status := config.Status(entry); status != "" {
+		t.Errorf("Expected no status, got %q", status)
+	}
+	config.Install(entry, "abc")
+	if status := config.Status(entry); status != "installed" {
+		t.Errorf("Expected installed, got %q", status)
+	}
+	entry.Hash = "def"
+	if status := config.Status(entry); status != "drifted" {
+		t.Errorf("Expected drifted, got %q", status)
+	}
+	config.Install(entry, "def")
+	if len(config.Installed) != 1 {
+		t.Errorf("Expected one installed template, got %+v", config.Installed)
+	}
+}
+
+func writeJSON(t *testing.T, path string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Could not marshal %s: %s", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Could not create %s: %s", filepath.Dir(path), err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Could not write %s: %s", path, err)
+	}
+}
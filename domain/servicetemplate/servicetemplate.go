@@ -17,6 +17,9 @@ import (
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 
 	"github.com/control-center/serviced/datastore"
@@ -31,6 +34,7 @@ type ServiceTemplate struct {
 	Description string                                  // Meaningful description of service
 	Services    []servicedefinition.ServiceDefinition   // Child services
 	ConfigFiles map[string]servicedefinition.ConfigFile // Config file templates
+	Parameters  []Parameter                             `json:",omitempty"` // Settings given values when the template is deployed
 	datastore.VersionedEntity
 }
 
@@ -54,6 +58,9 @@ func (a *ServiceTemplate) Equals(b *ServiceTemplate) bool {
 	if !reflect.DeepEqual(a.ConfigFiles, b.ConfigFiles) {
 		return false
 	}
+	if !reflect.DeepEqual(a.Parameters, b.Parameters) {
+		return false
+	}
 	return true
 }
 
@@ -103,6 +110,10 @@ func newWrapper(st ServiceTemplate) (*serviceTemplateWrapper, error) {
 
 }
 
+// ParametersFile is the file at the top of a template directory that declares
+// the template's parameters
+const ParametersFile = "parameters.json"
+
 //BuildFromPath given a path will create a ServiceDefintion
 func BuildFromPath(path string) (*ServiceTemplate, error) {
 	sd, err := servicedefinition.BuildFromPath(path)
@@ -115,5 +126,14 @@ func BuildFromPath(path string) (*ServiceTemplate, error) {
 		Version:     sd.Version,
 		Description: sd.Description,
 	}
+
+	data, err := ioutil.ReadFile(filepath.Join(path, ParametersFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &st.Parameters); err != nil {
+			return nil, fmt.Errorf("could not read %s: %s", ParametersFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
 	return &st, nil
 }
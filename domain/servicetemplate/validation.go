@@ -58,6 +58,15 @@ func (st *ServiceTemplate) ValidEntity() error {
 		violations.Add(servicedefinition.Walk(&sd, visit))
 	}
 
+	params := make(map[string]struct{})
+	for _, p := range st.Parameters {
+		if _, found := params[p.Name]; found {
+			violations.AddViolation(fmt.Sprintf("duplicate parameter found: %s", p.Name))
+		}
+		params[p.Name] = struct{}{}
+		violations.Add(p.validate(st))
+	}
+
 	if len(violations.Errors) > 0 {
 		return violations
 	}
@@ -0,0 +1,106 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package facade
+
+import (
+	"fmt"
+
+	"github.com/zenoss/glog"
+
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicedefinition"
+)
+
+// PlanApply returns the changes that applying a service definition to a tenant
+// would make, without making them.
+func (f *Facade) PlanApply(ctx datastore.Context, tenantID string, sd servicedefinition.ServiceDefinition, prune, overwrite bool) ([]dao.ServiceUpgradeAction, error) {
+	return f.applyServiceDefinition(ctx, tenantID, sd, prune, overwrite, "", false)
+}
+
+// ApplyServiceDefinition makes the services of a tenant match a service
+// definition.  The definition describes the tenant itself and its children are
+// matched to the child services by name.  Services that are missing are added
+// and the rest are updated the same way a template upgrade updates them.
+// Services that have no definition are removed only if prune is set.  If
+// overwrite is set, the instance count, context values and config files of the
+// services are set to the ones of the definition instead of being kept.
+// Applying the same definition again changes nothing.  Returns the changes
+// that were made.
+func (f *Facade) ApplyServiceDefinition(ctx datastore.Context, tenantID string, sd servicedefinition.ServiceDefinition, prune, overwrite bool, author string) ([]dao.ServiceUpgradeAction, error) {
+	return f.applyServiceDefinition(ctx, tenantID, sd, prune, overwrite, author, true)
+}
+
+func (f *Facade) applyServiceDefinition(ctx datastore.Context, tenantID string, sd servicedefinition.ServiceDefinition, prune, overwrite bool, author string, apply bool) ([]dao.ServiceUpgradeAction, error) {
+	tenant, err := f.GetService(ctx, tenantID)
+	if err != nil {
+		glog.Errorf("Unable to load tenant %s: %s", tenantID, err)
+		return nil, err
+	} else if tenant.ParentServiceID != "" {
+		return nil, fmt.Errorf("service %s is not a tenant", tenantID)
+	} else if tenant.Name != sd.Name {
+		return nil, fmt.Errorf("service definition %s does not match tenant %s (%s)", sd.Name, tenant.Name, tenantID)
+	}
+
+	var svcs []service.Service
+	collect := func(svc *service.Service) error {
+		svcs = append(svcs, *svc)
+		return nil
+	}
+	if err := f.walkServices(ctx, tenantID, collect); err != nil {
+		glog.Errorf("Unable to load the services of tenant %s: %s", tenantID, err)
+		return nil, err
+	}
+	// get the customized config files
+	if err := f.fillOutServices(ctx, svcs); err != nil {
+		return nil, err
+	}
+
+	// a plan reports the images that would change without pulling them
+	if apply {
+		if err := pullImages(sd); err != nil {
+			glog.Errorf("Unable to pull one or more images")
+			return nil, err
+		}
+	}
+
+	u := &serviceUpgrade{
+		f:            f,
+		ctx:          ctx,
+		deploymentID: tenant.DeploymentID,
+		poolID:       tenant.PoolID,
+		children:     make(map[string][]*service.Service),
+		apply:        apply,
+		prune:        prune,
+		overwrite:    overwrite,
+		updatedBy:    author,
+	}
+	var root *service.Service
+	for i := range svcs {
+		svc := &svcs[i]
+		if svc.ID == tenantID {
+			root = svc
+		}
+		u.children[svc.ParentServiceID] = append(u.children[svc.ParentServiceID], svc)
+	}
+
+	if err := u.update(root, sd, sd.Name, tenantID); err != nil {
+		return nil, err
+	}
+	if err := u.upgrade(sd.Services, tenantID, sd.Name, tenantID); err != nil {
+		return nil, err
+	}
+	return u.actions, nil
+}
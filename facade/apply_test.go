@@ -0,0 +1,94 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package facade
+
+import (
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/domain"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicedefinition"
+	. "gopkg.in/check.v1"
+)
+
+func (ft *FacadeTest) TestApplyServiceDefinition_Overwrite(t *C) {
+	defer func(pull func(string) error) {
+		pullImage = pull
+	}(pullImage)
+	var pulled []string
+	pullImage = func(image string) error {
+		pulled = append(pulled, image)
+		return nil
+	}
+
+	conf := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "v1"}
+	edited := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "v2"}
+	tenant := service.Service{
+		ID:              "apply-tenant",
+		Name:            "Zenoss",
+		Startup:         "run",
+		PoolID:          "default",
+		DeploymentID:    "apply",
+		Launch:          commons.AUTO,
+		Instances:       3,
+		InstanceLimits:  domain.MinMax{Min: 1},
+		Context:         map[string]interface{}{"workers": 8},
+		OriginalConfigs: map[string]servicedefinition.ConfigFile{conf.Filename: conf},
+		ConfigFiles:     map[string]servicedefinition.ConfigFile{edited.Filename: edited},
+	}
+	t.Assert(ft.Facade.AddService(ft.CTX, tenant), IsNil)
+
+	sd := servicedefinition.ServiceDefinition{
+		Name:        "Zenoss",
+		Command:     "run",
+		Launch:      commons.AUTO,
+		Instances:   domain.MinMax{Min: 1, Default: 2},
+		Context:     map[string]interface{}{"workers": 4},
+		ConfigFiles: map[string]servicedefinition.ConfigFile{conf.Filename: conf},
+		Services: []servicedefinition.ServiceDefinition{{
+			Name:      "redis",
+			Command:   "redis-server",
+			Launch:    commons.AUTO,
+			Instances: domain.MinMax{Min: 1},
+		}},
+	}
+
+	// a dry run does not pull the images
+	withImage := sd
+	withImage.ImageID = "test/apply"
+	_, err := ft.Facade.PlanApply(ft.CTX, tenant.ID, withImage, false, true)
+	t.Assert(err, IsNil)
+	t.Assert(pulled, HasLen, 0)
+
+	actions, err := ft.Facade.ApplyServiceDefinition(ft.CTX, tenant.ID, sd, false, true, "tester")
+	t.Assert(err, IsNil)
+	t.Assert(actions, HasLen, 2)
+	t.Assert(actions[0].Action, Equals, UpgradeUpdate)
+	t.Assert(actions[0].Changes[:3], DeepEquals, []string{"Instances", "Context", "ConfigFiles"})
+	t.Assert(actions[1].Action, Equals, UpgradeAdd)
+
+	// the values of the definition win over the ones set on the service
+	svc, err := ft.Facade.GetService(ft.CTX, tenant.ID)
+	t.Assert(err, IsNil)
+	t.Assert(svc.Instances, Equals, 2)
+	t.Assert(svc.Context, DeepEquals, map[string]interface{}{"workers": float64(4)})
+	t.Assert(svc.ConfigFiles, DeepEquals, sd.ConfigFiles)
+
+	// applying the same definition again changes nothing
+	actions, err = ft.Facade.PlanApply(ft.CTX, tenant.ID, sd, false, true)
+	t.Assert(err, IsNil)
+	t.Assert(actions, HasLen, 0)
+	actions, err = ft.Facade.ApplyServiceDefinition(ft.CTX, tenant.ID, sd, false, true, "tester")
+	t.Assert(err, IsNil)
+	t.Assert(actions, HasLen, 0)
+}
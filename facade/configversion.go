@@ -0,0 +1,104 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package facade
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/serviceconfigfile"
+	"github.com/control-center/serviced/domain/servicedefinition"
+	"github.com/zenoss/glog"
+)
+
+// GetServiceConfigVersions returns the versions of a service's config file,
+// oldest first
+func (f *Facade) GetServiceConfigVersions(ctx datastore.Context, serviceID, filename string) ([]*serviceconfigfile.ConfigVersion, error) {
+	svc, err := f.getService(ctx, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, servicePath, err := f.getTenantIDAndPath(ctx, svc)
+	if err != nil {
+		return nil, err
+	}
+	return serviceconfigfile.NewStore().GetConfigVersions(ctx, tenantID, servicePath, filename)
+}
+
+// RevertServiceConfig restores a version of a service's config file.  The
+// restored file is kept as a new version.
+func (f *Facade) RevertServiceConfig(ctx datastore.Context, serviceID, filename string, version int, author string) error {
+	svc, err := f.GetService(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+	if _, ok := svc.ConfigFiles[filename]; !ok {
+		return fmt.Errorf("service %s has no config file %s", serviceID, filename)
+	}
+
+	versions, err := f.GetServiceConfigVersions(ctx, serviceID, filename)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			glog.Infof("Reverting config file %s of service %s (%s) to version %d", filename, svc.Name, svc.ID, version)
+			svc.ConfigFiles[filename] = v.ConfFile
+			svc.UpdatedBy = author
+			// reconcile the config files even if the file is reverted to the
+			// original
+			return f.saveService(ctx, svc, true)
+		}
+	}
+	return fmt.Errorf("version %d of config file %s not found", version, filename)
+}
+
+// configLock serializes the updates of the stored config files, so that
+// concurrent edits of a file get distinct version numbers
+var configLock sync.Mutex
+
+// addConfigVersion keeps a version of a changed config file.  The first time
+// a file changes, its previous content is kept as well, so that the change can
+// be reverted.  The caller must hold configLock.
+func (f *Facade) addConfigVersion(ctx datastore.Context, tenantID, servicePath, author string, previous *servicedefinition.ConfigFile, conf servicedefinition.ConfigFile) error {
+	store := serviceconfigfile.NewStore()
+	versions, err := store.GetConfigVersions(ctx, tenantID, servicePath, conf.Filename)
+	if err != nil {
+		return err
+	}
+
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1].Version + 1
+	} else if previous != nil {
+		if err := putConfigVersion(ctx, store, tenantID, servicePath, next, "", *previous); err != nil {
+			return err
+		}
+		next++
+	}
+	return putConfigVersion(ctx, store, tenantID, servicePath, next, author, conf)
+}
+
+func putConfigVersion(ctx datastore.Context, store *serviceconfigfile.Store, tenantID, servicePath string, version int, author string, conf servicedefinition.ConfigFile) error {
+	cv, err := serviceconfigfile.NewVersion(tenantID, servicePath, version, author, conf)
+	if err != nil {
+		return err
+	}
+	if err := store.Put(ctx, serviceconfigfile.VersionKey(cv.ID), cv); err != nil {
+		glog.Errorf("Could not keep version %d of config file %s in %s: %s", version, conf.Filename, servicePath, err)
+		return err
+	}
+	return nil
+}
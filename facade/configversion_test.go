@@ -0,0 +1,122 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package facade
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/domain"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/serviceconfigfile"
+	"github.com/control-center/serviced/domain/servicedefinition"
+	. "gopkg.in/check.v1"
+)
+
+func (ft *FacadeTest) addConfigVersionService(t *C, id string, conf servicedefinition.ConfigFile) {
+	svc := service.Service{
+		ID:              id,
+		Name:            id,
+		PoolID:          "default",
+		DeploymentID:    id,
+		Launch:          commons.AUTO,
+		Instances:       1,
+		InstanceLimits:  domain.MinMax{Min: 1},
+		OriginalConfigs: map[string]servicedefinition.ConfigFile{conf.Filename: conf},
+		ConfigFiles:     map[string]servicedefinition.ConfigFile{conf.Filename: conf},
+	}
+	t.Assert(ft.Facade.AddService(ft.CTX, svc), IsNil)
+}
+
+func (ft *FacadeTest) TestUpdateService_VersionsRevertToOriginal(t *C) {
+	original := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "v1"}
+	edited := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "v2"}
+	ft.addConfigVersionService(t, "config-revert-service", original)
+
+	svc, err := ft.Facade.GetService(ft.CTX, "config-revert-service")
+	t.Assert(err, IsNil)
+	svc.ConfigFiles[edited.Filename] = edited
+	t.Assert(ft.Facade.UpdateService(ft.CTX, *svc), IsNil)
+
+	// setting the file back to the original removes the stored file and is
+	// kept as a version too
+	svc, err = ft.Facade.GetService(ft.CTX, "config-revert-service")
+	t.Assert(err, IsNil)
+	t.Assert(svc.ConfigFiles[edited.Filename], DeepEquals, edited)
+	svc.ConfigFiles[original.Filename] = original
+	t.Assert(ft.Facade.UpdateService(ft.CTX, *svc), IsNil)
+
+	svc, err = ft.Facade.GetService(ft.CTX, "config-revert-service")
+	t.Assert(err, IsNil)
+	t.Assert(svc.ConfigFiles[original.Filename], DeepEquals, original)
+
+	versions, err := ft.Facade.GetServiceConfigVersions(ft.CTX, svc.ID, original.Filename)
+	t.Assert(err, IsNil)
+	t.Assert(versions, HasLen, 3)
+	for i, conf := range []servicedefinition.ConfigFile{original, edited, original} {
+		t.Check(versions[i].Version, Equals, i+1)
+		t.Check(versions[i].ConfFile, DeepEquals, conf)
+	}
+}
+
+func (ft *FacadeTest) TestUpdateService_ConcurrentConfigVersions(t *C) {
+	original := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "v0"}
+	ft.addConfigVersionService(t, "config-concurrent-service", original)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 5; i++ {
+		svc, err := ft.Facade.GetService(ft.CTX, "config-concurrent-service")
+		t.Assert(err, IsNil)
+		svc.ConfigFiles[original.Filename] = servicedefinition.ConfigFile{Filename: original.Filename, Content: fmt.Sprintf("v%d", i)}
+		wg.Add(1)
+		go func(svc service.Service) {
+			defer wg.Done()
+			// the service itself may conflict with another edit, but every
+			// edit keeps a version
+			ft.Facade.UpdateService(ft.CTX, svc)
+		}(*svc)
+	}
+	wg.Wait()
+
+	versions, err := ft.Facade.GetServiceConfigVersions(ft.CTX, "config-concurrent-service", original.Filename)
+	t.Assert(err, IsNil)
+	t.Assert(len(versions) > 1, Equals, true)
+	for i, v := range versions {
+		t.Check(v.Version, Equals, i+1)
+	}
+}
+
+func (ft *FacadeTest) TestRemoveService_DeletesConfigVersions(t *C) {
+	original := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "v1"}
+	edited := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "v2"}
+	ft.addConfigVersionService(t, "config-removed-service", original)
+
+	svc, err := ft.Facade.GetService(ft.CTX, "config-removed-service")
+	t.Assert(err, IsNil)
+	svc.ConfigFiles[edited.Filename] = edited
+	t.Assert(ft.Facade.UpdateService(ft.CTX, *svc), IsNil)
+
+	tenantID, servicePath, err := ft.Facade.getTenantIDAndPath(ft.CTX, *svc)
+	t.Assert(err, IsNil)
+	versions, err := serviceconfigfile.NewStore().GetConfigVersions(ft.CTX, tenantID, servicePath, original.Filename)
+	t.Assert(err, IsNil)
+	t.Assert(versions, HasLen, 2)
+
+	// a service deployed later at the same path starts without versions
+	t.Assert(ft.Facade.RemoveService(ft.CTX, svc.ID), IsNil)
+	versions, err = serviceconfigfile.NewStore().GetConfigVersions(ft.CTX, tenantID, servicePath, original.Filename)
+	t.Assert(err, IsNil)
+	t.Assert(versions, HasLen, 0)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/zenoss/glog"
 
 	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/commons/docker"
 	"github.com/control-center/serviced/dao"
 	"github.com/control-center/serviced/datastore"
 	"github.com/control-center/serviced/domain"
@@ -27,6 +28,9 @@ import (
 	"github.com/control-center/serviced/domain/servicestate"
 )
 
+// findImage looks up an image in the local repository
+var findImage = docker.FindImage
+
 // AddService adds a service; return error if service already exists
 func (f *Facade) AddService(ctx datastore.Context, svc service.Service) error {
 	glog.V(2).Infof("Facade.AddService: %+v", svc)
@@ -100,8 +104,17 @@ func (f *Facade) UpdateService(ctx datastore.Context, svc service.Service) error
 func (f *Facade) RemoveService(ctx datastore.Context, id string) error {
 	//TODO: should services already be stopped before removing to prevent half running service in case of error while deleting?
 
+	// the versions of the config files are kept by tenant and path, which
+	// cannot be looked up once the services are gone
+	type configPath struct{ tenantID, servicePath string }
+	var configPaths []configPath
 	err := f.walkServices(ctx, id, func(svc *service.Service) error {
 		zkAPI(f).RemoveService(svc)
+		if tenantID, servicePath, err := f.getTenantIDAndPath(ctx, *svc); err != nil {
+			glog.Warningf("Could not find the config file versions of service %s: %s", svc.ID, err)
+		} else {
+			configPaths = append(configPaths, configPath{tenantID, servicePath})
+		}
 		return nil
 	})
 
@@ -122,6 +135,14 @@ func (f *Facade) RemoveService(ctx datastore.Context, id string) error {
 	if err != nil {
 		return err
 	}
+
+	configStore := serviceconfigfile.NewStore()
+	for _, p := range configPaths {
+		if err := configStore.DeleteConfigVersions(ctx, p.tenantID, p.servicePath); err != nil {
+			glog.Errorf("Could not remove the config file versions of %s in tenant %s: %s", p.servicePath, p.tenantID, err)
+			return err
+		}
+	}
 	//TODO: remove AddressAssignments with f Service
 	return nil
 }
@@ -819,11 +840,10 @@ func (f *Facade) fillServiceConfigs(ctx datastore.Context, svc *service.Service)
 		foundConfs[svcConfig.ConfFile.Filename] = &svcConfig.ConfFile
 	}
 
-	//replace with stored service config only if it is an existing config
+	//replace with stored service config; stored configs that are not in the
+	//original configs were kept when the service was upgraded
 	for name, conf := range foundConfs {
-		if _, found := svc.ConfigFiles[name]; found {
-			svc.ConfigFiles[name] = *conf
-		}
+		svc.ConfigFiles[name] = *conf
 	}
 	return nil
 }
@@ -847,6 +867,14 @@ func (f *Facade) fillServiceAddr(ctx datastore.Context, svc *service.Service) er
 
 // updateService internal method to use when service has been validated
 func (f *Facade) updateService(ctx datastore.Context, svc *service.Service) error {
+	return f.saveService(ctx, svc, false)
+}
+
+// saveService stores a service and its customized config files, and keeps a
+// version of every config file that changed.  The original configs of a
+// service are only replaced if replaceConfigs is set, when it is upgraded to a
+// new template.
+func (f *Facade) saveService(ctx datastore.Context, svc *service.Service, replaceConfigs bool) error {
 	id := strings.TrimSpace(svc.ID)
 	if id == "" {
 		return errors.New("empty Service.ID not allowed")
@@ -862,59 +890,29 @@ func (f *Facade) updateService(ctx datastore.Context, svc *service.Service) erro
 		return err
 	}
 
+	// the pinned image only applies to the image it was resolved from, so pin
+	// a new image unless the caller already did
+	if svc.ImageID != oldSvc.ImageID && svc.ImageDigest == oldSvc.ImageDigest {
+		svc.ImageDigest = resolveImageDigest(svc.ImageID)
+	}
+
 	//Deal with Service Config Files
 	//For now always make sure originalConfigs stay the same, essentially they are immutable
-	svc.OriginalConfigs = oldSvc.OriginalConfigs
+	if !replaceConfigs {
+		svc.OriginalConfigs = oldSvc.OriginalConfigs
+	}
 
-	//check if config files haven't changed
-	if !reflect.DeepEqual(oldSvc.OriginalConfigs, svc.ConfigFiles) {
-		//lets validate Service before doing more work....
+	//lets validate Service before doing more work....
+	if replaceConfigs || !reflect.DeepEqual(svc.OriginalConfigs, svc.ConfigFiles) {
 		if err := svc.ValidEntity(); err != nil {
 			return err
 		}
+	}
 
-		tenantID, servicePath, err := f.getTenantIDAndPath(ctx, *svc)
-		if err != nil {
-			return err
-		}
-
-		newConfs := make(map[string]*serviceconfigfile.SvcConfigFile)
-		//config files are different, for each one that is different validate and add to newConfs
-		for key, oldConf := range oldSvc.OriginalConfigs {
-			if conf, found := svc.ConfigFiles[key]; found {
-				if !reflect.DeepEqual(oldConf, conf) {
-					newConf, err := serviceconfigfile.New(tenantID, servicePath, conf)
-					if err != nil {
-						return err
-					}
-					newConfs[key] = newConf
-				}
-			}
-		}
-
-		//Get current stored conf files and replace as needed
-		configStore := serviceconfigfile.NewStore()
-		existingConfs, err := configStore.GetConfigFiles(ctx, tenantID, servicePath)
-		if err != nil {
-			return err
-		}
-		foundConfs := make(map[string]*serviceconfigfile.SvcConfigFile)
-		for _, svcConfig := range existingConfs {
-			foundConfs[svcConfig.ConfFile.Filename] = svcConfig
-		}
-		//add or replace stored service config
-		for _, newConf := range newConfs {
-			if existing, found := foundConfs[newConf.ConfFile.Filename]; found {
-				newConf.ID = existing.ID
-				//delete it from stored confs, left overs will be deleted from DB
-				delete(foundConfs, newConf.ConfFile.Filename)
-			}
-			configStore.Put(ctx, serviceconfigfile.Key(newConf.ID), newConf)
-		}
-		//remove leftover non-updated stored confs, conf was probably reverted to original or no longer exists
-		for _, confToDelete := range foundConfs {
-			configStore.Delete(ctx, serviceconfigfile.Key(confToDelete.ID))
-		}
+	//always reconcile the stored config files, so that the files that were
+	//reverted to the original are removed too
+	if err := f.saveServiceConfigs(ctx, svc, oldSvc); err != nil {
+		return err
 	}
 
 	svc.UpdatedAt = time.Now()
@@ -933,6 +931,93 @@ func (f *Facade) updateService(ctx datastore.Context, svc *service.Service) erro
 	return err
 }
 
+// resolveImageDigest returns the docker ID of an image in the local
+// repository, or "" to leave the service unpinned if it is not there
+func resolveImageDigest(imageID string) string {
+	if imageID == "" {
+		return ""
+	}
+	image, err := findImage(imageID, false)
+	if err != nil {
+		glog.Warningf("Could not find image %s, not pinning it: %s", imageID, err)
+		return ""
+	}
+	return image.UUID
+}
+
+// saveServiceConfigs stores the config files of a service that differ from its
+// original configs, removes the stored files that no longer do, and keeps a
+// version of every config file that changed
+func (f *Facade) saveServiceConfigs(ctx datastore.Context, svc, oldSvc *service.Service) error {
+	tenantID, servicePath, err := f.getTenantIDAndPath(ctx, *svc)
+	if err != nil {
+		return err
+	}
+
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	newConfs := make(map[string]*serviceconfigfile.SvcConfigFile)
+	//config files are different, for each one that is different validate and add to newConfs
+	//files that are not in the original configs, e.g. customized files that
+	//were removed from the template, are stored too
+	for key, conf := range svc.ConfigFiles {
+		if oldConf, found := svc.OriginalConfigs[key]; !found || !reflect.DeepEqual(oldConf, conf) {
+			newConf, err := serviceconfigfile.New(tenantID, servicePath, conf)
+			if err != nil {
+				return err
+			}
+			newConfs[key] = newConf
+		}
+	}
+
+	//Get current stored conf files and replace as needed
+	configStore := serviceconfigfile.NewStore()
+	existingConfs, err := configStore.GetConfigFiles(ctx, tenantID, servicePath)
+	if err != nil {
+		return err
+	}
+	foundConfs := make(map[string]*serviceconfigfile.SvcConfigFile)
+	for _, svcConfig := range existingConfs {
+		foundConfs[svcConfig.ConfFile.Filename] = svcConfig
+	}
+	//keep a version of each config file that changed
+	for key, conf := range svc.ConfigFiles {
+		previous, found := oldSvc.OriginalConfigs[key]
+		if existing, ok := foundConfs[key]; ok {
+			previous, found = existing.ConfFile, true
+		}
+		if found && reflect.DeepEqual(previous, conf) {
+			continue
+		}
+		var prev *servicedefinition.ConfigFile
+		if found {
+			prev = &previous
+		}
+		if err := f.addConfigVersion(ctx, tenantID, servicePath, svc.UpdatedBy, prev, conf); err != nil {
+			return err
+		}
+	}
+	//add or replace stored service config
+	for _, newConf := range newConfs {
+		if existing, found := foundConfs[newConf.ConfFile.Filename]; found {
+			newConf.ID = existing.ID
+			//delete it from stored confs, left overs will be deleted from DB
+			delete(foundConfs, newConf.ConfFile.Filename)
+		}
+		if err := configStore.Put(ctx, serviceconfigfile.Key(newConf.ID), newConf); err != nil {
+			return err
+		}
+	}
+	//remove leftover non-updated stored confs, conf was probably reverted to original or no longer exists
+	for _, confToDelete := range foundConfs {
+		if err := configStore.Delete(ctx, serviceconfigfile.Key(confToDelete.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func lookUpTenant(svcID string) (string, bool) {
 	tenanIDMutex.RLock()
 	defer tenanIDMutex.RUnlock()
@@ -0,0 +1,80 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package facade
+
+import (
+	"fmt"
+
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/commons/docker"
+	"github.com/control-center/serviced/domain"
+	"github.com/control-center/serviced/domain/service"
+	. "gopkg.in/check.v1"
+)
+
+func (ft *FacadeTest) TestUpdateService_ImageDigest(t *C) {
+	defer func(find func(string, bool) (*docker.Image, error)) {
+		findImage = find
+	}(findImage)
+	images := map[string]string{"localhost:5000/digest/core:latest": "def"}
+	findImage = func(repotag string, pull bool) (*docker.Image, error) {
+		if uuid, ok := images[repotag]; ok {
+			return &docker.Image{UUID: uuid}, nil
+		}
+		return nil, fmt.Errorf("image %s not found", repotag)
+	}
+
+	svc := service.Service{
+		ID:             "digest-service",
+		Name:           "digest-service",
+		PoolID:         "default",
+		DeploymentID:   "digest",
+		ImageID:        "zenoss/core:latest",
+		ImageDigest:    "abc",
+		Launch:         commons.AUTO,
+		Instances:      1,
+		InstanceLimits: domain.MinMax{Min: 1},
+	}
+	t.Assert(ft.Facade.AddService(ft.CTX, svc), IsNil)
+
+	// the pin is kept while the image is the same
+	s, err := ft.Facade.GetService(ft.CTX, svc.ID)
+	t.Assert(err, IsNil)
+	s.Startup = "run"
+	t.Assert(ft.Facade.UpdateService(ft.CTX, *s), IsNil)
+	s, err = ft.Facade.GetService(ft.CTX, svc.ID)
+	t.Assert(err, IsNil)
+	t.Check(s.ImageDigest, Equals, "abc")
+
+	// a new image is pinned to what it resolves to
+	s.ImageID = "localhost:5000/digest/core:latest"
+	t.Assert(ft.Facade.UpdateService(ft.CTX, *s), IsNil)
+	s, err = ft.Facade.GetService(ft.CTX, svc.ID)
+	t.Assert(err, IsNil)
+	t.Check(s.ImageDigest, Equals, "def")
+
+	// unless the caller pinned it already
+	s.ImageID, s.ImageDigest = "zenoss/core:latest", "ghi"
+	t.Assert(ft.Facade.UpdateService(ft.CTX, *s), IsNil)
+	s, err = ft.Facade.GetService(ft.CTX, svc.ID)
+	t.Assert(err, IsNil)
+	t.Check(s.ImageDigest, Equals, "ghi")
+
+	// an image that is not found locally is not pinned
+	s.ImageID = "zenoss/other:latest"
+	t.Assert(ft.Facade.UpdateService(ft.CTX, *s), IsNil)
+	s, err = ft.Facade.GetService(ft.CTX, svc.ID)
+	t.Assert(err, IsNil)
+	t.Check(s.ImageDigest, Equals, "")
+}
@@ -28,10 +28,12 @@ import (
 	"github.com/control-center/serviced/commons/docker"
 	"github.com/control-center/serviced/dao"
 	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/addressassignment"
 	"github.com/control-center/serviced/domain/service"
 	"github.com/control-center/serviced/domain/servicedefinition"
 	"github.com/control-center/serviced/domain/servicetemplate"
 	"github.com/control-center/serviced/isvcs"
+	"github.com/control-center/serviced/utils"
 )
 
 type reloadLogstashContainer func(ctx datastore.Context, f *Facade) error
@@ -87,6 +89,12 @@ func (f *Facade) RemoveServiceTemplate(ctx datastore.Context, id string) error {
 	return nil
 }
 
+// ReloadLogstashContainer rewrites the logstash configuration from the service
+// templates and restarts the logstash container
+func (f *Facade) ReloadLogstashContainer(ctx datastore.Context) error {
+	return LogstashContainerReloader(ctx, f)
+}
+
 func (f *Facade) GetServiceTemplates(ctx datastore.Context) (map[string]servicetemplate.ServiceTemplate, error) {
 	glog.V(2).Infof("Facade.GetServiceTemplates")
 	results, err := f.templateStore.GetServiceTemplates(ctx)
@@ -118,8 +126,16 @@ func getImageIDs(sds ...servicedefinition.ServiceDefinition) []string {
 	return result
 }
 
+// pullImage pulls an image into the local repository
+var pullImage = docker.PullImage
+
 func pullTemplateImages(template *servicetemplate.ServiceTemplate) error {
-	for _, img := range getImageIDs(template.Services...) {
+	return pullImages(template.Services...)
+}
+
+// pullImages pulls the images of service definitions and their children
+func pullImages(sds ...servicedefinition.ServiceDefinition) error {
+	for _, img := range getImageIDs(sds...) {
 		imageID, err := commons.ParseImageID(img)
 		if err != nil {
 			return err
@@ -130,7 +146,7 @@ func pullTemplateImages(template *servicetemplate.ServiceTemplate) error {
 		}
 		image := fmt.Sprintf("%s:%s", imageID.BaseName(), tag)
 		glog.Infof("Pulling image %s", image)
-		if err := docker.PullImage(image); err != nil {
+		if err := pullImage(image); err != nil {
 			glog.Warningf("Unable to pull image %s", image)
 		}
 	}
@@ -177,8 +193,9 @@ func (f *Facade) DeployTemplateStatus(deploymentID string, status *string) error
 	return nil
 }
 
-//DeployTemplate creates and deployes a service to the pool and returns the tenant id of the newly deployed service
-func (f *Facade) DeployTemplate(ctx datastore.Context, poolID string, templateID string, deploymentID string) (string, error) {
+//DeployTemplate creates and deployes a service to the pool and returns the tenant id of the newly deployed service.
+//The values of the template parameters are applied to its service definitions and stored with the tenants.
+func (f *Facade) DeployTemplate(ctx datastore.Context, poolID string, templateID string, deploymentID string, values map[string]string) (string, error) {
 	// add an entry for reporting status
 	deployments[deploymentID] = map[string]string{
 		"TemplateID":   templateID,
@@ -197,20 +214,19 @@ func (f *Facade) DeployTemplate(ctx datastore.Context, poolID string, templateID
 	}
 
 	//check that deployment id does not already exist
-	svcs, err := f.serviceStore.GetServicesByDeployment(ctx, deploymentID)
-	if err != nil {
+	if err := f.checkDeploymentID(ctx, deploymentID); err != nil {
 		glog.Errorf("unable to validate deploymentID %v while deploying %v", deploymentID, templateID)
 		return "", err
 	}
-	for _, svc := range svcs {
-		if svc.DeploymentID == deploymentID {
-			return "", fmt.Errorf("deployment ID %v is already in use", deploymentID)
-		}
-	}
 
 	//now that we know the template name, set it in the status
 	deployments[deploymentID]["templateName"] = template.Name
 
+	if err := template.ApplyParameters(values); err != nil {
+		glog.Errorf("Unable to apply the parameters of template %s: %s", templateID, err)
+		return "", err
+	}
+
 	UpdateDeployTemplateStatus(deploymentID, "deploy_loading_resource_pool|"+poolID)
 	pool, err := f.GetResourcePool(ctx, poolID)
 	if err != nil {
@@ -230,8 +246,154 @@ func (f *Facade) DeployTemplate(ctx datastore.Context, poolID string, templateID
 	volumes := make(map[string]string)
 	var tenantID string
 	err = f.deployServiceDefinitions(ctx, template.Services, poolID, "", volumes, deploymentID, &tenantID)
+	if err != nil {
+		return tenantID, err
+	}
 
-	return tenantID, err
+	// keep the defaults too, so that an upgrade applies the values the
+	// deployment was actually deployed with
+	return tenantID, f.setTemplate(ctx, deploymentID, templateID, template.ParameterValues(values))
+}
+
+// checkDeploymentID returns an error if the deployment ID is already in use
+func (f *Facade) checkDeploymentID(ctx datastore.Context, deploymentID string) error {
+	svcs, err := f.serviceStore.GetServicesByDeployment(ctx, deploymentID)
+	if err != nil {
+		return err
+	}
+	for _, svc := range svcs {
+		if svc.DeploymentID == deploymentID {
+			return fmt.Errorf("deployment ID %v is already in use", deploymentID)
+		}
+	}
+	return nil
+}
+
+// DeployServices deploys a copy of the services of a tenant, such as the ones
+// saved with a snapshot, as a new tenant.  The copies get new ids and their
+// images are tagged for the new tenant as with a template deployment.  The
+// services and image tags that were added are removed again on error.
+// Services keep their pool unless poolID is set.  Returns the id of the new
+// tenant.
+func (f *Facade) DeployServices(ctx datastore.Context, svcs []service.Service, poolID, deploymentID string) (string, error) {
+	if err := f.checkDeploymentID(ctx, deploymentID); err != nil {
+		return "", err
+	}
+	defer delete(deployments, deploymentID)
+
+	if poolID != "" {
+		if pool, err := f.GetResourcePool(ctx, poolID); err != nil {
+			glog.Errorf("Unable to load resource pool: %s", poolID)
+			return "", err
+		} else if pool == nil {
+			return "", fmt.Errorf("poolid %s not found", poolID)
+		}
+	}
+
+	// map the services to their parents; the tenant is the one service whose
+	// parent is not copied
+	ids := make(map[string]struct{})
+	for _, svc := range svcs {
+		ids[svc.ID] = struct{}{}
+	}
+	children := make(map[string][]service.Service)
+	var tenants []service.Service
+	for _, svc := range svcs {
+		if _, ok := ids[svc.ParentServiceID]; ok {
+			children[svc.ParentServiceID] = append(children[svc.ParentServiceID], svc)
+		} else {
+			tenants = append(tenants, svc)
+		}
+	}
+	if len(tenants) != 1 {
+		return "", fmt.Errorf("expected services of one tenant, found %d", len(tenants))
+	}
+
+	var tenantID string
+	var tags []string
+	var deploy func(svc service.Service, parentID string) error
+	deploy = func(svc service.Service, parentID string) error {
+		serviceID := svc.ID
+		id, err := utils.NewUUID36()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		svc.ID = id
+		svc.ParentServiceID = parentID
+		svc.DeploymentID = deploymentID
+		svc.DesiredState = service.SVCStop
+		svc.DatabaseVersion = 0
+		svc.CreatedAt, svc.UpdatedAt = now, now
+		if poolID != "" {
+			svc.PoolID = poolID
+		}
+
+		// address assignments are not valid for a new tenant
+		for i := range svc.Endpoints {
+			svc.Endpoints[i].AddressAssignment = addressassignment.AddressAssignment{}
+		}
+
+		tag, err := f.deployService(ctx, &svc, &tenantID)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+		if err != nil {
+			return err
+		}
+		for _, child := range children[serviceID] {
+			if err := deploy(child, svc.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := deploy(tenants[0], ""); err != nil {
+		glog.Errorf("Unable to deploy a copy of %s; rolling back: %s", tenants[0].ID, err)
+		f.removeDeployment(ctx, tenantID, tags)
+		return "", err
+	}
+	return tenantID, nil
+}
+
+// removeDeployment removes a tenant and the image tags that were added for it
+func (f *Facade) removeDeployment(ctx datastore.Context, tenantID string, tags []string) {
+	if tenantID != "" {
+		if err := f.RemoveService(ctx, tenantID); err != nil {
+			glog.Warningf("Unable to remove tenant %s: %s", tenantID, err)
+		}
+	}
+	for _, tag := range tags {
+		if image, err := docker.FindImage(tag, false); err != nil {
+			glog.Warningf("Unable to find image %s: %s", tag, err)
+		} else if err := image.Delete(); err != nil {
+			glog.Warningf("Unable to remove image %s: %s", tag, err)
+		}
+	}
+}
+
+// setTemplate stores the template and the values of its parameters with the
+// tenants of a deployment, so that they can be applied again on upgrade and
+// the tenant images can be named by the template's images on export
+func (f *Facade) setTemplate(ctx datastore.Context, deploymentID, templateID string, values map[string]string) error {
+	tenantIDs, err := f.GetDeploymentTenants(ctx, deploymentID)
+	if err != nil {
+		return err
+	}
+	for _, tenantID := range tenantIDs {
+		svc, err := f.GetService(ctx, tenantID)
+		if err != nil {
+			return err
+		}
+		svc.TemplateID = templateID
+		svc.TemplateValues = values
+		if err := f.updateService(ctx, svc); err != nil {
+			glog.Errorf("Unable to store the template of %s: %s", tenantID, err)
+			return err
+		}
+	}
+	return nil
 }
 
 func (f *Facade) DeployService(ctx datastore.Context, parentID string, sd servicedefinition.ServiceDefinition) (string, error) {
@@ -262,6 +424,20 @@ func (f *Facade) deployServiceDefinition(ctx datastore.Context, sd servicedefini
 		return "", err
 	}
 
+	if _, err := f.deployService(ctx, svc, tenantId); err != nil {
+		return "", err
+	}
+
+	return svc.ID, f.deployServiceDefinitions(ctx, sd.Services, pool, svc.ID, exportedVolumes, deploymentId, tenantId)
+}
+
+// deployService evaluates the endpoints of a new service, tags its image for
+// the tenant and adds the service.  Returns the image tag if one was added.
+func (f *Facade) deployService(ctx datastore.Context, svc *service.Service, tenantId *string) (string, error) {
+	deploymentId := svc.DeploymentID
+	parentServiceID := svc.ParentServiceID
+	var tag string
+
 	UpdateDeployTemplateStatus(deploymentId, "deploy_loading_service|"+svc.Name)
 	getSvc := func(svcID string) (service.Service, error) {
 		svc, err := f.GetService(ctx, svcID)
@@ -273,13 +449,13 @@ func (f *Facade) deployServiceDefinition(ctx datastore.Context, sd servicedefini
 	}
 
 	//for each endpoint, evaluate its Application
-	if err = svc.EvaluateEndpointTemplates(getSvc, findChild); err != nil {
-		return "", err
+	if err := svc.EvaluateEndpointTemplates(getSvc, findChild); err != nil {
+		return tag, err
 	}
 
 	//for each endpoint, evaluate its Application
-	if err = svc.EvaluateEndpointTemplates(getSvc, findChild); err != nil {
-		return "", err
+	if err := svc.EvaluateEndpointTemplates(getSvc, findChild); err != nil {
+		return tag, err
 	}
 
 	if parentServiceID == "" {
@@ -292,37 +468,35 @@ func (f *Facade) deployServiceDefinition(ctx datastore.Context, sd servicedefini
 		name, err := renameImageID(f.dockerRegistry, svc.ImageID, *tenantId)
 		if err != nil {
 			glog.Errorf("malformed imageId: %s", svc.ImageID)
-			return "", err
+			return tag, err
 		}
 
-		_, err = docker.FindImage(name, false)
+		tenantImage, err := docker.FindImage(name, false)
 		if err != nil {
 			if err != docker.ErrNoSuchImage && !strings.HasPrefix(err.Error(), "No such id:") {
 				glog.Error(err)
-				return "", err
+				return tag, err
 			}
 			UpdateDeployTemplateStatus(deploymentId, "deploy_loading_image|"+name)
 			image, err := docker.FindImage(svc.ImageID, false)
 			if err != nil {
 				msg := fmt.Errorf("could not look up image %s: %s. Check your docker login and retry application deployment.", svc.ImageID, err)
 				glog.Error(err.Error())
-				return "", msg
+				return tag, msg
 			}
 			UpdateDeployTemplateStatus(deploymentId, "deploy_tagging_image|"+name)
-			if _, err := image.Tag(name); err != nil {
+			if tenantImage, err = image.Tag(name); err != nil {
 				glog.Errorf("could not tag image: %s (%v)", image.ID, err)
-				return "", err
+				return tag, err
 			}
+			tag = name
 		}
 		svc.ImageID = name
+		// record the bits that were deployed
+		svc.ImageDigest = tenantImage.UUID
 	}
 
-	err = f.AddService(ctx, *svc)
-	if err != nil {
-		return "", err
-	}
-
-	return svc.ID, f.deployServiceDefinitions(ctx, sd.Services, pool, svc.ID, exportedVolumes, deploymentId, tenantId)
+	return tag, f.AddService(ctx, *svc)
 }
 
 func (f *Facade) deployServiceDefinitions(ctx datastore.Context, sds []servicedefinition.ServiceDefinition, pool string, parentServiceID string, volumes map[string]string, deploymentId string, tenantId *string) error {
@@ -361,15 +535,23 @@ func getSubServiceImageIDs(ids map[string]struct{}, svc servicedefinition.Servic
 }
 
 func renameImageID(dockerRegistry, imageId, tenantId string) (string, error) {
+	name, err := imageName(imageId)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/%s", dockerRegistry, tenantId, name), nil
+}
 
+// imageName returns the last part of the repository of an image, which names
+// the image in the registry of a tenant
+func imageName(imageId string) (string, error) {
 	repo, _ := parsers.ParseRepositoryTag(imageId)
 	re := regexp.MustCompile("/?([^/]+)\\z")
 	matches := re.FindStringSubmatch(repo)
 	if matches == nil {
 		return "", errors.New("malformed imageid")
 	}
-	name := matches[1]
-	return fmt.Sprintf("%s/%s/%s", dockerRegistry, tenantId, name), nil
+	return matches[1], nil
 }
 
 // writeLogstashConfiguration takes all the available
@@ -16,8 +16,10 @@ package facade
 import (
 	"github.com/control-center/serviced/commons"
 	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain"
 	"github.com/control-center/serviced/domain/service"
 	"github.com/control-center/serviced/domain/servicedefinition"
+	"github.com/control-center/serviced/domain/servicetemplate"
 	. "gopkg.in/check.v1"
 )
 
@@ -79,3 +81,165 @@ func (ft *FacadeTest) TestRenameImageID(t *C) {
 		t.FailNow()
 	}
 }
+
+func (ft *FacadeTest) TestPortableImageID(t *C) {
+	images := templateImages("", []*servicetemplate.ServiceTemplate{
+		{ID: "b", Services: []servicedefinition.ServiceDefinition{{ImageID: "zenoss/core:5.0.1"}}},
+		{ID: "a", Services: []servicedefinition.ServiceDefinition{{ImageID: "quay.io/zenossinc/core:5.0.0"}}},
+	})
+	t.Assert(portableImageID("localhost:5000", "X", "localhost:5000/X/core", images), Equals, "quay.io/zenossinc/core:5.0.0")
+	t.Assert(portableImageID("localhost:5000", "X", "localhost:5000/X/opentsdb", images), Equals, "opentsdb")
+	t.Assert(portableImageID("localhost:5000", "X", "localhost:5000/Y/core", images), Equals, "localhost:5000/Y/core")
+	t.Assert(portableImageID("localhost:5000", "X", "", images), Equals, "")
+
+	// the images of the template the tenant was deployed from win
+	images = templateImages("b", []*servicetemplate.ServiceTemplate{
+		{ID: "b", Services: []servicedefinition.ServiceDefinition{{ImageID: "zenoss/core:5.0.1"}}},
+		{ID: "a", Services: []servicedefinition.ServiceDefinition{{ImageID: "quay.io/zenossinc/core:5.0.0"}}},
+	})
+	t.Assert(portableImageID("localhost:5000", "X", "localhost:5000/X/core", images), Equals, "zenoss/core:5.0.1")
+}
+
+func (ft *FacadeTest) TestExportTemplate_DeployedTemplateImages(t *C) {
+	for _, template := range []servicetemplate.ServiceTemplate{
+		{
+			ID:       "export-template-a",
+			Name:     "Zenoss.core",
+			Version:  "5.0.0",
+			Services: []servicedefinition.ServiceDefinition{{Name: "Zenoss.core", Launch: commons.AUTO, ImageID: "zenoss/core:5.0.0"}},
+		}, {
+			ID:       "export-template-b",
+			Name:     "Zenoss.core",
+			Version:  "5.0.1",
+			Services: []servicedefinition.ServiceDefinition{{Name: "Zenoss.core", Launch: commons.AUTO, ImageID: "zenoss/core:5.0.1"}},
+		},
+	} {
+		t.Assert(ft.Facade.templateStore.Put(ft.CTX, template), IsNil)
+	}
+
+	// the tenant was upgraded to the second version of the template
+	tenant := service.Service{
+		ID:           "export-tenant",
+		Name:         "Zenoss.core",
+		PoolID:       "default",
+		DeploymentID: "export",
+		Launch:       commons.AUTO,
+		Instances:    1,
+		ImageID:      "localhost:5000/export-tenant/core",
+		TemplateID:   "export-template-b",
+	}
+	t.Assert(ft.Facade.AddService(ft.CTX, tenant), IsNil)
+
+	exported, err := ft.Facade.ExportTemplate(ft.CTX, tenant.ID)
+	t.Assert(err, IsNil)
+	t.Assert(exported.Services, HasLen, 1)
+	t.Assert(exported.Services[0].ImageID, Equals, "zenoss/core:5.0.1")
+}
+
+func (ft *FacadeTest) TestExportTemplate_LogFilters(t *C) {
+	template := servicetemplate.ServiceTemplate{
+		ID:      "export-filters-template",
+		Name:    "Zenoss.core",
+		Version: "5.0.0",
+		Services: []servicedefinition.ServiceDefinition{
+			{
+				Name:       "Zenoss.core",
+				Launch:     commons.AUTO,
+				LogFilters: map[string]string{"pythondaemon": "grok {}"},
+				Services: []servicedefinition.ServiceDefinition{
+					{Name: "zenhub", Launch: commons.AUTO, LogFilters: map[string]string{"zenhub": "mutate {}"}},
+					{Name: "redis", Launch: commons.AUTO},
+				},
+			},
+		},
+	}
+	t.Assert(ft.Facade.templateStore.Put(ft.CTX, template), IsNil)
+
+	svcs := []service.Service{
+		{
+			ID:           "export-filters-tenant",
+			Name:         "Zenoss.core",
+			PoolID:       "default",
+			DeploymentID: "export-filters",
+			Launch:       commons.AUTO,
+			Instances:    1,
+			TemplateID:   "export-filters-template",
+		}, {
+			ID:              "export-filters-zenhub",
+			Name:            "zenhub",
+			ParentServiceID: "export-filters-tenant",
+			PoolID:          "default",
+			DeploymentID:    "export-filters",
+			Launch:          commons.AUTO,
+			Instances:       1,
+		}, {
+			ID:              "export-filters-redis",
+			Name:            "redis",
+			ParentServiceID: "export-filters-tenant",
+			PoolID:          "default",
+			DeploymentID:    "export-filters",
+			Launch:          commons.AUTO,
+			Instances:       1,
+		},
+	}
+	for _, svc := range svcs {
+		t.Assert(ft.Facade.AddService(ft.CTX, svc), IsNil)
+	}
+
+	exported, err := ft.Facade.ExportTemplate(ft.CTX, "export-filters-tenant")
+	t.Assert(err, IsNil)
+	t.Assert(exported.Services, HasLen, 1)
+	tenant := exported.Services[0]
+	t.Assert(tenant.LogFilters, DeepEquals, map[string]string{"pythondaemon": "grok {}"})
+	t.Assert(tenant.Services, HasLen, 2)
+	t.Assert(tenant.Services[0].Name, Equals, "redis")
+	t.Assert(tenant.Services[0].LogFilters, IsNil)
+	t.Assert(tenant.Services[1].Name, Equals, "zenhub")
+	t.Assert(tenant.Services[1].LogFilters, DeepEquals, map[string]string{"zenhub": "mutate {}"})
+}
+
+func (ft *FacadeTest) TestDeployServices(t *C) {
+	svcs := []service.Service{
+		{
+			ID:             "clone-source",
+			Name:           "Zenoss",
+			PoolID:         "default",
+			DeploymentID:   "clone-source",
+			Launch:         commons.AUTO,
+			Instances:      1,
+			InstanceLimits: domain.MinMax{Min: 1},
+		}, {
+			ID:              "clone-source-child",
+			Name:            "redis",
+			ParentServiceID: "clone-source",
+			PoolID:          "default",
+			DeploymentID:    "clone-source",
+			Launch:          commons.AUTO,
+			Instances:       1,
+			InstanceLimits:  domain.MinMax{Min: 1},
+		},
+	}
+
+	tenantID, err := ft.Facade.DeployServices(ft.CTX, svcs, "", "clone-deployment")
+	t.Assert(err, IsNil)
+	t.Assert(tenantID, Not(Equals), "clone-source")
+	tenant, err := ft.Facade.GetService(ft.CTX, tenantID)
+	t.Assert(err, IsNil)
+	t.Assert(tenant.DeploymentID, Equals, "clone-deployment")
+	child, err := ft.Facade.FindChildService(ft.CTX, tenantID, "redis")
+	t.Assert(err, IsNil)
+	t.Assert(child, NotNil)
+	t.Assert(child.ID, Not(Equals), "clone-source-child")
+
+	// the deployment ID is in use now
+	_, err = ft.Facade.DeployServices(ft.CTX, svcs, "", "clone-deployment")
+	t.Assert(err, NotNil)
+
+	// nothing is left of a deployment that fails
+	svcs[1].ImageID = "localhost:5000/clone-source/"
+	_, err = ft.Facade.DeployServices(ft.CTX, svcs, "", "clone-failed")
+	t.Assert(err, NotNil)
+	deployed, err := ft.Facade.GetDeploymentTenants(ft.CTX, "clone-failed")
+	t.Assert(err, IsNil)
+	t.Assert(deployed, HasLen, 0)
+}
@@ -0,0 +1,167 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package facade
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/zenoss/glog"
+
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicedefinition"
+	"github.com/control-center/serviced/domain/servicetemplate"
+)
+
+// ExportTemplate builds a template from the services of a tenant, so that a
+// deployment that was tuned in production can be captured and deployed again.
+// The services keep their current config files, instance counts, endpoints,
+// volumes and monitoring profiles.  Tenant images are named by the images of
+// the template the tenant was deployed or upgraded from, then by those of the
+// other templates, or by their name in the registry if no template has them.
+// Services do not keep their log filters, so they are copied from the
+// template the tenant was deployed or upgraded from.
+func (f *Facade) ExportTemplate(ctx datastore.Context, tenantID string) (*servicetemplate.ServiceTemplate, error) {
+	tenant, err := f.GetService(ctx, tenantID)
+	if err != nil {
+		glog.Errorf("Unable to load tenant %s: %s", tenantID, err)
+		return nil, err
+	} else if tenant.ParentServiceID != "" {
+		return nil, fmt.Errorf("service %s is not a tenant", tenantID)
+	}
+
+	var svcs []service.Service
+	collect := func(svc *service.Service) error {
+		svcs = append(svcs, *svc)
+		return nil
+	}
+	if err := f.walkServices(ctx, tenantID, collect); err != nil {
+		glog.Errorf("Unable to load the services of tenant %s: %s", tenantID, err)
+		return nil, err
+	}
+	// get the customized config files
+	if err := f.fillOutServices(ctx, svcs); err != nil {
+		return nil, err
+	}
+
+	templates, err := f.templateStore.GetServiceTemplates(ctx)
+	if err != nil {
+		glog.Errorf("Unable to load the templates: %s", err)
+		return nil, err
+	}
+	images := templateImages(tenant.TemplateID, templates)
+	logFilters := templateLogFilters(tenant.TemplateID, templates)
+
+	children := make(map[string][]*service.Service)
+	var root *service.Service
+	for i := range svcs {
+		svc := &svcs[i]
+		if svc.ID == tenantID {
+			root = svc
+		}
+		children[svc.ParentServiceID] = append(children[svc.ParentServiceID], svc)
+	}
+
+	var export func(svc *service.Service, svcPath string) servicedefinition.ServiceDefinition
+	export = func(svc *service.Service, svcPath string) servicedefinition.ServiceDefinition {
+		sd := service.BuildServiceDefinition(*svc)
+		sd.ImageID = portableImageID(f.dockerRegistry, tenantID, svc.ImageID, images)
+		sd.LogFilters = logFilters[svcPath]
+		for _, child := range children[svc.ID] {
+			sd.Services = append(sd.Services, export(child, path.Join(svcPath, child.Name)))
+		}
+		sort.Sort(servicedefinition.ServiceDefinitionByName(sd.Services))
+		return sd
+	}
+
+	return &servicetemplate.ServiceTemplate{
+		Name:        tenant.Name,
+		Version:     tenant.Version,
+		Description: tenant.Description,
+		Services:    []servicedefinition.ServiceDefinition{export(root, root.Name)},
+	}, nil
+}
+
+// templateImages maps the names that images get in the registry of a tenant
+// to the images of the templates.  The images of the template with templateID
+// win, then those of the others by ID.
+func templateImages(templateID string, templates []*servicetemplate.ServiceTemplate) map[string]string {
+	sort.Sort(templatesByID(templates))
+	images := make(map[string]string)
+	add := func(template *servicetemplate.ServiceTemplate) {
+		for _, imageID := range getImageIDs(template.Services...) {
+			name, err := imageName(imageID)
+			if err != nil {
+				continue
+			}
+			if _, ok := images[name]; !ok {
+				images[name] = imageID
+			}
+		}
+	}
+	for _, template := range templates {
+		if template.ID == templateID {
+			add(template)
+		}
+	}
+	for _, template := range templates {
+		add(template)
+	}
+	return images
+}
+
+// templateLogFilters maps the paths of the services of the template with
+// templateID to their log filters.
+func templateLogFilters(templateID string, templates []*servicetemplate.ServiceTemplate) map[string]map[string]string {
+	filters := make(map[string]map[string]string)
+	var add func(sds []servicedefinition.ServiceDefinition, parentPath string)
+	add = func(sds []servicedefinition.ServiceDefinition, parentPath string) {
+		for _, sd := range sds {
+			svcPath := path.Join(parentPath, sd.Name)
+			if len(sd.LogFilters) > 0 {
+				filters[svcPath] = sd.LogFilters
+			}
+			add(sd.Services, svcPath)
+		}
+	}
+	for _, template := range templates {
+		if template.ID == templateID {
+			add(template.Services, "")
+		}
+	}
+	return filters
+}
+
+type templatesByID []*servicetemplate.ServiceTemplate
+
+func (t templatesByID) Len() int           { return len(t) }
+func (t templatesByID) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t templatesByID) Less(i, j int) bool { return t[i].ID < t[j].ID }
+
+// portableImageID reverses renameImageID.  Images that are not in the
+// registry of the tenant are kept as they are.
+func portableImageID(dockerRegistry, tenantID, imageID string, images map[string]string) string {
+	prefix := fmt.Sprintf("%s/%s/", dockerRegistry, tenantID)
+	if !strings.HasPrefix(imageID, prefix) {
+		return imageID
+	}
+	name := strings.TrimPrefix(imageID, prefix)
+	if original, ok := images[name]; ok {
+		return original
+	}
+	return name
+}
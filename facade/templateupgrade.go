@@ -0,0 +1,644 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package facade
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+
+	"github.com/zenoss/glog"
+
+	"github.com/control-center/serviced/commons/diff"
+	"github.com/control-center/serviced/commons/docker"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/datastore"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicedefinition"
+)
+
+// Actions of a template upgrade or an apply
+const (
+	UpgradeAdd    = "add"
+	UpgradeUpdate = "update"
+	UpgradeRemove = "remove"
+)
+
+// PlanTemplateUpgrade returns the changes that upgrading a deployment to a
+// template would make, without making them.
+func (f *Facade) PlanTemplateUpgrade(ctx datastore.Context, deploymentID, templateID string) (*dao.ServiceTemplateUpgradePlan, error) {
+	return f.upgradeTemplate(ctx, deploymentID, templateID, false)
+}
+
+// UpgradeTemplate applies a template to the services of an existing
+// deployment.  Services that are new in the template are deployed, services
+// that are no longer in the template are removed and the rest are updated.
+// Updated services keep their instance count, context values and customized
+// config files.  The template parameters are set to the values the deployment
+// was deployed with.  Returns the changes that were made.
+func (f *Facade) UpgradeTemplate(ctx datastore.Context, deploymentID, templateID string) (*dao.ServiceTemplateUpgradePlan, error) {
+	return f.upgradeTemplate(ctx, deploymentID, templateID, true)
+}
+
+// GetDeploymentTenants returns the IDs of the tenants of a deployment
+func (f *Facade) GetDeploymentTenants(ctx datastore.Context, deploymentID string) ([]string, error) {
+	svcs, err := f.serviceStore.GetServicesByDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	var tenantIDs []string
+	for _, svc := range svcs {
+		if svc.ParentServiceID == "" {
+			tenantIDs = append(tenantIDs, svc.ID)
+		}
+	}
+	return tenantIDs, nil
+}
+
+// serviceUpgrade walks the service definitions and the deployed services
+// together
+type serviceUpgrade struct {
+	f            *Facade
+	ctx          datastore.Context
+	deploymentID string
+	poolID       string
+	children     map[string][]*service.Service
+	apply        bool
+	prune        bool   // remove the services that have no definition
+	overwrite    bool   // set the instances, context and config files to the definition
+	updatedBy    string // recorded on the services that are updated
+	actions      []dao.ServiceUpgradeAction
+}
+
+func (f *Facade) upgradeTemplate(ctx datastore.Context, deploymentID, templateID string, apply bool) (*dao.ServiceTemplateUpgradePlan, error) {
+	template, err := f.templateStore.Get(ctx, templateID)
+	if err != nil {
+		glog.Errorf("Unable to load template %s: %s", templateID, err)
+		return nil, err
+	}
+
+	svcs, err := f.serviceStore.GetServicesByDeployment(ctx, deploymentID)
+	if err != nil {
+		glog.Errorf("Unable to load the services of deployment %s: %s", deploymentID, err)
+		return nil, err
+	} else if len(svcs) == 0 {
+		return nil, fmt.Errorf("deployment %s not found", deploymentID)
+	}
+	// get the customized config files
+	if err := f.fillOutServices(ctx, svcs); err != nil {
+		return nil, err
+	}
+
+	// apply the parameter values the deployment was deployed with, dropping
+	// any parameters that the template no longer has
+	values := make(map[string]string)
+	for _, svc := range svcs {
+		if svc.ParentServiceID != "" {
+			continue
+		}
+		for name, value := range svc.TemplateValues {
+			for _, p := range template.Parameters {
+				if p.Name == name {
+					values[name] = value
+					break
+				}
+			}
+		}
+	}
+	if err := template.ApplyParameters(values); err != nil {
+		glog.Errorf("Unable to apply the parameters of template %s: %s", templateID, err)
+		return nil, err
+	}
+
+	// only pull the images when the upgrade is applied; a plan reports the
+	// images that would change without pulling them
+	if apply {
+		if err := pullTemplateImages(template); err != nil {
+			glog.Errorf("Unable to pull one or more images")
+			return nil, err
+		}
+	}
+
+	u := &serviceUpgrade{
+		f:            f,
+		ctx:          ctx,
+		deploymentID: deploymentID,
+		children:     make(map[string][]*service.Service),
+		apply:        apply,
+		prune:        true,
+		updatedBy:    fmt.Sprintf("template %s", templateID),
+	}
+	for i := range svcs {
+		svc := &svcs[i]
+		if svc.ParentServiceID == "" {
+			u.poolID = svc.PoolID
+		}
+		u.children[svc.ParentServiceID] = append(u.children[svc.ParentServiceID], svc)
+	}
+
+	if err := u.upgrade(template.Services, "", "", ""); err != nil {
+		return nil, err
+	}
+	// keep the new template and the values of its parameters, including the
+	// defaults of the parameters that were added
+	if apply {
+		if err := f.setTemplate(ctx, deploymentID, templateID, template.ParameterValues(values)); err != nil {
+			return nil, err
+		}
+	}
+	return &dao.ServiceTemplateUpgradePlan{
+		TemplateID:   templateID,
+		DeploymentID: deploymentID,
+		Actions:      u.actions,
+	}, nil
+}
+
+// upgrade matches the service definitions to the children of parentID by name
+func (u *serviceUpgrade) upgrade(sds []servicedefinition.ServiceDefinition, parentID, parentPath, tenantID string) error {
+	existing := make(map[string]*service.Service)
+	for _, svc := range u.children[parentID] {
+		existing[svc.Name] = svc
+	}
+
+	for _, sd := range sds {
+		svcPath := path.Join(parentPath, sd.Name)
+		svc, ok := existing[sd.Name]
+		if !ok {
+			if err := u.add(sd, parentID, svcPath, tenantID); err != nil {
+				return err
+			}
+			continue
+		}
+		delete(existing, sd.Name)
+
+		svcTenantID := tenantID
+		if parentID == "" {
+			svcTenantID = svc.ID
+		}
+		if err := u.update(svc, sd, svcPath, svcTenantID); err != nil {
+			return err
+		}
+		if err := u.upgrade(sd.Services, svc.ID, svcPath, svcTenantID); err != nil {
+			return err
+		}
+	}
+
+	if !u.prune {
+		return nil
+	}
+	var names []string
+	for name := range existing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := u.remove(existing[name], path.Join(parentPath, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *serviceUpgrade) add(sd servicedefinition.ServiceDefinition, parentID, svcPath, tenantID string) error {
+	var planAdd func(sd servicedefinition.ServiceDefinition, svcPath string)
+	planAdd = func(sd servicedefinition.ServiceDefinition, svcPath string) {
+		u.actions = append(u.actions, dao.ServiceUpgradeAction{Action: UpgradeAdd, Path: svcPath})
+		for _, child := range sd.Services {
+			planAdd(child, path.Join(svcPath, child.Name))
+		}
+	}
+	planAdd(sd, svcPath)
+
+	if !u.apply {
+		return nil
+	}
+	// deploys the child services too
+	_, err := u.f.deployServiceDefinition(u.ctx, sd, u.poolID, parentID, make(map[string]string), u.deploymentID, &tenantID)
+	if err != nil {
+		glog.Errorf("Could not add service %s: %s", svcPath, err)
+	}
+	return err
+}
+
+func (u *serviceUpgrade) remove(svc *service.Service, svcPath string) error {
+	var planRemove func(svc *service.Service, svcPath string)
+	planRemove = func(svc *service.Service, svcPath string) {
+		u.actions = append(u.actions, dao.ServiceUpgradeAction{Action: UpgradeRemove, Path: svcPath, ServiceID: svc.ID})
+		for _, child := range u.children[svc.ID] {
+			planRemove(child, path.Join(svcPath, child.Name))
+		}
+	}
+	planRemove(svc, svcPath)
+
+	if !u.apply {
+		return nil
+	}
+	// removes the child services too
+	if err := u.f.RemoveService(u.ctx, svc.ID); err != nil {
+		glog.Errorf("Could not remove service %s (%s): %s", svcPath, svc.ID, err)
+		return err
+	}
+	return nil
+}
+
+func (u *serviceUpgrade) update(svc *service.Service, sd servicedefinition.ServiceDefinition, svcPath, tenantID string) error {
+	var changes []string
+	if u.overwrite {
+		changes = overwriteService(svc, sd)
+	}
+	upgraded, err := upgradeService(svc, sd)
+	if err != nil {
+		return err
+	}
+	changes = append(changes, upgraded...)
+	if changed, err := u.upgradeImage(svc, sd, tenantID); err != nil {
+		return err
+	} else if changed {
+		changes = append(changes, "ImageID")
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	u.actions = append(u.actions, dao.ServiceUpgradeAction{Action: UpgradeUpdate, Path: svcPath, ServiceID: svc.ID, Changes: changes})
+
+	if !u.apply {
+		return nil
+	}
+	getSvc := func(svcID string) (service.Service, error) {
+		svc, err := u.f.GetService(u.ctx, svcID)
+		return *svc, err
+	}
+	findChild := func(svcID, childName string) (service.Service, error) {
+		svc, err := u.f.FindChildService(u.ctx, svcID, childName)
+		return *svc, err
+	}
+	if err := svc.EvaluateEndpointTemplates(getSvc, findChild); err != nil {
+		return err
+	}
+	svc.UpdatedBy = u.updatedBy
+	if err := u.f.saveService(u.ctx, svc, true); err != nil {
+		glog.Errorf("Could not update service %s (%s): %s", svcPath, svc.ID, err)
+		return err
+	}
+	return nil
+}
+
+// upgradeImage tags the image of the service definition for the tenant,
+// unless the tenant image already descends from it, i.e. it is the same image
+// or one that was committed on top of it.  Returns true if the image changes.
+func (u *serviceUpgrade) upgradeImage(svc *service.Service, sd servicedefinition.ServiceDefinition, tenantID string) (bool, error) {
+	if sd.ImageID == "" {
+		if svc.ImageID == "" {
+			return false, nil
+		}
+		svc.ImageID, svc.ImageDigest = "", ""
+		return true, nil
+	}
+
+	name, err := renameImageID(u.f.dockerRegistry, sd.ImageID, tenantID)
+	if err != nil {
+		glog.Errorf("malformed imageId: %s", sd.ImageID)
+		return false, err
+	}
+	image, err := docker.FindImage(sd.ImageID, false)
+	if err != nil && !u.apply {
+		// the image has not been pulled yet, so it would change
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("could not look up image %s: %s", sd.ImageID, err)
+	}
+
+	if svc.ImageID == name {
+		if tenantImage, err := docker.FindImage(name, false); err == nil {
+			layers, err := tenantImage.History()
+			if err != nil {
+				return false, err
+			}
+			for _, layer := range layers {
+				if layer.ID == image.UUID {
+					return false, nil
+				}
+			}
+		}
+	}
+
+	if u.apply {
+		tenantImage, err := image.Tag(name)
+		if err != nil {
+			glog.Errorf("could not tag image: %s (%v)", image.ID, err)
+			return false, err
+		}
+		svc.ImageID = name
+		svc.ImageDigest = tenantImage.UUID
+	}
+	return true, nil
+}
+
+// overwriteService sets the instance count, context values and config files of
+// a service to the ones of its definition, dropping the values that were set
+// on the service, and returns the fields that change
+func overwriteService(svc *service.Service, sd servicedefinition.ServiceDefinition) []string {
+	var changes []string
+	instances := sd.Instances.Default
+	if instances == 0 {
+		instances = sd.Instances.Min
+	}
+	if svc.Instances != instances {
+		svc.Instances = instances
+		changes = append(changes, "Instances")
+	}
+	if !upgradeEqual(svc.Context, sd.Context) {
+		svc.Context = sd.Context
+		changes = append(changes, "Context")
+	}
+
+	// the files of the definition become the originals, so that none of them
+	// is customized
+	configs := make(map[string]servicedefinition.ConfigFile)
+	for filename, conf := range sd.ConfigFiles {
+		configs[filename] = conf
+	}
+	if !upgradeEqual(svc.ConfigFiles, configs) || !upgradeEqual(svc.OriginalConfigs, sd.ConfigFiles) || len(svc.ConfigConflicts) > 0 {
+		changes = append(changes, "ConfigFiles")
+	}
+	svc.OriginalConfigs = sd.ConfigFiles
+	svc.ConfigFiles = configs
+	svc.ConfigConflicts = nil
+	return changes
+}
+
+// upgradeService updates a service from its new definition and returns the
+// fields that change.  The instance count and context values of the service
+// are kept, as are config files that were customized.
+func upgradeService(svc *service.Service, sd servicedefinition.ServiceDefinition) ([]string, error) {
+	var changes []string
+	differs := func(field string, a, b interface{}) bool {
+		if upgradeEqual(a, b) {
+			return false
+		}
+		changes = append(changes, field)
+		return true
+	}
+
+	if differs("Title", svc.Title, sd.Title) {
+		svc.Title = sd.Title
+	}
+	if differs("Version", svc.Version, sd.Version) {
+		svc.Version = sd.Version
+	}
+	if differs("Description", svc.Description, sd.Description) {
+		svc.Description = sd.Description
+	}
+	if differs("Startup", svc.Startup, sd.Command) {
+		svc.Startup = sd.Command
+	}
+	if differs("Tags", svc.Tags, sd.Tags) {
+		svc.Tags = sd.Tags
+	}
+	if differs("InstanceLimits", svc.InstanceLimits, sd.Instances) {
+		svc.InstanceLimits = sd.Instances
+	}
+	if differs("ChangeOptions", svc.ChangeOptions, sd.ChangeOptions) {
+		svc.ChangeOptions = sd.ChangeOptions
+	}
+	if differs("Launch", svc.Launch, sd.Launch) {
+		svc.Launch = sd.Launch
+	}
+	if differs("HostPolicy", svc.HostPolicy, sd.HostPolicy) {
+		svc.HostPolicy = sd.HostPolicy
+	}
+	if differs("Hostname", svc.Hostname, sd.Hostname) {
+		svc.Hostname = sd.Hostname
+	}
+	if differs("Privileged", svc.Privileged, sd.Privileged) {
+		svc.Privileged = sd.Privileged
+	}
+	if differs("Tasks", svc.Tasks, sd.Tasks) {
+		svc.Tasks = sd.Tasks
+	}
+	if differs("Volumes", svc.Volumes, sd.Volumes) {
+		svc.Volumes = sd.Volumes
+	}
+	if differs("LogConfigs", svc.LogConfigs, sd.LogConfigs) {
+		svc.LogConfigs = sd.LogConfigs
+	}
+	if differs("Snapshot", svc.Snapshot, sd.Snapshot) {
+		svc.Snapshot = sd.Snapshot
+	}
+	if differs("RAMCommitment", svc.RAMCommitment, sd.RAMCommitment) {
+		svc.RAMCommitment = sd.RAMCommitment
+	}
+	if differs("Runs", svc.Runs, sd.Runs) {
+		svc.Runs = sd.Runs
+	}
+	if differs("Actions", svc.Actions, sd.Actions) {
+		svc.Actions = sd.Actions
+	}
+	if differs("HealthChecks", svc.HealthChecks, sd.HealthChecks) {
+		svc.HealthChecks = sd.HealthChecks
+	}
+	if differs("Prereqs", svc.Prereqs, sd.Prereqs) {
+		svc.Prereqs = sd.Prereqs
+	}
+	if differs("PIDFile", svc.PIDFile, sd.PIDFile) {
+		svc.PIDFile = sd.PIDFile
+	}
+	if differs("MemoryLimit", svc.MemoryLimit, sd.MemoryLimit) {
+		svc.MemoryLimit = sd.MemoryLimit
+	}
+	if differs("CPUShares", svc.CPUShares, sd.CPUShares) {
+		svc.CPUShares = sd.CPUShares
+	}
+	if differs("ResourceLimits", svc.ResourceLimits, sd.ResourceLimits) {
+		svc.ResourceLimits = sd.ResourceLimits
+	}
+	if differs("Network", svc.Network, sd.Network) {
+		svc.Network = sd.Network
+	}
+
+	tags := map[string][]string{
+		"controlplane_service_id": []string{svc.ID},
+	}
+	profile, err := sd.MonitoringProfile.ReBuild("1h-ago", tags)
+	if err != nil {
+		return nil, err
+	}
+	if differs("MonitoringProfile", svc.MonitoringProfile, *profile) {
+		svc.MonitoringProfile = *profile
+	}
+
+	// keep the instance count within the new limits
+	instances := svc.Instances
+	if limits := svc.InstanceLimits; limits.Min > 0 && instances < limits.Min {
+		instances = limits.Min
+	} else if limits.Max > 0 && instances > limits.Max {
+		instances = limits.Max
+	}
+	if differs("Instances", svc.Instances, instances) {
+		svc.Instances = instances
+	}
+
+	// values set on the service win over the template defaults
+	context := make(map[string]interface{})
+	for key, value := range sd.Context {
+		context[key] = value
+	}
+	for key, value := range svc.Context {
+		context[key] = value
+	}
+	if differs("Context", svc.Context, context) {
+		svc.Context = context
+	}
+
+	// endpoints keep their address assignments by name when they are saved
+	var endpoints []servicedefinition.EndpointDefinition
+	for _, ep := range svc.Endpoints {
+		def := ep.EndpointDefinition
+		if def.ApplicationTemplate != "" {
+			def.Application, def.ApplicationTemplate = def.ApplicationTemplate, ""
+		}
+		endpoints = append(endpoints, def)
+	}
+	if differs("Endpoints", endpoints, sd.Endpoints) {
+		svc.Endpoints = make([]service.ServiceEndpoint, 0)
+		for _, ep := range sd.Endpoints {
+			svc.Endpoints = append(svc.Endpoints, service.BuildServiceEndpoint(ep))
+		}
+	}
+
+	changes = append(changes, upgradeConfigFiles(svc, sd)...)
+	return changes, nil
+}
+
+// upgradeConfigFiles replaces the config files of a service that were not
+// customized with the ones of its new definition.  The changes to customized
+// files are merged with the changes to the definition.  If they conflict, the
+// customized file is kept and the conflict is recorded on the service.
+// Customized files are kept, even if they were removed from the definition.
+func upgradeConfigFiles(svc *service.Service, sd servicedefinition.ServiceDefinition) []string {
+	customized := func(filename string) bool {
+		original, ok := svc.OriginalConfigs[filename]
+		return !ok || !reflect.DeepEqual(original, svc.ConfigFiles[filename])
+	}
+
+	var filenames []string
+	for filename := range sd.ConfigFiles {
+		filenames = append(filenames, filename)
+	}
+	for filename := range svc.ConfigFiles {
+		if _, ok := sd.ConfigFiles[filename]; !ok {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+
+	var changes []string
+	configs := make(map[string]servicedefinition.ConfigFile)
+	for _, filename := range filenames {
+		current, exists := svc.ConfigFiles[filename]
+		conf, inTemplate := sd.ConfigFiles[filename]
+		switch {
+		case exists && customized(filename):
+			original := svc.OriginalConfigs[filename]
+			if !inTemplate {
+				configs[filename] = current
+				changes = append(changes, fmt.Sprintf("ConfigFiles[%s] (customized, kept)", filename))
+			} else if reflect.DeepEqual(original, conf) {
+				configs[filename] = current
+			} else if merged, ok := mergeConfigFile(original, current, conf); ok {
+				configs[filename] = merged
+				changes = append(changes, fmt.Sprintf("ConfigFiles[%s] (merged)", filename))
+			} else {
+				configs[filename] = current
+				if svc.ConfigConflicts == nil {
+					svc.ConfigConflicts = make(map[string]service.ConfigConflict)
+				}
+				svc.ConfigConflicts[filename] = service.ConfigConflict{Template: conf, Merged: merged.Content}
+				changes = append(changes, fmt.Sprintf("ConfigFiles[%s] (conflict)", filename))
+			}
+		case inTemplate:
+			configs[filename] = conf
+			if !exists || !reflect.DeepEqual(current, conf) {
+				changes = append(changes, fmt.Sprintf("ConfigFiles[%s]", filename))
+			}
+		default:
+			changes = append(changes, fmt.Sprintf("ConfigFiles[%s] (removed)", filename))
+		}
+	}
+
+	svc.OriginalConfigs = sd.ConfigFiles
+	svc.ConfigFiles = configs
+	return changes
+}
+
+// mergeConfigFile merges the customizations of a config file with the changes
+// to its template.  Returns false if they conflict.
+func mergeConfigFile(original, current, conf servicedefinition.ConfigFile) (servicedefinition.ConfigFile, bool) {
+	merged := current
+	if current.Owner == original.Owner {
+		merged.Owner = conf.Owner
+	}
+	if current.Permissions == original.Permissions {
+		merged.Permissions = conf.Permissions
+	}
+	content, conflicts := diff.Merge3(original.Content, current.Content, conf.Content, "customized", "template")
+	merged.Content = content
+	return merged, conflicts == 0
+}
+
+// upgradeEqual compares two fields by their json, where empty and missing
+// slices and maps are the same
+func upgradeEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(upgradeNormalize(a), upgradeNormalize(b))
+}
+
+func upgradeNormalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return v
+	}
+	return upgradePrune(value)
+}
+
+// upgradePrune removes the empty values from the json form of a field
+func upgradePrune(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{})
+		for key, value := range t {
+			if value = upgradePrune(value); value != nil {
+				m[key] = value
+			}
+		}
+		if len(m) > 0 {
+			return m
+		}
+	case []interface{}:
+		if len(t) > 0 {
+			for i := range t {
+				t[i] = upgradePrune(t[i])
+			}
+			return t
+		}
+	default:
+		return v
+	}
+	return nil
+}
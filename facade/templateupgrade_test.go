@@ -0,0 +1,176 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package facade
+
+import (
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/domain"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicedefinition"
+	"github.com/control-center/serviced/domain/servicetemplate"
+	. "gopkg.in/check.v1"
+)
+
+func (ft *FacadeTest) TestUpgradeService(t *C) {
+	appConf := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "threads = 4\nlog = app.log\ntimeout = 30\n"}
+	dbConf := servicedefinition.ConfigFile{Filename: "/etc/db.conf", Content: "host = a\n"}
+	logConf := servicedefinition.ConfigFile{Filename: "/etc/log.conf", Content: "v1"}
+	editedAppConf := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "threads = 8\nlog = app.log\ntimeout = 30\n"}
+	editedDBConf := servicedefinition.ConfigFile{Filename: "/etc/db.conf", Content: "host = b\n"}
+	svc := service.Service{
+		ID:              "upgrade-service",
+		Name:            "app",
+		Startup:         "run v1",
+		Instances:       5,
+		InstanceLimits:  domain.MinMax{Min: 1},
+		Context:         map[string]interface{}{"threads": 8},
+		OriginalConfigs: map[string]servicedefinition.ConfigFile{appConf.Filename: appConf, dbConf.Filename: dbConf, logConf.Filename: logConf},
+		ConfigFiles:     map[string]servicedefinition.ConfigFile{appConf.Filename: editedAppConf, dbConf.Filename: editedDBConf, logConf.Filename: logConf},
+	}
+
+	newAppConf := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "threads = 4\nlog = app.log\ntimeout = 60\n"}
+	newDBConf := servicedefinition.ConfigFile{Filename: "/etc/db.conf", Content: "host = c\n"}
+	newLogConf := servicedefinition.ConfigFile{Filename: "/etc/log.conf", Content: "v2"}
+	sd := servicedefinition.ServiceDefinition{
+		Name:      "app",
+		Command:   "run v2",
+		Instances: domain.MinMax{Min: 1, Max: 3},
+		Context:   map[string]interface{}{"threads": 4, "timeout": 30},
+		ConfigFiles: map[string]servicedefinition.ConfigFile{
+			newAppConf.Filename: newAppConf,
+			newDBConf.Filename:  newDBConf,
+			newLogConf.Filename: newLogConf,
+		},
+	}
+
+	changes, err := upgradeService(&svc, sd)
+	t.Assert(err, IsNil)
+	t.Assert(changes, DeepEquals, []string{
+		"Startup",
+		"InstanceLimits",
+		"Instances",
+		"Context",
+		"ConfigFiles[/etc/app.conf] (merged)",
+		"ConfigFiles[/etc/db.conf] (conflict)",
+		"ConfigFiles[/etc/log.conf]",
+	})
+
+	t.Assert(svc.Startup, Equals, "run v2")
+	t.Assert(svc.Instances, Equals, 3)
+	t.Assert(svc.Context, DeepEquals, map[string]interface{}{"threads": 8, "timeout": 30})
+	t.Assert(svc.ConfigFiles, DeepEquals, map[string]servicedefinition.ConfigFile{
+		appConf.Filename:    {Filename: "/etc/app.conf", Content: "threads = 8\nlog = app.log\ntimeout = 60\n"},
+		dbConf.Filename:     editedDBConf,
+		newLogConf.Filename: newLogConf,
+	})
+	t.Assert(svc.OriginalConfigs, DeepEquals, sd.ConfigFiles)
+	t.Assert(svc.ConfigConflicts, DeepEquals, map[string]service.ConfigConflict{
+		dbConf.Filename: {
+			Template: newDBConf,
+			Merged:   "<<<<<<< customized\nhost = b\n=======\nhost = c\n>>>>>>> template\n",
+		},
+	})
+
+	// upgrading again changes nothing
+	changes, err = upgradeService(&svc, sd)
+	t.Assert(err, IsNil)
+	t.Assert(changes, HasLen, 0)
+}
+
+func (ft *FacadeTest) TestUpgradeTemplate_KeepsConfigFiles(t *C) {
+	appConf := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "v1"}
+	oldConf := servicedefinition.ConfigFile{Filename: "/etc/old.conf", Content: "a"}
+	editedOldConf := servicedefinition.ConfigFile{Filename: "/etc/old.conf", Content: "b"}
+	svc := service.Service{
+		ID:              "upgrade-reload-service",
+		Name:            "app",
+		PoolID:          "default",
+		DeploymentID:    "upgrade-reload",
+		Launch:          commons.AUTO,
+		Instances:       1,
+		InstanceLimits:  domain.MinMax{Min: 1},
+		OriginalConfigs: map[string]servicedefinition.ConfigFile{appConf.Filename: appConf, oldConf.Filename: oldConf},
+		ConfigFiles:     map[string]servicedefinition.ConfigFile{appConf.Filename: appConf, oldConf.Filename: editedOldConf},
+	}
+	t.Assert(ft.Facade.AddService(ft.CTX, svc), IsNil)
+
+	newAppConf := servicedefinition.ConfigFile{Filename: "/etc/app.conf", Content: "v2"}
+	template := servicetemplate.ServiceTemplate{
+		ID:   "upgrade-reload-template",
+		Name: "upgrade-reload",
+		Services: []servicedefinition.ServiceDefinition{{
+			Name:        "app",
+			Command:     "run",
+			Launch:      commons.AUTO,
+			Instances:   domain.MinMax{Min: 1},
+			ConfigFiles: map[string]servicedefinition.ConfigFile{newAppConf.Filename: newAppConf},
+		}},
+	}
+	t.Assert(ft.Facade.templateStore.Put(ft.CTX, template), IsNil)
+
+	plan, err := ft.Facade.UpgradeTemplate(ft.CTX, svc.DeploymentID, template.ID)
+	t.Assert(err, IsNil)
+	t.Assert(plan.Actions, HasLen, 1)
+
+	// the customized file that is no longer in the template is kept
+	upgraded, err := ft.Facade.GetService(ft.CTX, svc.ID)
+	t.Assert(err, IsNil)
+	t.Assert(upgraded.OriginalConfigs, DeepEquals, template.Services[0].ConfigFiles)
+	t.Assert(upgraded.ConfigFiles, DeepEquals, map[string]servicedefinition.ConfigFile{
+		newAppConf.Filename:    newAppConf,
+		editedOldConf.Filename: editedOldConf,
+	})
+}
+
+func (ft *FacadeTest) TestPlanTemplateUpgrade_DoesNotPull(t *C) {
+	defer func(pull func(string) error) {
+		pullImage = pull
+	}(pullImage)
+	var pulled []string
+	pullImage = func(image string) error {
+		pulled = append(pulled, image)
+		return nil
+	}
+
+	svc := service.Service{
+		ID:             "upgrade-plan-service",
+		Name:           "app",
+		PoolID:         "default",
+		DeploymentID:   "upgrade-plan",
+		Launch:         commons.AUTO,
+		Instances:      1,
+		InstanceLimits: domain.MinMax{Min: 1},
+	}
+	t.Assert(ft.Facade.AddService(ft.CTX, svc), IsNil)
+
+	template := servicetemplate.ServiceTemplate{
+		ID:   "upgrade-plan-template",
+		Name: "upgrade-plan",
+		Services: []servicedefinition.ServiceDefinition{{
+			Name:      "app",
+			Command:   "run",
+			ImageID:   "test/upgrade-plan",
+			Launch:    commons.AUTO,
+			Instances: domain.MinMax{Min: 1},
+		}},
+	}
+	t.Assert(ft.Facade.templateStore.Put(ft.CTX, template), IsNil)
+
+	plan, err := ft.Facade.PlanTemplateUpgrade(ft.CTX, svc.DeploymentID, template.ID)
+	t.Assert(err, IsNil)
+	t.Assert(pulled, HasLen, 0)
+	t.Assert(plan.Actions, HasLen, 1)
+	changes := plan.Actions[0].Changes
+	t.Assert(changes[len(changes)-1], Equals, "ImageID")
+}
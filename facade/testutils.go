@@ -50,6 +50,7 @@ func (ft *FacadeTest) SetUpSuite(c *gocheck.C) {
 	ft.Mappings = append(ft.Mappings, servicetemplate.MAPPING)
 	ft.Mappings = append(ft.Mappings, addressassignment.MAPPING)
 	ft.Mappings = append(ft.Mappings, serviceconfigfile.MAPPING)
+	ft.Mappings = append(ft.Mappings, serviceconfigfile.VERSION_MAPPING)
 	ft.Mappings = append(ft.Mappings, user.MAPPING)
 
 	ft.ElasticTest.SetUpSuite(c)
@@ -52,6 +52,7 @@ import (
 	"github.com/control-center/serviced/proxy"
 	"github.com/control-center/serviced/rpc/master"
 	"github.com/control-center/serviced/utils"
+	"github.com/control-center/serviced/validation"
 	"github.com/control-center/serviced/zzk"
 	zkdocker "github.com/control-center/serviced/zzk/docker"
 	zkservice "github.com/control-center/serviced/zzk/service"
@@ -87,6 +88,9 @@ type HostAgent struct {
 	zkClient             *coordclient.Client
 	dockerRegistry       string        // the docker registry to use
 	maxContainerAge      time.Duration // maximum age for a stopped container before it is removed
+	imageGCInterval      time.Duration // how often unused images are removed, 0 to never remove them
+	strictImages         bool          // refuse to start containers whose image is not the pinned one
+	readOnlyVolumes      bool          // mount the DFS volumes read-only, since the master overwrites them
 	virtualAddressSubnet string        // subnet for virtual addresses
 }
 
@@ -120,6 +124,9 @@ type AgentOptions struct {
 	Mux                  *proxy.TCPMux
 	DockerRegistry       string
 	MaxContainerAge      time.Duration // Maximum container age for a stopped container before being removed
+	ImageGCInterval      time.Duration // How often unused images are removed, 0 to never remove them
+	StrictImages         bool          // Refuse to start containers whose image is not the pinned one
+	ReadOnlyVolumes      bool          // Mount the DFS volumes read-only, for pools whose volumes are copied from the master
 	VirtualAddressSubnet string
 }
 
@@ -137,6 +144,9 @@ func NewHostAgent(options AgentOptions) (*HostAgent, error) {
 	agent.vfs = options.VFS
 	agent.mux = options.Mux
 	agent.maxContainerAge = options.MaxContainerAge
+	agent.imageGCInterval = options.ImageGCInterval
+	agent.strictImages = options.StrictImages
+	agent.readOnlyVolumes = options.ReadOnlyVolumes
 	agent.virtualAddressSubnet = options.VirtualAddressSubnet
 
 	dsn := getZkDSN(options.Zookeepers)
@@ -294,6 +304,81 @@ func (a *HostAgent) reapOldContainersLoop(interval time.Duration, shutdown <-cha
 	}
 }
 
+// reapImages removes the images of the master's registry that the master no
+// longer keeps, and the untagged images that are left behind when a newer
+// image is pulled.  Docker keeps the images that are used by a container.
+func (a *HostAgent) reapImages() error {
+	client, err := NewControlClient(a.master)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var kept dao.KeptImages
+	if err := client.GetKeptImages(0, &kept); err != nil {
+		return err
+	}
+	images, err := docker.Images()
+	if err != nil {
+		return err
+	}
+	for _, image := range unkeptImages(images, &kept) {
+		if err := image.Delete(); err != nil {
+			glog.V(1).Infof("Could not remove image %s: %s", image.ID, err)
+			continue
+		}
+		glog.Infof("Removed unused image %s", image.ID)
+	}
+
+	uuids, err := docker.DanglingImages()
+	if err != nil {
+		return err
+	}
+	for _, uuid := range uuids {
+		if err := docker.RemoveImage(uuid); err != nil {
+			glog.V(1).Infof("Could not remove image %s: %s", uuid, err)
+			continue
+		}
+		glog.Infof("Removed unused image %s", uuid)
+	}
+
+	return nil
+}
+
+// unkeptImages returns the images of the master's registry that are not in
+// the master's keep set
+func unkeptImages(images []*docker.Image, kept *dao.KeptImages) []*docker.Image {
+	keep := make(map[string]struct{})
+	for _, imageID := range kept.Images {
+		keep[imageID] = struct{}{}
+	}
+
+	var result []*docker.Image
+	for _, image := range images {
+		if image.ID.Registry() != kept.Registry {
+			continue
+		} else if _, ok := keep[image.ID.String()]; !ok {
+			result = append(result, image)
+		}
+	}
+	return result
+}
+
+func (a *HostAgent) reapImagesLoop(interval time.Duration, shutdown <-chan interface{}) {
+	glog.V(1).Infof("will reap unused images every %v", interval)
+
+	for {
+		select {
+		case <-time.After(interval):
+			if err := a.reapImages(); err != nil {
+				glog.Warningf("Could not reap unused images: %s", err)
+			}
+		case <-shutdown:
+			return
+		}
+	}
+}
+
 // Get the state of the docker container given the dockerId
 func getDockerState(dockerID string) (*docker.Container, error) {
 	glog.V(1).Infof("Inspecting container: %s", dockerID)
@@ -553,10 +638,17 @@ func configureContainer(a *HostAgent, client *ControlClient,
 	}
 
 	// Make sure the image exists locally.
-	if _, err = docker.FindImage(svc.ImageID, true); err != nil {
+	img, err := docker.FindImage(svc.ImageID, true)
+	if err != nil {
 		glog.Errorf("can't find docker image %s: %s", svc.ImageID, err)
 		return nil, nil, err
 	}
+	if a.strictImages {
+		if err := checkImageDigest(svc, img); err != nil {
+			glog.Errorf("Refusing to start service %s (%s): %s", svc.Name, svc.ID, err)
+			return nil, nil, err
+		}
+	}
 
 	cfg.Volumes = make(map[string]struct{})
 	hcfg.Binds = []string{}
@@ -578,6 +670,10 @@ func configureContainer(a *HostAgent, client *ControlClient,
 
 		binding := fmt.Sprintf("%s:%s", resourcePath, volume.ContainerPath)
 		cfg.Volumes[strings.Split(binding, ":")[1]] = struct{}{}
+		if a.readOnlyVolumes {
+			// writes would be overwritten by the next sync from the master
+			binding += ":ro"
+		}
 		hcfg.Binds = append(hcfg.Binds, strings.TrimSpace(binding))
 	}
 
@@ -689,9 +785,10 @@ func configureContainer(a *HostAgent, client *ControlClient,
 		}
 	}
 
-	// Add hostname if set
-	if svc.Hostname != "" {
-		cfg.Hostname = svc.Hostname
+	// Add hostname, if set, and the other networking options
+	if err := setNetworkOptions(cfg, hcfg, svc); err != nil {
+		glog.Errorf("Invalid networking options for service %s (%s): %s", svc.Name, svc.ID, err)
+		return nil, nil, err
 	}
 
 	cfg.Cmd = append([]string{},
@@ -719,10 +816,86 @@ func configureContainer(a *HostAgent, client *ControlClient,
 		cfg.CpuShares = svc.CPUShares
 	}
 
+	if err := setResourceLimits(cfg, svc.ResourceLimits); err != nil {
+		glog.Errorf("Invalid resource limits for service %s (%s): %s", svc.Name, svc.ID, err)
+		return nil, nil, err
+	}
+
 	return cfg, hcfg, nil
 }
 
+// setResourceLimits passes the resource limits of a service through to the
+// config of its container.  The pinned docker client only has a field for the
+// swap limit; validation rejects the other limits, and a service that still
+// sets one fails to start instead of running without it.
+func setResourceLimits(cfg *dockerclient.Config, limits servicedefinition.ResourceLimits) error {
+	if unsupported := limits.Unsupported(); len(unsupported) > 0 {
+		return fmt.Errorf("resource limits %s are not supported by the docker client", strings.Join(unsupported, ", "))
+	}
+
+	cfg.MemorySwap = limits.MemorySwap
+	return nil
+}
+
+// setNetworkOptions validates the hostname of a service, as evaluated for the
+// instance, and its networking options, and passes them through to the config
+// of its container.  The ports of exported endpoints must already be exposed.
+func setNetworkOptions(cfg *dockerclient.Config, hcfg *dockerclient.HostConfig, svc *service.Service) error {
+	network := svc.Network
+	if err := network.ValidEntity(); err != nil {
+		return err
+	}
+	endpoints := make([]servicedefinition.EndpointDefinition, len(svc.Endpoints))
+	for i, ep := range svc.Endpoints {
+		endpoints[i] = ep.EndpointDefinition
+	}
+	if err := network.ValidHostNetwork(svc.Hostname, endpoints, svc.Instances, svc.HostPolicy); err != nil {
+		return err
+	}
+
+	if svc.Hostname != "" {
+		if err := validation.IsHostname(svc.Hostname); err != nil {
+			return err
+		}
+		cfg.Hostname = svc.Hostname
+	}
+
+	// the ports of exported endpoints are already open on the network of the
+	// host, and docker cannot publish ports there
+	if network.HostNetwork {
+		hcfg.NetworkMode = "host"
+		hcfg.PortBindings = make(map[dockerclient.Port][]dockerclient.PortBinding)
+	}
+
+	for _, pm := range network.Ports {
+		protocol := pm.Protocol
+		if protocol == "" {
+			protocol = commons.TCP
+		}
+		port := dockerclient.Port(fmt.Sprintf("%d/%s", pm.ContainerPort, protocol))
+		if _, found := cfg.ExposedPorts[port]; found {
+			return fmt.Errorf("port %s is already exposed by an exported endpoint", port)
+		}
+		binding := dockerclient.PortBinding{HostIP: pm.HostIP}
+		if pm.HostPort != 0 {
+			binding.HostPort = strconv.Itoa(int(pm.HostPort))
+		}
+		cfg.ExposedPorts[port] = struct{}{}
+		hcfg.PortBindings[port] = []dockerclient.PortBinding{binding}
+	}
+	return nil
+}
+
 // setupVolume
+// checkImageDigest verifies that the image of a service is the one that the
+// service is pinned to.  Services that are not pinned run any image.
+func checkImageDigest(svc *service.Service, img *docker.Image) error {
+	if svc.ImageDigest != "" && img.UUID != svc.ImageDigest {
+		return fmt.Errorf("image %s is %s, but the service is pinned to %s", svc.ImageID, img.UUID, svc.ImageDigest)
+	}
+	return nil
+}
+
 func (a *HostAgent) setupVolume(tenantID string, service *service.Service, volume servicedefinition.Volume) (string, error) {
 	glog.V(4).Infof("setupVolume for service Name:%s ID:%s", service.Name, service.ID)
 	sv, err := dfs.GetSubvolume(a.vfs, a.varPath, tenantID)
@@ -777,6 +950,16 @@ func (a *HostAgent) Start(shutdown <-chan interface{}) {
 		wg.Done()
 	}()
 
+	if a.imageGCInterval > 0 {
+		wg.Add(1)
+		go func() {
+			glog.Info("reapImagesLoop starting")
+			a.reapImagesLoop(a.imageGCInterval, shutdown)
+			glog.Info("reapImagesLoop Done")
+			wg.Done()
+		}()
+	}
+
 	for {
 		// handle shutdown if we are waiting for a zk connection
 		var conn coordclient.Connection
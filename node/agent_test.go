@@ -15,7 +15,15 @@ package node
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
+
+	"github.com/control-center/serviced/commons"
+	"github.com/control-center/serviced/commons/docker"
+	"github.com/control-center/serviced/dao"
+	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/servicedefinition"
+	dockerclient "github.com/zenoss/go-dockerclient"
 )
 
 const example_state = `
@@ -104,3 +112,160 @@ func TestParseContainerState(t *testing.T) {
 		t.Fatalf("Problem unmarshaling test state: %s", err)
 	}
 }
+
+// Test passing the resource limits of a service to its container.
+func TestSetResourceLimits(t *testing.T) {
+	cfg := &dockerclient.Config{}
+	if err := setResourceLimits(cfg, servicedefinition.ResourceLimits{MemorySwap: -1}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if cfg.MemorySwap != -1 {
+		t.Errorf("Expected MemorySwap -1, got %d", cfg.MemorySwap)
+	}
+
+	// the limits that the docker client cannot pass are rejected
+	for _, limits := range []servicedefinition.ResourceLimits{
+		{CPUQuota: 200000, CPUPeriod: 100000},
+		{PidsLimit: 512},
+		{BlkioWeight: 300},
+		{BlkioThrottles: []servicedefinition.BlkioThrottle{{Device: "/dev/sda", ReadBps: 1048576}}},
+		{Ulimits: []servicedefinition.Ulimit{{Name: "nofile", Soft: 1024, Hard: 4096}}},
+	} {
+		if err := setResourceLimits(&dockerclient.Config{}, limits); err == nil {
+			t.Errorf("Expected an error for %+v", limits)
+		}
+	}
+}
+
+// Test passing the hostname and networking options of a service to its
+// container.
+func TestSetNetworkOptions(t *testing.T) {
+	newConfigs := func() (*dockerclient.Config, *dockerclient.HostConfig) {
+		cfg := &dockerclient.Config{ExposedPorts: map[dockerclient.Port]struct{}{"8080/tcp": struct{}{}}}
+		hcfg := &dockerclient.HostConfig{PortBindings: map[dockerclient.Port][]dockerclient.PortBinding{"8080/tcp": {{}}}}
+		return cfg, hcfg
+	}
+	svc := &service.Service{
+		Hostname: "collector-2",
+		Network: servicedefinition.NetworkOptions{
+			Ports: []servicedefinition.PortMapping{
+				{ContainerPort: 514, HostPort: 1514, Protocol: "udp"},
+				{ContainerPort: 9000, HostIP: "127.0.0.1"},
+			},
+		},
+	}
+	cfg, hcfg := newConfigs()
+	if err := setNetworkOptions(cfg, hcfg, svc); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if cfg.Hostname != "collector-2" {
+		t.Errorf("Expected hostname collector-2, got %s", cfg.Hostname)
+	}
+	if hcfg.NetworkMode != "" {
+		t.Errorf("Expected the default network, got %s", hcfg.NetworkMode)
+	}
+	bindings := map[dockerclient.Port][]dockerclient.PortBinding{
+		"8080/tcp": {{}},
+		"514/udp":  {{HostPort: "1514"}},
+		"9000/tcp": {{HostIP: "127.0.0.1"}},
+	}
+	if !reflect.DeepEqual(hcfg.PortBindings, bindings) {
+		t.Errorf("Expected port bindings %v, got %v", bindings, hcfg.PortBindings)
+	}
+	if len(cfg.ExposedPorts) != 3 {
+		t.Errorf("Expected 3 exposed ports, got %v", cfg.ExposedPorts)
+	}
+
+	// the docker client cannot pass dns search domains or extra hosts
+	for _, network := range []servicedefinition.NetworkOptions{
+		{DNSSearch: []string{"example.com"}},
+		{ExtraHosts: []servicedefinition.ExtraHost{{Hostname: "db", IPAddr: "10.0.0.5"}}},
+	} {
+		cfg, hcfg = newConfigs()
+		if err := setNetworkOptions(cfg, hcfg, &service.Service{Network: network}); err == nil {
+			t.Errorf("Expected an error for %+v", network)
+		}
+	}
+
+	// an evaluated hostname must be valid
+	svc = &service.Service{Hostname: "collector_2"}
+	cfg, hcfg = newConfigs()
+	if err := setNetworkOptions(cfg, hcfg, svc); err == nil {
+		t.Errorf("Expected an error for hostname %s", svc.Hostname)
+	}
+
+	// extra ports cannot take the port of an exported endpoint
+	svc = &service.Service{Network: servicedefinition.NetworkOptions{
+		Ports: []servicedefinition.PortMapping{{ContainerPort: 8080, HostPort: 80}},
+	}}
+	cfg, hcfg = newConfigs()
+	if err := setNetworkOptions(cfg, hcfg, svc); err == nil {
+		t.Errorf("Expected an error for port 8080/tcp")
+	}
+
+	// host networking
+	svc = &service.Service{Network: servicedefinition.NetworkOptions{HostNetwork: true}}
+	cfg, hcfg = newConfigs()
+	if err := setNetworkOptions(cfg, hcfg, svc); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if hcfg.NetworkMode != "host" {
+		t.Errorf("Expected the host network, got %s", hcfg.NetworkMode)
+	}
+	// the exported endpoint is not published on the network of the host
+	if len(hcfg.PortBindings) != 0 {
+		t.Errorf("Expected no port bindings, got %v", hcfg.PortBindings)
+	}
+	svc.Hostname = "collector"
+	cfg, hcfg = newConfigs()
+	if err := setNetworkOptions(cfg, hcfg, svc); err == nil {
+		t.Errorf("Expected an error for a hostname on the host network")
+	}
+}
+
+func TestUnkeptImages(t *testing.T) {
+	var images []*docker.Image
+	for _, tag := range []string{
+		"master:5000/tenant/core:latest",
+		"master:5000/tenant/core:20141201-120000",
+		"master:5000/tenant/core:20141101-120000", // collected on the master
+		"master:5000/removed/core:latest",         // collected on the master
+		"zenoss/core:20141101-120000",             // not in the registry
+	} {
+		imageID, err := commons.ParseImageID(tag)
+		if err != nil {
+			t.Fatalf("Could not parse %s: %s", tag, err)
+		}
+		images = append(images, &docker.Image{UUID: tag, ID: *imageID})
+	}
+	kept := &dao.KeptImages{
+		Registry: "master:5000",
+		Images:   []string{"master:5000/tenant/core:20141201-120000", "master:5000/tenant/core:latest"},
+	}
+
+	var actual []string
+	for _, image := range unkeptImages(images, kept) {
+		actual = append(actual, image.ID.String())
+	}
+	expected := []string{"master:5000/tenant/core:20141101-120000", "master:5000/removed/core:latest"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Mismatch (Expected: %v) (Actual: %v)", expected, actual)
+	}
+}
+
+func TestCheckImageDigest(t *testing.T) {
+	img := &docker.Image{UUID: "abc"}
+
+	svc := &service.Service{ImageID: "localhost:5000/tenant/core", ImageDigest: "abc"}
+	if err := checkImageDigest(svc, img); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	svc.ImageDigest = "def"
+	if err := checkImageDigest(svc, img); err == nil {
+		t.Errorf("Expected an error for an image that differs from the pin")
+	}
+	svc.ImageDigest = ""
+	if err := checkImageDigest(svc, img); err != nil {
+		t.Errorf("Unexpected error for a service that is not pinned: %s", err)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/control-center/serviced/domain"
 	"github.com/control-center/serviced/domain/addressassignment"
 	"github.com/control-center/serviced/domain/service"
+	"github.com/control-center/serviced/domain/serviceconfigfile"
 	"github.com/control-center/serviced/domain/servicestate"
 	"github.com/control-center/serviced/domain/servicetemplate"
 	"github.com/control-center/serviced/domain/user"
@@ -109,6 +110,18 @@ func (s *ControlClient) GetServiceAddressAssignments(serviceID string, addresses
 	return s.rpcClient.Call("ControlPlane.GetServiceAddressAssignments", serviceID, addresses)
 }
 
+func (s *ControlClient) GetServiceConfigVersions(request dao.ServiceConfigRequest, versions *[]serviceconfigfile.ConfigVersion) (err error) {
+	return s.rpcClient.Call("ControlPlane.GetServiceConfigVersions", request, versions)
+}
+
+func (s *ControlClient) RevertServiceConfig(request dao.ServiceConfigRequest, unused *int) (err error) {
+	return s.rpcClient.Call("ControlPlane.RevertServiceConfig", request, unused)
+}
+
+func (s *ControlClient) ApplyServices(request dao.ServiceApplyRequest, plan *dao.ServiceApplyPlan) (err error) {
+	return s.rpcClient.Call("ControlPlane.ApplyServices", request, plan)
+}
+
 func (s *ControlClient) GetServiceLogs(serviceId string, logs *string) error {
 	return s.rpcClient.Call("ControlPlane.GetServiceLogs", serviceId, logs)
 }
@@ -169,6 +182,14 @@ func (s *ControlClient) DeployTemplate(request dao.ServiceTemplateDeploymentRequ
 	return s.rpcClient.Call("ControlPlane.DeployTemplate", request, tenantId)
 }
 
+func (s *ControlClient) ExportTemplate(tenantID string, serviceTemplate *servicetemplate.ServiceTemplate) error {
+	return s.rpcClient.Call("ControlPlane.ExportTemplate", tenantID, serviceTemplate)
+}
+
+func (s *ControlClient) UpgradeTemplate(request dao.ServiceTemplateUpgradeRequest, plan *dao.ServiceTemplateUpgradePlan) error {
+	return s.rpcClient.Call("ControlPlane.UpgradeTemplate", request, plan)
+}
+
 func (s *ControlClient) DeployTemplateStatus(request dao.ServiceTemplateDeploymentRequest, status *string) error {
 	return s.rpcClient.Call("ControlPlane.DeployTemplateStatus", request, status)
 }
@@ -197,6 +218,10 @@ func (s *ControlClient) GetVolume(serviceID string, volume *volume.Volume) error
 	return s.rpcClient.Call("ControlPlane.GetVolume", serviceID, volume)
 }
 
+func (s *ControlClient) GetVolumeStatus(unused int, status *[]dao.VolumeStatus) error {
+	return s.rpcClient.Call("ControlPlane.GetVolumeStatus", unused, status)
+}
+
 func (s *ControlClient) DeleteSnapshot(snapshotId string, unused *int) error {
 	return s.rpcClient.Call("ControlPlane.DeleteSnapshot", snapshotId, unused)
 }
@@ -217,6 +242,26 @@ func (s *ControlClient) AsyncSnapshot(serviceId string, label *string) error {
 	return s.rpcClient.Call("ControlPlane.AsyncSnapshot", serviceId, label)
 }
 
+func (s *ControlClient) DiffSnapshots(request dao.SnapshotDiffRequest, diff *dao.SnapshotDiff) error {
+	return s.rpcClient.Call("ControlPlane.DiffSnapshots", request, diff)
+}
+
+func (s *ControlClient) CloneSnapshot(request dao.CloneSnapshotRequest, tenantID *string) error {
+	return s.rpcClient.Call("ControlPlane.CloneSnapshot", request, tenantID)
+}
+
+func (s *ControlClient) ExportSnapshot(request dao.ExportSnapshotRequest, filename *string) error {
+	return s.rpcClient.Call("ControlPlane.ExportSnapshot", request, filename)
+}
+
+func (s *ControlClient) ImportSnapshot(filename string, tenantID *string) error {
+	return s.rpcClient.Call("ControlPlane.ImportSnapshot", filename, tenantID)
+}
+
+func (s *ControlClient) CollectImages(dryRun bool, images *[]string) error {
+	return s.rpcClient.Call("ControlPlane.CollectImages", dryRun, images)
+}
+
 func (s *ControlClient) ListSnapshots(serviceId string, labels *[]string) error {
 	return s.rpcClient.Call("ControlPlane.ListSnapshots", serviceId, labels)
 }
@@ -225,6 +270,10 @@ func (s *ControlClient) Commit(containerId string, label *string) error {
 	return s.rpcClient.Call("ControlPlane.Commit", containerId, label)
 }
 
+func (s *ControlClient) GetKeptImages(unused int, kept *dao.KeptImages) error {
+	return s.rpcClient.Call("ControlPlane.GetKeptImages", unused, kept)
+}
+
 func (s *ControlClient) ReadyDFS(unused bool, unusedint *int) error {
 	return s.rpcClient.Call("ControlPlane.ReadyDFS", unused, unusedint)
 }
@@ -245,6 +294,14 @@ func (s *ControlClient) AsyncRestore(backupFilePath string, unused *int) error {
 	return s.rpcClient.Call("ControlPlane.AsyncRestore", backupFilePath, unused)
 }
 
+func (s *ControlClient) RestoreTenant(request dao.RestoreTenantRequest, unused *int) error {
+	return s.rpcClient.Call("ControlPlane.RestoreTenant", request, unused)
+}
+
+func (s *ControlClient) VerifyBackup(backupFilePath string, unused *int) error {
+	return s.rpcClient.Call("ControlPlane.VerifyBackup", backupFilePath, unused)
+}
+
 func (s *ControlClient) BackupStatus(notUsed int, backupStatus *string) error {
 	return s.rpcClient.Call("ControlPlane.BackupStatus", notUsed, backupStatus)
 }
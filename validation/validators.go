@@ -16,9 +16,13 @@ package validation
 import (
 	"fmt"
 	"net"
+	"regexp"
 	"strings"
 )
 
+// hostnameLabel is a label of a hostname, as in RFC 1123
+var hostnameLabel = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
 //NotEmpty check to see if the value is not an empty string or a string with just whitespace characters, returns an
 // error if empty. FieldName is used to create a meaningful error
 func NotEmpty(fieldName string, value string) error {
@@ -36,6 +40,19 @@ func IsIP(value string) error {
 	return nil
 }
 
+//IsHostname checks to see if the value is a valid hostname or domain name. Returns an error if not valid
+func IsHostname(value string) error {
+	if len(value) == 0 || len(value) > 253 {
+		return NewViolation(fmt.Sprintf("invalid hostname %q", value))
+	}
+	for _, label := range strings.Split(value, ".") {
+		if !hostnameLabel.MatchString(label) {
+			return NewViolation(fmt.Sprintf("invalid hostname %q", value))
+		}
+	}
+	return nil
+}
+
 //IsSubnet16 checks to see if the value is a valid /16 subnet.  Returns an error if not valid
 func IsSubnet16(value string) error {
 	parts := strings.Split(value, ".")
@@ -14,6 +14,7 @@
 package validation
 
 import (
+	"strings"
 	"testing"
 
 	. "gopkg.in/check.v1"
@@ -64,3 +65,37 @@ func (vs *ValidationSuite) Test_IsSubnet16(c *C) {
 		}
 	}
 }
+
+func (vs *ValidationSuite) Test_IsHostname(c *C) {
+
+	hostnamesValid := []string{
+		"localhost",
+		"zenoss-1",
+		"db.example.com",
+		"1.example.com",
+		"a",
+	}
+
+	for _, hostname := range hostnamesValid {
+		if err := IsHostname(hostname); err != nil {
+			c.Fatalf("Unexpected error validating valid hostname %s: %v", hostname, err)
+		}
+	}
+
+	hostnamesInvalid := []string{
+		"",
+		"-zenoss",
+		"zenoss-",
+		"zen_oss",
+		"db..example.com",
+		"example.com.",
+		"db example",
+		strings.Repeat("a", 64),
+	}
+
+	for _, hostname := range hostnamesInvalid {
+		if err := IsHostname(hostname); err == nil {
+			c.Fatalf("Unexpected non-error validating invalid hostname %s: %v", hostname, err)
+		}
+	}
+}
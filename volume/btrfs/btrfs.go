@@ -21,6 +21,7 @@ import (
 	"os/exec"
 	"os/user"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -220,6 +221,40 @@ func (c *BtrfsConn) Rollback(label string) error {
 	return err
 }
 
+// Usage returns the number of bytes referenced by the subvolume, according to
+// its qgroup.  If quotas are not enabled on the filesystem, du is used instead.
+func (c *BtrfsConn) Usage() (uint64, error) {
+	c.Lock()
+	defer c.Unlock()
+	if output, err := runcmd(c.sudoer, "qgroup", "show", "--raw", "-f", c.Path()); err == nil {
+		for _, line := range strings.Split(string(output), "\n") {
+			if fields := strings.Fields(line); len(fields) > 1 && strings.HasPrefix(fields[0], "0/") {
+				if used, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+					return used, nil
+				}
+			}
+		}
+	}
+	glog.V(2).Infof("Could not get qgroup usage of %s, falling back to du", c.Path())
+	return volume.DiskUsage(c.Path())
+}
+
+// SetLimit enables quotas on the filesystem and limits the size of the
+// subvolume
+func (c *BtrfsConn) SetLimit(bytes uint64) error {
+	c.Lock()
+	defer c.Unlock()
+	if output, err := runcmd(c.sudoer, "quota", "enable", c.root); err != nil {
+		glog.Errorf("Could not enable quotas on %s: %s", c.root, string(output))
+		return err
+	}
+	if output, err := runcmd(c.sudoer, "qgroup", "limit", strconv.FormatUint(bytes, 10), c.Path()); err != nil {
+		glog.Errorf("Could not limit %s to %d bytes: %s", c.Path(), bytes, string(output))
+		return err
+	}
+	return nil
+}
+
 // snapshotExists queries the snapshot existence for the given label
 func (c *BtrfsConn) snapshotExists(label string) (exists bool, err error) {
 	if snapshots, err := c.Snapshots(); err != nil {
@@ -21,6 +21,8 @@ import (
 	"os/user"
 	"reflect"
 	"testing"
+
+	"github.com/control-center/serviced/volume/drivertest"
 )
 
 var btrfsTestVolumePath = "/var/lib/serviced"
@@ -105,3 +107,25 @@ func TestBtrfsVolume(t *testing.T) {
 
 	}
 }
+
+func TestBtrfsConformance(t *testing.T) {
+	if user, err := user.Current(); err != nil {
+		panic(err)
+	} else if user.Uid != "0" {
+		t.Skip("Skipping BTRFS tests because we are not running as root")
+	}
+
+	if _, err := exec.LookPath("btrfs"); err != nil {
+		t.Skip("Skipping BTRFS tests because btrfs-tools were not found in the path")
+	}
+
+	if err := os.MkdirAll(btrfsTestVolumePath, 0775); err != nil {
+		t.Fatalf("Could not create test volume path: %s : %s", btrfsTestVolumePath, err)
+	}
+
+	btrfsd, err := New()
+	if err != nil {
+		t.Fatalf("Unable to create btrfs driver: %v", err)
+	}
+	drivertest.RunDriverTests(t, btrfsd, btrfsTestVolumePath)
+}
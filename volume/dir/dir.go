@@ -0,0 +1,290 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dir
+
+import (
+	"github.com/control-center/serviced/volume"
+	"github.com/zenoss/glog"
+
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// DriverName is the name of this directory volume driver implementation
+	DriverName = "dir"
+)
+
+// DirDriver is a driver for volumes that are plain directories.  Snapshots
+// are full copies made without any external tools, which makes the driver
+// suitable for tests.
+type DirDriver struct {
+	sync.Mutex
+}
+
+// DirConn is a connection to a directory volume
+type DirConn struct {
+	name string
+	root string
+	sync.Mutex
+}
+
+func init() {
+	dirdriver, err := New()
+	if err != nil {
+		glog.Errorf("Can't create dir driver: %s", err)
+		return
+	}
+
+	volume.Register(DriverName, dirdriver)
+}
+
+// New creates a new DirDriver
+func New() (*DirDriver, error) {
+	return &DirDriver{}, nil
+}
+
+// Mount creates the volume directory at the given root dir
+func (d *DirDriver) Mount(volumeName, rootDir string) (volume.Conn, error) {
+	d.Lock()
+	defer d.Unlock()
+	conn := &DirConn{name: volumeName, root: rootDir}
+	if err := os.MkdirAll(conn.Path(), 0775); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// List lists all of the folders at the given root dir
+func (d *DirDriver) List(rootDir string) (result []string) {
+	if files, err := ioutil.ReadDir(rootDir); err != nil {
+		glog.Errorf("Error trying to read from root directory: %s", rootDir)
+	} else {
+		for _, fi := range files {
+			if fi.IsDir() {
+				result = append(result, fi.Name())
+			}
+		}
+	}
+
+	return
+}
+
+// Name provides the name of the volume
+func (c *DirConn) Name() string {
+	return c.name
+}
+
+// Path provides the full path to the volume
+func (c *DirConn) Path() string {
+	return path.Join(c.root, c.name)
+}
+
+// SnapshotPath provides the full path to the snapshot
+func (c *DirConn) SnapshotPath(label string) string {
+	return path.Join(c.root, label)
+}
+
+// Snapshot copies the volume to the snapshot directory
+func (c *DirConn) Snapshot(label string) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.checkLabel(label); err != nil {
+		return err
+	}
+	dest := c.SnapshotPath(label)
+	if exists, err := volume.IsDir(dest); exists || err != nil {
+		if exists {
+			return fmt.Errorf("snapshot %s already exists", label)
+		}
+		return err
+	}
+
+	if err := copyDir(c.Path(), dest); err != nil {
+		glog.Errorf("Could not snapshot %s to %s: %s", c.Path(), dest, err)
+		os.RemoveAll(dest)
+		return err
+	}
+	return nil
+}
+
+// Snapshots returns the current snapshots on the volume
+func (c *DirConn) Snapshots() ([]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.snapshots()
+}
+
+// RemoveSnapshot removes the snapshot with the given label
+func (c *DirConn) RemoveSnapshot(label string) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.checkLabel(label); err != nil {
+		return err
+	}
+	return os.RemoveAll(c.SnapshotPath(label))
+}
+
+// Unmount deletes the volume and snapshots
+func (c *DirConn) Unmount() error {
+	c.Lock()
+	defer c.Unlock()
+	snapshots, err := c.snapshots()
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range snapshots {
+		if err := os.RemoveAll(c.SnapshotPath(snapshot)); err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(c.Path())
+}
+
+// Rollback replaces the contents of the volume with the contents of the
+// given snapshot
+func (c *DirConn) Rollback(label string) error {
+	c.Lock()
+	defer c.Unlock()
+	src := c.SnapshotPath(label)
+	if exists, err := volume.IsDir(src); !exists || err != nil {
+		if !exists {
+			return fmt.Errorf("snapshot %s does not exist", label)
+		}
+		return err
+	}
+
+	// keep the volume directory itself, since it may be exported
+	files, err := ioutil.ReadDir(c.Path())
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if err := os.RemoveAll(path.Join(c.Path(), fi.Name())); err != nil {
+			return err
+		}
+	}
+	return copyDir(src, c.Path())
+}
+
+// Usage returns the total size of the files in the volume
+func (c *DirConn) Usage() (uint64, error) {
+	c.Lock()
+	defer c.Unlock()
+	var size uint64
+	err := filepath.Walk(c.Path(), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		size += uint64(info.Size())
+		return nil
+	})
+	return size, err
+}
+
+func (c *DirConn) snapshots() ([]string, error) {
+	infos, err := ioutil.ReadDir(c.root)
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]string, 0)
+	for _, info := range infos {
+		if info.IsDir() && strings.HasPrefix(info.Name(), c.name+"_") {
+			labels = append(labels, info.Name())
+		}
+	}
+	return labels, nil
+}
+
+// checkLabel makes sure the label refers to a snapshot of this volume
+func (c *DirConn) checkLabel(label string) error {
+	if !strings.HasPrefix(label, c.name+"_") || strings.ContainsRune(label, '/') {
+		return fmt.Errorf("label %s refers to some other volume", label)
+	}
+	return nil
+}
+
+// copyDir recursively copies the contents of src into dest, preserving
+// permissions, ownership and symlinks
+func copyDir(src, dest string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, mode.Perm()); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode.Perm()); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			if err := copyFile(p, target, mode.Perm()); err != nil {
+				return err
+			}
+		default:
+			glog.Warningf("Skipping special file %s", p)
+			return nil
+		}
+
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			if err := os.Lchown(target, int(stat.Uid), int(stat.Gid)); err != nil && !os.IsPermission(err) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dest string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,36 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/control-center/serviced/volume/drivertest"
+)
+
+func TestDirVolume(t *testing.T) {
+	root, err := ioutil.TempDir("", "dir-volume-test")
+	if err != nil {
+		t.Fatalf("Could not create test volume path: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	dird, err := New()
+	if err != nil {
+		t.Fatalf("Unable to create dir driver: %v", err)
+	}
+	drivertest.RunDriverTests(t, dird, root)
+}
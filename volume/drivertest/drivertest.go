@@ -0,0 +1,165 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package drivertest provides a conformance suite that every volume.Driver
+// implementation is expected to pass.
+package drivertest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/control-center/serviced/volume"
+)
+
+const volumeName = "conformance"
+
+// RunDriverTests mounts a volume with the given driver below root and checks
+// that writes, snapshots, rollbacks and unmounts behave as dfs expects.
+func RunDriverTests(t *testing.T, driver volume.Driver, root string) {
+	c, err := driver.Mount(volumeName, root)
+	if err != nil {
+		t.Fatalf("Could not mount volume %s: %s", volumeName, err)
+	}
+	defer func() {
+		if c != nil {
+			c.Unmount()
+		}
+	}()
+
+	// mount
+	if c.Name() != volumeName {
+		t.Errorf("Expected volume name %s, got %s", volumeName, c.Name())
+	}
+	if dirp, err := volume.IsDir(c.Path()); err != nil || !dirp {
+		t.Fatalf("Volume path %s is not a directory (%v)", c.Path(), err)
+	}
+	if !contains(driver.List(root), volumeName) {
+		t.Errorf("Volume %s was not listed in %s", volumeName, root)
+	}
+
+	// write
+	data1, data2 := []byte("cheese and crackers\n"), []byte("peanut butter and jelly\n")
+	writeFile(t, c.Path(), "a.txt", data1)
+	writeFile(t, c.Path(), "dir/b.txt", data2)
+	if used, err := c.Usage(); err != nil {
+		t.Errorf("Could not get usage of %s: %s", volumeName, err)
+	} else if used == 0 {
+		t.Errorf("Expected usage of %s to be greater than zero", volumeName)
+	}
+
+	// snapshot
+	label := volumeName + "_snapshot"
+	if err := c.Snapshot(label); err != nil {
+		t.Fatalf("Could not snapshot %s: %s", label, err)
+	}
+	if err := c.Snapshot(label); err == nil {
+		t.Errorf("Expected error creating duplicate snapshot %s", label)
+	}
+	checkFile(t, c.SnapshotPath(label), "a.txt", data1)
+
+	// list
+	if snapshots, err := c.Snapshots(); err != nil {
+		t.Fatalf("Could not list snapshots: %s", err)
+	} else if len(snapshots) != 1 || snapshots[0] != label {
+		t.Errorf("Expected snapshots [%s], got %v", label, snapshots)
+	}
+
+	// rollback restores content
+	writeFile(t, c.Path(), "a.txt", data2)
+	writeFile(t, c.Path(), "c.txt", data2)
+	if err := os.RemoveAll(path.Join(c.Path(), "dir")); err != nil {
+		t.Fatalf("Could not remove %s: %s", path.Join(c.Path(), "dir"), err)
+	}
+	checkFile(t, c.SnapshotPath(label), "a.txt", data1)
+	if err := c.Rollback(label); err != nil {
+		t.Fatalf("Could not roll back to %s: %s", label, err)
+	}
+	checkFile(t, c.Path(), "a.txt", data1)
+	checkFile(t, c.Path(), "dir/b.txt", data2)
+	if _, err := os.Stat(path.Join(c.Path(), "c.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected c.txt to be removed by rollback (%v)", err)
+	}
+	if err := c.Rollback(volumeName + "_missing"); err == nil {
+		t.Errorf("Expected error rolling back to a missing snapshot")
+	}
+
+	// remove snapshot
+	if err := c.RemoveSnapshot(label); err != nil {
+		t.Fatalf("Could not remove snapshot %s: %s", label, err)
+	}
+	if snapshots, err := c.Snapshots(); err != nil {
+		t.Fatalf("Could not list snapshots: %s", err)
+	} else if len(snapshots) != 0 {
+		t.Errorf("Expected no snapshots, got %v", snapshots)
+	}
+
+	// unmount
+	if err := c.Snapshot(label); err != nil {
+		t.Fatalf("Could not snapshot %s: %s", label, err)
+	}
+	if err := c.Unmount(); err != nil {
+		t.Fatalf("Could not unmount %s: %s", volumeName, err)
+	}
+	if dirp, err := volume.IsDir(c.Path()); err != nil || dirp {
+		t.Errorf("Expected volume path %s to be removed (%v)", c.Path(), err)
+	}
+	if dirp, err := volume.IsDir(c.SnapshotPath(label)); err != nil || dirp {
+		t.Errorf("Expected snapshot path %s to be removed (%v)", c.SnapshotPath(label), err)
+	}
+
+	// remount
+	if c, err = driver.Mount(volumeName, root); err != nil {
+		t.Fatalf("Could not remount volume %s: %s", volumeName, err)
+	}
+	writeFile(t, c.Path(), "a.txt", data1)
+	checkFile(t, c.Path(), "a.txt", data1)
+	if snapshots, err := c.Snapshots(); err != nil {
+		t.Fatalf("Could not list snapshots: %s", err)
+	} else if len(snapshots) != 0 {
+		t.Errorf("Expected no snapshots after remount, got %v", snapshots)
+	}
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) {
+	filename := path.Join(dir, name)
+	if err := os.MkdirAll(path.Dir(filename), 0755); err != nil {
+		t.Fatalf("Could not create directory for %s: %s", filename, err)
+	}
+	if err := ioutil.WriteFile(filename, data, 0664); err != nil {
+		t.Fatalf("Could not write %s: %s", filename, err)
+	}
+}
+
+func checkFile(t *testing.T, dir, name string, expected []byte) {
+	filename := path.Join(dir, name)
+	if actual, err := ioutil.ReadFile(filename); err != nil {
+		t.Errorf("Could not read %s: %s", filename, err)
+	} else if !bytes.Equal(actual, expected) {
+		t.Errorf("Expected %q in %s, got %q", expected, filename, actual)
+	}
+}
+
+// contains checks the list by base name, since some drivers list volumes by
+// their full path
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if path.Base(item) == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,379 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lvm
+
+import (
+	"github.com/control-center/serviced/volume"
+	"github.com/zenoss/glog"
+
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// DriverName is the name of this lvm driver implementation
+	DriverName = "lvm"
+)
+
+// Environment variables used to configure the thin pool backing the volumes
+const (
+	VolumeGroupEnv = "SERVICED_LVM_VOLUME_GROUP"
+	ThinPoolEnv    = "SERVICED_LVM_THINPOOL"
+	VolumeSizeEnv  = "SERVICED_LVM_VOLUME_SIZE"
+	FSTypeEnv      = "SERVICED_LVM_FSTYPE"
+)
+
+var (
+	defaultVolumeGroup = "serviced"
+	defaultThinPool    = "thinpool"
+	defaultVolumeSize  = "100G"
+	defaultFSType      = "xfs"
+)
+
+// LVMDriver is a driver for thin provisioned logical volumes
+type LVMDriver struct {
+	sudoer bool
+	vg     string
+	pool   string
+	size   string
+	fstype string
+	sync.Mutex
+}
+
+// LVMConn is a connection to a thin provisioned logical volume
+type LVMConn struct {
+	sudoer bool
+	vg     string
+	fstype string
+	name   string
+	root   string
+	sync.Mutex
+}
+
+func init() {
+	lvmdriver, err := New()
+	if err != nil {
+		glog.Errorf("Can't create lvm driver: %s", err)
+		return
+	}
+
+	volume.Register(DriverName, lvmdriver)
+}
+
+// New creates a new LVMDriver
+func New() (*LVMDriver, error) {
+	user, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LVMDriver{
+		vg:     getenv(VolumeGroupEnv, defaultVolumeGroup),
+		pool:   getenv(ThinPoolEnv, defaultThinPool),
+		size:   getenv(VolumeSizeEnv, defaultVolumeSize),
+		fstype: getenv(FSTypeEnv, defaultFSType),
+	}
+	if user.Uid != "0" {
+		err := exec.Command("sudo", "-n", "lvs", "--version").Run()
+		result.sudoer = err == nil
+	}
+
+	return result, nil
+}
+
+// Mount creates a new thin volume in the thin pool, if it does not already
+// exist, and mounts it at the given root dir
+func (d *LVMDriver) Mount(volumeName, rootDir string) (volume.Conn, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	c := &LVMConn{sudoer: d.sudoer, vg: d.vg, fstype: d.fstype, name: volumeName, root: rootDir}
+	if exists, err := lvExists(d.sudoer, d.vg, volumeName); err != nil {
+		return nil, err
+	} else if !exists {
+		pool := d.vg + "/" + d.pool
+		if _, err := runcmd(d.sudoer, "lvcreate", "-T", pool, "-V", d.size, "-n", volumeName); err != nil {
+			glog.Errorf("Could not create thin volume %s in pool %s", volumeName, pool)
+			return nil, fmt.Errorf("could not create thin volume: %s (%v)", volumeName, err)
+		}
+		if _, err := runcmd(d.sudoer, "mkfs", "-t", d.fstype, c.device(volumeName)); err != nil {
+			glog.Errorf("Could not create %s filesystem on %s", d.fstype, c.device(volumeName))
+			return nil, fmt.Errorf("could not create filesystem: %s (%v)", volumeName, err)
+		}
+	}
+
+	if err := c.mount(volumeName, c.Path()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// List returns a list of thin volumes in the volume group
+func (d *LVMDriver) List(rootDir string) (result []string) {
+	if raw, err := runcmd(d.sudoer, "lvs", "--noheadings", "-o", "lv_name,lv_attr", d.vg); err != nil {
+		glog.Errorf("Could not list logical volumes in %s", d.vg)
+	} else {
+		for _, row := range strings.Split(string(raw), "\n") {
+			// thin volumes have an attribute string that starts with 'V'
+			if fields := strings.Fields(row); len(fields) == 2 && strings.HasPrefix(fields[1], "V") {
+				result = append(result, fields[0])
+			}
+		}
+	}
+
+	return
+}
+
+// Name provides the name of the volume
+func (c *LVMConn) Name() string {
+	return c.name
+}
+
+// Path provides the full path to the mounted volume
+func (c *LVMConn) Path() string {
+	return path.Join(c.root, c.name)
+}
+
+// SnapshotPath provides the full path to the mounted snapshot
+func (c *LVMConn) SnapshotPath(label string) string {
+	return path.Join(c.root, label)
+}
+
+// Snapshot creates a thin snapshot of the volume and mounts it
+func (c *LVMConn) Snapshot(label string) error {
+	c.Lock()
+	defer c.Unlock()
+	if _, err := runcmd(c.sudoer, "lvcreate", "-s", "-kn", "-n", label, c.vg+"/"+c.name); err != nil {
+		glog.Errorf("Could not snapshot %s: %s", c.name, err)
+		return err
+	}
+	return c.mount(label, c.SnapshotPath(label))
+}
+
+// Snapshots returns the current snapshots on the volume
+func (c *LVMConn) Snapshots() ([]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.snapshots()
+}
+
+// RemoveSnapshot unmounts and removes the snapshot with the given label
+func (c *LVMConn) RemoveSnapshot(label string) error {
+	c.Lock()
+	defer c.Unlock()
+	if exists, err := c.snapshotExists(label); err != nil {
+		return err
+	} else if !exists {
+		// snapshots restored from a backup are plain directories
+		if dirp, err := volume.IsDir(c.SnapshotPath(label)); err != nil || !dirp {
+			return fmt.Errorf("snapshot %s does not exist", label)
+		}
+		return os.RemoveAll(c.SnapshotPath(label))
+	}
+	return c.remove(label, c.SnapshotPath(label))
+}
+
+// Unmount removes the snapshots and the thin volume
+func (c *LVMConn) Unmount() error {
+	c.Lock()
+	defer c.Unlock()
+	snapshots, err := c.snapshots()
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range snapshots {
+		if err := c.remove(snapshot, c.SnapshotPath(snapshot)); err != nil {
+			return err
+		}
+	}
+
+	return c.remove(c.name, c.Path())
+}
+
+// Rollback replaces the volume with a thin snapshot of the given snapshot
+func (c *LVMConn) Rollback(label string) error {
+	c.Lock()
+	defer c.Unlock()
+	if exists, err := c.snapshotExists(label); err != nil {
+		return err
+	} else if !exists {
+		// snapshots restored from a backup are plain directories, so copy
+		// the data into the volume instead
+		if dirp, err := volume.IsDir(c.SnapshotPath(label)); err != nil || !dirp {
+			return fmt.Errorf("snapshot %s does not exist", label)
+		}
+		_, err := runcmd(c.sudoer, "rsync", "-a", "--del", "--force", c.SnapshotPath(label)+"/", c.Path()+"/")
+		return err
+	}
+
+	if err := c.remove(c.name, c.Path()); err != nil {
+		return err
+	}
+	if _, err := runcmd(c.sudoer, "lvcreate", "-s", "-kn", "-n", c.name, c.vg+"/"+label); err != nil {
+		glog.Errorf("Could not create %s from snapshot %s: %s", c.name, label, err)
+		return err
+	}
+	return c.mount(c.name, c.Path())
+}
+
+// Usage returns the number of bytes used on the volume's filesystem
+func (c *LVMConn) Usage() (uint64, error) {
+	c.Lock()
+	defer c.Unlock()
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(c.Path(), &stat); err != nil {
+		return 0, fmt.Errorf("could not stat %s: %s", c.Path(), err)
+	}
+	return (stat.Blocks - stat.Bfree) * uint64(stat.Bsize), nil
+}
+
+// snapshots lists the logical volumes that are snapshots of this volume
+func (c *LVMConn) snapshots() ([]string, error) {
+	labels := make([]string, 0)
+	output, err := runcmd(c.sudoer, "lvs", "--noheadings", "-o", "lv_name", c.vg)
+	if err != nil {
+		glog.Errorf("got an error with lvs: %s", string(output))
+		return labels, err
+	}
+	prefixedName := c.name + "_"
+	for _, line := range strings.Split(string(output), "\n") {
+		if label := strings.TrimSpace(line); strings.HasPrefix(label, prefixedName) {
+			labels = append(labels, label)
+		}
+	}
+	return labels, nil
+}
+
+// snapshotExists queries the snapshot existence for the given label
+func (c *LVMConn) snapshotExists(label string) (bool, error) {
+	snapshots, err := c.snapshots()
+	if err != nil {
+		return false, fmt.Errorf("could not get current snapshot list: %v", err)
+	}
+	for _, snapLabel := range snapshots {
+		if label == snapLabel {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// device returns the path to the device of the logical volume
+func (c *LVMConn) device(lv string) string {
+	return path.Join("/dev", c.vg, lv)
+}
+
+// mount activates the logical volume and mounts it at the given dir
+func (c *LVMConn) mount(lv, dir string) error {
+	if mounted, err := isMounted(dir); err != nil {
+		return err
+	} else if mounted {
+		return nil
+	}
+
+	if _, err := runcmd(c.sudoer, "lvchange", "-ay", "-K", c.vg+"/"+lv); err != nil {
+		glog.Errorf("Could not activate %s: %s", lv, err)
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		glog.Errorf("Could not create mount point %s: %s", dir, err)
+		return err
+	}
+
+	args := []string{"-t", c.fstype}
+	if c.fstype == "xfs" {
+		// snapshots share the filesystem uuid of their origin
+		args = append(args, "-o", "nouuid")
+	}
+	if _, err := runcmd(c.sudoer, "mount", append(args, c.device(lv), dir)...); err != nil {
+		glog.Errorf("Could not mount %s at %s: %s", lv, dir, err)
+		return fmt.Errorf("could not mount %s: %v", lv, err)
+	}
+	return nil
+}
+
+// remove unmounts and deletes the logical volume mounted at the given dir
+func (c *LVMConn) remove(lv, dir string) error {
+	if mounted, err := isMounted(dir); err != nil {
+		return err
+	} else if mounted {
+		if _, err := runcmd(c.sudoer, "umount", dir); err != nil {
+			glog.Errorf("Could not unmount %s: %s", dir, err)
+			return err
+		}
+	}
+
+	if _, err := runcmd(c.sudoer, "lvremove", "-f", c.vg+"/"+lv); err != nil {
+		glog.Errorf("Could not remove %s: %s", lv, err)
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// lvExists checks if the logical volume exists in the volume group
+func lvExists(sudoer bool, vg, lv string) (bool, error) {
+	output, err := runcmd(sudoer, "lvs", "--noheadings", "-o", "lv_name", vg)
+	if err != nil {
+		return false, fmt.Errorf("could not list logical volumes in %s: %s (%v)", vg, string(output), err)
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == lv {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isMounted checks /proc/mounts for the given mount point
+func isMounted(dir string) (bool, error) {
+	file, err := os.Open("/proc/mounts")
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	dir = path.Clean(dir)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 1 && fields[1] == dir {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+func getenv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// runcmd runs the command as root
+func runcmd(sudoer bool, name string, args ...string) ([]byte, error) {
+	cmd := append([]string{name}, args...)
+	if sudoer {
+		cmd = append([]string{"sudo", "-n"}, cmd...)
+	}
+	glog.V(4).Infof("Executing: %v", cmd)
+	return exec.Command(cmd[0], cmd[1:]...).CombinedOutput()
+}
@@ -0,0 +1,127 @@
+// Copyright 2014 The Serviced Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lvm
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/control-center/serviced/volume/drivertest"
+)
+
+var lvmTestVolumePath = "/var/lib/serviced"
+
+const lvmTestVolumePathEnv = "SERVICED_LVM_TEST_VOLUME_PATH"
+
+func init() {
+	testVolumePathEnv := os.Getenv(lvmTestVolumePathEnv)
+	if len(testVolumePathEnv) > 0 {
+		lvmTestVolumePath = testVolumePathEnv
+	}
+}
+
+// testDriver returns a driver for the configured thin pool, or skips the
+// test if lvm is not available
+func testDriver(t *testing.T) *LVMDriver {
+	if user, err := user.Current(); err != nil {
+		panic(err)
+	} else if user.Uid != "0" {
+		t.Skip("Skipping LVM tests because we are not running as root")
+	}
+
+	if _, err := exec.LookPath("lvcreate"); err != nil {
+		t.Skip("Skipping LVM tests because lvm2 was not found in the path")
+	}
+
+	lvmd, err := New()
+	if err != nil {
+		t.Fatalf("Unable to create lvm driver: %v", err)
+	}
+
+	if _, err := runcmd(false, "lvs", lvmd.vg+"/"+lvmd.pool); err != nil {
+		t.Skipf("Skipping LVM tests because thin pool %s/%s was not found, use env '%s' and '%s' to override.",
+			lvmd.vg, lvmd.pool, VolumeGroupEnv, ThinPoolEnv)
+	}
+	return lvmd
+}
+
+func TestLVMVolume(t *testing.T) {
+	lvmd := testDriver(t)
+
+	t.Logf("Using '%s' as lvm test volume path, use env '%s' to override.",
+		lvmTestVolumePath, lvmTestVolumePathEnv)
+
+	c, err := lvmd.Mount("unittest", lvmTestVolumePath)
+	if err != nil {
+		t.Fatalf("Could not create volume object :%s", err)
+	}
+	defer c.Unmount()
+
+	testFile := path.Join(c.Path(), "test.txt")
+	testData := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	testData2 := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	if err := ioutil.WriteFile(testFile, testData, 0664); err != nil {
+		t.Fatalf("Could not write out test file: %s", err)
+	}
+
+	label := "unittest_foo"
+	if err := c.Snapshot(label); err != nil {
+		t.Fatalf("Could not snapshot: %s", err)
+	}
+
+	if err := ioutil.WriteFile(testFile, testData2, 0664); err != nil {
+		t.Errorf("Could not write out test file 2: %s", err)
+	}
+
+	if snapshots, err := c.Snapshots(); err != nil {
+		t.Fatalf("Could not list snapshots: %s", err)
+	} else if !reflect.DeepEqual(snapshots, []string{label}) {
+		t.Errorf("Expected %v, got %v", []string{label}, snapshots)
+	}
+
+	// the snapshot must not see writes made after it was taken
+	if output, err := ioutil.ReadFile(path.Join(c.SnapshotPath(label), "test.txt")); err != nil {
+		t.Fatalf("Could not read snapshot test file: %s", err)
+	} else if !reflect.DeepEqual(output, testData) {
+		t.Errorf("Expected %v, got %v", testData, output)
+	}
+
+	t.Logf("About to rollback %s", label)
+	if err := c.Rollback(label); err != nil {
+		t.Fatalf("Could not roll back: %s", err)
+	}
+
+	if output, err := ioutil.ReadFile(testFile); err != nil {
+		t.Fatalf("Could not read back test file: %s", err)
+	} else if !reflect.DeepEqual(output, testData) {
+		t.Logf("testdata: %v", testData)
+		t.Logf("readdata: %v", output)
+		t.FailNow()
+	}
+
+	t.Logf("About to remove snapshot %s", label)
+	if err := c.RemoveSnapshot(label); err != nil {
+		t.Fatalf("Could not remove %s: %s", label, err)
+	}
+}
+
+func TestLVMConformance(t *testing.T) {
+	drivertest.RunDriverTests(t, testDriver(t), lvmTestVolumePath)
+}
@@ -230,3 +230,10 @@ func (c *RsyncConn) Rollback(label string) (err error) {
 	}
 	return nil
 }
+
+// Usage returns the number of bytes used by the volume
+func (c *RsyncConn) Usage() (uint64, error) {
+	c.Lock()
+	defer c.Unlock()
+	return volume.DiskUsage(c.Path())
+}
@@ -14,6 +14,7 @@
 package rsync
 
 import (
+	"github.com/control-center/serviced/volume/drivertest"
 	"github.com/zenoss/glog"
 
 	"io/ioutil"
@@ -56,7 +57,7 @@ func TestRsyncVolume(t *testing.T) {
 
 	rsyncd, err := New()
 	if err != nil {
-		t.Fatal("Unable to create rsync driver: %v", err)
+		t.Fatalf("Unable to create rsync driver: %v", err)
 	}
 
 	if c, err := rsyncd.Mount("unittest", rsyncTestVolumePath); err != nil {
@@ -114,3 +115,19 @@ func TestRsyncVolume(t *testing.T) {
 
 	}
 }
+
+func TestRsyncConformance(t *testing.T) {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		t.Skip("Skipping rsync volume test, rsync not found in path")
+	}
+
+	if err := os.MkdirAll(rsyncTestVolumePath, 0775); err != nil {
+		t.Fatalf("Could not create test volume path: %s : %s", rsyncTestVolumePath, err)
+	}
+
+	rsyncd, err := New()
+	if err != nil {
+		t.Fatalf("Unable to create rsync driver: %v", err)
+	}
+	drivertest.RunDriverTests(t, rsyncd, rsyncTestVolumePath)
+}
@@ -16,6 +16,9 @@ package volume
 import (
 	"fmt"
 	"os"
+	"os/exec"
+	"strconv"
+	"strings"
 )
 
 // IsDir() checks if the given dir is a directory. If any error is encoutered
@@ -33,3 +36,17 @@ func IsDir(dirName string) (dir bool, err error) {
 	}
 	return true, nil
 }
+
+// DiskUsage returns the number of bytes used by the given dir, as reported by
+// du.
+func DiskUsage(dirName string) (uint64, error) {
+	output, err := exec.Command("du", "-s", "-B1", dirName).Output()
+	if err != nil {
+		return 0, fmt.Errorf("could not get disk usage of %s: %s", dirName, err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("could not get disk usage of %s: no output", dirName)
+	}
+	return strconv.ParseUint(fields[0], 10, 64)
+}
@@ -37,6 +37,13 @@ type Conn interface {
 	RemoveSnapshot(label string) error
 	Rollback(label string) error
 	Unmount() error
+	Usage() (uint64, error)
+}
+
+// Limiter is implemented by connections that can enforce a hard limit on the
+// size of the volume
+type Limiter interface {
+	SetLimit(bytes uint64) error
 }
 
 type Volume struct {
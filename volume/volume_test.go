@@ -23,6 +23,10 @@ func (d TestDriver) Mount(volumeName, root string) (Conn, error) {
 	return TestConn{volumeName, root}, nil
 }
 
+func (d TestDriver) List(root string) []string {
+	return []string{}
+}
+
 type TestConn struct {
 	name string
 	root string
@@ -36,6 +40,10 @@ func (c TestConn) Path() string {
 	return c.root
 }
 
+func (c TestConn) SnapshotPath(label string) string {
+	return c.root
+}
+
 func (c TestConn) Snapshot(label string) error {
 	return nil
 }
@@ -52,6 +60,14 @@ func (c TestConn) Rollback(label string) error {
 	return nil
 }
 
+func (c TestConn) Unmount() error {
+	return nil
+}
+
+func (c TestConn) Usage() (uint64, error) {
+	return 0, nil
+}
+
 func TestNilRegistration(t *testing.T) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -577,6 +577,7 @@ func restUpdateService(w *rest.ResponseWriter, r *rest.Request, client *node.Con
 		restBadRequest(w, err)
 		return
 	}
+	payload.UpdatedBy = sessionUser(r)
 	err = client.UpdateService(payload, &unused)
 	if err != nil {
 		glog.Errorf("Unable to update service %s: %v", serviceID, err)
@@ -85,6 +85,21 @@ func loginOK(r *rest.Request) bool {
 	return true
 }
 
+/*
+ * Returns the name of the user logged in to the session of a request
+ */
+func sessionUser(r *rest.Request) string {
+	cookie, err := r.Request.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+	session, err := findsessionT(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return session.User
+}
+
 /*
  * Perform logout, return JSON
  */